@@ -0,0 +1,239 @@
+// Package audit records a row-level audit trail of the writes issued through GORM: the before and after
+// images of every inserted, updated or deleted row are written to an audit table by the same statement
+// pipeline, so inside a unit of work the trail is committed or rolled back together with the change.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	"time"
+)
+
+const (
+	// DefaultTable is the audit table used when Config.Table is empty.
+	DefaultTable = "audit_records"
+
+	// Operations recorded in Record.Operation.
+	OperationInsert = "INSERT"
+	OperationUpdate = "UPDATE"
+	OperationDelete = "DELETE"
+
+	// beforeImageKey holds the row image loaded before an update or delete.
+	beforeImageKey = "audit:before"
+)
+
+type (
+	// Config selects what is audited.
+	Config struct {
+		Table          string        // Table is the audit table; DefaultTable if empty.
+		Include        []string      // Include limits the audit to these tables; empty audits all tables.
+		Exclude        []string      // Exclude lists tables that are never audited.
+		ExcludeColumns []string      // ExcludeColumns lists columns left out of the images, as "column" or "table.column".
+		Actor          uow.ActorFunc // Actor extracts the acting user from the bound context; uow.ActorFromContext if nil.
+	}
+
+	// Record is a row of the audit table. The table can be created with:
+	//
+	//	CREATE TABLE audit_records (
+	//	  id         bigserial PRIMARY KEY,
+	//	  table_name text        NOT NULL,
+	//	  operation  text        NOT NULL,
+	//	  row_id     text        NOT NULL,
+	//	  before     jsonb,
+	//	  after      jsonb,
+	//	  actor      text,
+	//	  created_at timestamptz NOT NULL
+	//	);
+	Record struct {
+		ID        uint64    `gorm:"primary_key"`       // ID identifies the record.
+		Table     string    `gorm:"column:table_name"` // Table is the table of the modified row.
+		Operation string    // Operation is OperationInsert, OperationUpdate or OperationDelete.
+		RowID     string    // RowID is the primary key of the modified row.
+		Before    *string   // Before is the JSON image of the row before the change; nil for inserts.
+		After     *string   // After is the JSON image of the row after the change; nil for deletes.
+		Actor     *string   // Actor is the user the change is attributed to, if known.
+		CreatedAt time.Time // CreatedAt is the time the change was recorded.
+	}
+
+	// auditor implements the callbacks for a Config.
+	auditor struct {
+		config         Config
+		include        map[string]bool
+		exclude        map[string]bool
+		excludeColumns map[string]bool
+	}
+)
+
+// Register registers GORM callbacks on db recording the audit trail configured by config.
+// Rows are identified by their primary key: statements without one, such as bulk updates with
+// conditions only, are not audited.
+// Example:
+//
+//	audit.Register(db, audit.Config{
+//	  Exclude:        []string{"sessions"},
+//	  ExcludeColumns: []string{"users.password_hash"},
+//	})
+func Register(db *gorm.DB, config Config) {
+	if config.Table == "" {
+		config.Table = DefaultTable
+	}
+	if config.Actor == nil {
+		config.Actor = uow.ActorFromContext
+	}
+	a := &auditor{
+		config:         config,
+		include:        toSet(config.Include),
+		exclude:        toSet(config.Exclude),
+		excludeColumns: toSet(config.ExcludeColumns),
+	}
+
+	callbacks := db.Callback()
+	callbacks.Create().After("gorm:create").Register("audit:after_create", a.afterCreate)
+	callbacks.Update().Before("gorm:update").Register("audit:before_update", a.loadBefore)
+	callbacks.Update().After("gorm:update").Register("audit:after_update", a.afterUpdate)
+	callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", a.loadBefore)
+	callbacks.Delete().After("gorm:delete").Register("audit:after_delete", a.afterDelete)
+}
+
+// audited reports whether the row of scope is audited.
+func (a *auditor) audited(scope *gorm.Scope) bool {
+	if scope.HasError() || scope.PrimaryKeyZero() {
+		return false
+	}
+	table := scope.TableName()
+	if table == a.config.Table || a.exclude[table] {
+		return false
+	}
+	return len(a.include) == 0 || a.include[table]
+}
+
+// afterCreate records the image of an inserted row.
+func (a *auditor) afterCreate(scope *gorm.Scope) {
+	if !a.audited(scope) {
+		return
+	}
+	after, ok := a.load(scope)
+	if !ok {
+		return
+	}
+	a.write(scope, OperationInsert, nil, after)
+}
+
+// loadBefore keeps the image of the row before an update or delete.
+func (a *auditor) loadBefore(scope *gorm.Scope) {
+	if !a.audited(scope) {
+		return
+	}
+	if before, ok := a.load(scope); ok {
+		scope.InstanceSet(beforeImageKey, before)
+	}
+}
+
+// afterUpdate records the images of an updated row.
+func (a *auditor) afterUpdate(scope *gorm.Scope) {
+	before, ok := scope.InstanceGet(beforeImageKey)
+	if !ok || !a.audited(scope) {
+		return
+	}
+	after, ok := a.load(scope)
+	if !ok {
+		return
+	}
+	a.write(scope, OperationUpdate, before.(map[string]interface{}), after)
+}
+
+// afterDelete records the image of a deleted row.
+func (a *auditor) afterDelete(scope *gorm.Scope) {
+	before, ok := scope.InstanceGet(beforeImageKey)
+	if !ok || !a.audited(scope) {
+		return
+	}
+	a.write(scope, OperationDelete, before.(map[string]interface{}), nil)
+}
+
+// load reads the current image of the row of scope by its primary key, without the excluded columns.
+func (a *auditor) load(scope *gorm.Scope) (map[string]interface{}, bool) {
+	table := scope.TableName()
+	rows, err := scope.NewDB().Unscoped().Table(table).
+		Where(fmt.Sprintf("%v = ?", scope.Quote(scope.PrimaryKey())), scope.PrimaryKeyValue()).Rows()
+	if scope.Err(err) != nil {
+		return nil, false
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, false
+	}
+
+	columns, err := rows.Columns()
+	if scope.Err(err) != nil {
+		return nil, false
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if scope.Err(rows.Scan(pointers...)) != nil {
+		return nil, false
+	}
+
+	image := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if a.excludeColumns[column] || a.excludeColumns[table+"."+column] {
+			continue
+		}
+		if bytes, ok := values[i].([]byte); ok {
+			values[i] = string(bytes)
+		}
+		image[column] = values[i]
+	}
+	return image, true
+}
+
+// write inserts the audit record of a change in the transaction of scope.
+func (a *auditor) write(scope *gorm.Scope, operation string, before, after map[string]interface{}) {
+	record := Record{
+		Table:     scope.TableName(),
+		Operation: operation,
+		RowID:     fmt.Sprint(scope.PrimaryKeyValue()),
+		CreatedAt: gorm.NowFunc(),
+	}
+	var err error
+	if record.Before, err = marshal(before); scope.Err(err) != nil {
+		return
+	}
+	if record.After, err = marshal(after); scope.Err(err) != nil {
+		return
+	}
+	if ctx, ok := uow.ContextFromScope(scope); ok {
+		if actor, ok := a.config.Actor(ctx); ok {
+			name := fmt.Sprint(actor)
+			record.Actor = &name
+		}
+	}
+	_ = scope.Err(scope.NewDB().Table(a.config.Table).Create(&record).Error)
+}
+
+// marshal encodes an image as JSON; a nil image is encoded as nil.
+func marshal(image map[string]interface{}) (*string, error) {
+	if image == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(image)
+	if err != nil {
+		return nil, err
+	}
+	text := string(encoded)
+	return &text, nil
+}
+
+// toSet converts a list of names to a set.
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
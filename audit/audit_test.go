@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type account struct {
+	ID           int
+	Balance      int
+	PasswordHash string
+}
+
+// Test that updates are recorded with their before and after images in the same transaction
+func TestRegister(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	Register(db, Config{ExcludeColumns: []string{"accounts.password_hash"}})
+	ctx := uow.ContextWithActor(context.Background(), "ann")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "accounts"  WHERE ("id" = $1)`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "password_hash"}).AddRow(7, 10, "secret"))
+	mock.ExpectExec(`UPDATE "accounts" SET "balance" = $1, "password_hash" = $2  WHERE "accounts"."id" = $3`).
+		WithArgs(20, "secret", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT * FROM "accounts"  WHERE ("id" = $1)`).WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "balance", "password_hash"}).AddRow(7, 20, "secret"))
+	mock.ExpectQuery(`INSERT INTO "audit_records" ("table_name","operation","row_id","before","after","actor","created_at") VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING "audit_records"."id"`).
+		WithArgs("accounts", OperationUpdate, "7", `{"balance":10,"id":7}`, `{"balance":20,"id":7}`, "ann", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, uow.WithContext(db, ctx).Save(&account{ID: 7, Balance: 20, PasswordHash: "secret"}).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,268 @@
+// Package fixtures loads YAML or JSON fixture files into tables, for integration test setup and local seeding.
+// A file maps table names to lists of rows, each row mapping column names to values:
+//
+//	customers:
+//	  - id: 1
+//	    name: Ann
+//	orders:
+//	  - id: 10
+//	    customer_id: 1
+//	    created_at: {{ now }}
+//	    items: [{"sku": "tea", "quantity": 2}]   # maps and lists are stored as JSON
+//
+// Files are text/template templates rendered with Config.Data before they are parsed. All rows are inserted
+// in a single transaction, referenced tables first, and the sequences of the loaded columns are moved past
+// the loaded values, so rows inserted afterwards get fresh keys.
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// DefaultSchema is the schema of the tables when Config.Schema is empty.
+	DefaultSchema = "public"
+
+	// savepoint is set when the fixtures are loaded inside a running transaction with Config.Rollback.
+	savepoint = "uow_fixtures"
+)
+
+type (
+	// Config holds the settings of a Loader. Zero values fall back to defaults.
+	Config struct {
+		Schema   string           // Schema is the schema of the tables; DefaultSchema if empty.
+		Data     interface{}      // Data is the data the templates of the files are rendered with.
+		Funcs    template.FuncMap // Funcs are added to the template functions, next to now.
+		Rollback bool             // Rollback rolls the rows back once loaded, e.g. to validate fixture files.
+	}
+
+	// Loader loads fixture files into the database of db.
+	Loader struct {
+		db     *gorm.DB
+		config Config
+	}
+
+	// file is the content of a fixture file.
+	file struct {
+		name    string // Name the format is derived from.
+		content []byte // Template of the file.
+	}
+
+	// row maps the columns of a row to their values.
+	row = map[string]interface{}
+)
+
+// New creates a Loader for db. If db is a running transaction, e.g. txContext.Provider() inside a unit of
+// work, the fixtures are loaded in it; otherwise Load begins a transaction of its own.
+// Example:
+//
+//	loader := fixtures.New(db, fixtures.Config{Data: map[string]interface{}{"Tenant": 7}})
+//	if err := loader.Load(ctx, "testdata/customers.yml", "testdata/orders.json"); err != nil { t.Fatal(err) }
+func New(db *gorm.DB, config Config) *Loader {
+	if config.Schema == "" {
+		config.Schema = DefaultSchema
+	}
+	return &Loader{db: db, config: config}
+}
+
+// Load loads the fixture files at paths, with a glob pattern expanding to the matching files.
+func (l *Loader) Load(ctx context.Context, paths ...string) error {
+	return l.LoadFS(ctx, os.DirFS("."), paths...)
+}
+
+// LoadFS is Load for the files of fsys, e.g. an embed.FS; absolute paths are read from the file system.
+func (l *Loader) LoadFS(ctx context.Context, fsys fs.FS, paths ...string) error {
+	var files []file
+	for _, path := range paths {
+		names := []string{path}
+		if strings.ContainsAny(path, "*?[") {
+			var err error
+			if names, err = fs.Glob(fsys, path); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			var content []byte
+			var err error
+			if filepath.IsAbs(name) {
+				content, err = os.ReadFile(name)
+			} else {
+				content, err = fs.ReadFile(fsys, name)
+			}
+			if err != nil {
+				return err
+			}
+			files = append(files, file{name: name, content: content})
+		}
+	}
+	return l.load(ctx, files)
+}
+
+// load parses files and inserts their rows in one transaction.
+func (l *Loader) load(ctx context.Context, files []file) error {
+	tables := map[string][]row{}
+	var names []string
+	for _, f := range files {
+		parsed, err := l.parse(f)
+		if err != nil {
+			return fmt.Errorf("fixtures: %s: %w", f.name, err)
+		}
+		for table, rows := range parsed {
+			if _, ok := tables[table]; !ok {
+				names = append(names, table)
+			}
+			tables[table] = append(tables[table], rows...)
+		}
+	}
+	sort.Strings(names)
+
+	return l.transaction(ctx, func(tx *gorm.DB) error {
+		ordered, err := postgres.SortTablesByForeignKeys(tx, l.config.Schema, names)
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("SET CONSTRAINTS ALL DEFERRED").Error; err != nil {
+			return err
+		}
+		for _, table := range ordered {
+			if err := l.insert(tx, table, tables[table]); err != nil {
+				return fmt.Errorf("fixtures: %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// transaction runs fn in a transaction, or in the running transaction of the loader's db, and rolls it
+// back instead of committing with Config.Rollback.
+func (l *Loader) transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	if _, running := l.db.CommonDB().(*sql.Tx); running {
+		if !l.config.Rollback {
+			return fn(l.db)
+		}
+		if err := l.db.Exec("SAVEPOINT " + savepoint).Error; err != nil {
+			return err
+		}
+		err := fn(l.db)
+		if rollbackErr := l.db.Exec("ROLLBACK TO SAVEPOINT " + savepoint).Error; err == nil {
+			err = rollbackErr
+		}
+		return err
+	}
+
+	tx := l.db.BeginTx(ctx, nil)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if err := fn(tx); err != nil || l.config.Rollback {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// parse renders the template of f and decodes the tables of the result.
+func (l *Loader) parse(f file) (map[string][]row, error) {
+	funcs := template.FuncMap{"now": func() string { return time.Now().UTC().Format(time.RFC3339Nano) }}
+	for name, fn := range l.config.Funcs {
+		funcs[name] = fn
+	}
+	tmpl, err := template.New(f.name).Funcs(funcs).Option("missingkey=error").Parse(string(f.content))
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, l.config.Data); err != nil {
+		return nil, err
+	}
+
+	var tables map[string][]row
+	switch strings.ToLower(filepath.Ext(f.name)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(rendered.Bytes(), &tables)
+	case ".json":
+		decoder := json.NewDecoder(&rendered)
+		decoder.UseNumber()
+		err = decoder.Decode(&tables)
+	default:
+		err = fmt.Errorf("unsupported format %q, expected .yml, .yaml or .json", filepath.Ext(f.name))
+	}
+	return tables, err
+}
+
+// insert inserts rows into table and moves the sequences of the inserted columns past their values.
+func (l *Loader) insert(tx *gorm.DB, table string, rows []row) error {
+	qualified := postgres.QuoteIdentifier(l.config.Schema) + "." + postgres.QuoteIdentifier(table)
+	inserted := map[string]bool{}
+	for _, r := range rows {
+		columns := make([]string, 0, len(r))
+		for column := range r {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		quoted := make([]string, len(columns))
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			quoted[i] = postgres.QuoteIdentifier(column)
+			value, err := columnValue(r[column])
+			if err != nil {
+				return fmt.Errorf("column %s: %w", column, err)
+			}
+			values[i] = value
+			inserted[column] = true
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+		statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", qualified, strings.Join(quoted, ", "), placeholders)
+		if err := tx.Exec(statement, values...).Error; err != nil {
+			return err
+		}
+	}
+
+	columns := make([]string, 0, len(inserted))
+	for column := range inserted {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		var sequence sql.NullString
+		if err := tx.Raw("SELECT pg_get_serial_sequence(?, ?)", qualified, column).Row().Scan(&sequence); err != nil {
+			return err
+		}
+		if !sequence.Valid {
+			continue
+		}
+		quotedColumn := postgres.QuoteIdentifier(column)
+		err := tx.Exec(fmt.Sprintf("SELECT setval(?, (SELECT max(%s) FROM %s))", quotedColumn, qualified), sequence.String).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columnValue converts a decoded fixture value into a statement argument: maps and lists become JSON.
+func columnValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	case json.Number:
+		return value.(json.Number).String(), nil
+	}
+	return value, nil
+}
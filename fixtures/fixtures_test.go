@@ -0,0 +1,92 @@
+package fixtures
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+// testFiles are fixture files of two tables, orders referencing customers.
+var testFiles = fstest.MapFS{
+	"orders.json":    {Data: []byte(`{"orders": [{"id": 10, "customer_id": 1, "note": "{{ .Note }}", "items": [{"sku": "tea"}]}]}`)},
+	"customers.yaml": {Data: []byte("customers:\n  - id: 1\n    name: Ann\n")},
+	"notes.txt":      {Data: []byte("not a fixture")},
+}
+
+// getTestLoader returns a Loader over sqlmock.
+func getTestLoader(t *testing.T, config Config) (*Loader, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return New(db, config), mock
+}
+
+// expectSequence expects the lookup of the sequence of column, returning sequence or NULL if empty.
+func expectSequence(mock sqlmock.Sqlmock, table, column, sequence string) {
+	rows := sqlmock.NewRows([]string{"pg_get_serial_sequence"}).AddRow(nil)
+	if sequence != "" {
+		rows = sqlmock.NewRows([]string{"pg_get_serial_sequence"}).AddRow(sequence)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_get_serial_sequence($1, $2)")).WithArgs(table, column).WillReturnRows(rows)
+	if sequence != "" {
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT setval($1, (SELECT max("` + column + `") FROM ` + table + `))`)).
+			WithArgs(sequence).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+}
+
+// Test LoadFS to verify rendered templates are inserted referenced tables first in one transaction
+func TestLoader_LoadFS(t *testing.T) {
+	loader, mock := getTestLoader(t, Config{Data: map[string]string{"Note": "rush"}})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_constraint`).WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"child", "parent"}).AddRow("orders", "customers"))
+	mock.ExpectExec("SET CONSTRAINTS ALL DEFERRED").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "public"."customers" ("id", "name") VALUES ($1, $2)`)).
+		WithArgs(1, "Ann").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSequence(mock, `"public"."customers"`, "id", "public.customers_id_seq")
+	expectSequence(mock, `"public"."customers"`, "name", "")
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "public"."orders" ("customer_id", "id", "items", "note") VALUES ($1, $2, $3, $4)`)).
+		WithArgs("1", "10", `[{"sku":"tea"}]`, "rush").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSequence(mock, `"public"."orders"`, "customer_id", "")
+	expectSequence(mock, `"public"."orders"`, "id", "public.orders_id_seq")
+	expectSequence(mock, `"public"."orders"`, "items", "")
+	expectSequence(mock, `"public"."orders"`, "note", "")
+	mock.ExpectCommit()
+
+	assert.NoError(t, loader.LoadFS(context.Background(), testFiles, "*.json", "customers.yaml"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Config.Rollback to verify the loaded rows are rolled back
+func TestLoader_Rollback(t *testing.T) {
+	loader, mock := getTestLoader(t, Config{Rollback: true})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FROM pg_constraint`).WithArgs("public").WillReturnRows(sqlmock.NewRows([]string{"child", "parent"}))
+	mock.ExpectExec("SET CONSTRAINTS ALL DEFERRED").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "public"."customers" ("id", "name") VALUES ($1, $2)`)).
+		WithArgs(1, "Ann").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSequence(mock, `"public"."customers"`, "id", "")
+	expectSequence(mock, `"public"."customers"`, "name", "")
+	mock.ExpectRollback()
+
+	assert.NoError(t, loader.LoadFS(context.Background(), testFiles, "customers.yaml"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test LoadFS to verify files of unknown formats and failing templates are rejected before any statement
+func TestLoader_InvalidFiles(t *testing.T) {
+	loader, mock := getTestLoader(t, Config{})
+
+	assert.ErrorContains(t, loader.LoadFS(context.Background(), testFiles, "notes.txt"), "unsupported format")
+	assert.ErrorContains(t, loader.LoadFS(context.Background(), testFiles, "orders.json"), "Note")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
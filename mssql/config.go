@@ -0,0 +1,16 @@
+// Package mssql provides the unit-of-work semantics of the postgres package on top of SQL Server and Azure SQL.
+package mssql
+
+// MSSQLConfig holds the configuration settings required to connect to a SQL Server database.
+type MSSQLConfig struct {
+	Host                    string            // Host is the database server address (e.g., "myserver.database.windows.net").
+	Port                    int               // Port is the server port; 1433 is used when zero.
+	DBName                  string            // DBName is the name of the specific database to connect to.
+	User                    string            // User is the username for authenticating to the database.
+	Password                string            // Password is the password for the specified User.
+	MaxOpenConnections      int               // MaxOpenConnections defines the maximum number of open connections allowed to the database.
+	ConnectionMaxLifetimeMS int               // ConnectionMaxLifetimeMS sets the maximum time (in milliseconds) a connection can be reused.
+	LogMode                 bool              // LogMode enables or disables SQL query logging (true for enabled).
+	Encrypt                 string            // Encrypt controls TLS ("true", "false" or "disable"); Azure SQL requires "true".
+	Params                  map[string]string // Params holds additional connection parameters (e.g., "app name": "billing").
+}
@@ -0,0 +1,107 @@
+package mssql
+
+import (
+	"database/sql"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultConnectionNumberOfRetries defines the maximum number of connection retries.
+	defaultConnectionNumberOfRetries = 8
+	// defaultConnectionSecondsBetweenRetries defines the delay in seconds between each retry.
+	defaultConnectionSecondsBetweenRetries = 4
+)
+
+// NewConnect establishes a new connection to the SQL Server database using the provided configuration.
+// It retries on failure and panics if connection attempts are exhausted.
+func NewConnect(config *MSSQLConfig) *gorm.DB {
+	logger := log.FromDefaultContext()
+	db, err := Open(config)
+	if err != nil {
+		logger.Infof("can't connect to db (connect error): %v", err)
+		panic(err)
+	}
+	return db
+}
+
+// CheckConnection executes a basic query to verify the database connection is still active.
+func CheckConnection(db *gorm.DB) {
+	db.Exec("SELECT 1;")
+}
+
+// Open attempts to open a database connection using the provided MSSQLConfig settings.
+// If the connection fails, it will retry based on default retry parameters.
+// On success, it applies SQL and GORM-specific configurations.
+func Open(cfg *MSSQLConfig) (db *gorm.DB, err error) {
+	logger := log.FromDefaultContext()
+	for retry := 0; retry < defaultConnectionNumberOfRetries; retry++ {
+		logger.Infof("Connecting to mssql %s@%s... (retry %d of %d)",
+			cfg.DBName, cfg.Host, retry, defaultConnectionNumberOfRetries)
+
+		db, err = gorm.Open("mssql", dsn(cfg))
+
+		// Log and retry on failure
+		if err != nil {
+			logger.Errorf("Connecting to mssql %s@%s FAILED: %s",
+				cfg.DBName, cfg.Host, err)
+
+			time.Sleep(defaultConnectionSecondsBetweenRetries * time.Second)
+			continue
+		}
+		db.SetLogger(logger)
+		// Log on successful connection
+		logger.Infof("Successfully connected to mssql %s@%s", cfg.DBName, cfg.Host)
+
+		// Apply database settings
+		setSQLSettings(db.DB(), cfg)
+		setGORMSettings(db, cfg)
+
+		return
+	}
+	logger.Fatalf("Connecting to mssql %s@%s FAILED", cfg.DBName, cfg.Host)
+	return
+}
+
+// defaultPort is the SQL Server port used when MSSQLConfig.Port is not set.
+const defaultPort = 1433
+
+// dsn builds the sqlserver:// connection URL, escaping credentials and parameters.
+func dsn(cfg *MSSQLConfig) string {
+	port := cfg.Port
+	if port == 0 {
+		port = defaultPort
+	}
+
+	query := url.Values{}
+	query.Set("database", cfg.DBName)
+	if cfg.Encrypt != "" {
+		query.Set("encrypt", cfg.Encrypt)
+	}
+	for key, value := range cfg.Params {
+		query.Set(key, value)
+	}
+
+	u := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// setGORMSettings configures GORM-specific settings, such as enabling or disabling log mode.
+func setGORMSettings(db *gorm.DB, config *MSSQLConfig) {
+	db.LogMode(config.LogMode)
+}
+
+// setSQLSettings applies SQL settings, including max open connections and connection lifetime.
+func setSQLSettings(db *sql.DB, config *MSSQLConfig) {
+	db.SetMaxOpenConns(config.MaxOpenConnections)
+	db.SetConnMaxLifetime(time.Duration(config.ConnectionMaxLifetimeMS) * time.Millisecond)
+}
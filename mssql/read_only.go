@@ -0,0 +1,57 @@
+package mssql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// readOnlyScopeKey marks the *gorm.DB of a read-only transaction for the write-rejecting callbacks.
+const readOnlyScopeKey = "uow:read_only"
+
+// ErrReadOnlyTransaction is returned by Create/Update/Delete issued inside a read-only transaction.
+var ErrReadOnlyTransaction = errors.New("write operation rejected: the transaction is read-only")
+
+// BeginReadOnly starts a read-only transaction and returns its unique identifier.
+// SQL Server has no read-only transactions and go-mssqldb refuses to begin one, so the transaction is begun
+// read-write and GORM rejects its Create, Update and Delete with ErrReadOnlyTransaction before they are
+// sent. Raw statements such as tx.Exec("DELETE ...") are not inspected.
+// Example:
+//
+//	id, err := txContext.BeginReadOnly()
+//	if err != nil { return err }
+//	defer txContext.Rollback()
+func (c *transactionContext) BeginReadOnly() (uuid.UUID, error) {
+	return c.BeginWithOptions(TxOptions{ReadOnly: true})
+}
+
+// registerReadOnlyCallbacks registers GORM callbacks on db that fail Create, Update and Delete with
+// ErrReadOnlyTransaction when they run inside a read-only transaction of a transaction context.
+func registerReadOnlyCallbacks(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:begin_transaction").Register("uow:reject_read_only_create", rejectReadOnlyWrites)
+	callbacks.Update().Before("gorm:begin_transaction").Register("uow:reject_read_only_update", rejectReadOnlyWrites)
+	callbacks.Delete().Before("gorm:begin_transaction").Register("uow:reject_read_only_delete", rejectReadOnlyWrites)
+}
+
+// rejectReadOnlyWrites aborts the operation if the scope belongs to a read-only transaction.
+func rejectReadOnlyWrites(scope *gorm.Scope) {
+	if readOnly, ok := scope.Get(readOnlyScopeKey); ok && readOnly == true {
+		_ = scope.Err(fmt.Errorf("%w: cannot write %s", ErrReadOnlyTransaction, scope.TableName()))
+		scope.SkipLeft()
+	}
+}
+
+// beginTx begins a transaction of db with the isolation level of opts. The access mode is emulated: a
+// read-only transaction is marked for the write-rejecting callbacks.
+func beginTx(ctx context.Context, db *gorm.DB, opts TxOptions) *gorm.DB {
+	sqlOpts := opts.SQLOptions()
+	sqlOpts.ReadOnly = false
+	tx := db.BeginTx(ctx, sqlOpts)
+	if tx.Error == nil && opts.ReadOnly {
+		tx = tx.Set(readOnlyScopeKey, true)
+	}
+	return tx
+}
@@ -0,0 +1,92 @@
+package mssql
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// invoice is the model written by the tests.
+type invoice struct {
+	ID    uint
+	Total int
+}
+
+// getTestTransactionContext builds a transaction context backed by go-sqlmock.
+func getTestTransactionContext(t *testing.T) (*transactionContext, *gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open("mssql", db)
+	assert.NoError(t, err)
+	return newTransactionContext(log.FromDefaultContext(), NewDBHolder(gormDB)), gormDB, mock
+}
+
+// Test BeginReadOnly to verify reads run while GORM writes are rejected before reaching the server
+func TestTransactionContext_BeginReadOnly(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM \[invoices\]`).WillReturnRows(sqlmock.NewRows([]string{"id", "total"}).AddRow(1, 10))
+	mock.ExpectCommit()
+
+	id, err := tx.BeginReadOnly()
+	assert.NoError(t, err)
+	_, err = tx.Begin()
+	assert.ErrorIs(t, err, ErrIncompatibleTxOptions)
+
+	var invoices []invoice
+	assert.NoError(t, tx.Provider().Find(&invoices).Error)
+	assert.Len(t, invoices, 1)
+	assert.ErrorIs(t, tx.Provider().Create(&invoice{Total: 20}).Error, ErrReadOnlyTransaction)
+	assert.ErrorIs(t, tx.Provider().Model(&invoices[0]).Update("total", 30).Error, ErrReadOnlyTransaction)
+	assert.ErrorIs(t, tx.Provider().Delete(&invoices[0]).Error, ErrReadOnlyTransaction)
+
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a read-write transaction after a read-only one writes again
+func TestTransactionContext_BeginAfterReadOnly(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO \[invoices\]`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	_, err := tx.BeginReadOnly()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, tx.Reset())
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Create(&invoice{Total: 20}).Error)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test SavePoint and RollbackTo to verify the SQL Server savepoint statements run in the transaction
+func TestTransactionContext_SavePoint(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVE TRANSACTION item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TRANSACTION item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, tx.SavePoint("item"), ErrNotInTransaction)
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.SavePoint("item"))
+	assert.NoError(t, tx.RollbackTo("item"))
+	assert.ErrorIs(t, tx.RollbackTo("item-1"), ErrInvalidSavePoint)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,14 @@
+// Package mysql provides the unit-of-work semantics of the postgres package on top of MySQL.
+package mysql
+
+// MySQLConfig holds the configuration settings required to connect to a MySQL database.
+type MySQLConfig struct {
+	Host                    string            // Host is the database server address including the port (e.g., "localhost:3306").
+	DBName                  string            // DBName is the name of the specific database to connect to.
+	User                    string            // User is the username for authenticating to the database.
+	Password                string            // Password is the password for the specified User.
+	MaxOpenConnections      int               // MaxOpenConnections defines the maximum number of open connections allowed to the database.
+	ConnectionMaxLifetimeMS int               // ConnectionMaxLifetimeMS sets the maximum time (in milliseconds) a connection can be reused.
+	LogMode                 bool              // LogMode enables or disables SQL query logging (true for enabled).
+	Params                  map[string]string // Params holds additional DSN parameters (e.g., "charset": "utf8mb4").
+}
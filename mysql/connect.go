@@ -0,0 +1,90 @@
+package mysql
+
+import (
+	"database/sql"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"time"
+)
+
+const (
+	// defaultConnectionNumberOfRetries defines the maximum number of connection retries.
+	defaultConnectionNumberOfRetries = 8
+	// defaultConnectionSecondsBetweenRetries defines the delay in seconds between each retry.
+	defaultConnectionSecondsBetweenRetries = 4
+)
+
+// NewConnect establishes a new connection to the MySQL database using the provided configuration.
+// It retries on failure and panics if connection attempts are exhausted.
+func NewConnect(config *MySQLConfig) *gorm.DB {
+	logger := log.FromDefaultContext()
+	db, err := Open(config)
+	if err != nil {
+		logger.Infof("can't connect to db (connect error): %v", err)
+		panic(err)
+	}
+	return db
+}
+
+// CheckConnection executes a basic query to verify the database connection is still active.
+func CheckConnection(db *gorm.DB) {
+	db.Exec("SELECT 1;")
+}
+
+// Open attempts to open a database connection using the provided MySQLConfig settings.
+// If the connection fails, it will retry based on default retry parameters.
+// On success, it applies SQL and GORM-specific configurations.
+func Open(cfg *MySQLConfig) (db *gorm.DB, err error) {
+	logger := log.FromDefaultContext()
+	for retry := 0; retry < defaultConnectionNumberOfRetries; retry++ {
+		logger.Infof("Connecting to mysql %s@%s... (retry %d of %d)",
+			cfg.DBName, cfg.Host, retry, defaultConnectionNumberOfRetries)
+
+		db, err = gorm.Open("mysql", dsn(cfg))
+
+		// Log and retry on failure
+		if err != nil {
+			logger.Errorf("Connecting to mysql %s@%s FAILED: %s",
+				cfg.DBName, cfg.Host, err)
+
+			time.Sleep(defaultConnectionSecondsBetweenRetries * time.Second)
+			continue
+		}
+		db.SetLogger(logger)
+		// Log on successful connection
+		logger.Infof("Successfully connected to mysql %s@%s", cfg.DBName, cfg.Host)
+
+		// Apply database settings
+		setSQLSettings(db.DB(), cfg)
+		setGORMSettings(db, cfg)
+
+		return
+	}
+	logger.Fatalf("Connecting to mysql %s@%s FAILED", cfg.DBName, cfg.Host)
+	return
+}
+
+// dsn builds the driver connection string; parseTime is always enabled so DATETIME columns scan into time.Time.
+func dsn(cfg *MySQLConfig) string {
+	driverConfig := driver.NewConfig()
+	driverConfig.Net = "tcp"
+	driverConfig.Addr = cfg.Host
+	driverConfig.User = cfg.User
+	driverConfig.Passwd = cfg.Password
+	driverConfig.DBName = cfg.DBName
+	driverConfig.ParseTime = true
+	driverConfig.Params = cfg.Params
+	return driverConfig.FormatDSN()
+}
+
+// setGORMSettings configures GORM-specific settings, such as enabling or disabling log mode.
+func setGORMSettings(db *gorm.DB, config *MySQLConfig) {
+	db.LogMode(config.LogMode)
+}
+
+// setSQLSettings applies SQL settings, including max open connections and connection lifetime.
+func setSQLSettings(db *sql.DB, config *MySQLConfig) {
+	db.SetMaxOpenConns(config.MaxOpenConnections)
+	db.SetConnMaxLifetime(time.Duration(config.ConnectionMaxLifetimeMS) * time.Millisecond)
+}
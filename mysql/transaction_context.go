@@ -0,0 +1,234 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	log "github.com/public-forge/go-logger"
+)
+
+type contextKey string
+
+// TransactionContextKey is used as the context key to store transaction contexts.
+const TransactionContextKey = contextKey("TransactionContextKey")
+
+// Important errors related to transaction handling, shared through the uow package.
+var (
+	ErrTxWasRollbacked  = uow.ErrTxWasRollbacked  // ErrTxWasRollbacked occurs when a rollback has already been performed.
+	ErrNotInTransaction = uow.ErrNotInTransaction // ErrNotInTransaction occurs when a transaction is expected but not started.
+
+	ErrIncompatibleTxOptions = uow.ErrIncompatibleTxOptions // ErrIncompatibleTxOptions occurs when a nested Begin asks for stricter options.
+	ErrNotTransactionOwner   = uow.ErrNotTransactionOwner   // ErrNotTransactionOwner occurs in strict-commit mode when a non-owner commits.
+
+	ErrInvalidSavePoint = uow.ErrInvalidSavePoint // ErrInvalidSavePoint occurs when a savepoint name is not a plain identifier.
+
+	DbConfig *MySQLConfig = nil // Global database configuration.
+)
+
+type (
+	// ITransactionContext provides methods for handling transactions, including nested transactions.
+	// It is an alias of uow.ITransactionContext, see its documentation for usage.
+	ITransactionContext = uow.ITransactionContext
+
+	// TxOptions holds the isolation level and access mode a transaction is started with.
+	TxOptions = uow.TxOptions
+
+	// transactionContext contains transaction details and management logic. The backend-independent
+	// state, including Commit, Rollback and the hooks, is the embedded uow.TxState.
+	transactionContext struct {
+		uow.TxState                 // State shared with the other backends.
+		dbHolder    *DatabaseHolder // Database holder providing the connection.
+		tx          *gorm.DB        // Database transaction instance.
+	}
+
+	// TransactionContextOption configures a transaction context when GetTransactionContext creates it.
+	// Options are ignored if the context already carries a transaction context.
+	TransactionContextOption func(*transactionContext)
+)
+
+// WithStrictCommit makes Commit return ErrNotTransactionOwner instead of nil when called with a UUID
+// other than the owner's, so inner scopes mistaking themselves for the owner are detected.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithStrictCommit())
+func WithStrictCommit() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.StrictCommit = true
+	}
+}
+
+// WithAutoBegin makes the first Provider() call outside a transaction begin one owned by the context.
+// The implicit transaction is committed by Complete() or discarded by Rollback().
+func WithAutoBegin() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.AutoBegin = true
+	}
+}
+
+// GetTransactionContext retrieves or creates a transaction context and its associated context for use within functions.
+// Example:
+//
+//	func doSomething(ctx context.Context) error {
+//	  txContext, ctx := GetTransactionContext(ctx)
+//	  id, err := txContext.Begin()
+//	  if err != nil { return err }
+//	  defer txContext.Rollback()
+//	  // ... perform operations ...
+//	  return txContext.Commit(id)
+//	}
+func GetTransactionContext(ctx context.Context, opts ...TransactionContextOption) (ITransactionContext, context.Context) {
+	transactionContext, found := ctx.Value(TransactionContextKey).(ITransactionContext)
+	if !found {
+		transactionContext := newTransactionContext(log.FromContext(ctx), NewDBHolderInstance(DbConfig), opts...)
+		transactionContext.Ctx = ctx
+		return transactionContext, context.WithValue(ctx, TransactionContextKey, transactionContext)
+	}
+	return transactionContext, ctx
+}
+
+// Begin starts a new transaction and returns its unique identifier.
+// Nested calls reuse the running transaction; only the first identifier owns it.
+func (c *transactionContext) Begin() (id uuid.UUID, err error) {
+	return c.BeginWithOptions(TxOptions{})
+}
+
+// BeginWithOptions starts a new transaction with the given isolation level and access mode and returns
+// its unique identifier. A nested call joins the running transaction and fails with ErrIncompatibleTxOptions
+// if it asks for a stricter isolation level or for writes inside a read-only transaction.
+func (c *transactionContext) BeginWithOptions(opts TxOptions) (id uuid.UUID, err error) {
+	return c.begin(context.Background(), opts)
+}
+
+// BeginContext starts a new transaction like Begin, but gives up with the error of ctx once ctx is done
+// before the transaction has begun, e.g. while waiting for a connection of an exhausted pool. The begun
+// transaction is not bound to ctx. A nested call joins the running transaction.
+func (c *transactionContext) BeginContext(ctx context.Context) (uuid.UUID, error) {
+	return c.begin(ctx, TxOptions{})
+}
+
+// begin starts or joins a transaction with opts; ctx bounds the start of a new transaction.
+func (c *transactionContext) begin(ctx context.Context, opts TxOptions) (id uuid.UUID, err error) {
+	id, joined, err := c.Join(opts)
+	if err != nil || joined {
+		return
+	}
+
+	if err = ctx.Err(); err != nil {
+		err = fmt.Errorf("begin transaction %v: %w", id, err)
+		return
+	}
+	opts = c.TxOptionsFor(ctx, opts)
+	txCtx, cancel := context.WithCancel(context.Background())
+	begun := uow.UntilBegun(ctx, cancel)
+	tx := c.dbHolder.dbConnection.BeginTx(txCtx, opts.SQLOptions())
+	err = tx.Error
+	if doneErr := begun(); doneErr != nil {
+		if err == nil {
+			tx.Rollback()
+		}
+		err = doneErr
+	}
+	if err != nil {
+		cancel()
+		c.Logger.Errorf("cannot begin transaction (%v): %s", id, err)
+		err = fmt.Errorf("begin transaction %v: %w", id, err)
+		return
+	}
+	c.tx = tx
+	c.Begun(id, opts, cancel)
+
+	return
+}
+
+// BeginReadOnly starts a read-only transaction and returns its unique identifier.
+func (c *transactionContext) BeginReadOnly() (uuid.UUID, error) {
+	return c.BeginWithOptions(TxOptions{ReadOnly: true})
+}
+
+// Provider returns the *gorm.DB instance for database operations within the transaction.
+func (c *transactionContext) Provider() *gorm.DB {
+	if c.WasRollbacked() {
+		c.Logger.Error("transaction has been rolled back!")
+		return nil
+	}
+
+	if c.InTransaction() {
+		return c.tx
+	}
+
+	if c.AutoBegin {
+		id, err := c.Begin()
+		if err != nil {
+			db := c.dbHolder.dbConnection.New()
+			_ = db.AddError(err)
+			return db
+		}
+		c.BeganImplicitly(id)
+		return c.tx
+	}
+
+	return c.dbHolder.dbConnection
+}
+
+// ProviderWithContext returns Provider() bound to ctx: operations started after ctx is done fail with ctx.Err().
+// GORM v1 does not pass contexts to the driver, so a statement already running is not interrupted.
+func (c *transactionContext) ProviderWithContext(ctx context.Context) *gorm.DB {
+	db := c.Provider()
+	if db == nil {
+		return nil
+	}
+	return uow.WithContext(db, ctx)
+}
+
+// SavePoint sets the savepoint name in the running transaction, so the work done after it can be undone
+// with RollbackTo while the transaction goes on. Outside a transaction it fails with ErrNotInTransaction.
+func (c *transactionContext) SavePoint(name string) error {
+	if err := c.CheckSavePoint(name); err != nil {
+		return err
+	}
+	if err := c.tx.Exec("SAVEPOINT " + name).Error; err != nil {
+		return fmt.Errorf("savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// RollbackTo undoes the work done in the running transaction since the savepoint name was set.
+func (c *transactionContext) RollbackTo(name string) error {
+	if err := c.CheckSavePoint(name); err != nil {
+		return err
+	}
+	if err := c.tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error; err != nil {
+		return fmt.Errorf("rollback to savepoint %s: %w", name, err)
+	}
+	return nil
+}
+
+// CommitTx implements uow.TxEnder.
+func (c *transactionContext) CommitTx() error {
+	return c.tx.Commit().Error
+}
+
+// RollbackTx implements uow.TxEnder.
+func (c *transactionContext) RollbackTx() error {
+	return c.tx.Rollback().Error
+}
+
+// ReleaseTx implements uow.TxEnder.
+func (c *transactionContext) ReleaseTx() {
+	c.tx = nil
+}
+
+// newTransactionContext creates a new instance of transactionContext with the given logger, dbHolder and options.
+func newTransactionContext(logger log.Logger, dbHolder *DatabaseHolder, opts ...TransactionContextOption) *transactionContext {
+	c := &transactionContext{dbHolder: dbHolder}
+	c.TxState = uow.NewTxState(logger, c)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Interface compliance check
+var _ ITransactionContext = (*transactionContext)(nil)
@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test nested Begin/Commit where only the owner commits and after-commit hooks run once
+func TestTransactionContext_NestedCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open("mysql", db)
+	assert.NoError(t, err)
+	defer gormDB.Close()
+	tx := newTransactionContext(log.FromDefaultContext(), NewDBHolder(gormDB))
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	outer, err := tx.Begin()
+	assert.NoError(t, err)
+	inner, err := tx.Begin()
+	assert.NoError(t, err)
+
+	hooks := 0
+	tx.RegisterAfterCommit(func() { hooks++ })
+	assert.NoError(t, tx.Commit(inner))
+	assert.NoError(t, tx.Commit(outer))
+	assert.Equal(t, 1, hooks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test SavePoint and RollbackTo to verify the MySQL savepoint statements run in the transaction
+func TestTransactionContext_SavePoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open("mysql", db)
+	assert.NoError(t, err)
+	defer gormDB.Close()
+	tx := newTransactionContext(log.FromDefaultContext(), NewDBHolder(gormDB))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, tx.SavePoint("item"), ErrNotInTransaction)
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.SavePoint("item"))
+	assert.NoError(t, tx.RollbackTo("item"))
+	assert.ErrorIs(t, tx.RollbackTo("item-1"), ErrInvalidSavePoint)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test BeginContext to verify it gives up once its context is done while the transaction is begun
+func TestTransactionContext_BeginContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open("mysql", db)
+	assert.NoError(t, err)
+	defer gormDB.Close()
+	tx := newTransactionContext(log.FromDefaultContext(), NewDBHolder(gormDB))
+
+	mock.ExpectBegin().WillDelayFor(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = tx.BeginContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, tx.InTransaction())
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, err := tx.BeginContext(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,253 @@
+// Package outbox implements the transactional outbox pattern on Postgres. Messages are added in the caller's
+// unit of work, so they exist only if its transaction commits, and a Relay publishes them to a broker in
+// batches claimed with FOR UPDATE SKIP LOCKED. Delivery is at least once: a message is marked published in
+// the transaction that claimed it, so a crash between publishing and committing publishes it again, and
+// consumers drop duplicates by Message.DedupeKey. Messages of one aggregate are published in the order they
+// were added.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	log "github.com/public-forge/go-logger"
+	"time"
+)
+
+const (
+	// DefaultTable is the outbox table used when Config.Table is empty.
+	DefaultTable = "outbox"
+
+	// defaultBatchSize defines the number of messages claimed at once when RelayConfig.BatchSize is not set.
+	defaultBatchSize = 100
+	// defaultPollInterval defines how long an idle relay waits when RelayConfig.PollInterval is not set.
+	defaultPollInterval = time.Second
+)
+
+type (
+	// Message is a row of the outbox table. The table can be created with:
+	//
+	//	CREATE TABLE outbox (
+	//	  id           bigserial   PRIMARY KEY,
+	//	  topic        text        NOT NULL,
+	//	  aggregate    text        NOT NULL,
+	//	  dedupe_key   text        NOT NULL UNIQUE,
+	//	  payload      jsonb       NOT NULL,
+	//	  attempts     integer     NOT NULL DEFAULT 0,
+	//	  last_error   text,
+	//	  created_at   timestamptz NOT NULL,
+	//	  published_at timestamptz
+	//	);
+	//	CREATE INDEX outbox_unpublished ON outbox (id) WHERE published_at IS NULL;
+	Message struct {
+		ID          uint64     `gorm:"primary_key"` // ID identifies the message and orders the messages of an aggregate.
+		Topic       string     // Topic is the Kafka topic or NATS subject the message is published to.
+		Aggregate   string     // Aggregate identifies the entity the message is about, e.g. "order:42"; it is the partition key.
+		DedupeKey   string     // DedupeKey is unique per message, for consumers to drop redeliveries.
+		Payload     string     // Payload is the JSON encoded payload passed to Add.
+		Attempts    int        // Attempts is the number of failed publications.
+		LastError   *string    // LastError is the error of the last failed publication.
+		CreatedAt   time.Time  // CreatedAt is the time the message was added.
+		PublishedAt *time.Time // PublishedAt is the time the message was published; nil until then.
+	}
+
+	// Publisher publishes outbox messages to a broker, see KafkaPublisher and NATSPublisher. Publish returns
+	// once the broker has acknowledged the message.
+	Publisher interface {
+		Publish(ctx context.Context, message *Message) error
+	}
+
+	// Config holds the settings of an Outbox. Zero values fall back to defaults.
+	Config struct {
+		Table   string                              // Table is the outbox table; DefaultTable if empty.
+		Factory *postgres.TransactionContextFactory // Factory creates the transaction contexts of relays; nil uses postgres.GetTransactionContext.
+		Logger  log.Logger                          // Logger used by relays; defaults to the default logger.
+	}
+
+	// RelayConfig holds the settings of a Relay. Zero values fall back to defaults.
+	RelayConfig struct {
+		Publisher    Publisher      // Publisher the messages are published with.
+		BatchSize    int            // BatchSize is the maximum number of messages claimed per transaction.
+		PollInterval time.Duration  // PollInterval is how long Run waits before looking for messages again once the outbox is drained.
+		Clock        postgres.Clock // Clock waits for the PollInterval; defaults to postgres.SystemClock.
+	}
+
+	// Outbox adds messages to an outbox table.
+	Outbox struct {
+		config Config
+	}
+
+	// Relay publishes the messages of an outbox. Several relays may run concurrently, e.g. one per instance.
+	Relay struct {
+		outbox *Outbox
+		config RelayConfig
+	}
+)
+
+// New creates an Outbox.
+func New(config Config) *Outbox {
+	if config.Table == "" {
+		config.Table = DefaultTable
+	}
+	if config.Logger == nil {
+		config.Logger = log.FromDefaultContext()
+	}
+	return &Outbox{config: config}
+}
+
+// Add adds a message with payload, encoded as JSON, for topic to the outbox in the unit of work of ctx: if ctx
+// runs a transaction, the message is published only once it commits. aggregate identifies the entity the
+// message is about; messages with the same aggregate are published in the order they are added.
+// Example:
+//
+//	err := postgres.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+//	  if err := db.Create(&order).Error; err != nil { return err }
+//	  return events.Add(ctx, "orders", fmt.Sprint("order:", order.ID), OrderPlaced{OrderID: order.ID})
+//	})
+func (o *Outbox) Add(ctx context.Context, topic, aggregate string, payload interface{}) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	txContext, ctx := o.transactionContext(ctx)
+	db := txContext.ProviderWithContext(ctx)
+	if db == nil {
+		return postgres.ErrTxWasRollbacked
+	}
+	message := Message{Topic: topic, Aggregate: aggregate, DedupeKey: uuid.NewString(), Payload: string(encoded)}
+	return db.Table(o.config.Table).Create(&message).Error
+}
+
+// NewRelay creates a Relay publishing the messages of the outbox with config.Publisher.
+func (o *Outbox) NewRelay(config RelayConfig) *Relay {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.Clock == nil {
+		config.Clock = postgres.SystemClock{}
+	}
+	return &Relay{outbox: o, config: config}
+}
+
+// PublishBatch claims up to BatchSize unpublished messages, skipping messages claimed by other relays, and
+// publishes them in order in a unit of work of its own, marking each published message. Messages of an
+// aggregate with an older message claimed by another relay are left for later, and so are the messages
+// following a failed one of the same aggregate; failures are recorded on the message and returned once the
+// batch has been committed. The first result is the number of published messages.
+func (r *Relay) PublishBatch(ctx context.Context) (int, error) {
+	var (
+		published int     // Number of messages published.
+		failures  []error // Errors of the failed publications.
+	)
+	table := r.outbox.config.Table
+	_, txCtx := r.outbox.transactionContext(context.WithValue(ctx, postgres.TransactionContextKey, nil))
+	err := postgres.RunInTransaction(txCtx, func(ctx context.Context, db *gorm.DB) error {
+		var messages []Message
+		err := db.Table(table).Where("published_at IS NULL").Order("id").Limit(r.config.BatchSize).
+			Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Find(&messages).Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		blocked, err := r.claimedElsewhere(db, messages)
+		if err != nil {
+			return err
+		}
+		for i := range messages {
+			message := &messages[i]
+			if blocked[message.Aggregate] {
+				continue
+			}
+			if err := r.config.Publisher.Publish(ctx, message); err != nil {
+				blocked[message.Aggregate] = true
+				failures = append(failures, fmt.Errorf("outbox: publish message %d to %s: %w", message.ID, message.Topic, err))
+				if err := r.recordFailure(db, message, err); err != nil {
+					return err
+				}
+				continue
+			}
+			now := gorm.NowFunc()
+			message.PublishedAt = &now
+			if err := r.update(db, message, map[string]interface{}{"published_at": now}); err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, errors.Join(failures...)
+}
+
+// Run publishes batches until ctx is done, waiting PollInterval once the outbox is drained or a batch
+// fails. Errors are logged and do not stop the relay.
+// Example:
+//
+//	relay := events.NewRelay(outbox.RelayConfig{Publisher: outbox.KafkaPublisher{Produce: produce}})
+//	go relay.Run(ctx)
+func (r *Relay) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := r.PublishBatch(ctx)
+		if err != nil {
+			r.outbox.config.Logger.Errorf("outbox %s: cannot publish messages: %s", r.outbox.config.Table, err)
+		}
+		if published == r.config.BatchSize && err == nil {
+			continue
+		}
+		_ = r.config.Clock.Sleep(ctx, r.config.PollInterval)
+	}
+}
+
+// claimedElsewhere returns the aggregates of messages that have older unpublished messages outside of the
+// batch. These are locked by other relays, as the batch holds the oldest unlocked messages.
+func (r *Relay) claimedElsewhere(db *gorm.DB, messages []Message) (map[string]bool, error) {
+	ids := make([]uint64, len(messages))
+	aggregates := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i], aggregates[i] = message.ID, message.Aggregate
+	}
+	var claimed []string
+	err := db.Table(r.outbox.config.Table).
+		Where("published_at IS NULL AND id < ? AND id NOT IN (?) AND aggregate IN (?)", ids[len(ids)-1], ids, aggregates).
+		Pluck("DISTINCT aggregate", &claimed).Error
+	blocked := make(map[string]bool, len(claimed))
+	for _, aggregate := range claimed {
+		blocked[aggregate] = true
+	}
+	return blocked, err
+}
+
+// recordFailure records a failed publication of message.
+func (r *Relay) recordFailure(db *gorm.DB, message *Message, err error) error {
+	text := err.Error()
+	message.LastError = &text
+	message.Attempts++
+	return r.update(db, message, map[string]interface{}{"attempts": message.Attempts, "last_error": message.LastError})
+}
+
+// update writes columns of message.
+func (r *Relay) update(db *gorm.DB, message *Message, columns map[string]interface{}) error {
+	result := db.Table(r.outbox.config.Table).Where("id = ?", message.ID).Updates(columns)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return errors.New("outbox: message " + fmt.Sprint(message.ID) + " has disappeared")
+	}
+	return result.Error
+}
+
+// transactionContext returns the transaction context of ctx, created by the configured factory.
+func (o *Outbox) transactionContext(ctx context.Context) (postgres.ITransactionContext, context.Context) {
+	if o.config.Factory != nil {
+		return o.config.Factory.GetTransactionContext(ctx)
+	}
+	return postgres.GetTransactionContext(ctx)
+}
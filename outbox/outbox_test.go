@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// recordingPublisher records the published messages and fails for the messages of failures.
+type recordingPublisher struct {
+	published []Message
+	failures  map[uint64]error
+}
+
+// Publish implements Publisher.
+func (p *recordingPublisher) Publish(_ context.Context, message *Message) error {
+	if err := p.failures[message.ID]; err != nil {
+		return err
+	}
+	p.published = append(p.published, *message)
+	return nil
+}
+
+// newTestOutbox builds an outbox whose transaction contexts are backed by go-sqlmock.
+func newTestOutbox(t *testing.T) (*Outbox, *gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	return New(Config{Factory: postgres.NewTransactionContextFactory(postgres.NewDBHolder(db))}), db, mock
+}
+
+var messageColumns = []string{"id", "topic", "aggregate", "dedupe_key", "payload"}
+
+// Test Add to verify the message is inserted with a dedupe key in the unit of work of the caller
+func TestOutbox_Add(t *testing.T) {
+	o, db, mock := newTestOutbox(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "outbox"`).
+		WithArgs("orders", "order:1", sqlmock.AnyArg(), `{"total":3}`, 0, nil, sqlmock.AnyArg(), nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, o.Add(context.Background(), "orders", "order:1", map[string]int{"total": 3}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test PublishBatch to verify messages are published in order, and that a failed message and the later
+// messages of its aggregate, and aggregates with messages claimed by another relay, are left unpublished
+func TestRelay_PublishBatch(t *testing.T) {
+	o, db, mock := newTestOutbox(t)
+	defer db.Close()
+	failure := errors.New("broker unavailable")
+	publisher := &recordingPublisher{failures: map[uint64]error{2: failure}}
+	relay := o.NewRelay(RelayConfig{Publisher: publisher, BatchSize: 10})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "outbox" WHERE \(published_at IS NULL\) ORDER BY "id" LIMIT 10 FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(1, "orders", "order:1", "k1", `{}`).
+			AddRow(2, "orders", "order:2", "k2", `{}`).
+			AddRow(3, "orders", "order:2", "k3", `{}`).
+			AddRow(4, "orders", "order:3", "k4", `{}`).
+			AddRow(5, "orders", "order:1", "k5", `{}`))
+	mock.ExpectQuery(`SELECT DISTINCT aggregate FROM "outbox" WHERE \(published_at IS NULL AND id < \$1 AND id NOT IN \(\$2,\$3,\$4,\$5,\$6\) AND aggregate IN \(\$7,\$8,\$9,\$10,\$11\)\)`).
+		WithArgs(5, 1, 2, 3, 4, 5, "order:1", "order:2", "order:2", "order:3", "order:1").
+		WillReturnRows(sqlmock.NewRows([]string{"aggregate"}).AddRow("order:3"))
+	mock.ExpectExec(`UPDATE "outbox" SET "published_at" = \$1 WHERE \(id = \$2\)`).WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "outbox" SET "attempts" = \$1, "last_error" = \$2 WHERE \(id = \$3\)`).WithArgs(1, failure.Error(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "outbox" SET "published_at" = \$1 WHERE \(id = \$2\)`).WithArgs(sqlmock.AnyArg(), 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	published, err := relay.PublishBatch(context.Background())
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 2, published)
+	assert.Len(t, publisher.published, 2)
+	assert.Equal(t, "k1", publisher.published[0].DedupeKey)
+	assert.Equal(t, "k5", publisher.published[1].DedupeKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test the Kafka and NATS adapters to verify the partition key and the dedupe headers
+func TestPublishers(t *testing.T) {
+	message := &Message{Topic: "orders", Aggregate: "order:1", DedupeKey: "k1", Payload: `{}`}
+
+	var key []byte
+	var headers map[string]string
+	kafka := KafkaPublisher{Produce: func(_ context.Context, topic string, k, value []byte, h map[string]string) error {
+		key, headers = k, h
+		return nil
+	}}
+	assert.NoError(t, kafka.Publish(context.Background(), message))
+	assert.Equal(t, "order:1", string(key))
+	assert.Equal(t, map[string]string{DedupeKeyHeader: "k1"}, headers)
+
+	nats := NATSPublisher{PublishMsg: func(_ context.Context, subject string, data []byte, h map[string]string) error {
+		headers = h
+		return nil
+	}}
+	assert.NoError(t, nats.Publish(context.Background(), message))
+	assert.Equal(t, map[string]string{NATSMsgIDHeader: "k1"}, headers)
+}
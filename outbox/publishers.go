@@ -0,0 +1,57 @@
+package outbox
+
+import "context"
+
+const (
+	// DedupeKeyHeader is the header KafkaPublisher sets to Message.DedupeKey.
+	DedupeKeyHeader = "Outbox-Dedupe-Key"
+	// NATSMsgIDHeader is the header NATSPublisher sets to Message.DedupeKey; JetStream drops messages with an
+	// ID it has seen within the duplicate window of the stream.
+	NATSMsgIDHeader = "Nats-Msg-Id"
+)
+
+type (
+	// KafkaPublisher publishes messages to Kafka through Produce, keyed by Message.Aggregate so the messages of
+	// an aggregate go to one partition and keep their order, with the DedupeKeyHeader header. Produce adapts
+	// the client in use and must wait for the acknowledgement of the write.
+	// Example, with github.com/segmentio/kafka-go:
+	//
+	//	writer := &kafka.Writer{Addr: kafka.TCP("kafka:9092"), RequiredAcks: kafka.RequireAll}
+	//	publisher := outbox.KafkaPublisher{Produce: func(ctx context.Context, topic string, key, value []byte, headers map[string]string) error {
+	//	  message := kafka.Message{Topic: topic, Key: key, Value: value}
+	//	  for name, value := range headers { message.Headers = append(message.Headers, kafka.Header{Key: name, Value: []byte(value)}) }
+	//	  return writer.WriteMessages(ctx, message)
+	//	}}
+	KafkaPublisher struct {
+		Produce func(ctx context.Context, topic string, key, value []byte, headers map[string]string) error
+	}
+
+	// NATSPublisher publishes messages to the subject Message.Topic through PublishMsg, with the NATSMsgIDHeader
+	// header so a JetStream stream deduplicates redeliveries. PublishMsg adapts the client in use and must wait
+	// for the acknowledgement of the stream.
+	// Example, with github.com/nats-io/nats.go:
+	//
+	//	js, _ := conn.JetStream()
+	//	publisher := outbox.NATSPublisher{PublishMsg: func(ctx context.Context, subject string, data []byte, headers map[string]string) error {
+	//	  message := nats.NewMsg(subject)
+	//	  message.Data = data
+	//	  for name, value := range headers { message.Header.Set(name, value) }
+	//	  _, err := js.PublishMsg(message, nats.Context(ctx))
+	//	  return err
+	//	}}
+	NATSPublisher struct {
+		PublishMsg func(ctx context.Context, subject string, data []byte, headers map[string]string) error
+	}
+)
+
+// Publish implements Publisher.
+func (p KafkaPublisher) Publish(ctx context.Context, message *Message) error {
+	headers := map[string]string{DedupeKeyHeader: message.DedupeKey}
+	return p.Produce(ctx, message.Topic, []byte(message.Aggregate), []byte(message.Payload), headers)
+}
+
+// Publish implements Publisher.
+func (p NATSPublisher) Publish(ctx context.Context, message *Message) error {
+	headers := map[string]string{NATSMsgIDHeader: message.DedupeKey}
+	return p.PublishMsg(ctx, message.Topic, []byte(message.Payload), headers)
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+)
+
+// AdvisoryLock is a Postgres advisory lock acquired by AcquireAdvisoryLock or TryAdvisoryLock.
+// Transaction-level locks are released by Postgres at commit or rollback; session-level locks hold a
+// dedicated pooled connection until Release is called.
+type AdvisoryLock struct {
+	Key  int64     // Key is the lock key.
+	conn *sql.Conn // Connection holding a session-level lock; nil for transaction-level locks.
+}
+
+// AdvisoryLockKey derives a lock key from a name, so locks can be identified by strings.
+// Example:
+//
+//	lock, err := AcquireAdvisoryLock(ctx, AdvisoryLockKey("billing:monthly-close"))
+func AdvisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// AcquireAdvisoryLock waits for the advisory lock key. If the transaction context in ctx runs a transaction,
+// a transaction-level lock (pg_advisory_xact_lock) is taken and released when the transaction ends;
+// otherwise a session-level lock (pg_advisory_lock) is taken on a dedicated connection until Release.
+// Example:
+//
+//	lock, err := AcquireAdvisoryLock(ctx, AdvisoryLockKey("reports"))
+//	if err != nil { return err }
+//	defer lock.Release()
+func AcquireAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	return advisoryLock(ctx, key, "pg_advisory_xact_lock", "pg_advisory_lock")
+}
+
+// TryAdvisoryLock is AcquireAdvisoryLock without waiting: it returns nil and no error if the lock
+// is held by another session.
+// Example:
+//
+//	lock, err := TryAdvisoryLock(ctx, AdvisoryLockKey("scheduler"))
+//	if err != nil || lock == nil { return err } // another instance is running the job
+//	defer lock.Release()
+func TryAdvisoryLock(ctx context.Context, key int64) (*AdvisoryLock, error) {
+	return advisoryLock(ctx, key, "pg_try_advisory_xact_lock", "pg_try_advisory_lock")
+}
+
+// Release releases a session-level lock and returns its connection to the pool.
+// It is a no-op for transaction-level locks.
+func (l *AdvisoryLock) Release() error {
+	if l == nil || l.conn == nil {
+		return nil
+	}
+	defer func() {
+		_ = l.conn.Close()
+		l.conn = nil
+	}()
+	_, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.Key)
+	return err
+}
+
+// advisoryLock takes the lock with xactFunc inside a running transaction or with sessionFunc on a
+// dedicated connection. Both functions either return void or a boolean telling whether the lock was taken.
+func advisoryLock(ctx context.Context, key int64, xactFunc, sessionFunc string) (*AdvisoryLock, error) {
+	txContext, _ := GetTransactionContext(ctx)
+	c, ok := txContext.(*transactionContext)
+	if ok && c.inTransaction() {
+		var locked interface{}
+		if err := c.Provider().Raw("SELECT "+xactFunc+"(?)", key).Row().Scan(&locked); err != nil {
+			return nil, err
+		}
+		if locked == false {
+			return nil, nil
+		}
+		return &AdvisoryLock{Key: key}, nil
+	}
+
+	db := txContext.Provider()
+	if ok {
+		db = c.providerWithoutTransaction() // a session-level lock must not auto-begin a transaction
+	}
+	return sessionAdvisoryLock(ctx, db.DB(), key, sessionFunc)
+}
+
+// sessionAdvisoryLock takes a session-level lock with sessionFunc on a dedicated connection of sqlDB,
+// which is held by the returned lock until Release.
+func sessionAdvisoryLock(ctx context.Context, sqlDB *sql.DB, key int64, sessionFunc string) (*AdvisoryLock, error) {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked interface{}
+	if err = conn.QueryRowContext(ctx, "SELECT "+sessionFunc+"($1)", key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if locked == false {
+		_ = conn.Close()
+		return nil, nil
+	}
+	return &AdvisoryLock{Key: key, conn: conn}, nil
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that a lock taken inside a transaction is transaction-level and one taken outside is session-level
+func TestAcquireAdvisoryLock(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectQuery("SELECT pg_advisory_lock($1)").WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_advisory_lock"}).AddRow(""))
+	mock.ExpectExec("SELECT pg_advisory_unlock($1)").WithArgs(int64(42)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	lock, err := AcquireAdvisoryLock(ctx, 42)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Release())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock($1)").WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_xact_lock"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err = tx.Begin()
+	assert.NoError(t, err)
+	lock, err = TryAdvisoryLock(ctx, 42)
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+)
+
+const (
+	// autoBeginScopeKey marks the *gorm.DB returned by Provider in auto-begin mode before the transaction
+	// has begun; it holds the transaction context.
+	autoBeginScopeKey = "uow:auto_begin"
+	// autoBegunScopeKey marks the write whose GORM transaction became the transaction of the context.
+	autoBegunScopeKey = "uow:auto_begun"
+)
+
+// ErrStaleProvider occurs in auto-begin mode when a *gorm.DB returned by Provider before the transaction
+// was begun is used after the first write began it; Provider must be called again to use the transaction.
+var ErrStaleProvider = errors.New("the *gorm.DB was returned by Provider before the transaction began")
+
+// WithAutoBegin makes the first write outside a transaction (Create, Update or Delete through Provider())
+// begin one owned by the context; reads before it run on the pool without a transaction. The implicit
+// transaction is committed by Complete() (typically called by middleware) or discarded by Rollback().
+// Explicit Begin()/Commit() pairs inside it behave like nested transactions. The *gorm.DB returned by
+// Provider() before the first write fails with ErrStaleProvider once the write has begun the transaction,
+// and raw statements sent with Exec do not begin it, as GORM runs them without callbacks. A context whose
+// ctx carries transaction options (uow.WithTxOptions) begins on the first Provider() call instead.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithAutoBegin())
+//	defer txContext.Rollback()
+//	if err := handler(ctx); err != nil { return err }
+//	return txContext.Complete()
+func WithAutoBegin() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.autoBegin = true
+	}
+}
+
+// Complete commits the transaction begun implicitly in auto-begin mode.
+// It is a no-op if no implicit transaction is active, e.g. if the unit of work only read.
+func (c *transactionContext) Complete() error {
+	if c.wasRollbacked() {
+		return ErrTxWasRollbacked
+	}
+	if c.autoTxUUID == nil {
+		return nil
+	}
+	return c.Commit(*c.autoTxUUID)
+}
+
+// providerWithAutoBegin returns the connection marked for the auto-begin callbacks, or begins the implicit
+// transaction right away if the context carries transaction options.
+// If the transaction cannot be started, the returned *gorm.DB carries the error so the caller's
+// statement fails instead of silently running outside a transaction.
+func (c *transactionContext) providerWithAutoBegin() *gorm.DB {
+	if uow.TxOptionsFromContext(c.ctx) == (TxOptions{}) {
+		db := c.providerWithoutTransaction()
+		if db == nil {
+			return nil
+		}
+		return db.Set(autoBeginScopeKey, c)
+	}
+
+	id, err := c.Begin()
+	if err != nil {
+		db := c.providerWithoutTransaction()
+		if db == nil {
+			return nil // the holder cannot connect, as without auto-begin
+		}
+		db = db.New()
+		_ = db.AddError(err)
+		return db
+	}
+	c.autoTxUUID = &id
+	c.logger.Debugf("auto-began transaction: %v", id)
+	return c.tx
+}
+
+// adoptAutoBegin makes db, running the transaction GORM has begun for the first write in auto-begin mode,
+// the implicit transaction of the context, and returns the handle of the transaction.
+func (c *transactionContext) adoptAutoBegin(db *gorm.DB) (*txHandle, error) {
+	if c.dbHolder.shuttingDown.Load() {
+		return nil, ErrShuttingDown
+	}
+	sqlTx, ok := db.CommonDB().(*sql.Tx)
+	if !ok {
+		return nil, ErrNotInTransaction
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	c.transactionUUID = &id
+	c.txOptions = TxOptions{}
+	c.txCtx, c.cancelTx = c.beginContext()
+	// GORM began the transaction without a context: roll it back once the transaction context is done, as
+	// database/sql does for BeginTx.
+	context.AfterFunc(c.txCtx, func() { _ = sqlTx.Rollback() })
+	c.startTxSpan()(nil)
+	c.tx = db.New().Set(autoBeginScopeKey, nil) // the statements of the transaction are not marked
+	if err := c.setUp(id); err != nil {
+		return nil, err
+	}
+	c.openLevel(id)
+	c.autoTxUUID = &id
+	c.logger.Debugf("auto-began transaction on first write: %v", id)
+	return c.handle, nil
+}
+
+// registerAutoBegin registers the callbacks beginning the implicit transaction of auto-begin mode on the
+// first write, and rejecting the use of the *gorm.DB marked by Provider once the transaction has begun.
+func registerAutoBegin(db *gorm.DB) {
+	callbacks := db.Callback()
+	for _, write := range []struct {
+		processor func() *gorm.CallbackProcessor
+		name      string
+	}{
+		{callbacks.Create, "create"},
+		{callbacks.Update, "update"},
+		{callbacks.Delete, "delete"},
+	} {
+		write.processor().After("gorm:begin_transaction").Register("uow:auto_begin_"+write.name, autoBeginOnWrite)
+		write.processor().Replace("gorm:commit_or_rollback_transaction", commitUnlessAutoBegun)
+	}
+	callbacks.Query().Before("gorm:query").Register("uow:reject_stale_auto_begin_query", rejectStaleProvider)
+	callbacks.RowQuery().Before("gorm:row_query").Register("uow:reject_stale_auto_begin_row_query", rejectStaleProvider)
+}
+
+// autoBeginOnWrite makes the transaction GORM has begun for a write of a marked *gorm.DB the implicit
+// transaction of its context, so the write and the following statements run in it.
+func autoBeginOnWrite(scope *gorm.Scope) {
+	c, ok := autoBeginContext(scope)
+	if !ok || scope.HasError() {
+		return
+	}
+	switch {
+	case c.wasRollbacked():
+		_ = scope.Err(ErrTxWasRollbacked)
+		return
+	case c.inTransaction():
+		_ = scope.Err(ErrStaleProvider)
+		return
+	}
+	if _, started := scope.InstanceGet("gorm:started_transaction"); !started {
+		return
+	}
+	handle, err := c.adoptAutoBegin(scope.DB())
+	if err != nil {
+		_ = scope.Err(err)
+		return
+	}
+	scope.InstanceSet(autoBegunScopeKey, true)
+	scope.Set(transactionContextScopeKey, handle)
+}
+
+// commitUnlessAutoBegun replaces gorm:commit_or_rollback_transaction, leaving the transaction adopted by
+// autoBeginOnWrite to its transaction context.
+func commitUnlessAutoBegun(scope *gorm.Scope) {
+	if _, adopted := scope.InstanceGet(autoBegunScopeKey); adopted {
+		return
+	}
+	scope.CommitOrRollback()
+}
+
+// rejectStaleProvider fails reads of a marked *gorm.DB once its context has begun a transaction, as they
+// would not see the writes of the transaction.
+func rejectStaleProvider(scope *gorm.Scope) {
+	if c, ok := autoBeginContext(scope); ok && c.inTransaction() {
+		_ = scope.Err(ErrStaleProvider)
+	}
+}
+
+// autoBeginContext returns the transaction context marking the *gorm.DB of scope.
+func autoBeginContext(scope *gorm.Scope) (*transactionContext, bool) {
+	value, _ := scope.Get(autoBeginScopeKey)
+	c, ok := value.(*transactionContext)
+	return c, ok && c != nil
+}
@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+type (
+	// Batch collects independent statements that are sent to the database together.
+	//
+	// On a holder connected with DriverPgx the whole batch is sent in one round trip with the
+	// pipeline mode of pgx; arguments are passed to pgx unchanged, so a slice binds an array
+	// (use = ANY(?) rather than IN (?)). Otherwise statements without arguments are joined and
+	// executed as a single multi-statement round trip. Statements with arguments cannot share a
+	// round trip on lib/pq, whose multi-statement queries take no parameters, so they flush the
+	// pending group and are executed on their own, preserving order: a batch of n statements with
+	// arguments takes n round trips there.
+	//
+	// Either way the batch is logged by the GORM logger of the transaction context, so it shows up
+	// in the SQL log, dry-run reports and statement spans. Question marks in strings, comments and
+	// dollar quotes and the jsonb operators ?| and ?& are not placeholders; write the jsonb ?
+	// operator as \?.
+	// Example:
+	//   batch := NewBatch().
+	//     Queue("UPDATE accounts SET frozen = true WHERE id = ?", id).
+	//     Queue("DELETE FROM sessions WHERE expired")
+	//   err := ExecBatch(ctx, batch)
+	Batch struct {
+		statements []batchStatement // Statements in the order they were queued.
+	}
+
+	// batchStatement is a single queued statement together with its bind arguments.
+	batchStatement struct {
+		sql  string        // SQL text with "?" placeholders.
+		args []interface{} // Arguments bound to the placeholders.
+	}
+)
+
+// NewBatch creates an empty Batch.
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Queue appends a statement to the batch and returns the batch for chaining.
+func (b *Batch) Queue(sql string, args ...interface{}) *Batch {
+	b.statements = append(b.statements, batchStatement{sql: strings.TrimSuffix(strings.TrimSpace(sql), ";"), args: args})
+	return b
+}
+
+// Len returns the number of queued statements.
+func (b *Batch) Len() int {
+	return len(b.statements)
+}
+
+// ExecBatch executes the batch through the transaction context stored in ctx.
+// When a transaction is active the statements run inside it, otherwise they run on the connection.
+func ExecBatch(ctx context.Context, batch *Batch) error {
+	txContext, _ := GetTransactionContext(ctx)
+	db := txContext.Provider()
+	if db == nil {
+		return ErrTxWasRollbacked
+	}
+	if c, ok := txContext.(*transactionContext); ok && c.dbHolder.sendsPipelines() {
+		return batch.execPipeline(ctx, db)
+	}
+	return batch.exec(db)
+}
+
+// execPipeline sends the queued statements to db as one pgx pipeline, see pipelineConn.
+func (b *Batch) execPipeline(ctx context.Context, db *gorm.DB) error {
+	if len(b.statements) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	queries := make([]string, len(b.statements))
+	for i, stmt := range b.statements {
+		queries[i] = numberPlaceholders(stmt.sql)
+	}
+	// A scope rather than db.Exec, which would bind the pipeline to the first question mark of the batch;
+	// scope.Exec logs the batch like db.Exec does.
+	scope := db.NewScope(nil)
+	scope.Raw(strings.Join(queries, ";\n"))
+	scope.SQLVars = []interface{}{pipeline{ctx: ctx, statements: b.statements}}
+	return scope.Exec().DB().Error
+}
+
+// exec sends the queued statements to db, grouping consecutive statements without arguments.
+func (b *Batch) exec(db *gorm.DB) error {
+	var pending []string
+
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		query := strings.Join(pending, ";\n")
+		pending = pending[:0]
+		return db.Exec(query).Error
+	}
+
+	for _, stmt := range b.statements {
+		sql, args := stmt.gormExec()
+		if len(args) == 0 {
+			pending = append(pending, sql)
+			continue
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if err := db.Exec(sql, args...).Error; err != nil {
+			return err
+		}
+	}
+
+	return flush()
+}
+
+// gormExec returns the SQL and arguments executing the statement with db.Exec. GORM binds every question
+// mark of a statement with arguments, so the question marks that are not placeholders, see
+// scanPlaceholders, are bound to gorm.Expr("?"), which GORM writes back unchanged.
+func (s batchStatement) gormExec() (string, []interface{}) {
+	sql, placeholders := scanPlaceholders(s.sql)
+	if len(s.args) == 0 {
+		return sql, nil
+	}
+	args := make([]interface{}, 0, len(placeholders))
+	next := 0
+	for _, placeholder := range placeholders {
+		switch {
+		case !placeholder:
+			args = append(args, gorm.Expr("?"))
+		case next < len(s.args):
+			args = append(args, s.args[next])
+			next++
+		}
+	}
+	return sql, append(args, s.args[next:]...)
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that statements without arguments are grouped into one round trip
+func TestBatch_GroupsStatementsWithoutArgs(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM a;\nDELETE FROM b").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE c SET x = $1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM d").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+
+	batch := NewBatch().
+		Queue("DELETE FROM a;").
+		Queue("DELETE FROM b").
+		Queue("UPDATE c SET x = ?", 1).
+		Queue("DELETE FROM d")
+	assert.Equal(t, 4, batch.Len())
+	assert.NoError(t, batch.exec(tx.Provider()))
+
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that the question marks that are not placeholders reach lib/pq unchanged
+func TestBatch_LiteralQuestionMarks(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE a SET x = $1 WHERE tags ? 'vip' AND tags ?| $2 -- why?").
+		WithArgs(1, "{a,b}").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SELECT '?';\nSELECT tags ? 'vip' FROM a").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+
+	batch := NewBatch().
+		Queue(`UPDATE a SET x = ? WHERE tags \? 'vip' AND tags ?| ? -- why?`, 1, "{a,b}").
+		Queue("SELECT '?'").
+		Queue(`SELECT tags \? 'vip' FROM a`)
+	assert.NoError(t, batch.exec(tx.Provider()))
+
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// WithRollbackOnCancel ties the transactions of the context to the context passed to GetTransactionContext.
+// Transactions are started with BeginTx(ctx), so database/sql rolls them back and releases the connection as
+// soon as ctx is cancelled or its deadline expires. The next call on the transaction context then observes the
+// cancellation and moves into the rolled back state (ErrTxWasRollbacked).
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(r.Context(), WithRollbackOnCancel())
+func WithRollbackOnCancel() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.rollbackOnCancel = true
+	}
+}
+
+// WithTxTimeout limits how long a transaction of the context may stay open, overriding PgConfig.TxTimeoutMS.
+// A transaction still open after timeout is rolled back by database/sql; the owning UUID and the call site
+// of Begin() are logged so leaks can be traced. A zero timeout disables the limit.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithTxTimeout(30*time.Second))
+func WithTxTimeout(timeout time.Duration) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.txTimeout = &timeout
+	}
+}
+
+// beginContext returns the context a new transaction is started with, bound to the request context, or the
+// context passed to BeginContext, in rollback-on-cancel mode and limited by the transaction timeout. The
+// returned cancel func must be called once the transaction is disposed.
+func (c *transactionContext) beginContext() (context.Context, context.CancelFunc) {
+	ctx := context.Background()
+	if c.rollbackOnCancel {
+		ctx = c.ctx
+		if c.beginCtx != nil {
+			ctx = c.beginCtx
+		}
+	}
+	if timeout := c.effectiveTxTimeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// effectiveTxTimeout returns the timeout set by WithTxTimeout or, if absent, the one from the holder configuration.
+func (c *transactionContext) effectiveTxTimeout() time.Duration {
+	if c.txTimeout != nil {
+		return *c.txTimeout
+	}
+	return c.dbHolder.currentSettings().txTimeout
+}
+
+// watchTxTimeout logs the owner and call site of the running transaction once ctx, its context, exceeds the
+// deadline. Watching ctx rather than running a timer of its own, the log line cannot miss the expiry.
+func (c *transactionContext) watchTxTimeout(ctx context.Context) {
+	timeout := c.effectiveTxTimeout()
+	if timeout <= 0 {
+		return
+	}
+	id, callSite, logger := *c.transactionUUID, c.txInfo.Caller, c.logger
+	c.stopTxWatch = context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logger.Errorf("transaction %v exceeded timeout of %s and was rolled back; begun at %s", id, timeout, callSite)
+		}
+	})
+}
+
+// rollbackIfCancelled moves the context into the rolled back state if its transaction was
+// aborted by the cancellation of the bound context or by the transaction timeout.
+func (c *transactionContext) rollbackIfCancelled() {
+	if !c.inTransaction() || c.txCtx == nil || c.txCtx.Err() == nil {
+		return
+	}
+	c.logger.Warnf("transaction context done, rolling back transaction %v: %s", c.transactionUUID, c.txCtx.Err())
+	_ = c.tx.Rollback() // database/sql has already rolled back; this only releases gorm's handle
+	c.disposeAfterRollback()
+}
+
+// releaseTxContext stops the timeout watcher and cancels the context of the disposed transaction.
+func (c *transactionContext) releaseTxContext() {
+	if c.stopTxWatch != nil {
+		c.stopTxWatch()
+		c.stopTxWatch = nil
+	}
+	if c.cancelTx != nil {
+		c.cancelTx()
+		c.cancelTx = nil
+	}
+	c.txCtx, c.beginCtx = nil, nil
+}
+
+// callerOutsidePackage returns the file:line of the first caller outside this package.
+func callerOutsidePackage() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.Contains(frame.Function, "go-gorm-unit-of-work/postgres.") || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"fmt"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// errorsLogger sends the errors logged through it to a channel, as they are logged by the timeout watcher.
+type errorsLogger struct {
+	log.Logger
+	errors chan string
+}
+
+func (l *errorsLogger) WithField(key string, value interface{}) log.Logger {
+	return &errorsLogger{Logger: l.Logger.WithField(key, value), errors: l.errors}
+}
+
+func (l *errorsLogger) Errorf(format string, args ...interface{}) {
+	l.errors <- fmt.Sprintf(format, args...)
+}
+
+// Test that a transaction open longer than its timeout is rolled back and logged with its owner and call site
+func TestTransactionContext_TxTimeout(t *testing.T) {
+	for name, configure := range map[string]func(tx *transactionContext){
+		"WithTxTimeout": func(tx *transactionContext) { WithTxTimeout(20 * time.Millisecond)(tx) },
+		"TxTimeoutMS":   func(tx *transactionContext) { tx.dbHolder.Reload(&PgConfig{TxTimeoutMS: 20}) },
+		"override": func(tx *transactionContext) {
+			tx.dbHolder.Reload(&PgConfig{TxTimeoutMS: int(time.Hour / time.Millisecond)})
+			WithTxTimeout(20 * time.Millisecond)(tx)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tx, db, mock := getTestTransactionContext(t)
+			defer db.Close()
+			logger := &errorsLogger{Logger: tx.logger, errors: make(chan string, 1)}
+			tx.logger = logger
+			configure(tx)
+
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+			id, err := tx.Begin()
+			assert.NoError(t, err)
+
+			select {
+			case line := <-logger.errors:
+				assert.Contains(t, line, fmt.Sprintf("transaction %v exceeded timeout of 20ms", id))
+				assert.Contains(t, line, "cancellation_test.go")
+			case <-time.After(time.Second):
+				t.Fatal("the timeout was not logged")
+			}
+			assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, time.Millisecond)
+			assert.ErrorIs(t, tx.Commit(id), ErrTxWasRollbacked)
+		})
+	}
+}
+
+// Test that WithTxTimeout(0) disables the timeout of PgConfig.TxTimeoutMS
+func TestTransactionContext_TxTimeoutDisabled(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx.dbHolder.Reload(&PgConfig{TxTimeoutMS: 1})
+	WithTxTimeout(0)(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
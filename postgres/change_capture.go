@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	"sync/atomic"
+)
+
+// changeBeforeKey holds the row image loaded before an update or delete.
+const changeBeforeKey = "uow:change_before"
+
+// changeCaptureCount numbers the RegisterChangeCapture calls, for unique callback names.
+var changeCaptureCount atomic.Int64
+
+type (
+	// RowChange is a write captured by RegisterChangeCapture.
+	RowChange struct {
+		Table      string                 // Table is the table of the written row.
+		Operation  string                 // Operation is ChangeInsert, ChangeUpdate or ChangeDelete.
+		PrimaryKey interface{}            // PrimaryKey is the primary key of the row; nil for statements without one, such as bulk updates.
+		Before     map[string]interface{} // Before is the image of the row before an update or delete, if ChangeCaptureConfig.Before is set.
+		After      map[string]interface{} // After holds the written columns of an insert or update; nil for deletes.
+	}
+
+	// ChangeSetHandler receives the changes of a committed transaction in the order they were made.
+	// ctx is the context of the unit of work, detached from its cancellation.
+	ChangeSetHandler func(ctx context.Context, changes []RowChange)
+
+	// ChangeCaptureConfig selects what RegisterChangeCapture captures.
+	ChangeCaptureConfig struct {
+		Include []string // Include limits the capture to these tables; empty captures all tables.
+		Exclude []string // Exclude lists tables that are never captured.
+		Before  bool     // Before loads the image of a row before it is updated or deleted, at the cost of a SELECT.
+	}
+
+	// changeCapture implements the callbacks of a RegisterChangeCapture call.
+	changeCapture struct {
+		config  ChangeCaptureConfig
+		include map[string]bool
+		exclude map[string]bool
+		handler ChangeSetHandler
+	}
+)
+
+// RegisterChangeCapture registers GORM callbacks on db capturing the inserts, updates and deletes of the
+// transactions of the transaction contexts, and passes the change set of every committed transaction to
+// handler once it has been committed, so downstream caches and search indexes can be updated without
+// triggers or Debezium. Rolled back transactions are not reported, but writes rolled back to a savepoint
+// are. Writes outside transaction contexts are reported one by one, right after the statement. Raw Exec
+// statements bypass the GORM callbacks and are not captured.
+// Example:
+//
+//	postgres.RegisterChangeCapture(db, postgres.ChangeCaptureConfig{Include: []string{"products"}},
+//	  func(ctx context.Context, changes []postgres.RowChange) {
+//	    for _, change := range changes { search.Reindex(ctx, change.Table, change.PrimaryKey) }
+//	  })
+func RegisterChangeCapture(db *gorm.DB, config ChangeCaptureConfig, handler ChangeSetHandler) {
+	cc := &changeCapture{config: config, include: map[string]bool{}, exclude: map[string]bool{}, handler: handler}
+	for _, table := range config.Include {
+		cc.include[table] = true
+	}
+	for _, table := range config.Exclude {
+		cc.exclude[table] = true
+	}
+	name := fmt.Sprintf("uow:change_capture_%d", changeCaptureCount.Add(1))
+	callbacks := db.Callback()
+	callbacks.Create().After("gorm:create").Register(name+"_create", cc.afterCreate)
+	callbacks.Update().Before("gorm:update").Register(name+"_before_update", cc.loadBefore)
+	callbacks.Update().After("gorm:update").Register(name+"_update", cc.afterUpdate)
+	callbacks.Delete().Before("gorm:delete").Register(name+"_before_delete", cc.loadBefore)
+	callbacks.Delete().After("gorm:delete").Register(name+"_delete", cc.afterDelete)
+}
+
+// captured reports whether the writes of scope are captured.
+func (cc *changeCapture) captured(scope *gorm.Scope) bool {
+	table := scope.TableName()
+	return !scope.HasError() && !cc.exclude[table] && (len(cc.include) == 0 || cc.include[table])
+}
+
+// afterCreate captures an insert.
+func (cc *changeCapture) afterCreate(scope *gorm.Scope) {
+	if cc.captured(scope) && scope.DB().RowsAffected > 0 {
+		cc.capture(scope, RowChange{Operation: ChangeInsert, After: fieldValues(scope)})
+	}
+}
+
+// loadBefore keeps the image of the row before an update or delete, if configured.
+func (cc *changeCapture) loadBefore(scope *gorm.Scope) {
+	if !cc.config.Before || !cc.captured(scope) || scope.PrimaryKeyZero() {
+		return
+	}
+	if before, ok := loadRowImage(scope); ok {
+		scope.InstanceSet(changeBeforeKey, before)
+	}
+}
+
+// afterUpdate captures an update with the updated columns.
+func (cc *changeCapture) afterUpdate(scope *gorm.Scope) {
+	if !cc.captured(scope) || scope.DB().RowsAffected == 0 {
+		return
+	}
+	after, ok := scope.InstanceGet("gorm:update_attrs")
+	if !ok {
+		after = fieldValues(scope)
+	}
+	cc.capture(scope, RowChange{Operation: ChangeUpdate, Before: instanceImage(scope), After: after.(map[string]interface{})})
+}
+
+// afterDelete captures a delete.
+func (cc *changeCapture) afterDelete(scope *gorm.Scope) {
+	if cc.captured(scope) && scope.DB().RowsAffected > 0 {
+		cc.capture(scope, RowChange{Operation: ChangeDelete, Before: instanceImage(scope)})
+	}
+}
+
+// capture adds change to the change set of the transaction of scope, registering the delivery of the
+// change set after its commit on the first change; outside transaction contexts change is delivered.
+func (cc *changeCapture) capture(scope *gorm.Scope, change RowChange) {
+	change.Table = scope.TableName()
+	if !scope.PrimaryKeyZero() {
+		change.PrimaryKey = scope.PrimaryKeyValue()
+	}
+	value, inTx := scope.Get(transactionContextScopeKey)
+	ctx, bound := uow.ContextFromScope(scope)
+	if !inTx {
+		if !bound {
+			ctx = context.Background()
+		}
+		cc.handler(context.WithoutCancel(ctx), []RowChange{change})
+		return
+	}
+
+	c := value.(*txHandle)
+	if changes := c.changeSets[cc]; changes != nil {
+		*changes = append(*changes, change)
+		return
+	}
+	if !bound {
+		ctx = c.ctx
+	}
+	changes := &[]RowChange{change}
+	if c.changeSets == nil {
+		c.changeSets = map[*changeCapture]*[]RowChange{}
+	}
+	c.changeSets[cc] = changes
+	handlerCtx := context.WithoutCancel(ctx)
+	c.RegisterAfterCommit(func() { cc.handler(handlerCtx, *changes) })
+}
+
+// fieldValues returns the values of the columns of the model of scope, by column name.
+func fieldValues(scope *gorm.Scope) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, field := range scope.Fields() {
+		if field.IsNormal && !field.IsIgnored {
+			values[field.DBName] = field.Field.Interface()
+		}
+	}
+	return values
+}
+
+// instanceImage returns the row image kept by loadBefore; nil if none was loaded.
+func instanceImage(scope *gorm.Scope) map[string]interface{} {
+	if before, ok := scope.InstanceGet(changeBeforeKey); ok {
+		return before.(map[string]interface{})
+	}
+	return nil
+}
+
+// loadRowImage reads the current image of the row of scope by its primary key.
+func loadRowImage(scope *gorm.Scope) (map[string]interface{}, bool) {
+	rows, err := scope.NewDB().Unscoped().Table(scope.TableName()).
+		Where(fmt.Sprintf("%v = ?", scope.Quote(scope.PrimaryKey())), scope.PrimaryKeyValue()).Rows()
+	if scope.Err(err) != nil {
+		return nil, false
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, false
+	}
+	columns, err := rows.Columns()
+	if scope.Err(err) != nil {
+		return nil, false
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if scope.Err(rows.Scan(pointers...)) != nil {
+		return nil, false
+	}
+	image := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if bytes, ok := values[i].([]byte); ok {
+			values[i] = string(bytes)
+		}
+		image[column] = values[i]
+	}
+	return image, true
+}
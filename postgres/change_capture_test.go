@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// capturedProduct is a model whose writes are captured.
+type capturedProduct struct {
+	ID    int
+	Price int
+}
+
+// getTestChangeCapture returns a transaction context whose connection captures the changes of products.
+func getTestChangeCapture(t *testing.T, config ChangeCaptureConfig) (*transactionContext, sqlmock.Sqlmock, *[][]RowChange) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	var sets [][]RowChange
+	RegisterChangeCapture(db, config, func(ctx context.Context, changes []RowChange) { sets = append(sets, changes) })
+	return newTransactionContext(log.FromDefaultContext(), NewDBHolder(db)), mock, &sets
+}
+
+// Test RegisterChangeCapture to verify the ordered change set of a transaction is delivered once after
+// its commit, with the row images before updates and deletes
+func TestRegisterChangeCapture(t *testing.T) {
+	tx, mock, sets := getTestChangeCapture(t, ChangeCaptureConfig{Include: []string{"captured_products"}, Before: true})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "captured_products"`).WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "captured_products"`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "price"}).AddRow(1, 10))
+	mock.ExpectExec(`UPDATE "captured_products" SET "price" = \$1`).WithArgs(12, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "other_rows"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT \* FROM "captured_products"`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "price"}).AddRow(1, 12))
+	mock.ExpectExec(`DELETE FROM "captured_products"`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	product := &capturedProduct{Price: 10}
+	assert.NoError(t, tx.Provider().Create(product).Error)
+	assert.NoError(t, tx.Provider().Model(product).Updates(map[string]interface{}{"price": 12}).Error)
+	assert.NoError(t, tx.Provider().Table("other_rows").Updates(map[string]interface{}{"a": 1}).Error)
+	assert.NoError(t, tx.Provider().Delete(product).Error)
+	assert.Empty(t, *sets)
+	assert.NoError(t, tx.Commit(id))
+
+	assert.Equal(t, [][]RowChange{{
+		{Table: "captured_products", Operation: ChangeInsert, PrimaryKey: 1, After: map[string]interface{}{"id": 1, "price": 10}},
+		{Table: "captured_products", Operation: ChangeUpdate, PrimaryKey: 1,
+			Before: map[string]interface{}{"id": int64(1), "price": int64(10)}, After: map[string]interface{}{"price": 12}},
+		{Table: "captured_products", Operation: ChangeDelete, PrimaryKey: 1, Before: map[string]interface{}{"id": int64(1), "price": int64(12)}},
+	}}, *sets)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test RegisterChangeCapture to verify the changes of a rolled back transaction are discarded and writes
+// outside transaction contexts are delivered right away
+func TestRegisterChangeCapture_Rollback(t *testing.T) {
+	tx, mock, sets := getTestChangeCapture(t, ChangeCaptureConfig{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "captured_products"`).WithArgs(10).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "captured_products"`).WithArgs(20).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Create(&capturedProduct{Price: 10}).Error)
+	assert.NoError(t, tx.Rollback())
+	assert.Empty(t, *sets)
+
+	assert.NoError(t, tx.dbHolder.currentConnection().Create(&capturedProduct{Price: 20}).Error)
+	assert.Equal(t, [][]RowChange{{
+		{Table: "captured_products", Operation: ChangeInsert, PrimaryKey: 2, After: map[string]interface{}{"id": 2, "price": 20}},
+	}}, *sets)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// Sleeper waits between the attempts of the retry loops, such as the connection retries of OpenContext.
+	Sleeper interface {
+		// Sleep waits for d, or returns the error of ctx once ctx is done.
+		Sleep(ctx context.Context, d time.Duration) error
+	}
+
+	// Clock tells the time and waits for the retry loops and the HealthWatchdog, so their backoff can be
+	// tested with a fake clock instead of real delays. SystemClock is used by default.
+	Clock interface {
+		Sleeper
+		Now() time.Time // Now returns the current time.
+	}
+
+	// SystemClock is the Clock of the time package.
+	SystemClock struct{}
+)
+
+// Now implements Clock.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep implements Sleeper.
+func (SystemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// sleepUnlessWoken waits with clock for d, returning early once ctx is done or wake receives a signal.
+func sleepUnlessWoken(ctx context.Context, clock Clock, d time.Duration, wake <-chan struct{}) {
+	sleepCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-wake:
+			cancel()
+		case <-sleepCtx.Done():
+		}
+	}()
+	_ = clock.Sleep(sleepCtx, d)
+}
+
+// WithClock makes OpenContext wait between its connection attempts with clock instead of the time package,
+// e.g. a fake clock advancing instantly in unit tests.
+// Example:
+//
+//	db, err := OpenContext(ctx, &config, WithClock(fakeClock))
+func WithClock(clock Clock) OpenOption {
+	return func(o *openOptions) {
+		o.clock = clock
+	}
+}
+
+// retryClock returns the clock of WithClock, or SystemClock.
+func (c *PgConfig) retryClock() Clock {
+	if c.clock == nil {
+		return SystemClock{}
+	}
+	return c.clock
+}
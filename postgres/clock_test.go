@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose Sleep advances the time instantly and records the durations.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	sleeps []time.Duration
+}
+
+// Now implements Clock.
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep implements Sleeper.
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.sleeps = append(c.sleeps, d)
+	return ctx.Err()
+}
+
+// Sleeps returns the recorded durations.
+func (c *fakeClock) Sleeps() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.sleeps...)
+}
+
+// Test SystemClock.Sleep to verify it returns the error of a done context without waiting
+func TestSystemClock_Sleep(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, SystemClock{}.Sleep(ctx, time.Hour), context.Canceled)
+	assert.NoError(t, SystemClock{}.Sleep(context.Background(), time.Millisecond))
+}
+
+// Test WithClock to verify OpenContext waits between its attempts with the clock
+func TestOpenContext_WithClock(t *testing.T) {
+	clock := &fakeClock{}
+
+	db, err := OpenContext(context.Background(), &PgConfig{
+		Host:         "127.0.0.1",
+		Port:         1,
+		DBName:       "testdb",
+		SSLMode:      "disable",
+		ConnectRetry: ConnectRetryPolicy{MaxAttempts: 3, Backoff: time.Hour, MaxBackoff: 4 * time.Hour, Jitter: -1},
+	}, WithClock(clock))
+
+	assert.ErrorIs(t, err, ErrConnectFailed)
+	assert.Nil(t, db)
+	assert.Equal(t, []time.Duration{time.Hour, 2 * time.Hour}, clock.Sleeps())
+}
+
+// Test the HealthWatchdog with a fake clock to verify the backoff and the time of the health changes
+func TestHealthWatchdog_Clock(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	mock.ExpectPing() // by gorm.Open
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	pingErr := errors.New("connection refused")
+	mock.ExpectPing().WillReturnError(pingErr)
+	mock.ExpectPing().WillReturnError(pingErr)
+	mock.ExpectPing()
+
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	ctx, cancel := context.WithCancel(context.Background())
+	watchdog := NewHealthWatchdog(NewDBHolder(db), HealthWatchdogConfig{
+		Interval: time.Hour,
+		Backoff:  ConnectRetryPolicy{Backoff: time.Second, Jitter: -1},
+		OnChange: func(healthy bool, err error) {
+			if healthy {
+				cancel()
+			}
+		},
+		Clock: clock,
+	})
+
+	watchdog.Run(ctx)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, time.Hour}, clock.Sleeps())
+	assert.True(t, watchdog.State().Healthy)
+	assert.Equal(t, time.Date(2024, 6, 1, 12, 0, 3, 0, time.UTC), watchdog.State().Since)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test the TaskRunner with a fake clock to verify the backoff between the attempts of a failing task
+func TestTaskRunner_Clock(t *testing.T) {
+	clock := &fakeClock{}
+	runner := NewTaskRunner(TaskRunnerConfig{Workers: 1, MaxAttempts: 3, RetryBackoff: time.Hour, Clock: clock})
+
+	runner.submit(queuedTask{name: "failing", ctx: context.Background(), task: func(ctx context.Context) error {
+		return errors.New("unavailable")
+	}})
+
+	assert.NoError(t, runner.Stop(context.Background()))
+	assert.Equal(t, []time.Duration{time.Hour, 2 * time.Hour}, clock.Sleeps())
+}
+
+// Test sleepUnlessWoken to verify a signal ends the wait before the clock has waited the full duration
+func TestSleepUnlessWoken(t *testing.T) {
+	wake := make(chan struct{}, 1)
+	wake <- struct{}{}
+
+	start := time.Now()
+	sleepUnlessWoken(context.Background(), SystemClock{}, time.Hour, wake)
+	assert.Less(t, time.Since(start), time.Minute)
+	assert.Empty(t, wake)
+}
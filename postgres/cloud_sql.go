@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+type (
+	// CloudSQLConfig connects to a GCP Cloud SQL instance through the Cloud SQL Go connector
+	// (cloud.google.com/go/cloudsqlconn) instead of host and port, so no auth proxy sidecar is needed.
+	// The connector encrypts the connection itself, hence SSLMode is ignored.
+	CloudSQLConfig struct {
+		Instance string                                                       // Instance is the instance connection name, "project:region:instance".
+		Dial     func(ctx context.Context, instance string) (net.Conn, error) // Dial opens a connection to the instance, typically cloudsqlconn.Dialer.Dial.
+	}
+
+	// dialerFunc adapts a dial function to the dialer interfaces of lib/pq and pgx.
+	dialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+)
+
+// dialer returns the dial function connections of the configuration must use, or nil for the driver default.
+func (c *PgConfig) dialer() dialerFunc {
+	if c.dial != nil {
+		return c.dial
+	}
+	if c.CloudSQL == nil {
+		return nil
+	}
+	cloudSQL := *c.CloudSQL
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return cloudSQL.Dial(ctx, cloudSQL.Instance)
+	}
+}
+
+// dialConfig returns the configuration the DSN of a connection dialed with the custom dialer is built from.
+func (c *PgConfig) dialConfig() PgConfig {
+	cfg := *c
+	if cfg.CloudSQL != nil && cfg.dial == nil {
+		cfg.SSLMode = "disable" // encrypted by the connector
+	}
+	return cfg
+}
+
+// Dial implements pq.Dialer.
+func (d dialerFunc) Dial(network, address string) (net.Conn, error) {
+	return d(context.Background(), network, address)
+}
+
+// DialTimeout implements pq.Dialer.
+func (d dialerFunc) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d(ctx, network, address)
+}
+
+// DialContext implements pq.DialerContext.
+func (d dialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return d(ctx, network, address)
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// Test that connections to a Cloud SQL instance are dialed through the connector, with both drivers
+func TestOpenContext_CloudSQL(t *testing.T) {
+	for _, driver := range []string{DriverPQ, DriverPgx} {
+		var dialed []string
+		dialErr := errors.New("instance not reachable")
+		db, err := OpenContext(context.Background(), &PgConfig{
+			User:    "service-account@project.iam",
+			DBName:  "billing",
+			SSLMode: "verify-full",
+			Driver:  driver,
+			CloudSQL: &CloudSQLConfig{
+				Instance: "project:europe-west1:billing",
+				Dial: func(ctx context.Context, instance string) (net.Conn, error) {
+					dialed = append(dialed, instance)
+					return nil, dialErr
+				},
+			},
+			ConnectRetry: ConnectRetryPolicy{MaxAttempts: 1},
+		})
+
+		assert.ErrorIs(t, err, ErrConnectFailed, driver)
+		assert.ErrorContains(t, err, dialErr.Error(), driver)
+		assert.Nil(t, db)
+		assert.NotEmpty(t, dialed, driver)
+		assert.Equal(t, "project:europe-west1:billing", dialed[0], driver)
+	}
+}
+
+// Test that the connector takes care of the encryption
+func TestPgConfig_DialConfigCloudSQL(t *testing.T) {
+	cfg := &PgConfig{SSLMode: "verify-full", CloudSQL: &CloudSQLConfig{Instance: "p:r:i"}}
+
+	assert.Equal(t, "disable", cfg.dialConfig().SSLMode)
+	assert.Equal(t, "verify-full", cfg.SSLMode)
+	assert.Nil(t, (&PgConfig{}).dialer())
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDeadlineTooClose is returned by Commit, which rolled the transaction back instead of sending COMMIT,
+// when the deadline of the transaction is closer than the floor of WithCommitDeadlineFloor.
+var ErrDeadlineTooClose = errors.New("deadline too close to commit")
+
+// WithCommitDeadlineFloor makes Commit roll the transaction back and return ErrDeadlineTooClose when less
+// than floor is left until the deadline of the context passed to GetTransactionContext or BeginContext, or
+// the transaction timeout, instead of sending COMMIT. A commit racing the deadline may succeed on the server
+// while the caller times out waiting for the acknowledgment, e.g. charge a payment the caller reports as
+// failed; with a floor covering the commit latency a late unit of work fails unambiguously.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(r.Context(), WithCommitDeadlineFloor(200*time.Millisecond))
+//	err := RunInTransaction(ctx, chargePayment)
+//	if errors.Is(err, ErrDeadlineTooClose) { return http.StatusGatewayTimeout }
+func WithCommitDeadlineFloor(floor time.Duration) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.commitFloor = floor
+	}
+}
+
+// checkCommitDeadline returns ErrDeadlineTooClose when the running transaction may not be committed in time.
+func (c *transactionContext) checkCommitDeadline() error {
+	if c.commitFloor <= 0 {
+		return nil
+	}
+	deadline, ok := c.ctx.Deadline()
+	for _, ctx := range []context.Context{c.beginCtx, c.txCtx} {
+		if ctx == nil {
+			continue
+		}
+		if txDeadline, txOK := ctx.Deadline(); txOK && (!ok || txDeadline.Before(deadline)) {
+			deadline, ok = txDeadline, true
+		}
+	}
+	if !ok {
+		return nil
+	}
+	if remaining := time.Until(deadline); remaining < c.commitFloor {
+		return fmt.Errorf("%w: %s left, at least %s required", ErrDeadlineTooClose,
+			remaining.Round(time.Millisecond), c.commitFloor)
+	}
+	return nil
+}
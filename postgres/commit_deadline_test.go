@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test WithCommitDeadlineFloor to verify a commit too close to the deadline rolls back instead
+func TestWithCommitDeadlineFloor(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithCommitDeadlineFloor(time.Second)(tx)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	tx.ctx = ctx
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, _ := tx.Begin()
+	assert.NoError(t, tx.Commit(id))
+
+	WithTxTimeout(500 * time.Millisecond)(tx)
+	id, _ = tx.Begin()
+	assert.ErrorIs(t, tx.Commit(id), ErrDeadlineTooClose)
+	assert.True(t, tx.wasRollbacked())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,503 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	log "github.com/public-forge/go-logger"
+	"sync"
+)
+
+// CommitMode selects how a CompositeTransactionContext commits its member transactions.
+type CommitMode int
+
+const (
+	// BestEffortCommit commits the members one after another and rolls back the remaining ones when a
+	// commit fails. Members committed before the failure stay committed.
+	BestEffortCommit CommitMode = iota
+	// TwoPhaseCommit prepares every member with PREPARE TRANSACTION before committing any of them, so either
+	// all members commit or none does. It requires max_prepared_transactions > 0 on every server.
+	TwoPhaseCommit
+)
+
+var (
+	// ErrPartialCommit occurs when a composite commit fails after some members have been committed.
+	ErrPartialCommit = errors.New("composite commit failed after some databases committed")
+	// ErrTwoPhaseUnsupported occurs when TwoPhaseCommit is used with members that are not Postgres transaction contexts, or in dry-run mode.
+	ErrTwoPhaseUnsupported = errors.New("two-phase commit requires postgres transaction contexts")
+	// ErrTwoPhaseNotify occurs when a member of a two-phase commit has queued notifications with Notify, as
+	// Postgres cannot prepare a transaction that has executed NOTIFY.
+	ErrTwoPhaseNotify = errors.New("two-phase commit of a transaction with notifications")
+)
+
+// CompositeTransactionContext runs one unit of work across several databases: Begin begins a transaction
+// on every member, Commit commits them together according to its CommitMode, and Rollback rolls all of
+// them back. It implements ITransactionContext; Provider returns the first member's Provider, the others
+// are reached through their own transaction contexts (see GetTransactionContextFor) or Members.
+// Hooks registered on the composite run once for the whole unit of work.
+type CompositeTransactionContext struct {
+	members []ITransactionContext // Transaction contexts of the databases, in commit order.
+	mode    CommitMode            // How the members are committed.
+	logger  log.Logger            // Logs failures of hooks and of the resolution of prepared transactions.
+
+	mu            sync.Mutex                // Guards the fields below.
+	ids           map[uuid.UUID][]uuid.UUID // Member UUIDs returned by each Begin, by composite UUID.
+	owner         *uuid.UUID                // UUID of the outermost Begin.
+	rollbacked    bool                      // Set once the composite has been rolled back.
+	afterCommit   []func()                  // Hooks executed after all members have been committed.
+	beforeCommit  []func() error            // Hooks executed before the members are committed.
+	afterRollback []func()                  // Hooks executed after the members have been rolled back.
+}
+
+var _ ITransactionContext = (*CompositeTransactionContext)(nil)
+
+// NewCompositeTransactionContext creates a CompositeTransactionContext over members, committed in order.
+// The members must not run a transaction when the composite begins.
+func NewCompositeTransactionContext(mode CommitMode, members ...ITransactionContext) *CompositeTransactionContext {
+	return &CompositeTransactionContext{
+		members: members,
+		mode:    mode,
+		logger:  log.FromDefaultContext(),
+		ids:     map[uuid.UUID][]uuid.UUID{},
+	}
+}
+
+// GetCompositeTransactionContext creates a CompositeTransactionContext over the transaction contexts
+// registered under names (see GetTransactionContextFor) and returns it with the context carrying them.
+// Example:
+//
+//	txContext, ctx := GetCompositeTransactionContext(ctx, TwoPhaseCommit, DefaultTransactionName, "billing")
+//	id, err := txContext.Begin()
+//	if err != nil { return err }
+//	defer txContext.Rollback()
+//	// ... write through GetTransactionContext(ctx) and GetTransactionContextFor(ctx, "billing") ...
+//	return txContext.Commit(id)
+func GetCompositeTransactionContext(ctx context.Context, mode CommitMode, names ...string) (*CompositeTransactionContext, context.Context) {
+	members := make([]ITransactionContext, 0, len(names))
+	for _, name := range names {
+		var member ITransactionContext
+		member, ctx = GetTransactionContextFor(ctx, name)
+		members = append(members, member)
+	}
+	composite := NewCompositeTransactionContext(mode, members...)
+	composite.logger = log.FromContext(ctx)
+	return composite, ctx
+}
+
+// Members returns the transaction contexts of the composite, in commit order.
+func (c *CompositeTransactionContext) Members() []ITransactionContext {
+	return c.members
+}
+
+// Begin begins a transaction on every member and returns the UUID of the composite transaction.
+func (c *CompositeTransactionContext) Begin() (uuid.UUID, error) {
+	return c.BeginWithOptions(TxOptions{})
+}
+
+// BeginReadOnly begins a read-only transaction on every member.
+func (c *CompositeTransactionContext) BeginReadOnly() (uuid.UUID, error) {
+	return c.BeginWithOptions(TxOptions{ReadOnly: true})
+}
+
+// BeginWithOptions begins a transaction with opts on every member. If a member fails to begin, the
+// transactions begun by an outermost call are rolled back.
+func (c *CompositeTransactionContext) BeginWithOptions(opts TxOptions) (uuid.UUID, error) {
+	return c.begin(func(member ITransactionContext) (uuid.UUID, error) { return member.BeginWithOptions(opts) })
+}
+
+// BeginContext begins a transaction on every member with BeginContext(ctx), so the composite gives up once
+// ctx is done. If a member fails to begin, the transactions begun by an outermost call are rolled back.
+func (c *CompositeTransactionContext) BeginContext(ctx context.Context) (uuid.UUID, error) {
+	return c.begin(func(member ITransactionContext) (uuid.UUID, error) { return member.BeginContext(ctx) })
+}
+
+// begin begins a transaction on every member with beginMember.
+func (c *CompositeTransactionContext) begin(beginMember func(ITransactionContext) (uuid.UUID, error)) (uuid.UUID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rollbacked {
+		return uuid.Nil, ErrTxWasRollbacked
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	outermost := c.owner == nil
+	memberIDs := make([]uuid.UUID, 0, len(c.members))
+	for _, member := range c.members {
+		memberID, err := beginMember(member)
+		if err != nil {
+			if outermost {
+				c.rollbackMembers(c.members[:len(memberIDs)])
+			}
+			return uuid.Nil, err
+		}
+		memberIDs = append(memberIDs, memberID)
+	}
+
+	c.ids[id] = memberIDs
+	if outermost {
+		c.owner = &id
+	}
+	return id, nil
+}
+
+// Commit commits the member transactions if id is the UUID of the outermost Begin; for nested
+// Begin calls it is a no-op, like for a single transaction context.
+func (c *CompositeTransactionContext) Commit(id uuid.UUID) error {
+	c.mu.Lock()
+	if c.rollbacked {
+		c.mu.Unlock()
+		return ErrTxWasRollbacked
+	}
+	memberIDs, ok := c.ids[id]
+	if !ok || c.owner == nil {
+		c.mu.Unlock()
+		return ErrNotInTransaction
+	}
+	if *c.owner != id {
+		delete(c.ids, id)
+		c.mu.Unlock()
+		return c.commitNested(memberIDs)
+	}
+	beforeCommit := c.beforeCommit
+	c.mu.Unlock()
+
+	for i := 0; i < len(beforeCommit); i++ {
+		if err := beforeCommit[i](); err != nil {
+			_ = c.Rollback()
+			return err
+		}
+		c.mu.Lock()
+		beforeCommit = c.beforeCommit // Hooks may register further hooks.
+		c.mu.Unlock()
+	}
+
+	var err error
+	if c.mode == TwoPhaseCommit {
+		err = c.commitTwoPhase(id, memberIDs)
+	} else {
+		err = c.commitBestEffort(memberIDs)
+	}
+
+	c.mu.Lock()
+	afterCommit, afterRollback := c.afterCommit, c.afterRollback
+	c.dispose()
+	c.rollbacked = err != nil
+	c.mu.Unlock()
+
+	if err != nil {
+		c.runHooks("after-rollback", afterRollback)
+		return err
+	}
+	c.runHooks("after-commit", afterCommit)
+	return nil
+}
+
+// Rollback rolls back all member transactions.
+func (c *CompositeTransactionContext) Rollback() error {
+	c.mu.Lock()
+	if c.rollbacked {
+		c.mu.Unlock()
+		return ErrTxWasRollbacked
+	}
+	if c.owner == nil {
+		c.mu.Unlock()
+		return nil
+	}
+	afterRollback := c.afterRollback
+	err := c.rollbackMembers(c.members)
+	c.dispose()
+	c.rollbacked = true
+	c.mu.Unlock()
+
+	c.runHooks("after-rollback", afterRollback)
+	return err
+}
+
+// Reset rolls back the running transactions, if any, and makes the composite and its members usable again.
+func (c *CompositeTransactionContext) Reset() error {
+	err := c.Rollback()
+	if errors.Is(err, ErrTxWasRollbacked) {
+		err = nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rollbacked = false
+	for _, member := range c.members {
+		if resetErr := member.Reset(); resetErr != nil && err == nil {
+			err = resetErr
+		}
+	}
+	return err
+}
+
+// Provider returns the Provider of the first member.
+func (c *CompositeTransactionContext) Provider() *gorm.DB {
+	if len(c.members) == 0 {
+		return nil
+	}
+	return c.members[0].Provider()
+}
+
+// ProviderWithContext returns the ProviderWithContext of the first member.
+func (c *CompositeTransactionContext) ProviderWithContext(ctx context.Context) *gorm.DB {
+	if len(c.members) == 0 {
+		return nil
+	}
+	return c.members[0].ProviderWithContext(ctx)
+}
+
+// RegisterAfterCommit registers fn to run once all members have been committed.
+// Without an active transaction fn runs immediately.
+func (c *CompositeTransactionContext) RegisterAfterCommit(fn func()) {
+	c.mu.Lock()
+	if c.owner == nil {
+		c.mu.Unlock()
+		c.runHooks("after-commit", []func(){fn})
+		return
+	}
+	c.afterCommit = append(c.afterCommit, fn)
+	c.mu.Unlock()
+}
+
+// RegisterBeforeCommit registers fn to run before the members are committed; an error rolls all of them back.
+// Without an active transaction fn runs immediately and its error is logged.
+func (c *CompositeTransactionContext) RegisterBeforeCommit(fn func() error) {
+	c.mu.Lock()
+	if c.owner == nil {
+		c.mu.Unlock()
+		if err := fn(); err != nil {
+			c.logger.Errorf("before-commit hook failed outside a transaction: %s", err)
+		}
+		return
+	}
+	c.beforeCommit = append(c.beforeCommit, fn)
+	c.mu.Unlock()
+}
+
+// RegisterAfterRollback registers fn to run once the members have been rolled back.
+// It is discarded when the transactions commit.
+func (c *CompositeTransactionContext) RegisterAfterRollback(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.owner == nil {
+		return
+	}
+	c.afterRollback = append(c.afterRollback, fn)
+}
+
+// Complete completes the implicit transactions of members in auto-begin mode, in order.
+func (c *CompositeTransactionContext) Complete() error {
+	for _, member := range c.members {
+		if err := member.Complete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SavePoint sets the savepoint name in the running transaction of every member, in order.
+func (c *CompositeTransactionContext) SavePoint(name string) error {
+	for _, member := range c.members {
+		if err := member.SavePoint(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollbackTo rolls the running transaction of every member back to the savepoint name, in order.
+func (c *CompositeTransactionContext) RollbackTo(name string) error {
+	for _, member := range c.members {
+		if err := member.RollbackTo(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitNested passes a nested Commit on to the members.
+func (c *CompositeTransactionContext) commitNested(memberIDs []uuid.UUID) error {
+	for i, member := range c.members {
+		if err := member.Commit(memberIDs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitBestEffort commits the members in order, rolling back the remaining ones on failure.
+func (c *CompositeTransactionContext) commitBestEffort(memberIDs []uuid.UUID) error {
+	for i, member := range c.members {
+		if err := member.Commit(memberIDs[i]); err != nil {
+			c.rollbackMembers(c.members[i+1:])
+			if i > 0 {
+				return fmt.Errorf("%w: database %d of %d: %w", ErrPartialCommit, i+1, len(c.members), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// commitTwoPhase prepares every member, then commits the prepared transactions. If a member cannot be
+// prepared, the prepared ones are rolled back and the others are rolled back normally. The after-commit
+// hooks of the members run only once every prepared transaction has been committed; the after-rollback
+// hooks run for the members whose transaction was rolled back.
+func (c *CompositeTransactionContext) commitTwoPhase(id uuid.UUID, memberIDs []uuid.UUID) error {
+	members := make([]*transactionContext, 0, len(c.members))
+	for _, member := range c.members {
+		tc, ok := member.(*transactionContext)
+		if !ok || tc.dryRun != nil {
+			c.rollbackMembers(c.members)
+			return ErrTwoPhaseUnsupported
+		}
+		members = append(members, tc)
+	}
+
+	gids := make([]string, len(members))
+	finish := make([]func(committed bool), len(members))
+	for i, member := range members {
+		gids[i] = fmt.Sprintf("uow-%s-%d", id, i)
+		var err error
+		if finish[i], err = member.prepare(memberIDs[i], gids[i]); err != nil {
+			c.resolvePrepared(members[:i+1], gids[:i+1], "ROLLBACK PREPARED")
+			c.rollbackMembers(c.members[i+1:])
+			finishAll(finish[:i], false)
+			return err
+		}
+	}
+
+	for i, member := range members {
+		if err := member.dbHolder.currentConnection().Exec(fmt.Sprintf("COMMIT PREPARED '%s'", gids[i])).Error; err != nil {
+			c.logger.Errorf("cannot commit prepared transaction %s; it must be resolved manually: %s", gids[i], err)
+			if i > 0 {
+				finishAll(finish[:i], true)
+				finishAll(finish[i:], false)
+				return fmt.Errorf("%w: database %d of %d: %w", ErrPartialCommit, i+1, len(c.members), err)
+			}
+			c.resolvePrepared(members[1:], gids[1:], "ROLLBACK PREPARED")
+			finishAll(finish, false)
+			return err
+		}
+	}
+	finishAll(finish, true)
+	return nil
+}
+
+// finishAll reports the outcome of their prepared transactions to the members of a two-phase commit.
+func finishAll(finish []func(committed bool), committed bool) {
+	for _, f := range finish {
+		f(committed)
+	}
+}
+
+// prepare ends the transaction owned by id with PREPARE TRANSACTION gid instead of COMMIT, after running the
+// before-commit hooks. The session's transaction block is then closed and the connection released, while
+// the work stays prepared until COMMIT PREPARED or ROLLBACK PREPARED. The after-commit or after-rollback
+// hooks run when the returned func is called with the outcome of the prepared transaction. Transactions
+// with queued notifications are rolled back, as Postgres cannot prepare a transaction that ran NOTIFY.
+func (c *transactionContext) prepare(id uuid.UUID, gid string) (func(committed bool), error) {
+	if c.wasRollbacked() {
+		return nil, ErrTxWasRollbacked
+	}
+	if !c.inTransaction() {
+		return nil, ErrNotInTransaction
+	}
+	if done, err := c.closeLevel(id); done {
+		if err == nil {
+			err = fmt.Errorf("%w: %v with %d levels open", ErrUnbalancedNesting, id, len(c.levels))
+		}
+		return nil, err
+	}
+	if *c.transactionUUID != id {
+		return nil, ErrNotTransactionOwner
+	}
+
+	if err := c.runBeforeCommit(); err != nil {
+		err = fmt.Errorf("prepare transaction %v: before-commit hook: %w", id, err)
+		_ = c.Rollback()
+		return nil, err
+	}
+	if len(c.notifications) > 0 {
+		_ = c.Rollback()
+		return nil, fmt.Errorf("%w: transaction %v", ErrTwoPhaseNotify, id)
+	}
+	if err := c.checkCommitDeadline(); err != nil {
+		c.logger.Warnf("not preparing transaction %v: %s", c.transactionUUID, err)
+		_ = c.Rollback()
+		return nil, err
+	}
+
+	endPrepare := c.childSpan("uow.prepare")
+	err := c.tx.Exec(fmt.Sprintf("PREPARE TRANSACTION '%s'", gid)).Error
+	endPrepare(err)
+	if err != nil {
+		c.logger.Errorf("cannot prepare transaction %v: %s", c.transactionUUID, err)
+		_ = c.Rollback()
+		return nil, fmt.Errorf("prepare transaction %v: %w", id, err)
+	}
+
+	afterCommit, afterRollback, info, readOnly := c.handle.afterCommit, c.afterRollback, c.txInfo, c.txOptions.ReadOnly
+	// The transaction block is empty after PREPARE TRANSACTION: COMMIT only releases the connection. The
+	// transaction counts as committed until finish says otherwise, so dispose holds its after-rollback hooks.
+	c.txCommitted = true
+	func() {
+		defer c.dispose()
+		if err := c.tx.Commit().Error; err != nil {
+			c.logger.Warnf("cannot release prepared transaction %v: %s", id, err)
+		}
+	}()
+
+	return func(committed bool) {
+		if !committed {
+			c.rollbacked = true
+			c.runHooks("after-rollback", afterRollback)
+			c.runAfterRollbackHooks(info)
+			return
+		}
+		c.captureConsistencyToken()
+		c.stickToPrimary(readOnly)
+		c.runHooks("after-commit", afterCommit)
+	}, nil
+}
+
+// resolvePrepared finishes prepared transactions with statement, logging failures; transactions that
+// were never prepared report an error that is ignored.
+func (c *CompositeTransactionContext) resolvePrepared(members []*transactionContext, gids []string, statement string) {
+	for i, member := range members {
+		if err := member.dbHolder.currentConnection().Exec(fmt.Sprintf("%s '%s'", statement, gids[i])).Error; err != nil {
+			c.logger.Debugf("%s %s: %s", statement, gids[i], err)
+		}
+	}
+}
+
+// rollbackMembers rolls back members, returning the first error.
+func (c *CompositeTransactionContext) rollbackMembers(members []ITransactionContext) error {
+	var first error
+	for _, member := range members {
+		if err := member.Rollback(); err != nil && !errors.Is(err, ErrTxWasRollbacked) && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// dispose clears the state of the finished composite transaction. c.mu must be held.
+func (c *CompositeTransactionContext) dispose() {
+	c.ids = map[uuid.UUID][]uuid.UUID{}
+	c.owner = nil
+	c.afterCommit = nil
+	c.beforeCommit = nil
+	c.afterRollback = nil
+}
+
+// runHooks executes hooks in order, see uow.RunHooks.
+func (c *CompositeTransactionContext) runHooks(kind string, hooks []func()) {
+	uow.RunHooks(c.logger, kind, hooks)
+}
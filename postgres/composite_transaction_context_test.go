@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// getRegexpTransactionContext builds a transaction context backed by go-sqlmock matching queries by regular expression.
+func getRegexpTransactionContext(t *testing.T) (*transactionContext, *gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	assert.NoError(t, err)
+
+	return newTransactionContext(log.FromDefaultContext(), NewDBHolder(gormDB)), gormDB, mock
+}
+
+// Test that a failing best-effort commit rolls back the remaining members and reports the partial commit
+func TestCompositeTransactionContext_BestEffort(t *testing.T) {
+	orders, ordersDB, ordersMock := getTestTransactionContext(t)
+	defer ordersDB.Close()
+	billing, billingDB, billingMock := getTestTransactionContext(t)
+	defer billingDB.Close()
+	composite := NewCompositeTransactionContext(BestEffortCommit, orders, billing)
+	failure := errors.New("connection reset")
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectCommit().WillReturnError(failure)
+
+	var rolledBack bool
+	id, err := composite.Begin()
+	assert.NoError(t, err)
+	nested, err := composite.Begin()
+	assert.NoError(t, err)
+	composite.RegisterAfterRollback(func() { rolledBack = true })
+	assert.NoError(t, composite.Commit(nested))
+
+	err = composite.Commit(id)
+	assert.ErrorIs(t, err, ErrPartialCommit)
+	assert.ErrorIs(t, err, failure)
+	assert.True(t, rolledBack)
+	assert.ErrorIs(t, composite.Commit(id), ErrTxWasRollbacked)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+// Test that a two-phase commit prepares every member before committing the prepared transactions
+func TestCompositeTransactionContext_TwoPhase(t *testing.T) {
+	orders, ordersDB, ordersMock := getRegexpTransactionContext(t)
+	defer ordersDB.Close()
+	billing, billingDB, billingMock := getRegexpTransactionContext(t)
+	defer billingDB.Close()
+	composite := NewCompositeTransactionContext(TwoPhaseCommit, orders, billing)
+
+	for i, mock := range []sqlmock.Sqlmock{ordersMock, billingMock} {
+		gid := `'uow-[0-9a-f-]+-` + string(rune('0'+i)) + `'`
+		mock.ExpectBegin()
+		mock.ExpectExec(`PREPARE TRANSACTION ` + gid).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+	}
+	ordersMock.ExpectExec(`COMMIT PREPARED 'uow-[0-9a-f-]+-0'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectExec(`COMMIT PREPARED 'uow-[0-9a-f-]+-1'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	id, err := composite.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, composite.Commit(id))
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+// Test that a member failing to prepare rolls back the whole two-phase commit
+func TestCompositeTransactionContext_TwoPhasePrepareFailure(t *testing.T) {
+	orders, ordersDB, ordersMock := getRegexpTransactionContext(t)
+	defer ordersDB.Close()
+	billing, billingDB, billingMock := getRegexpTransactionContext(t)
+	defer billingDB.Close()
+	composite := NewCompositeTransactionContext(TwoPhaseCommit, orders, billing)
+	failure := errors.New("prepared transactions are disabled")
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec(`PREPARE TRANSACTION`).WillReturnResult(sqlmock.NewResult(0, 0))
+	ordersMock.ExpectCommit()
+	ordersMock.ExpectExec(`ROLLBACK PREPARED 'uow-[0-9a-f-]+-0'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectBegin()
+	billingMock.ExpectExec(`PREPARE TRANSACTION`).WillReturnError(failure)
+	billingMock.ExpectRollback()
+	billingMock.ExpectExec(`ROLLBACK PREPARED 'uow-[0-9a-f-]+-1'`).WillReturnError(errors.New("does not exist"))
+
+	id, err := composite.Begin()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, composite.Commit(id), failure)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+// Test that the after-commit hooks of the members run only once every prepared transaction has been committed
+func TestCompositeTransactionContext_TwoPhaseMemberHooks(t *testing.T) {
+	orders, ordersDB, ordersMock := getRegexpTransactionContext(t)
+	defer ordersDB.Close()
+	billing, billingDB, billingMock := getRegexpTransactionContext(t)
+	defer billingDB.Close()
+	composite := NewCompositeTransactionContext(TwoPhaseCommit, orders, billing)
+
+	var events []string
+	for i, mock := range []sqlmock.Sqlmock{ordersMock, billingMock} {
+		gid := `'uow-[0-9a-f-]+-` + string(rune('0'+i)) + `'`
+		mock.ExpectBegin()
+		mock.ExpectExec(`PREPARE TRANSACTION ` + gid).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+	}
+	ordersMock.ExpectExec(`COMMIT PREPARED 'uow-[0-9a-f-]+-0'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectExec(`COMMIT PREPARED 'uow-[0-9a-f-]+-1'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	id, err := composite.Begin()
+	assert.NoError(t, err)
+	orders.RegisterAfterCommit(func() { events = append(events, "orders committed") })
+	billing.RegisterAfterCommit(func() { events = append(events, "billing committed") })
+	billing.RegisterAfterRollback(func() { events = append(events, "billing rolled back") })
+	assert.NoError(t, composite.Commit(id))
+	assert.Equal(t, []string{"orders committed", "billing committed"}, events)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+// Test that a failing COMMIT PREPARED of the first member runs the after-rollback hooks of every member
+func TestCompositeTransactionContext_TwoPhaseCommitFailure(t *testing.T) {
+	orders, ordersDB, ordersMock := getRegexpTransactionContext(t)
+	defer ordersDB.Close()
+	billing, billingDB, billingMock := getRegexpTransactionContext(t)
+	defer billingDB.Close()
+	composite := NewCompositeTransactionContext(TwoPhaseCommit, orders, billing)
+	failure := errors.New("connection reset")
+
+	var events []string
+	for i, mock := range []sqlmock.Sqlmock{ordersMock, billingMock} {
+		gid := `'uow-[0-9a-f-]+-` + string(rune('0'+i)) + `'`
+		mock.ExpectBegin()
+		mock.ExpectExec(`PREPARE TRANSACTION ` + gid).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+	}
+	ordersMock.ExpectExec(`COMMIT PREPARED 'uow-[0-9a-f-]+-0'`).WillReturnError(failure)
+	billingMock.ExpectExec(`ROLLBACK PREPARED 'uow-[0-9a-f-]+-1'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	id, err := composite.Begin()
+	assert.NoError(t, err)
+	orders.RegisterAfterCommit(func() { events = append(events, "orders committed") })
+	orders.RegisterAfterRollback(func() { events = append(events, "orders rolled back") })
+	billing.RegisterAfterRollback(func() { events = append(events, "billing rolled back") })
+	assert.ErrorIs(t, composite.Commit(id), failure)
+	assert.Equal(t, []string{"orders rolled back", "billing rolled back"}, events)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+// Test that a member with queued notifications is rolled back instead of prepared
+func TestCompositeTransactionContext_TwoPhaseNotify(t *testing.T) {
+	orders, ordersDB, ordersMock := getRegexpTransactionContext(t)
+	defer ordersDB.Close()
+	billing, billingDB, billingMock := getRegexpTransactionContext(t)
+	defer billingDB.Close()
+	composite := NewCompositeTransactionContext(TwoPhaseCommit, orders, billing)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec(`PREPARE TRANSACTION`).WillReturnResult(sqlmock.NewResult(0, 0))
+	ordersMock.ExpectCommit()
+	ordersMock.ExpectExec(`ROLLBACK PREPARED 'uow-[0-9a-f-]+-0'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectBegin()
+	billingMock.ExpectRollback()
+	billingMock.ExpectExec(`ROLLBACK PREPARED 'uow-[0-9a-f-]+-1'`).WillReturnError(errors.New("does not exist"))
+
+	id, err := composite.Begin()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), TransactionContextKey, billing)
+	assert.NoError(t, Notify(ctx, "invoices", "42"))
+	assert.ErrorIs(t, composite.Commit(id), ErrTwoPhaseNotify)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
@@ -1,14 +1,314 @@
 package postgres
 
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidURL occurs when a connection URL cannot be parsed by ParseConfigFromURL.
+var ErrInvalidURL = errors.New("invalid postgres connection URL")
+
+// defaultPort is the port the driver connects to if none is configured.
+const defaultPort = 5432
+
+// ConnectionConfig is the connection configuration accepted by Open and NewConnect: a *PgConfig, or a
+// postgres:// URL as parsed by ParseConfigFromURL.
+type ConnectionConfig interface {
+	*PgConfig | string
+}
+
 // PgConfig holds the configuration settings required to connect to a PostgreSQL database.
 type PgConfig struct {
-	Host                    string // Host is the database server address (e.g., "localhost" or an IP).
-	DBName                  string // DBName is the name of the specific database to connect to.
-	Schema                  string // Schema specifies the schema within the database (often "public").
-	User                    string // User is the username for authenticating to the database.
-	Password                string // Password is the password for the specified User.
-	MaxOpenConnections      int    // MaxOpenConnections defines the maximum number of open connections allowed to the database.
-	ConnectionMaxLifetimeMS int    // ConnectionMaxLifetimeMS sets the maximum time (in milliseconds) a connection can be reused.
-	LogMode                 bool   // LogMode enables or disables SQL query logging (true for enabled).
-	SSLMode                 string // SSLMode enables or disables SSL connection (e.g., "disable").
+	Host                       string   // Host is the database server address (e.g., "localhost", an IP or the directory of a Unix socket), or several comma-separated ones tried in order.
+	Port                       int      // Port is the database server port; the driver default (5432) is used if 0.
+	Ports                      []int    // Ports, when set, holds the port of every host listed in Host, in order, like a libpq port list; 0 entries use Port.
+	DBName                     string   // DBName is the name of the specific database to connect to.
+	Schema                     string   // Schema specifies the schema within the database (often "public"), or a search_path list such as `tenant_x, public`.
+	SearchPath                 []string // SearchPath, when set, is the search path instead of Schema, e.g. {"tenant_x", "public"}; names are quoted as needed.
+	User                       string   // User is the username for authenticating to the database.
+	Password                   string   // Password is the password for the specified User.
+	MaxOpenConnections         int      // MaxOpenConnections defines the maximum number of open connections allowed to the database.
+	ConnectionMaxLifetimeMS    int      // ConnectionMaxLifetimeMS sets the maximum time (in milliseconds) a connection can be reused.
+	MaxIdleConnections         int      // MaxIdleConnections defines how many idle connections are kept (0 keeps the database/sql default of 2, negative keeps none).
+	ConnectionMaxIdleTimeMS    int      // ConnectionMaxIdleTimeMS closes connections idle for longer than this many milliseconds (0 keeps them).
+	LogMode                    bool     // LogMode enables or disables SQL query logging (true for enabled).
+	SlowQueryMS                int      // SlowQueryMS logs a warning for GORM operations taking at least this many milliseconds (0 disables).
+	RedactColumns              []string // RedactColumns masks the bind parameters of these columns in SQL logs, e.g. {"password_hash", "users.email"}.
+	RedactPatterns             []string // RedactPatterns masks the matches of these regular expressions in logged statements and string parameters, e.g. {`[\w.+-]+@[\w-]+\.[\w.]+`}.
+	SSLMode                    string   // SSLMode selects the SSL mode (e.g., "disable", "require" or "verify-full"); the driver default is "require".
+	SSLRootCert                string   // SSLRootCert is the path of the CA certificate used to verify the server (verify-ca and verify-full).
+	SSLCert                    string   // SSLCert is the path of the client certificate, for certificate authentication.
+	SSLKey                     string   // SSLKey is the path of the private key of SSLCert.
+	ConnectTimeoutSeconds      int      // ConnectTimeoutSeconds limits how long connecting may take (0 waits indefinitely).
+	StatementTimeoutMS         int      // StatementTimeoutMS makes the server cancel statements running longer than this many milliseconds (0 keeps the server setting).
+	IdleInTransactionTimeoutMS int      // IdleInTransactionTimeoutMS makes the server end sessions idle in a transaction for longer than this many milliseconds (0 keeps the server setting).
+	TargetSessionAttrs         string   // TargetSessionAttrs selects the acceptable hosts, e.g. TargetSessionReadWrite to fail over to the new primary.
+	ApplicationName            string   // ApplicationName identifies the service in pg_stat_activity and the server logs.
+	TimeZone                   string   // TimeZone sets the session time zone, e.g. "Europe/Berlin", and converts loaded timestamps into it, see RegisterTimeZone.
+
+	Driver             string              // Driver selects the database/sql driver, DriverPQ (default) or DriverPgx.
+	Params             map[string]string   // Params holds additional connection and session parameters, e.g. {"lock_timeout": "2s"}; they override the fields above.
+	CloudSQL           *CloudSQLConfig     // CloudSQL, when set, dials the Cloud SQL instance through the Cloud SQL Go connector instead of Host and Port.
+	Vault              *VaultConfig        // Vault, when set, opens connections with dynamic credentials of Vault instead of User and Password.
+	Credentials        CredentialsProvider // Credentials, when set, provides the user and password of every new connection instead of User and Password.
+	StatementCacheSize int                 // StatementCacheSize, when positive, caches up to this many prepared statements per connection by SQL text (lib/pq; pgx caches them itself).
+	QueryCache         *QueryCache         // QueryCache, when set, is invalidated by the writes of the connection, see QueryCache.RegisterInvalidation.
+	Encryptor          Encryptor           // Encryptor, when set, encrypts the columns tagged `uow:"encrypt"` at rest, see RegisterEncryption.
+
+	ConnectRetry    ConnectRetryPolicy // ConnectRetry controls the connection attempts of Open; zero values fall back to defaults.
+	EnsureSchema    *SchemaBootstrap   // EnsureSchema, when set, creates Schema if missing and applies its owner and grants right after connecting, see EnsureSchema.
+	StartupChecks   *StartupChecks     // StartupChecks, when set, validates the server environment right after connecting.
+	TxTimeoutMS     int                // TxTimeoutMS rolls back transactions still open after this many milliseconds (0 disables).
+	LongTxWarningMS int                // LongTxWarningMS warns about transactions still open after this many milliseconds (0 disables).
+	LazyConnect     bool               // LazyConnect defers connecting the holders created from the config until their first use, see NewLazyDBHolder.
+	ReadOnly        bool               // ReadOnly marks a replica or other read-only database: writes fail with ErrReadOnlyDatabase before reaching it.
+
+	RejectReadOnlyWrites bool // RejectReadOnlyWrites registers callbacks failing Create/Update/Delete in read-only transactions.
+	TranslateErrors      bool // TranslateErrors registers callbacks translating Postgres errors into the Err* sentinels, see TranslateError.
+	OptimisticLocking    bool // OptimisticLocking registers the version column callbacks, see RegisterOptimisticLocking.
+	AuditFields          bool // AuditFields registers the created_by/updated_by callbacks with uow.ActorFromContext, see uow.RegisterAuditFields.
+
+	dial  dialerFunc // dial is the dialer of WithDialer.
+	clock Clock      // clock is the clock of WithClock.
+}
+
+// DSN returns the key/value connection string of the configuration. Empty settings are left out, so the
+// driver defaults apply; Params are appended in name order. Values with spaces, quotes, backslashes or
+// equals signs, such as generated passwords, are quoted as libpq expects. Parameters the driver does not
+// know, such as application_name or lock_timeout, are sent to the server as session parameters of every
+// connection.
+// Example:
+//
+//	cfg := PgConfig{Host: "db", Port: 6432, DBName: "billing", ApplicationName: "billing", Params: map[string]string{"lock_timeout": "2s"}}
+//	cfg.DSN() // host=db port=6432 dbname=billing application_name=billing lock_timeout=2s
+func (c *PgConfig) DSN() string {
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+dsnValue(value))
+		}
+	}
+	add("host", c.Host)
+	if len(c.Ports) > 0 {
+		ports := make([]string, len(c.Ports))
+		for i := range c.Ports {
+			port := c.hostPort(i)
+			if port == 0 {
+				port = defaultPort
+			}
+			ports[i] = strconv.Itoa(port)
+		}
+		add("port", strings.Join(ports, ","))
+	} else if c.Port > 0 {
+		add("port", strconv.Itoa(c.Port))
+	}
+	add("user", c.User)
+	add("password", c.Password)
+	add("dbname", c.DBName)
+	add("search_path", formatSearchPath(c.searchPath()))
+	add("sslmode", c.SSLMode)
+	add("sslrootcert", c.SSLRootCert)
+	add("sslcert", c.SSLCert)
+	add("sslkey", c.SSLKey)
+	if c.ConnectTimeoutSeconds > 0 {
+		add("connect_timeout", strconv.Itoa(c.ConnectTimeoutSeconds))
+	}
+	add("target_session_attrs", c.TargetSessionAttrs)
+	if c.StatementTimeoutMS > 0 {
+		add("statement_timeout", strconv.Itoa(c.StatementTimeoutMS))
+	}
+	if c.IdleInTransactionTimeoutMS > 0 {
+		add("idle_in_transaction_session_timeout", strconv.Itoa(c.IdleInTransactionTimeoutMS))
+	}
+	add("application_name", c.ApplicationName)
+	add("TimeZone", c.TimeZone)
+
+	keys := make([]string, 0, len(c.Params))
+	for key := range c.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		add(key, c.Params[key])
+	}
+	return strings.Join(parts, " ")
+}
+
+// dsnValue quotes value for a key/value connection string if it contains whitespace, quotes, backslashes
+// or equals signs, escaping quotes and backslashes with a backslash.
+func dsnValue(value string) string {
+	if !strings.ContainsAny(value, " \t\n\r\v\f'\\=") {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// ParseConfigFromURL parses a postgres:// (or postgresql://) URL into a PgConfig. The host, port, sslmode,
+// sslrootcert, sslcert, sslkey, search_path, application_name, TimeZone, connect_timeout, statement_timeout,
+// idle_in_transaction_session_timeout and target_session_attrs query parameters fill the matching fields;
+// any other parameter is kept in Params. Several hosts may be listed, each with its own port, as in
+// postgres://a:5432,[::1]:6432/db; their ports fill Ports unless they are all the same. Pool and GORM
+// settings keep their zero values.
+// Example:
+//
+//	cfg, err := ParseConfigFromURL(os.Getenv("DATABASE_URL"))
+//	if err != nil { return err }
+//	cfg.MaxOpenConnections = 10
+func ParseConfigFromURL(rawURL string) (*PgConfig, error) {
+	rawURL, hostList := cutHostList(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			urlErr.URL = redactURL(urlErr.URL) // do not leak the password into logs
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+
+	cfg := &PgConfig{Host: u.Hostname(), DBName: strings.TrimPrefix(u.Path, "/")}
+	if port := u.Port(); port != "" {
+		if cfg.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("%w: invalid port %q", ErrInvalidURL, port)
+		}
+	}
+	if hostList != "" {
+		var hosts, ports []string
+		for _, hostPort := range strings.Split(hostList, ",") {
+			host, port, err := net.SplitHostPort(hostPort)
+			if err != nil {
+				host, port = strings.Trim(hostPort, "[]"), "" // no port, e.g. db or [::1]
+			}
+			hosts, ports = append(hosts, host), append(ports, port)
+		}
+		cfg.Host = strings.Join(hosts, ",")
+		if err = cfg.setPorts(ports); err != nil {
+			return nil, err
+		}
+	}
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	for key := range query {
+		value := query.Get(key)
+		switch key {
+		case "host":
+			cfg.Host = value // e.g. postgres:///db?host=/var/run/postgresql for a Unix socket
+		case "port":
+			if err = cfg.setPorts(strings.Split(value, ",")); err != nil {
+				return nil, err
+			}
+		case "sslmode":
+			cfg.SSLMode = value
+		case "sslrootcert":
+			cfg.SSLRootCert = value
+		case "sslcert":
+			cfg.SSLCert = value
+		case "sslkey":
+			cfg.SSLKey = value
+		case "search_path":
+			cfg.Schema = value
+		case "target_session_attrs":
+			cfg.TargetSessionAttrs = value
+		case "application_name":
+			cfg.ApplicationName = value
+		case "TimeZone", "timezone":
+			cfg.TimeZone = value
+		case "connect_timeout":
+			if cfg.ConnectTimeoutSeconds, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("%w: invalid connect_timeout %q", ErrInvalidURL, value)
+			}
+		case "statement_timeout":
+			if cfg.StatementTimeoutMS, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("%w: invalid statement_timeout %q", ErrInvalidURL, value)
+			}
+		case "idle_in_transaction_session_timeout":
+			if cfg.IdleInTransactionTimeoutMS, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("%w: invalid idle_in_transaction_session_timeout %q", ErrInvalidURL, value)
+			}
+		default:
+			if cfg.Params == nil {
+				cfg.Params = map[string]string{}
+			}
+			cfg.Params[key] = value
+		}
+	}
+	return cfg, nil
+}
+
+// cutHostList returns rawURL with the host list of its authority, as in postgres://a:5432,[::1]:6432/db,
+// replaced by a single host net/url can parse, and the list; URLs with a single host are returned unchanged.
+func cutHostList(rawURL string) (string, string) {
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		return rawURL, ""
+	}
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	hostsAt := strings.LastIndex(rest[:end], "@") + 1
+	hostList := rest[hostsAt:end]
+	if !strings.Contains(hostList, ",") {
+		return rawURL, ""
+	}
+	return scheme + "://" + rest[:hostsAt] + "multi-host" + rest[end:], hostList
+}
+
+// setPorts sets the ports of the hosts: Port if they are all the same, and Ports otherwise. Empty ports
+// use the driver default.
+func (c *PgConfig) setPorts(ports []string) error {
+	c.Port, c.Ports = 0, make([]int, len(ports))
+	for i, port := range ports {
+		if port == "" {
+			continue
+		}
+		var err error
+		if c.Ports[i], err = strconv.Atoi(port); err != nil {
+			return fmt.Errorf("%w: invalid port %q", ErrInvalidURL, port)
+		}
+	}
+	for _, port := range c.Ports[1:] {
+		if port != c.Ports[0] {
+			return nil
+		}
+	}
+	c.Port, c.Ports = c.Ports[0], nil
+	return nil
+}
+
+// hostPort returns the port of the host at index i of Hosts, or 0 for the driver default.
+func (c *PgConfig) hostPort(i int) int {
+	if i < len(c.Ports) && c.Ports[i] > 0 {
+		return c.Ports[i]
+	}
+	return c.Port
+}
+
+// resolveConfig returns the PgConfig of a ConnectionConfig, parsing URLs.
+func resolveConfig[C ConnectionConfig](config C) (*PgConfig, error) {
+	switch c := any(config).(type) {
+	case string:
+		return ParseConfigFromURL(c)
+	default:
+		return c.(*PgConfig), nil
+	}
+}
+
+// readOnly reports whether the connections accept reads only: PgConfig.ReadOnly is set or only
+// read-only or standby hosts are accepted.
+func (c *PgConfig) readOnly() bool {
+	return c.ReadOnly || c.TargetSessionAttrs == TargetSessionReadOnly || c.TargetSessionAttrs == TargetSessionStandby
 }
@@ -1,24 +1,25 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jinzhu/gorm"
 	_ "github.com/lib/pq"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
 	log "github.com/public-forge/go-logger"
 	"time"
 )
 
-const (
-	// defaultConnectionNumberOfRetries defines the maximum number of connection retries.
-	defaultConnectionNumberOfRetries = 8
-	// defaultConnectionSecondsBetweenRetries defines the delay in seconds between each retry.
-	defaultConnectionSecondsBetweenRetries = 4
-)
+// defaultConnectionNumberOfRetries defines the maximum number of connection attempts when ConnectRetryPolicy.MaxAttempts is not set.
+const defaultConnectionNumberOfRetries = 8
 
-// NewConnect establishes a new connection to the PostgreSQL database using the provided configuration.
-// It retries on failure and panics if connection attempts are exhausted.
-func NewConnect(config *PgConfig) *gorm.DB {
+// NewConnect establishes a new connection to the PostgreSQL database using the provided configuration,
+// a *PgConfig or a postgres:// URL. It retries on failure and panics if connection attempts are exhausted.
+//
+// Deprecated: use OpenContext, which reports failures as errors instead of panicking.
+func NewConnect[C ConnectionConfig](config C) *gorm.DB {
 	logger := log.FromDefaultContext()
 	db, err := Open(config)
 	if err != nil {
@@ -33,53 +34,131 @@ func CheckConnection(db *gorm.DB) {
 	db.Exec("SELECT 1;")
 }
 
-// Open attempts to open a database connection using the provided PgConfig settings.
-// If the connection fails, it will retry based on default retry parameters.
-// On success, it applies SQL and GORM-specific configurations.
-func Open(cfg *PgConfig) (db *gorm.DB, err error) {
+// Open attempts to open a database connection using the provided PgConfig settings, or the settings
+// parsed from a postgres:// URL by ParseConfigFromURL.
+// If the connection fails, it will retry according to PgConfig.ConnectRetry.
+// On success, it applies SQL and GORM-specific configurations and runs the configured startup checks.
+// Once all attempts have failed it terminates the process with logger.Fatalf.
+// Example:
+//
+//	db, err := Open(os.Getenv("DATABASE_URL"))
+//
+// Deprecated: use OpenContext, which reports failures as errors instead of terminating the process.
+func Open[C ConnectionConfig](config C, opts ...OpenOption) (db *gorm.DB, err error) {
+	if db, err = OpenContext(context.Background(), config, opts...); errors.Is(err, ErrConnectFailed) {
+		log.FromDefaultContext().Fatalf("%s", err)
+	}
+	return
+}
+
+// OpenContext is Open stopping the retries, and returning the error of ctx, once ctx is done. It never
+// panics or terminates the process: once all attempts have failed it returns an error matching
+// ErrConnectFailed and wrapping the error of the last attempt.
+// Example:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer stop()
+//	db, err := OpenContext(ctx, &config)
+func OpenContext[C ConnectionConfig](ctx context.Context, config C, opts ...OpenOption) (db *gorm.DB, err error) {
 	logger := log.FromDefaultContext()
-	for retry := 0; retry < defaultConnectionNumberOfRetries; retry++ {
+	cfg, err := resolveConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	cfg = applyOpenOptions(cfg, opts)
+	if err = validateDriver(cfg.Driver); err != nil {
+		return nil, err
+	}
+	if err = cfg.validateSearchPath(); err != nil {
+		return nil, err
+	}
+	location, err := cfg.timeLocation()
+	if err != nil {
+		return nil, err
+	}
+	redactions, err := newRedactionRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	credentials := cfg.credentialsSource()
+	attempts := cfg.ConnectRetry.Attempts()
+	for retry := 0; retry < attempts; retry++ {
 		logger.Infof("Connecting to postgres %s@%s... (retry %d of %d)",
-			cfg.DBName, cfg.Host, retry, defaultConnectionNumberOfRetries)
+			cfg.DBName, cfg.Host, retry, attempts)
 
-		db, err = gorm.Open("postgres", fmt.Sprintf(`
-			host=%s
-			user=%s
-			password=%s
-			dbname=%s
-			search_path=%s
-			sslmode=%s
-		`, cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Schema, cfg.SSLMode))
+		db, err = openGORM(cfg, credentials)
 
 		// Log and retry on failure
 		if err != nil {
+			err = RedactError(err, cfg.Password)
 			logger.Errorf("Connecting to postgres %s@%s FAILED: %s",
 				cfg.DBName, cfg.Host, err)
 
-			time.Sleep(defaultConnectionSecondsBetweenRetries * time.Second)
+			if retry < attempts-1 {
+				if err = waitForRetry(ctx, cfg.retryClock(), cfg.ConnectRetry, retry); err != nil {
+					return nil, err
+				}
+			}
 			continue
 		}
-		db.SetLogger(logger)
+		db.SetLogger(newRedactingLogger(logger, redactions))
 		// Log on successful connection
 		logger.Infof("Successfully connected to postgres %s@%s", cfg.DBName, cfg.Host)
 
 		// Apply database settings
 		setSQLSettings(db.DB(), cfg)
+		limitConnLifetime(db.DB(), cfg, credentials)
 		setGORMSettings(db, cfg)
+		if location != nil {
+			RegisterTimeZone(db, location)
+		}
+
+		// Prepare and validate the server environment before handing out the connection
+		if err = ensureConfiguredSchema(db, cfg); err == nil {
+			err = runConfiguredStartupChecks(db, cfg)
+		}
+		if err != nil {
+			_ = db.Close()
+			db = nil
+		}
 
 		return
 	}
-	logger.Fatalf("Connecting to postgres %s@%s FAILED", cfg.DBName, cfg.Host)
-	return
+	return nil, fmt.Errorf("%w: %s@%s after %d attempts: %w", ErrConnectFailed, cfg.DBName, cfg.Host, attempts, err)
 }
 
-// setGORMSettings configures GORM-specific settings, such as enabling or disabling log mode.
+// setGORMSettings configures GORM-specific settings, such as log mode and the read-only callbacks.
 func setGORMSettings(db *gorm.DB, pgConfig *PgConfig) {
 	db.LogMode(pgConfig.LogMode)
+	if pgConfig.RejectReadOnlyWrites {
+		RegisterReadOnlyCallbacks(db)
+	}
+	if pgConfig.readOnly() {
+		RegisterStrictReadOnly(db)
+	}
+	if pgConfig.TranslateErrors {
+		RegisterErrorTranslation(db)
+	}
+	if pgConfig.OptimisticLocking {
+		RegisterOptimisticLocking(db)
+	}
+	if pgConfig.AuditFields {
+		uow.RegisterAuditFields(db, nil)
+	}
+	if pgConfig.QueryCache != nil {
+		pgConfig.QueryCache.RegisterInvalidation(db)
+	}
+	if pgConfig.Encryptor != nil {
+		RegisterEncryption(db, pgConfig.Encryptor)
+	}
 }
 
-// setSQLSettings applies SQL settings, including max open connections and connection lifetime.
+// setSQLSettings applies SQL settings, including max open and idle connections and connection lifetimes.
 func setSQLSettings(db *sql.DB, pgConfig *PgConfig) {
 	db.SetMaxOpenConns(pgConfig.MaxOpenConnections)
 	db.SetConnMaxLifetime(time.Duration(pgConfig.ConnectionMaxLifetimeMS) * time.Millisecond)
+	if pgConfig.MaxIdleConnections != 0 {
+		db.SetMaxIdleConns(pgConfig.MaxIdleConnections)
+	}
+	db.SetConnMaxIdleTime(time.Duration(pgConfig.ConnectionMaxIdleTimeMS) * time.Millisecond)
 }
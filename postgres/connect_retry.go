@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// defaultConnectRetryBackoff defines the delay before the first connection retry when ConnectRetryPolicy.Backoff is not set.
+	defaultConnectRetryBackoff = time.Second
+	// defaultConnectRetryMaxBackoff caps the connection retry delay when ConnectRetryPolicy.MaxBackoff is not set.
+	defaultConnectRetryMaxBackoff = 16 * time.Second
+	// defaultConnectRetryJitter defines the randomized share of the delay when ConnectRetryPolicy.Jitter is not set.
+	defaultConnectRetryJitter = 0.5
+)
+
+// ErrConnectFailed occurs when OpenContext has used up all connection attempts of the ConnectRetryPolicy.
+var ErrConnectFailed = errors.New("cannot connect to postgres")
+
+// ConnectRetryPolicy describes how often and how long Open and OpenContext keep trying to connect.
+// Zero values fall back to defaults.
+type ConnectRetryPolicy struct {
+	MaxAttempts int           // MaxAttempts is the total number of connection attempts (8 by default).
+	Backoff     time.Duration // Backoff is the delay before the first retry; it doubles on each attempt (1s by default).
+	MaxBackoff  time.Duration // MaxBackoff caps the delay between attempts (16s by default).
+	Jitter      float64       // Jitter is the share of each delay that is randomized, between 0 and 1 (0.5 by default); negative disables it.
+}
+
+// Attempts returns the total number of connection attempts.
+func (p ConnectRetryPolicy) Attempts() int {
+	if p.MaxAttempts <= 0 {
+		return defaultConnectionNumberOfRetries
+	}
+	return p.MaxAttempts
+}
+
+// Delay returns how long to wait after the given failed attempt, counted from 0: the exponential backoff,
+// capped by MaxBackoff, reduced by a random share of up to Jitter so that restarting instances spread out.
+func (p ConnectRetryPolicy) Delay(attempt int) time.Duration {
+	backoff, maxBackoff, jitter := p.Backoff, p.MaxBackoff, p.Jitter
+	if backoff <= 0 {
+		backoff = defaultConnectRetryBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultConnectRetryMaxBackoff
+	}
+	if jitter == 0 {
+		jitter = defaultConnectRetryJitter
+	}
+
+	delay := backoff
+	for i := 0; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	if jitter > 0 {
+		delay -= time.Duration(rand.Float64() * min(jitter, 1) * float64(delay))
+	}
+	return delay
+}
+
+// waitForRetry waits with sleeper for the delay after the given failed attempt, or returns the error of ctx
+// once it is done.
+func waitForRetry(ctx context.Context, sleeper Sleeper, policy ConnectRetryPolicy, attempt int) error {
+	return sleeper.Sleep(ctx, policy.Delay(attempt))
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test Delay to verify the backoff doubles and is capped
+func TestConnectRetryPolicy_Delay(t *testing.T) {
+	policy := ConnectRetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: -1}
+
+	assert.Equal(t, 100*time.Millisecond, policy.Delay(0))
+	assert.Equal(t, 200*time.Millisecond, policy.Delay(1))
+	assert.Equal(t, 800*time.Millisecond, policy.Delay(3))
+	assert.Equal(t, time.Second, policy.Delay(4))
+	assert.Equal(t, time.Second, policy.Delay(50))
+}
+
+// Test Delay to verify the jitter only shortens the delay, by up to its share
+func TestConnectRetryPolicy_DelayJitter(t *testing.T) {
+	policy := ConnectRetryPolicy{Backoff: time.Second, Jitter: 0.25}
+
+	for i := 0; i < 100; i++ {
+		delay := policy.Delay(2)
+		assert.LessOrEqual(t, delay, 4*time.Second)
+		assert.GreaterOrEqual(t, delay, 3*time.Second)
+	}
+}
+
+// Test the zero policy to verify the defaults apply
+func TestConnectRetryPolicy_Defaults(t *testing.T) {
+	var policy ConnectRetryPolicy
+
+	assert.Equal(t, defaultConnectionNumberOfRetries, policy.Attempts())
+	assert.LessOrEqual(t, policy.Delay(10), defaultConnectRetryMaxBackoff)
+	assert.GreaterOrEqual(t, policy.Delay(10), defaultConnectRetryMaxBackoff/2)
+}
+
+// Test OpenContext to verify the retries stop once the context is cancelled
+func TestOpenContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	db, err := OpenContext(ctx, &PgConfig{
+		Host:         "127.0.0.1",
+		Port:         1,
+		DBName:       "testdb",
+		SSLMode:      "disable",
+		ConnectRetry: ConnectRetryPolicy{MaxAttempts: 3, Backoff: time.Hour},
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, db)
+}
+
+// Test OpenContext to verify exhausted attempts are reported as an error instead of terminating the process
+func TestOpenContext_AttemptsExhausted(t *testing.T) {
+	db, err := OpenContext(context.Background(), &PgConfig{
+		Host:         "127.0.0.1",
+		Port:         1,
+		DBName:       "testdb",
+		SSLMode:      "disable",
+		ConnectRetry: ConnectRetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+	})
+
+	assert.ErrorIs(t, err, ErrConnectFailed)
+	assert.ErrorContains(t, err, "after 2 attempts")
+	assert.Nil(t, db)
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// defaultReplayPollInterval defines how often WaitForReplay checks the replica's replay position.
+const defaultReplayPollInterval = 50 * time.Millisecond
+
+// ConsistencyToken identifies a point in the primary's WAL (an LSN such as "0/16B3748").
+// A replica that has replayed past the token observes every transaction committed before it was taken.
+type ConsistencyToken string
+
+// ReplayOption configures how WaitForReplay polls the replica.
+type ReplayOption func(*replayConfig)
+
+// replayConfig collects the ReplayOption settings.
+type replayConfig struct {
+	clock        Clock         // Clock waiting between the polls.
+	pollInterval time.Duration // Time between two polls of the replay position.
+}
+
+// WithReplayClock makes WaitForReplay wait between its polls with clock instead of SystemClock,
+// e.g. a fake clock advancing instantly in unit tests.
+func WithReplayClock(clock Clock) ReplayOption {
+	return func(c *replayConfig) {
+		c.clock = clock
+	}
+}
+
+// WithReplayPollInterval sets how often WaitForReplay checks the replay position, 50ms by default.
+func WithReplayPollInterval(interval time.Duration) ReplayOption {
+	return func(c *replayConfig) {
+		c.pollInterval = interval
+	}
+}
+
+// WithConsistencyTokens makes the transaction context capture pg_current_wal_lsn() after every
+// successful commit. The token of the last commit is returned by GetConsistencyToken.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithConsistencyTokens())
+//	// ... Begin / Commit ...
+//	token, _ := GetConsistencyToken(ctx)
+//	w.Header().Set("X-Consistency-Token", string(token))
+func WithConsistencyTokens() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.trackConsistency = true
+	}
+}
+
+// GetConsistencyToken returns the token captured after the last commit of the transaction context stored in ctx.
+// The second result is false if no token has been captured.
+func GetConsistencyToken(ctx context.Context) (ConsistencyToken, bool) {
+	c, ok := ctx.Value(TransactionContextKey).(*transactionContext)
+	if !ok || c.consistencyToken == "" {
+		return "", false
+	}
+	return c.consistencyToken, true
+}
+
+// CurrentConsistencyToken returns the current WAL insert position of the server behind db.
+func CurrentConsistencyToken(db *gorm.DB) (ConsistencyToken, error) {
+	var lsn string
+	if err := db.Raw("SELECT pg_current_wal_lsn()::text").Row().Scan(&lsn); err != nil {
+		return "", err
+	}
+	return ConsistencyToken(lsn), nil
+}
+
+// WaitForReplay blocks until the replica behind db has replayed past token or ctx is done.
+// A server that is not in recovery (a primary) is considered caught up immediately.
+// Example:
+//
+//	if err := WaitForReplay(ctx, replicaDB, token); err != nil { return err }
+//	replicaDB.Find(&orders)
+func WaitForReplay(ctx context.Context, db *gorm.DB, token ConsistencyToken, opts ...ReplayOption) error {
+	config := replayConfig{clock: SystemClock{}, pollInterval: defaultReplayPollInterval}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	for {
+		var replayed bool
+		err := db.Raw("SELECT COALESCE(pg_last_wal_replay_lsn() >= ?::pg_lsn, true)", string(token)).Row().Scan(&replayed)
+		if err != nil {
+			return err
+		}
+		if replayed {
+			return nil
+		}
+
+		if err := config.clock.Sleep(ctx, config.pollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// captureConsistencyToken records the WAL position after a successful commit.
+// Failures are logged and leave the previous token untouched, the commit itself already succeeded.
+func (c *transactionContext) captureConsistencyToken() {
+	if !c.trackConsistency {
+		return
+	}
+	token, err := CurrentConsistencyToken(c.providerWithoutTransaction())
+	if err != nil {
+		c.logger.Warnf("cannot capture consistency token after commit: %s", err)
+		return
+	}
+	c.consistencyToken = token
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test that the WAL position is captured after commit
+func TestConsistencyToken_CapturedAfterCommit(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	tx := newTransactionContext(base.logger, base.dbHolder, WithConsistencyTokens())
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT pg_current_wal_lsn()::text").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_current_wal_lsn"}).AddRow("0/16B3748"))
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+
+	token, ok := GetConsistencyToken(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, ConsistencyToken("0/16B3748"), token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test WaitForReplay polls until the replica has caught up
+func TestWaitForReplay(t *testing.T) {
+	_, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	query := "SELECT COALESCE(pg_last_wal_replay_lsn() >= $1::pg_lsn, true)"
+	mock.ExpectQuery(query).WithArgs("0/16B3748").WillReturnRows(sqlmock.NewRows([]string{"replayed"}).AddRow(false))
+	mock.ExpectQuery(query).WithArgs("0/16B3748").WillReturnRows(sqlmock.NewRows([]string{"replayed"}).AddRow(true))
+
+	assert.NoError(t, WaitForReplay(context.Background(), db, "0/16B3748"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test WaitForReplay to verify it waits between its polls with the clock and stops once ctx is done
+func TestWaitForReplay_Clock(t *testing.T) {
+	_, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	clock := &fakeClock{}
+
+	query := "SELECT COALESCE(pg_last_wal_replay_lsn() >= $1::pg_lsn, true)"
+	for _, replayed := range []bool{false, false, true} {
+		mock.ExpectQuery(query).WithArgs("0/16B3748").WillReturnRows(sqlmock.NewRows([]string{"replayed"}).AddRow(replayed))
+	}
+	assert.NoError(t, WaitForReplay(context.Background(), db, "0/16B3748", WithReplayClock(clock), WithReplayPollInterval(time.Second)))
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, clock.Sleeps())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mock.ExpectQuery(query).WithArgs("0/16B3748").WillReturnRows(sqlmock.NewRows([]string{"replayed"}).AddRow(false))
+	assert.ErrorIs(t, WaitForReplay(ctx, db, "0/16B3748", WithReplayClock(clock)), context.Canceled)
+	assert.Equal(t, defaultReplayPollInterval, clock.Sleeps()[2])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type (
+	// CredentialsProvider returns the user and password of a new physical connection. It is consulted every
+	// time the pool opens a connection, so secrets rotated by the platform are picked up by new connections
+	// while established ones keep working until they are recycled.
+	// Example:
+	//
+	//	config.Credentials = func(ctx context.Context) (string, string, error) {
+	//	  secret, err := secrets.Get(ctx, "billing-db")
+	//	  return secret.User, secret.Password, err
+	//	}
+	CredentialsProvider func(ctx context.Context) (user, password string, err error)
+
+	// credentialsSource provides the user and password of every new physical connection.
+	credentialsSource interface {
+		credentials(ctx context.Context) (user, password string, err error)
+	}
+
+	// expiringCredentials is a credentialsSource whose credentials expire, so connections opened with them
+	// must not be reused for longer than maxConnLifetime.
+	expiringCredentials interface {
+		maxConnLifetime() time.Duration
+	}
+)
+
+// credentialsSource returns the source of per-connection credentials of the configuration, or nil if the
+// connections use User and Password.
+func (c *PgConfig) credentialsSource() credentialsSource {
+	if c.Credentials != nil {
+		return c.Credentials
+	}
+	if c.Vault != nil {
+		return newVaultCredentials(*c.Vault)
+	}
+	return nil
+}
+
+// credentials implements credentialsSource.
+func (p CredentialsProvider) credentials(ctx context.Context) (string, string, error) {
+	return p(ctx)
+}
+
+// limitConnLifetime shortens the lifetime of the connections of db below the expiry of their credentials.
+func limitConnLifetime(db *sql.DB, cfg *PgConfig, source credentialsSource) {
+	if lifetime := connLifetimeLimit(cfg, source); lifetime > 0 {
+		db.SetConnMaxLifetime(lifetime)
+	}
+}
+
+// connLifetimeLimit returns the connection lifetime required by the credentials of source, or 0 if the
+// configured lifetime is short enough.
+func connLifetimeLimit(cfg *PgConfig, source credentialsSource) time.Duration {
+	expiring, ok := source.(expiringCredentials)
+	if !ok {
+		return 0
+	}
+	lifetime := expiring.maxConnLifetime()
+	configured := time.Duration(cfg.ConnectionMaxLifetimeMS) * time.Millisecond
+	if lifetime > 0 && (configured <= 0 || configured > lifetime) {
+		return lifetime
+	}
+	return 0
+}
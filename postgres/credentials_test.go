@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test opening a connection with a CredentialsProvider to verify it is consulted and its errors are returned
+func TestOpenContext_CredentialsProvider(t *testing.T) {
+	for _, driver := range []string{DriverPQ, DriverPgx} {
+		var calls int
+		providerErr := errors.New("secret not found")
+		db, err := OpenContext(context.Background(), &PgConfig{
+			Host:    "127.0.0.1",
+			Port:    1,
+			SSLMode: "disable",
+			Driver:  driver,
+			Credentials: func(ctx context.Context) (string, string, error) {
+				calls++
+				return "", "", providerErr
+			},
+			ConnectRetry: ConnectRetryPolicy{MaxAttempts: 1},
+		})
+
+		assert.ErrorIs(t, err, ErrConnectFailed, driver)
+		assert.ErrorContains(t, err, providerErr.Error(), driver)
+		assert.Nil(t, db)
+		assert.Equal(t, 1, calls, driver)
+	}
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"sync/atomic"
+)
+
+// defaultCursorChunkSize is the number of rows fetched at once when OpenCursor gets no chunk size.
+const defaultCursorChunkSize = 1000
+
+// cursorSequence numbers the server-side cursors so their names are unique within a session.
+var cursorSequence atomic.Uint64
+
+// Cursor streams the rows of a query through a server-side cursor, fetching chunkSize rows at a time so
+// memory stays bounded however large the result is. It lives in the transaction it was opened in and is
+// released by Postgres when that transaction ends; Close releases it earlier.
+type Cursor struct {
+	db        *gorm.DB  // Transactional Provider() the cursor was declared on.
+	name      string    // Name of the server-side cursor.
+	chunkSize int       // Number of rows per FETCH.
+	rows      *sql.Rows // Rows of the current chunk.
+	fetched   int       // Number of rows read from the current chunk.
+	done      bool      // Whether the last chunk has been fetched.
+	closed    bool      // Whether Close has been called.
+	err       error     // First error met while iterating.
+}
+
+// OpenCursor declares a cursor for query in the running transaction of the transaction context in ctx.
+// The query uses ? placeholders for args. Outside a transaction it fails with ErrNotInTransaction.
+// A chunkSize <= 0 fetches 1000 rows at a time.
+// Example:
+//
+//	cursor, err := OpenCursor(ctx, 500, "SELECT id, total FROM orders WHERE created_at < ?", cutoff)
+//	if err != nil { return err }
+//	defer cursor.Close()
+//	for cursor.Next() {
+//	  var order Order
+//	  if err := cursor.ScanRow(&order); err != nil { return err }
+//	  export(order)
+//	}
+//	return cursor.Err()
+func OpenCursor(ctx context.Context, chunkSize int, query string, args ...interface{}) (*Cursor, error) {
+	txContext, _ := GetTransactionContext(ctx)
+	if c, ok := txContext.(*transactionContext); ok && !c.wasRollbacked() && !c.inTransaction() {
+		return nil, ErrNotInTransaction
+	}
+	db := txContext.ProviderWithContext(ctx)
+	if db == nil {
+		return nil, ErrTxWasRollbacked
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultCursorChunkSize
+	}
+
+	name := fmt.Sprintf("uow_cursor_%d", cursorSequence.Add(1))
+	if err := db.Exec("DECLARE "+name+" NO SCROLL CURSOR FOR "+query, args...).Error; err != nil {
+		return nil, err
+	}
+	return &Cursor{db: db, name: name, chunkSize: chunkSize}, nil
+}
+
+// Next prepares the next row for Scan or ScanRow, fetching the next chunk when the current one is read.
+// It returns false when the rows are exhausted or an error occurred; check Err to tell them apart.
+func (c *Cursor) Next() bool {
+	if c.err != nil || c.closed {
+		return false
+	}
+	for {
+		if c.rows != nil {
+			if c.rows.Next() {
+				c.fetched++
+				return true
+			}
+			if err := c.rows.Err(); err != nil {
+				c.err = err
+				return false
+			}
+			_ = c.rows.Close()
+			c.rows = nil
+			// A short chunk is the last one; saves a FETCH returning no rows.
+			c.done = c.fetched < c.chunkSize
+		}
+		if c.done {
+			return false
+		}
+
+		rows, err := c.db.Raw(fmt.Sprintf("FETCH FORWARD %d FROM %s", c.chunkSize, c.name)).Rows()
+		if err != nil {
+			c.err = err
+			return false
+		}
+		c.rows = rows
+		c.fetched = 0
+	}
+}
+
+// Scan copies the columns of the current row into dest, like (*sql.Rows).Scan.
+func (c *Cursor) Scan(dest ...interface{}) error {
+	if c.rows == nil {
+		return sql.ErrNoRows
+	}
+	return c.rows.Scan(dest...)
+}
+
+// ScanRow scans the current row into a model, like (*gorm.DB).ScanRows.
+func (c *Cursor) ScanRow(model interface{}) error {
+	if c.rows == nil {
+		return sql.ErrNoRows
+	}
+	return c.db.ScanRows(c.rows, model)
+}
+
+// Err returns the error met while iterating, if any.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Close releases the chunk being read and the server-side cursor. It is safe to call more than once.
+func (c *Cursor) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if c.rows != nil {
+		_ = c.rows.Close()
+		c.rows = nil
+	}
+	return c.db.Exec("CLOSE " + c.name).Error
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that a cursor streams all rows chunk by chunk inside the transaction
+func TestCursor(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	cursorSequence.Store(0)
+
+	_, err := OpenCursor(ctx, 2, "SELECT id FROM orders")
+	assert.ErrorIs(t, err, ErrNotInTransaction)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DECLARE uow_cursor_1 NO SCROLL CURSOR FOR SELECT id FROM orders WHERE status = $1`).
+		WithArgs("open").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`FETCH FORWARD 2 FROM uow_cursor_1`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectQuery(`FETCH FORWARD 2 FROM uow_cursor_1`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(`CLOSE uow_cursor_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	cursor, err := OpenCursor(ctx, 2, "SELECT id FROM orders WHERE status = ?", "open")
+	assert.NoError(t, err)
+
+	var ids []int
+	for cursor.Next() {
+		var order struct{ ID int }
+		assert.NoError(t, cursor.ScanRow(&order))
+		ids = append(ids, order.ID)
+	}
+	assert.NoError(t, cursor.Err())
+	assert.NoError(t, cursor.Close())
+	assert.NoError(t, cursor.Close())
+	assert.NoError(t, tx.Commit(id))
+
+	assert.Equal(t, []int{1, 2, 3}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attributes the OpenTelemetry API of dd-trace-go maps to the fields of Datadog spans.
+const (
+	datadogServiceAttribute   = attribute.Key("service.name")
+	datadogOperationAttribute = attribute.Key("operation.name")
+	datadogResourceAttribute  = attribute.Key("resource.name")
+	datadogSpanTypeAttribute  = attribute.Key("span.type")
+)
+
+// datadogResources are the Datadog resource names of the spans of WithTracing other than statements.
+var datadogResources = map[string]string{"uow.begin": "BEGIN", "uow.commit": "COMMIT", "uow.rollback": "ROLLBACK"}
+
+// WithDatadogTracing emits the spans of WithTracing for Datadog APM: provider is the OpenTelemetry
+// TracerProvider of dd-trace-go, and the spans carry the Datadog service, operation ("postgres.transaction",
+// "postgres.begin", "postgres.query", ...), resource (the statement, BEGIN, COMMIT, ROLLBACK or the
+// transaction name) and the sql span type, so they show up like the spans of the dd-trace-go integrations.
+// Example:
+//
+//	provider := ddotel.NewTracerProvider() // gopkg.in/DataDog/dd-trace-go.v1/ddtrace/opentelemetry
+//	defer provider.Shutdown()
+//	txContext, ctx := GetTransactionContext(r.Context(), WithDatadogTracing(provider, "orders-db"))
+func WithDatadogTracing(provider trace.TracerProvider, service string) TransactionContextOption {
+	return func(c *transactionContext) {
+		WithTracing(provider)(c)
+		c.datadogService = service
+	}
+}
+
+// datadogAttributes returns the Datadog attributes of the span named name with the given resource,
+// or the default resource of the span if it is empty; none without WithDatadogTracing.
+func (c *transactionContext) datadogAttributes(name, resource string) []attribute.KeyValue {
+	return datadogSpanAttributes(c.datadogService, c.txOptions.Name, name, resource)
+}
+
+// datadogSpanAttributes returns the Datadog attributes of the span named name of service, for a transaction
+// named txName; none without a service.
+func datadogSpanAttributes(service, txName, name, resource string) []attribute.KeyValue {
+	if service == "" {
+		return nil
+	}
+	operation := "postgres." + name[len("uow."):]
+	switch {
+	case name == "uow.statement":
+		operation = "postgres.query"
+	case resource != "":
+	case name == "uow.transaction" && txName != "":
+		resource = txName
+	case datadogResources[name] != "":
+		resource = datadogResources[name]
+	default:
+		resource = operation
+	}
+	return []attribute.KeyValue{
+		datadogServiceAttribute.String(service),
+		datadogOperationAttribute.String(operation),
+		datadogResourceAttribute.String(resource),
+		datadogSpanTypeAttribute.String("sql"),
+	}
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+// Test WithDatadogTracing to verify the spans carry the Datadog service, operation, resource and span type
+func TestWithDatadogTracing(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	recorder := tracetest.NewSpanRecorder()
+	WithDatadogTracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), "orders-db")(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "orders"`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectRollback()
+
+	_, err := tx.BeginWithOptions(TxOptions{Name: "cleanup"})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Exec(`DELETE FROM "orders"`).Error)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	resources := map[string]string{}
+	for _, span := range recorder.Ended() {
+		assert.Contains(t, span.Attributes(), datadogServiceAttribute.String("orders-db"))
+		assert.Contains(t, span.Attributes(), datadogSpanTypeAttribute.String("sql"))
+		attributes := map[attribute.Key]string{}
+		for _, attr := range span.Attributes() {
+			attributes[attr.Key] = attr.Value.Emit()
+		}
+		resources[attributes[datadogOperationAttribute]] = attributes[datadogResourceAttribute]
+	}
+	assert.Equal(t, map[string]string{
+		"postgres.begin":       "BEGIN",
+		"postgres.query":       `DELETE FROM "orders"`,
+		"postgres.rollback":    "ROLLBACK",
+		"postgres.transaction": "cleanup",
+	}, resources)
+}
@@ -1,8 +1,13 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
 	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	// driver for postgres
 	_ "github.com/lib/pq"
@@ -10,26 +15,180 @@ import (
 
 // NewDBHolderInstance initializes and returns a singleton instance of DatabaseHolder.
 // It ensures that only one instance of DatabaseHolder is created, even in concurrent contexts.
+//
+// Deprecated: use NewDBHolder or NewTransactionContextFactoryFromConfig, which do not share process-wide state.
 func NewDBHolderInstance(config *PgConfig) *DatabaseHolder {
+	holderMu.Lock()
+	defer holderMu.Unlock()
 	onceDBHolder.Do(func() {
-		connect := NewConnect(config)   // Establishes a new database connection.
-		dbHolder = NewDBHolder(connect) // Creates a new DatabaseHolder with the connection.
+		dbHolder = newDBHolderFromConfig(config)
 	})
 
 	return dbHolder
 }
 
+// newDBHolderFromConfig connects to the database described by config and wraps the connection.
+// With PgConfig.LazyConnect the connection is only established on first use.
+func newDBHolderFromConfig(config *PgConfig) *DatabaseHolder {
+	var holder *DatabaseHolder
+	if config.LazyConnect {
+		holder = NewLazyDBHolder(func() (*gorm.DB, error) { return OpenContext(context.Background(), config) })
+	} else {
+		connect := NewConnect(config) // Establishes a new database connection.
+		holder = NewDBHolder(connect) // Creates a new DatabaseHolder with the connection.
+	}
+	holder.settings = newHolderSettings(config)
+	holder.logRedactions, _ = newRedactionRules(config) // Invalid patterns fail OpenContext.
+	holder.RegisterCallbacks(holder.registerSlowQueryLog)
+	if config.LongTxWarningMS > 0 {
+		holder.WarnLongTransactions(time.Duration(config.LongTxWarningMS)*time.Millisecond, nil)
+	}
+	return holder
+}
+
 var (
 	dbHolder     *DatabaseHolder // Singleton instance of DatabaseHolder
 	onceDBHolder sync.Once       // Ensures single initialization of dbHolder
+	holderMu     sync.Mutex      // Guards dbHolder and onceDBHolder against ResetDBHolderForTesting
 )
 
 // DatabaseHolder wraps a gorm.DB database connection, providing a centralized way to access it.
 type DatabaseHolder struct {
-	dbConnection *gorm.DB // Holds the actual database connection.
+	dbConnection  *gorm.DB                 // Holds the actual database connection; nil until a lazy holder has connected.
+	connect       func() (*gorm.DB, error) // Establishes the connection of a lazy holder on first use.
+	connectMu     sync.Mutex               // Guards dbConnection of a lazy holder, and callbacks.
+	callbacks     []func(*gorm.DB)         // Functions of RegisterCallbacks, run for every connection of the holder.
+	shuttingDown  atomic.Bool              // Set by Shutdown; new transactions are rejected with ErrShuttingDown.
+	unhealthy     atomic.Bool              // Set by a HealthWatchdog while the database cannot be pinged.
+	settingsMu    sync.RWMutex             // Guards settings.
+	settings      holderSettings           // Settings of the configuration that Reload may change at runtime.
+	logRedactions redactionRules           // Mirrors PgConfig.RedactColumns and RedactPatterns so observed transactions keep masking their logs.
+
+	txObservers        txObservers        // Observers notified about the lifecycle of the holder's transactions.
+	activeTransactions activeTransactions // Running transactions reported by TxStats.
 }
 
 // NewDBHolder creates a new DatabaseHolder with the given gorm.DB connection.
 func NewDBHolder(db *gorm.DB) *DatabaseHolder {
-	return &DatabaseHolder{db} // Initializes DatabaseHolder with the provided db connection.
+	if db != nil {
+		registerHolderCallbacks(db)
+	}
+	return &DatabaseHolder{dbConnection: db} // Initializes DatabaseHolder with the provided db connection.
+}
+
+// NewDBHolderFromDB creates a DatabaseHolder around an existing connection, e.g. one opened over
+// go-sqlmock in tests, as NewDBHolder does. Unlike GetTransactionContext, the transaction contexts of
+// NewTransactionContextFactory(holder) never touch the singleton holder.
+// Example:
+//
+//	sqlDB, mock, _ := sqlmock.New()
+//	db, _ := gorm.Open("postgres", sqlDB)
+//	factory := NewTransactionContextFactory(NewDBHolderFromDB(db))
+func NewDBHolderFromDB(db *gorm.DB) *DatabaseHolder {
+	return NewDBHolder(db)
+}
+
+// NewLazyDBHolder creates a DatabaseHolder that calls connect on first use, i.e. the first Provider() or
+// Begin() of one of its transaction contexts, instead of right away. If connect fails, the call using the
+// holder fails and the next one tries again.
+// Example:
+//
+//	holder := NewLazyDBHolder(func() (*gorm.DB, error) { return OpenContext(context.Background(), &config) })
+func NewLazyDBHolder(connect func() (*gorm.DB, error)) *DatabaseHolder {
+	return &DatabaseHolder{connect: connect}
+}
+
+// registerHolderCallbacks registers the callbacks every connection of a holder relies on.
+func registerHolderCallbacks(db *gorm.DB) {
+	uow.RegisterContextCallbacks(db) // Enables ITransactionContext.ProviderWithContext.
+	registerLockErrorTranslation(db) // Reports ErrLockNotAvailable for LockForUpdate and LockForShare.
+	registerSoftDelete(db)           // Handles `uow:"soft_delete"` columns and OnlyDeleted.
+	registerHookCallbacks(db)        // Runs the BeforeQuery and AfterQuery hooks of transaction contexts.
+	registerExplain(db)              // Logs the plans of slow statements, see WithExplain.
+	registerAutoBegin(db)            // Begins the transactions of WithAutoBegin on the first write.
+}
+
+// connection returns the connection of the holder, connecting a lazy holder on first use.
+func (h *DatabaseHolder) connection() (*gorm.DB, error) {
+	if h.connect == nil {
+		return h.dbConnection, nil
+	}
+	h.connectMu.Lock()
+	defer h.connectMu.Unlock()
+	if h.dbConnection == nil {
+		db, err := h.connect()
+		if err != nil {
+			return nil, err
+		}
+		registerHolderCallbacks(db)
+		h.applyCallbacks(db)
+		h.applyReloadedPool(db)
+		h.dbConnection = db
+	}
+	return h.dbConnection, nil
+}
+
+// currentConnection returns the connection of the holder without connecting a lazy holder; it is nil
+// until the lazy holder has connected.
+func (h *DatabaseHolder) currentConnection() *gorm.DB {
+	if h.connect == nil {
+		return h.dbConnection
+	}
+	h.connectMu.Lock()
+	defer h.connectMu.Unlock()
+	return h.dbConnection
+}
+
+// Close closes the database connection of the holder.
+func (h *DatabaseHolder) Close() error {
+	db := h.currentConnection()
+	if db == nil {
+		return nil
+	}
+	return db.Close()
+}
+
+// SQLDB returns the *sql.DB of the connection pool of the holder, e.g. for custom health checks, or nil if
+// the holder has no connection, as a lazy holder before it has connected.
+func (h *DatabaseHolder) SQLDB() *sql.DB {
+	db := h.currentConnection()
+	if db == nil {
+		return nil
+	}
+	return db.DB()
+}
+
+// sendsPipelines reports whether the pool of the holder sends the batches of ExecBatch as pgx pipelines.
+func (h *DatabaseHolder) sendsPipelines() bool {
+	sqlDB := h.SQLDB()
+	if sqlDB == nil {
+		return false
+	}
+	_, ok := sqlDB.Driver().(pipelineDriver)
+	return ok
+}
+
+// Stats returns the statistics of the connection pool of the holder; they are zero without a connection.
+func (h *DatabaseHolder) Stats() sql.DBStats {
+	sqlDB := h.SQLDB()
+	if sqlDB == nil {
+		return sql.DBStats{}
+	}
+	return sqlDB.Stats()
+}
+
+// ResetDBHolderForTesting closes the singleton created by NewDBHolderInstance and forgets it,
+// so the next call connects again, e.g. with a different DbConfig. It is meant for integration
+// tests and must not be called while transactions are running.
+func ResetDBHolderForTesting() error {
+	holderMu.Lock()
+	defer holderMu.Unlock()
+
+	var err error
+	if dbHolder != nil {
+		err = dbHolder.Close()
+	}
+	dbHolder = nil
+	onceDBHolder = sync.Once{}
+	return err
 }
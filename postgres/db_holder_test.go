@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that ResetDBHolderForTesting closes the singleton and allows a new one to be created
+func TestResetDBHolderForTesting(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+
+	onceDBHolder.Do(func() { dbHolder = NewDBHolder(db) })
+	assert.Same(t, db, NewDBHolderInstance(nil).dbConnection)
+
+	mock.ExpectClose()
+	assert.NoError(t, ResetDBHolderForTesting())
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Nil(t, dbHolder)
+
+	onceDBHolder.Do(func() {})
+	assert.NoError(t, ResetDBHolderForTesting())
+}
+
+// Test that a lazy holder connects on first use only, and tries again after a failed attempt
+func TestNewLazyDBHolder(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+
+	calls := 0
+	connectErr := errors.New("connection refused")
+	holder := NewLazyDBHolder(func() (*gorm.DB, error) {
+		calls++
+		if calls == 1 {
+			return nil, connectErr
+		}
+		return db, nil
+	})
+	txContext := newTransactionContext(log.FromDefaultContext(), holder)
+	assert.Equal(t, 0, calls)
+	assert.NoError(t, holder.Close())
+
+	_, err = txContext.Begin()
+	assert.ErrorIs(t, err, connectErr)
+	assert.False(t, txContext.inTransaction())
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, err := txContext.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, txContext.Commit(id))
+	assert.Same(t, db, txContext.Provider())
+	assert.Equal(t, 2, calls)
+
+	mock.ExpectClose()
+	assert.NoError(t, holder.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test RegisterCallbacks to verify the functions run once per connection, on connect for a lazy holder
+func TestDatabaseHolder_RegisterCallbacks(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+
+	var registered []*gorm.DB
+	holder := NewLazyDBHolder(func() (*gorm.DB, error) { return db, nil })
+	holder.RegisterCallbacks(func(db *gorm.DB) { registered = append(registered, db) })
+	assert.Empty(t, registered)
+
+	txContext := newTransactionContext(log.FromDefaultContext(), holder)
+	assert.Same(t, db, txContext.Provider())
+	assert.Equal(t, []*gorm.DB{db}, registered)
+	assert.Same(t, db, txContext.Provider())
+	assert.Len(t, registered, 1)
+
+	NewTransactionContextFactory(holder).RegisterCallbacks(func(db *gorm.DB) { registered = append(registered, db) })
+	assert.Equal(t, []*gorm.DB{db, db}, registered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test SQLDB and Stats to verify the pool of the holder is exposed, and a lazy holder is not connected for it
+func TestDatabaseHolder_SQLDB(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	holder := NewDBHolder(db)
+	assert.Same(t, sqlDB, holder.SQLDB())
+	assert.Equal(t, sqlDB.Stats(), holder.Stats())
+
+	lazy := NewLazyDBHolder(func() (*gorm.DB, error) { return db, nil })
+	assert.Nil(t, lazy.SQLDB())
+	assert.Zero(t, lazy.Stats())
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"time"
+)
+
+// sqlStateUndefinedTable is reported when pg_stat_statements is queried without the extension.
+const sqlStateUndefinedTable = "42P01"
+
+const (
+	// topQueriesQuery selects the statements of the current database with the highest total execution time.
+	topQueriesQuery = `SELECT COALESCE(queryid, 0), query, calls, total_exec_time, mean_exec_time, rows,
+	shared_blks_hit, shared_blks_read
+FROM pg_stat_statements
+WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+ORDER BY total_exec_time DESC
+LIMIT $1`
+
+	// blockedSessionsQuery selects the sessions of the current database waiting for a lock held by others.
+	blockedSessionsQuery = `SELECT pid, pg_blocking_pids(pid), COALESCE(usename, ''), application_name, COALESCE(state, ''),
+	COALESCE(wait_event_type, ''), COALESCE(wait_event, ''), query,
+	COALESCE(EXTRACT(EPOCH FROM now() - query_start), 0)
+FROM pg_stat_activity
+WHERE datname = current_database() AND cardinality(pg_blocking_pids(pid)) > 0
+ORDER BY query_start`
+
+	// sessionStatesQuery counts the client sessions of the current database by state.
+	sessionStatesQuery = `SELECT COALESCE(state, ''), count(*)
+FROM pg_stat_activity
+WHERE datname = current_database() AND backend_type = 'client backend'
+GROUP BY 1`
+)
+
+// ErrStatStatementsUnavailable occurs when the pg_stat_statements extension is not installed in the database.
+var ErrStatStatementsUnavailable = errors.New("pg_stat_statements is not installed")
+
+type (
+	// Diagnostics is a snapshot of the statistics of the database of a holder, for admin and debug endpoints.
+	Diagnostics struct {
+		TakenAt         time.Time        // TakenAt is the time the snapshot was taken.
+		TopQueries      []QueryStat      // TopQueries are the statements with the highest total execution time; nil without pg_stat_statements.
+		BlockedSessions []BlockedSession // BlockedSessions are the sessions waiting for locks, longest waiting first.
+		SessionStates   map[string]int   // SessionStates counts the client sessions by state, e.g. "active" or "idle in transaction".
+	}
+
+	// QueryStat holds the statistics of pg_stat_statements for a normalized statement.
+	QueryStat struct {
+		QueryID          int64         // QueryID identifies the normalized statement.
+		Query            string        // Query is the normalized statement text.
+		Calls            int64         // Calls is the number of executions.
+		TotalTime        time.Duration // TotalTime is the time spent executing the statement.
+		MeanTime         time.Duration // MeanTime is the mean execution time.
+		Rows             int64         // Rows is the number of rows retrieved or affected.
+		SharedBlocksHit  int64         // SharedBlocksHit is the number of shared buffer hits.
+		SharedBlocksRead int64         // SharedBlocksRead is the number of shared blocks read from disk or the OS cache.
+	}
+
+	// BlockedSession is a session of pg_stat_activity waiting for locks held by other sessions.
+	BlockedSession struct {
+		PID           int           // PID is the process ID of the session.
+		BlockingPIDs  []int         // BlockingPIDs are the process IDs of the sessions holding the awaited locks.
+		User          string        // User is the user of the session.
+		Application   string        // Application is the application_name of the session.
+		State         string        // State is the state of the session, e.g. "active".
+		WaitEventType string        // WaitEventType is the type of the awaited event, e.g. "Lock".
+		WaitEvent     string        // WaitEvent is the awaited event, e.g. "transactionid".
+		Query         string        // Query is the statement of the session.
+		QueryTime     time.Duration // QueryTime is the time since the statement started.
+	}
+)
+
+// Diagnostics takes a snapshot of pg_stat_statements and pg_stat_activity for the database of the
+// holder, with the topQueries statements of the highest total execution time. Without the
+// pg_stat_statements extension, TopQueries is nil and the rest of the snapshot is returned.
+// Example:
+//
+//	http.HandleFunc("/admin/database", func(w http.ResponseWriter, r *http.Request) {
+//	  diagnostics, err := holder.Diagnostics(r.Context(), 20)
+//	  if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+//	  _ = json.NewEncoder(w).Encode(diagnostics)
+//	})
+func (h *DatabaseHolder) Diagnostics(ctx context.Context, topQueries int) (Diagnostics, error) {
+	diagnostics := Diagnostics{TakenAt: time.Now()}
+	var err error
+	if diagnostics.TopQueries, err = h.TopQueries(ctx, topQueries); err != nil && !errors.Is(err, ErrStatStatementsUnavailable) {
+		return Diagnostics{}, err
+	}
+	if diagnostics.BlockedSessions, err = h.BlockedSessions(ctx); err != nil {
+		return Diagnostics{}, err
+	}
+	if diagnostics.SessionStates, err = h.sessionStates(ctx); err != nil {
+		return Diagnostics{}, err
+	}
+	return diagnostics, nil
+}
+
+// Diagnostics takes a snapshot of the statistics of the database of the factory's holder, see
+// DatabaseHolder.Diagnostics.
+func (f *TransactionContextFactory) Diagnostics(ctx context.Context, topQueries int) (Diagnostics, error) {
+	return f.DBHolder().Diagnostics(ctx, topQueries)
+}
+
+// TopQueries returns the limit statements of the database of the holder with the highest total execution
+// time, according to pg_stat_statements. It fails with ErrStatStatementsUnavailable without the extension.
+func (h *DatabaseHolder) TopQueries(ctx context.Context, limit int) ([]QueryStat, error) {
+	db, err := h.connection()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.DB().QueryContext(ctx, topQueriesQuery, limit)
+	if SQLState(err) == sqlStateUndefinedTable {
+		return nil, fmt.Errorf("%w: %w", ErrStatStatementsUnavailable, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := []QueryStat{}
+	for rows.Next() {
+		var stat QueryStat
+		var total, mean float64
+		if err := rows.Scan(&stat.QueryID, &stat.Query, &stat.Calls, &total, &mean, &stat.Rows,
+			&stat.SharedBlocksHit, &stat.SharedBlocksRead); err != nil {
+			return nil, err
+		}
+		stat.TotalTime, stat.MeanTime = milliseconds(total), milliseconds(mean)
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+// BlockedSessions returns the sessions of the database of the holder waiting for locks held by other
+// sessions, longest waiting first.
+func (h *DatabaseHolder) BlockedSessions(ctx context.Context) ([]BlockedSession, error) {
+	db, err := h.connection()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.DB().QueryContext(ctx, blockedSessionsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []BlockedSession{}
+	for rows.Next() {
+		var session BlockedSession
+		var blocking pq.Int64Array
+		var application sql.NullString
+		var seconds float64
+		if err := rows.Scan(&session.PID, &blocking, &session.User, &application, &session.State,
+			&session.WaitEventType, &session.WaitEvent, &session.Query, &seconds); err != nil {
+			return nil, err
+		}
+		for _, pid := range blocking {
+			session.BlockingPIDs = append(session.BlockingPIDs, int(pid))
+		}
+		session.Application = application.String
+		session.QueryTime = time.Duration(seconds * float64(time.Second))
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// sessionStates counts the client sessions of the database of the holder by state.
+func (h *DatabaseHolder) sessionStates(ctx context.Context) (map[string]int, error) {
+	db, err := h.connection()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.DB().QueryContext(ctx, sessionStatesQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	states := map[string]int{}
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		states[state] = count
+	}
+	return states, rows.Err()
+}
+
+// milliseconds converts the milliseconds of the statistics views to a time.Duration.
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
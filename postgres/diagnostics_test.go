@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test Diagnostics to verify the statistics views are reported as structured results
+func TestDatabaseHolder_Diagnostics(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectQuery(topQueriesQuery).WithArgs(5).WillReturnRows(sqlmock.NewRows(
+		[]string{"queryid", "query", "calls", "total_exec_time", "mean_exec_time", "rows", "shared_blks_hit", "shared_blks_read"}).
+		AddRow(42, "SELECT * FROM orders WHERE id = $1", 1000, 2500.0, 2.5, 1000, 900, 100))
+	mock.ExpectQuery(blockedSessionsQuery).WillReturnRows(sqlmock.NewRows(
+		[]string{"pid", "pg_blocking_pids", "usename", "application_name", "state", "wait_event_type", "wait_event", "query", "seconds"}).
+		AddRow(101, "{100,99}", "app", nil, "active", "Lock", "transactionid", "UPDATE orders SET status = $1", 1.5))
+	mock.ExpectQuery(sessionStatesQuery).WillReturnRows(sqlmock.NewRows([]string{"state", "count"}).
+		AddRow("active", 2).AddRow("idle in transaction", 1))
+
+	diagnostics, err := tx.dbHolder.Diagnostics(context.Background(), 5)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []QueryStat{{QueryID: 42, Query: "SELECT * FROM orders WHERE id = $1", Calls: 1000, TotalTime: 2500 * time.Millisecond,
+		MeanTime: 2500 * time.Microsecond, Rows: 1000, SharedBlocksHit: 900, SharedBlocksRead: 100}}, diagnostics.TopQueries)
+	assert.Equal(t, []BlockedSession{{PID: 101, BlockingPIDs: []int{100, 99}, User: "app", State: "active", WaitEventType: "Lock",
+		WaitEvent: "transactionid", Query: "UPDATE orders SET status = $1", QueryTime: 1500 * time.Millisecond}}, diagnostics.BlockedSessions)
+	assert.Equal(t, map[string]int{"active": 2, "idle in transaction": 1}, diagnostics.SessionStates)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Diagnostics to verify the snapshot is taken without pg_stat_statements
+func TestDatabaseHolder_Diagnostics_WithoutStatStatements(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectQuery(topQueriesQuery).WithArgs(5).WillReturnError(&pq.Error{Code: "42P01", Message: `relation "pg_stat_statements" does not exist`})
+	mock.ExpectQuery(blockedSessionsQuery).WillReturnRows(sqlmock.NewRows([]string{"pid"}))
+	mock.ExpectQuery(sessionStatesQuery).WillReturnRows(sqlmock.NewRows([]string{"state", "count"}))
+
+	diagnostics, err := tx.dbHolder.Diagnostics(context.Background(), 5)
+
+	assert.NoError(t, err)
+	assert.Nil(t, diagnostics.TopQueries)
+	assert.Empty(t, diagnostics.BlockedSessions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(topQueriesQuery).WithArgs(5).WillReturnError(&pq.Error{Code: "42P01"})
+	_, err = tx.dbHolder.TopQueries(context.Background(), 5)
+	assert.ErrorIs(t, err, ErrStatStatementsUnavailable)
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+)
+
+// DryRunReport collects the statements executed by a dry-run transaction context.
+type DryRunReport struct {
+	mu         sync.Mutex  // Guards statements.
+	statements []Statement // Statements recorded in execution order.
+}
+
+// WithDryRun executes every transaction of the context as usual but rolls it back instead of committing.
+// The statements and row counts that would have been applied are available through GetDryRunReport.
+// Only work performed between Begin() and Commit() is previewed; statements issued outside a
+// transaction reach the database as usual.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithDryRun())
+//	err := deleteInactiveCustomers(ctx)
+//	report, _ := GetDryRunReport(ctx)
+//	logger.Infof("would affect %d rows", report.RowsAffected())
+func WithDryRun() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.dryRun = &DryRunReport{}
+	}
+}
+
+// GetDryRunReport returns the report of the dry-run transaction context stored in ctx.
+// The second result is false if ctx holds no transaction context or it is not in dry-run mode.
+func GetDryRunReport(ctx context.Context) (*DryRunReport, bool) {
+	c, ok := ctx.Value(TransactionContextKey).(*transactionContext)
+	if !ok || c.dryRun == nil {
+		return nil, false
+	}
+	return c.dryRun, true
+}
+
+// Statements returns a copy of the recorded statements.
+func (r *DryRunReport) Statements() []Statement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Statement(nil), r.statements...)
+}
+
+// RowsAffected returns the total number of rows the recorded statements affected.
+func (r *DryRunReport) RowsAffected() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var total int64
+	for _, stmt := range r.statements {
+		total += stmt.RowsAffected
+	}
+	return total
+}
+
+// record appends a statement to the report.
+func (r *DryRunReport) record(stmt Statement) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, stmt)
+}
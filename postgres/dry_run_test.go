@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that a dry-run context rolls back on Commit and reports the executed statements
+func TestDryRun_RollsBackAndReports(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	tx := newTransactionContext(base.logger, base.dbHolder, WithDryRun())
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM customers WHERE inactive").WillReturnResult(sqlmock.NewResult(0, 7))
+	mock.ExpectRollback()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Exec("DELETE FROM customers WHERE inactive").Error)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	report, ok := GetDryRunReport(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), report.RowsAffected())
+	assert.Len(t, report.Statements(), 1)
+	assert.Equal(t, "DELETE FROM customers WHERE inactive", report.Statements()[0].SQL)
+}
@@ -0,0 +1,294 @@
+package postgres
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	"reflect"
+	"strings"
+)
+
+const (
+	// encryptTag marks a string or []byte column encrypted at rest: `uow:"encrypt"`.
+	encryptTag = "encrypt"
+	// plaintextScopeKey holds the plaintext values of the fields encrypted for a write, to restore them after it.
+	plaintextScopeKey = "uow:plaintext"
+	// ciphertextPrefix starts the values written by AESGCMEncryptor, followed by the key ID and the sealed data.
+	ciphertextPrefix = "enc:v1:"
+)
+
+// ErrInvalidCiphertext occurs when an encrypted column holds a value that was not written by the Encryptor,
+// or that cannot be authenticated with its key.
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+type (
+	// Encryptor encrypts and decrypts the values of the columns tagged `uow:"encrypt"`, see RegisterEncryption.
+	// Ciphertexts must be valid UTF-8 to be stored in text columns.
+	Encryptor interface {
+		Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+		Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+	}
+
+	// KeyProvider provides the data keys of an AESGCMEncryptor, e.g. decrypted with a KMS and cached. Keys
+	// are 16, 24 or 32 bytes long, for AES-128, AES-192 or AES-256; their IDs must not contain colons.
+	KeyProvider interface {
+		// CurrentKey returns the key new values are encrypted with, and its ID.
+		CurrentKey(ctx context.Context) (id string, key []byte, err error)
+		// Key returns the key with the given ID, for values encrypted before a key rotation.
+		Key(ctx context.Context, id string) ([]byte, error)
+	}
+
+	// StaticKeys is a KeyProvider with keys known in advance, e.g. loaded from a secret store at startup.
+	StaticKeys struct {
+		Current string            // Current is the ID of the key new values are encrypted with.
+		Keys    map[string][]byte // Keys maps the key IDs to the keys.
+	}
+
+	// AESGCMEncryptor encrypts with AES-GCM and a random nonce, under the current key of its KeyProvider.
+	// Ciphertexts are written as "enc:v1:<key ID>:<base64 of nonce and sealed data>", so values encrypted
+	// with rotated keys remain readable as long as the provider knows them.
+	AESGCMEncryptor struct {
+		keys KeyProvider
+	}
+)
+
+// CurrentKey implements KeyProvider.
+func (k StaticKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	key, err := k.Key(ctx, k.Current)
+	return k.Current, key, err
+}
+
+// Key implements KeyProvider.
+func (k StaticKeys) Key(_ context.Context, id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %q", id)
+	}
+	return key, nil
+}
+
+// NewAESGCMEncryptor creates an AESGCMEncryptor using the keys of keys.
+// Example:
+//
+//	encryptor := postgres.NewAESGCMEncryptor(postgres.StaticKeys{Current: "2024-06", Keys: map[string][]byte{"2024-06": key}})
+func NewAESGCMEncryptor(keys KeyProvider) *AESGCMEncryptor {
+	return &AESGCMEncryptor{keys: keys}
+}
+
+// Encrypt implements Encryptor.
+func (e *AESGCMEncryptor) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	id, key, err := e.keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(id, ":") {
+		return nil, fmt.Errorf("encryption key ID %q contains a colon", id)
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return []byte(ciphertextPrefix + id + ":" + base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// Decrypt implements Encryptor.
+func (e *AESGCMEncryptor) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(string(ciphertext), ciphertextPrefix), ":")
+	if !ok || !strings.HasPrefix(string(ciphertext), ciphertextPrefix) {
+		return nil, fmt.Errorf("%w: missing %s prefix", ErrInvalidCiphertext, ciphertextPrefix)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	key, err := e.keys.Key(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidCiphertext)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+	return plaintext, nil
+}
+
+// newGCM returns AES-GCM for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RegisterEncryption registers GORM callbacks on db encrypting the string and []byte fields tagged
+// `uow:"encrypt"` with encryptor when they are written, and decrypting them when they are queried, so PII
+// is encrypted at rest without code in the repositories. The model keeps the plaintext after a write;
+// empty values are stored as they are. As ciphertexts are randomized, encrypted columns cannot be used in
+// conditions, and raw Exec and Raw statements bypass the callbacks.
+// It is applied automatically by Open when PgConfig.Encryptor is set.
+// Example:
+//
+//	type Customer struct {
+//	  ID    int
+//	  Email string `uow:"encrypt"`
+//	}
+func RegisterEncryption(db *gorm.DB, encryptor Encryptor) {
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:create").Register("uow:encrypt_create", encryptFields(encryptor))
+	callbacks.Create().After("gorm:create").Register("uow:restore_plaintext_create", restorePlaintext)
+	callbacks.Update().Before("gorm:update").Register("uow:encrypt_update", encryptFields(encryptor))
+	callbacks.Update().After("gorm:update").Register("uow:restore_plaintext_update", restorePlaintext)
+	callbacks.Query().After("gorm:query").Register("uow:decrypt_query", decryptFields(encryptor))
+}
+
+// encryptFields returns the callback encrypting the tagged fields of the written model and the tagged
+// columns of the attributes of an update, keeping the plaintext for restorePlaintext.
+func encryptFields(encryptor Encryptor) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		if scope.HasError() {
+			return
+		}
+		ctx := encryptionContext(scope)
+		plaintext := map[*gorm.Field]interface{}{}
+		scope.InstanceSet(plaintextScopeKey, plaintext)
+		for _, field := range encryptedFields(scope) {
+			value, err := encryptValue(ctx, encryptor, field.Field.Interface())
+			if scope.Err(err) != nil {
+				return
+			}
+			plaintext[field] = field.Field.Interface()
+			_ = field.Set(value)
+		}
+
+		attrs, ok := scope.InstanceGet("gorm:update_attrs")
+		if !ok {
+			return
+		}
+		encrypted := map[string]interface{}{}
+		for column, value := range attrs.(map[string]interface{}) {
+			if field, ok := scope.FieldByName(column); ok && field.Tag.Get("uow") == encryptTag {
+				var err error
+				if value, err = encryptValue(ctx, encryptor, value); scope.Err(err) != nil {
+					return
+				}
+			}
+			encrypted[column] = value
+		}
+		scope.InstanceSet("gorm:update_attrs", encrypted)
+	}
+}
+
+// restorePlaintext puts the plaintext kept by encryptFields back into the written model.
+func restorePlaintext(scope *gorm.Scope) {
+	plaintext, ok := scope.InstanceGet(plaintextScopeKey)
+	if !ok {
+		return
+	}
+	for field, value := range plaintext.(map[*gorm.Field]interface{}) {
+		_ = field.Set(value)
+	}
+}
+
+// decryptFields returns the callback decrypting the tagged fields of the queried models.
+func decryptFields(encryptor Encryptor) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		if scope.HasError() {
+			return
+		}
+		ctx := encryptionContext(scope)
+		decrypt := func(model *gorm.Scope) {
+			for _, field := range encryptedFields(model) {
+				value, err := decryptValue(ctx, encryptor, field.Field.Interface())
+				if scope.Err(err) != nil {
+					return
+				}
+				_ = field.Set(value)
+			}
+		}
+		value := scope.IndirectValue()
+		if value.Kind() != reflect.Slice {
+			decrypt(scope)
+			return
+		}
+		for i := 0; i < value.Len() && !scope.HasError(); i++ {
+			element := value.Index(i)
+			if element.Kind() != reflect.Ptr {
+				element = element.Addr()
+			}
+			decrypt(scope.New(element.Interface()))
+		}
+	}
+}
+
+// encryptedFields returns the non-empty fields of the model of scope tagged `uow:"encrypt"`.
+func encryptedFields(scope *gorm.Scope) []*gorm.Field {
+	if scope.IndirectValue().Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []*gorm.Field
+	for _, field := range scope.Fields() {
+		if field.Tag.Get("uow") == encryptTag && field.IsNormal && !field.IsBlank {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// encryptValue encrypts a string or []byte value; empty values are returned as they are.
+func encryptValue(ctx context.Context, encryptor Encryptor, value interface{}) (interface{}, error) {
+	switch plaintext := value.(type) {
+	case string:
+		if plaintext == "" {
+			return plaintext, nil
+		}
+		ciphertext, err := encryptor.Encrypt(ctx, []byte(plaintext))
+		return string(ciphertext), err
+	case []byte:
+		if len(plaintext) == 0 {
+			return plaintext, nil
+		}
+		return encryptor.Encrypt(ctx, plaintext)
+	default:
+		return nil, fmt.Errorf("cannot encrypt %T: only string and []byte fields can be tagged uow:%q", value, encryptTag)
+	}
+}
+
+// decryptValue decrypts a string or []byte value; empty values are returned as they are.
+func decryptValue(ctx context.Context, encryptor Encryptor, value interface{}) (interface{}, error) {
+	switch ciphertext := value.(type) {
+	case string:
+		plaintext, err := encryptor.Decrypt(ctx, []byte(ciphertext))
+		return string(plaintext), err
+	case []byte:
+		return encryptor.Decrypt(ctx, ciphertext)
+	default:
+		return nil, fmt.Errorf("cannot decrypt %T: only string and []byte fields can be tagged uow:%q", value, encryptTag)
+	}
+}
+
+// encryptionContext returns the context bound to scope by ProviderWithContext, for the KeyProvider.
+func encryptionContext(scope *gorm.Scope) context.Context {
+	if ctx, ok := uow.ContextFromScope(scope); ok {
+		return ctx
+	}
+	return context.Background()
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// encryptedCustomer is a model with an encrypted column.
+type encryptedCustomer struct {
+	ID    int
+	Name  string
+	Email string `uow:"encrypt"`
+}
+
+// ciphertextArg matches bind parameters encrypted with the key ID.
+type ciphertextArg string
+
+// Match implements sqlmock.Argument.
+func (id ciphertextArg) Match(value driver.Value) bool {
+	text, ok := value.(string)
+	return ok && strings.HasPrefix(text, ciphertextPrefix+string(id)+":")
+}
+
+// testKeys returns keys with a rotated key "k1" and the current key "k2".
+func testKeys() StaticKeys {
+	return StaticKeys{Current: "k2", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32), "k2": bytes.Repeat([]byte{2}, 16)}}
+}
+
+// Test AESGCMEncryptor to verify values encrypted with rotated keys are decrypted and tampering is detected
+func TestAESGCMEncryptor(t *testing.T) {
+	ctx := context.Background()
+	keys := testKeys()
+	old, err := NewAESGCMEncryptor(StaticKeys{Current: "k1", Keys: keys.Keys}).Encrypt(ctx, []byte("ann@example.com"))
+	assert.NoError(t, err)
+	encryptor := NewAESGCMEncryptor(keys)
+
+	plaintext, err := encryptor.Decrypt(ctx, old)
+	assert.NoError(t, err)
+	assert.Equal(t, "ann@example.com", string(plaintext))
+
+	current, err := encryptor.Encrypt(ctx, []byte("ann@example.com"))
+	assert.NoError(t, err)
+	assert.True(t, ciphertextArg("k2").Match(string(current)))
+	again, err := encryptor.Encrypt(ctx, []byte("ann@example.com"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, current, again)
+
+	tampered := append([]byte{}, current...)
+	tampered[len(tampered)-2] ^= 1
+	_, err = encryptor.Decrypt(ctx, tampered)
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+	_, err = encryptor.Decrypt(ctx, []byte("ann@example.com"))
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+	_, err = encryptor.Decrypt(ctx, []byte(ciphertextPrefix+"k3:AAAA"))
+	assert.ErrorContains(t, err, `unknown encryption key "k3"`)
+}
+
+// Test RegisterEncryption to verify tagged columns are written encrypted, the model keeps the plaintext
+// and queried rows are decrypted
+func TestRegisterEncryption(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	encryptor := NewAESGCMEncryptor(testKeys())
+	RegisterEncryption(db, encryptor)
+	stored, err := encryptor.Encrypt(context.Background(), []byte("bob@example.com"))
+	assert.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "encrypted_customers"`).WithArgs("Ann", ciphertextArg("k2")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "encrypted_customers" SET "email" = \$1`).WithArgs(ciphertextArg("k2"), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "encrypted_customers"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).AddRow(1, "Ann", "").AddRow(2, "Bob", stored))
+
+	customer := &encryptedCustomer{Name: "Ann", Email: "ann@example.com"}
+	assert.NoError(t, db.Create(customer).Error)
+	assert.Equal(t, "ann@example.com", customer.Email)
+	assert.NoError(t, db.Model(customer).Updates(map[string]interface{}{"email": "ann@example.org"}).Error)
+	assert.Equal(t, "ann@example.org", customer.Email)
+
+	var customers []encryptedCustomer
+	assert.NoError(t, db.Find(&customers).Error)
+	assert.Equal(t, []encryptedCustomer{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Bob", Email: "bob@example.com"}}, customers)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+)
+
+// SQLSTATE codes translated by TranslateError, besides the retryable ones.
+const (
+	SQLStateNotNullViolation    = "23502"
+	SQLStateForeignKeyViolation = "23503"
+	SQLStateUniqueViolation     = "23505"
+	SQLStateCheckViolation      = "23514"
+	SQLStateLockNotAvailable    = "55P03"
+)
+
+// Sentinel errors for common Postgres failures; use errors.Is on errors returned by TranslateError.
+var (
+	ErrUniqueViolation      = errors.New("unique violation")      // ErrUniqueViolation occurs when a unique constraint is violated (23505).
+	ErrForeignKeyViolation  = errors.New("foreign key violation") // ErrForeignKeyViolation occurs when a foreign key constraint is violated (23503).
+	ErrNotNullViolation     = errors.New("not null violation")    // ErrNotNullViolation occurs when a NOT NULL constraint is violated (23502).
+	ErrCheckViolation       = errors.New("check violation")       // ErrCheckViolation occurs when a CHECK constraint is violated (23514).
+	ErrDeadlock             = errors.New("deadlock detected")     // ErrDeadlock occurs when Postgres aborts a transaction to resolve a deadlock (40P01).
+	ErrSerializationFailure = errors.New("serialization failure") // ErrSerializationFailure occurs when a SERIALIZABLE transaction cannot be serialized (40001).
+	ErrLockNotAvailable     = errors.New("lock not available")    // ErrLockNotAvailable occurs when a NOWAIT row lock cannot be acquired (55P03).
+)
+
+// sentinelsBySQLState maps SQLSTATE codes to the sentinel errors they are translated to.
+var sentinelsBySQLState = map[string]error{
+	SQLStateUniqueViolation:      ErrUniqueViolation,
+	SQLStateForeignKeyViolation:  ErrForeignKeyViolation,
+	SQLStateNotNullViolation:     ErrNotNullViolation,
+	SQLStateCheckViolation:       ErrCheckViolation,
+	SQLStateDeadlockDetected:     ErrDeadlock,
+	SQLStateSerializationFailure: ErrSerializationFailure,
+	SQLStateLockNotAvailable:     ErrLockNotAvailable,
+}
+
+// Error is a Postgres error translated by TranslateError. It matches both its sentinel and the
+// original driver error, so errors.Is(err, ErrUniqueViolation) and errors.As(err, &pqErr) work alike.
+type Error struct {
+	Sentinel error           // Sentinel is one of the Err* sentinel errors of this package.
+	Cause    *pq.Error       // Cause is the error reported by lib/pq, the default driver.
+	PgError  *pgconn.PgError // PgError is the error reported by pgx, when connected with DriverPgx.
+}
+
+// Error implements the error interface with the message of the driver error.
+func (e *Error) Error() string {
+	return e.driverError().Error()
+}
+
+// Unwrap returns the sentinel and the driver error.
+func (e *Error) Unwrap() []error {
+	return []error{e.Sentinel, e.driverError()}
+}
+
+// Constraint returns the name of the violated constraint, if any.
+func (e *Error) Constraint() string {
+	if e.PgError != nil {
+		return e.PgError.ConstraintName
+	}
+	return e.Cause.Constraint
+}
+
+// driverError returns the error reported by the driver.
+func (e *Error) driverError() error {
+	if e.PgError != nil {
+		return e.PgError
+	}
+	return e.Cause
+}
+
+// TranslateError wraps Postgres errors with a known SQLSTATE into an *Error; other errors are returned unchanged.
+// Errors of both lib/pq and pgx are translated.
+// Example:
+//
+//	if err := TranslateError(db.Create(&user).Error); errors.Is(err, ErrUniqueViolation) {
+//	  return ErrEmailTaken
+//	}
+func TranslateError(err error) error {
+	var translated *Error
+	if err == nil || errors.As(err, &translated) {
+		return err
+	}
+	sentinel, ok := sentinelsBySQLState[SQLState(err)]
+	if !ok {
+		return err
+	}
+	translated = &Error{Sentinel: sentinel}
+	if !errors.As(err, &translated.Cause) {
+		errors.As(err, &translated.PgError)
+	}
+	return translated
+}
+
+// RegisterErrorTranslation registers GORM callbacks on db that pass the errors of every operation through
+// TranslateError, so repositories can match them without wrapping each call.
+// It is applied automatically by Open when PgConfig.TranslateErrors is set.
+func RegisterErrorTranslation(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Create().Register("uow:translate_create_error", translateScopeError)
+	callbacks.Update().Register("uow:translate_update_error", translateScopeError)
+	callbacks.Delete().Register("uow:translate_delete_error", translateScopeError)
+	callbacks.Query().Register("uow:translate_query_error", translateScopeError)
+	callbacks.RowQuery().After("gorm:row_query").Register("uow:translate_row_query_error", translateScopeError)
+}
+
+// translateScopeError replaces the error of the scope by its translation.
+func translateScopeError(scope *gorm.Scope) {
+	if db := scope.DB(); db.Error != nil {
+		db.Error = TranslateError(db.Error)
+	}
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that translated errors match their sentinel and the original driver error
+func TestTranslateError(t *testing.T) {
+	err := TranslateError(&pq.Error{Code: SQLStateUniqueViolation, Constraint: "users_email_key"})
+
+	assert.True(t, errors.Is(err, ErrUniqueViolation))
+	assert.False(t, errors.Is(err, ErrForeignKeyViolation))
+	var pqErr *pq.Error
+	assert.True(t, errors.As(err, &pqErr))
+	var translated *Error
+	assert.True(t, errors.As(err, &translated))
+	assert.Equal(t, "users_email_key", translated.Constraint())
+	assert.Equal(t, SQLStateUniqueViolation, SQLState(err))
+
+	other := errors.New("other")
+	assert.Equal(t, other, TranslateError(other))
+	assert.Nil(t, TranslateError(nil))
+}
+
+// Test that errors of pgx are translated like the ones of lib/pq
+func TestTranslateError_Pgx(t *testing.T) {
+	err := TranslateError(fmt.Errorf("insert: %w", &pgconn.PgError{Code: SQLStateForeignKeyViolation, ConstraintName: "orders_user_fk"}))
+
+	assert.True(t, errors.Is(err, ErrForeignKeyViolation))
+	var pgErr *pgconn.PgError
+	assert.True(t, errors.As(err, &pgErr))
+	var translated *Error
+	assert.True(t, errors.As(err, &translated))
+	assert.Nil(t, translated.Cause)
+	assert.Equal(t, "orders_user_fk", translated.Constraint())
+	assert.Equal(t, SQLStateForeignKeyViolation, SQLState(err))
+	assert.True(t, DefaultRetryPolicy.retryable(&pgconn.PgError{Code: SQLStateDeadlockDetected}))
+}
+
+// Test that the registered callbacks translate the errors of GORM operations
+func TestRegisterErrorTranslation(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	RegisterErrorTranslation(db)
+
+	type user struct {
+		ID    int
+		Email string
+	}
+	mock.ExpectQuery(`SELECT * FROM "users" WHERE ("users"."id" = 1) ORDER BY "users"."id" ASC LIMIT 1`).
+		WillReturnError(&pq.Error{Code: SQLStateDeadlockDetected})
+
+	err := tx.Provider().First(&user{}, 1).Error
+	assert.True(t, errors.Is(err, ErrDeadlock))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
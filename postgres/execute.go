@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+type (
+	// ExecuteOption configures Execute.
+	ExecuteOption func(*executeConfig)
+
+	// executeConfig holds the configuration of Execute.
+	executeConfig struct {
+		txOptions    TxOptions // Options the transaction is started with.
+		panicAsError bool      // Returns panics as *PanicError instead of propagating them.
+	}
+
+	// PanicError is returned by Execute WithPanicAsError when the transactional function panicked.
+	PanicError struct {
+		Value interface{} // Value is the value passed to panic.
+		Stack []byte      // Stack is the stack trace of the panicking goroutine.
+	}
+)
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("transactional function panicked: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// WithExecuteTxOptions starts the transaction of Execute with opts.
+func WithExecuteTxOptions(opts TxOptions) ExecuteOption {
+	return func(c *executeConfig) {
+		c.txOptions = opts
+	}
+}
+
+// WithPanicAsError makes Execute return a panic of the transactional function as *PanicError
+// instead of re-panicking once the transaction has been rolled back.
+func WithPanicAsError() ExecuteOption {
+	return func(c *executeConfig) {
+		c.panicAsError = true
+	}
+}
+
+// Execute runs fn in a transaction like RunInTransaction and guarantees the transaction is rolled back
+// if fn panics, so the connection is not left idle in transaction. The panic is propagated afterwards,
+// or returned as *PanicError WithPanicAsError.
+// Example:
+//
+//	err := Execute(ctx, importBatch, WithPanicAsError())
+//	var panicErr *PanicError
+//	if errors.As(err, &panicErr) {
+//	  logger.Errorf("import crashed: %v\n%s", panicErr.Value, panicErr.Stack)
+//	}
+func Execute(ctx context.Context, fn TxFunc, opts ...ExecuteOption) error {
+	var cfg executeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return execute(ctx, cfg, fn)
+}
+
+// execute executes fn in a transaction configured by cfg.
+// If ctx already carries a running transaction, fn joins it and the outer owner decides about the commit.
+// The transaction is rolled back if fn returns an error or panics.
+func execute(ctx context.Context, cfg executeConfig, fn TxFunc) (err error) {
+	txContext, ctx := GetTransactionContext(ctx)
+	joined := inRunningTransaction(txContext)
+
+	id, err := txContext.BeginWithOptions(cfg.txOptions)
+	if err != nil {
+		return err
+	}
+	if c, ok := txContext.(*transactionContext); ok {
+		if err = c.applySessionVariables(ctx); err != nil {
+			_ = txContext.Rollback()
+			return err
+		}
+	}
+	if !joined {
+		var restoreLabels func()
+		ctx, restoreLabels = labelGoroutine(ctx, txContext)
+		defer restoreLabels()
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = txContext.Rollback()
+			if !cfg.panicAsError {
+				panic(p)
+			}
+			err = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+
+	if err = fn(ctx, txContext.Provider()); err != nil {
+		_ = txContext.Rollback()
+		return err
+	}
+
+	if joined && !refCountedNesting(txContext) {
+		return nil // the owner commits; a non-owner Commit fails in strict-commit mode
+	}
+	return txContext.Commit(id)
+}
+
+// inRunningTransaction reports whether txContext already runs a transaction new work joins.
+func inRunningTransaction(txContext ITransactionContext) bool {
+	c, ok := txContext.(*transactionContext)
+	return ok && c.inTransaction()
+}
@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	"strings"
+	"time"
+)
+
+const (
+	// explainStartScopeKey holds the time an operation of an explained transaction started.
+	explainStartScopeKey = "uow:explain_start"
+	// explainSavePoint is the savepoint the statements are explained in, so their effects are undone.
+	explainSavePoint = "uow_explain"
+)
+
+// explainKey is the context key of the threshold of ContextWithExplain.
+type explainKey struct{}
+
+// WithExplain makes the transactions of the context log the plan of every statement taking at least
+// threshold, as reported by EXPLAIN (ANALYZE, BUFFERS), so slow queries can be investigated where they
+// happen. EXPLAIN ANALYZE executes the statement again: it runs right after it, in a savepoint rolled back
+// afterwards, so writes are not applied twice, but the statement costs as much a second time. Statements
+// outside transactions, raw Exec statements and those of Row, Rows, Pluck and Count are not explained.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithExplain(500*time.Millisecond))
+func WithExplain(threshold time.Duration) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.explainThreshold = threshold
+	}
+}
+
+// ContextWithExplain returns a copy of ctx making the transactions of transaction contexts created for it,
+// or of Providers bound to it by ProviderWithContext, explain their slow statements as in WithExplain. It
+// takes precedence over WithExplain; a threshold of 0 disables it.
+// Example:
+//
+//	if r.Header.Get("X-Debug-Explain") != "" {
+//	  ctx = postgres.ContextWithExplain(ctx, 100*time.Millisecond)
+//	}
+func ContextWithExplain(ctx context.Context, threshold time.Duration) context.Context {
+	return context.WithValue(ctx, explainKey{}, threshold)
+}
+
+// registerExplain registers the callbacks explaining the slow statements of transactions.
+func registerExplain(db *gorm.DB) {
+	for _, operation := range queryOperations(db) {
+		if operation.name == "row_query" {
+			continue // The rows are still being read when the callbacks run.
+		}
+		name := operation.name
+		operation.processor().Before(operation.callback).Register("uow:before_"+name+"_explain", func(scope *gorm.Scope) {
+			if _, _, threshold := explainSettings(scope); threshold > 0 {
+				scope.Set(explainStartScopeKey, time.Now())
+			}
+		})
+		operation.processor().After(operation.callback).Register("uow:after_"+name+"_explain", func(scope *gorm.Scope) {
+			explainSlowStatement(scope, name)
+		})
+	}
+}
+
+// explainSettings returns the transaction running the statement of scope, the context of the statement
+// and the threshold of its explanation; the threshold is 0 if the statement is not explained.
+func explainSettings(scope *gorm.Scope) (*txHandle, context.Context, time.Duration) {
+	value, ok := scope.Get(transactionContextScopeKey)
+	if !ok {
+		return nil, nil, 0
+	}
+	c := value.(*txHandle)
+	ctx, bound := uow.ContextFromScope(scope)
+	if !bound {
+		ctx = c.ctx
+	}
+	if threshold, ok := ctx.Value(explainKey{}).(time.Duration); ok {
+		return c, ctx, threshold
+	}
+	return c, ctx, c.explainThreshold
+}
+
+// explainSlowStatement logs the plan of the statement of scope if it took at least the threshold.
+func explainSlowStatement(scope *gorm.Scope, operation string) {
+	start, ok := scope.Get(explainStartScopeKey)
+	if !ok || scope.HasError() {
+		return
+	}
+	c, ctx, threshold := explainSettings(scope)
+	elapsed := time.Since(start.(time.Time))
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	logger := c.withLogFields(c.logger, ctx)
+	plan, err := explainAnalyze(scope.SQLDB(), scope.SQL, scope.SQLVars)
+	if err != nil {
+		logger.Warnf("cannot explain slow %s on %s: %s", operation, scope.TableName(), err)
+		return
+	}
+	logger.Warnf("slow %s on %s took %s: %s\n%s", operation, scope.TableName(), elapsed, scope.SQL, plan)
+}
+
+// explainAnalyze runs EXPLAIN (ANALYZE, BUFFERS) for sql in a savepoint of the transaction db, rolled
+// back afterwards, and returns the plan.
+func explainAnalyze(db gorm.SQLCommon, sql string, vars []interface{}) (string, error) {
+	if _, err := db.Exec("SAVEPOINT " + explainSavePoint); err != nil {
+		return "", err
+	}
+	plan, err := queryPlan(db, sql, vars)
+	if _, rollbackErr := db.Exec("ROLLBACK TO SAVEPOINT " + explainSavePoint); rollbackErr != nil {
+		return "", errors.Join(err, rollbackErr)
+	}
+	if _, releaseErr := db.Exec("RELEASE SAVEPOINT " + explainSavePoint); releaseErr != nil {
+		return "", errors.Join(err, releaseErr)
+	}
+	return plan, err
+}
+
+// queryPlan returns the lines of the plan of sql joined by newlines.
+func queryPlan(db gorm.SQLCommon, sql string, vars []interface{}) (string, error) {
+	rows, err := db.Query("EXPLAIN (ANALYZE, BUFFERS) "+sql, vars...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), rows.Err()
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// warningsLogger records the warnings logged through it.
+type warningsLogger struct {
+	log.Logger
+	warnings *[]string // Warnings logged through any logger derived from the warningsLogger.
+}
+
+func (l *warningsLogger) WithField(key string, value interface{}) log.Logger {
+	return &warningsLogger{Logger: l.Logger.WithField(key, value), warnings: l.warnings}
+}
+
+func (l *warningsLogger) Warnf(format string, args ...interface{}) {
+	*l.warnings = append(*l.warnings, fmt.Sprintf(format, args...))
+}
+
+// Test WithExplain to verify slow statements are explained in a savepoint and their plan is logged
+func TestWithExplain(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	var warnings []string
+	tx.logger = &warningsLogger{Logger: tx.logger, warnings: &warnings}
+	WithExplain(10 * time.Millisecond)(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "repository_orders" SET "status" = $1`).WithArgs("paid").
+		WillDelayFor(20 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`SAVEPOINT uow_explain`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`EXPLAIN (ANALYZE, BUFFERS) UPDATE "repository_orders" SET "status" = $1`).WithArgs("paid").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow("Update on repository_orders").AddRow("  ->  Seq Scan on repository_orders"))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT uow_explain`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT uow_explain`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT * FROM "repository_orders"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Model(&repositoryOrder{}).Update("status", "paid").Error)
+	var orders []repositoryOrder
+	assert.NoError(t, tx.Provider().Find(&orders).Error)
+	assert.NoError(t, tx.Commit(id))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0], `slow update on repository_orders took`)
+		assert.Contains(t, warnings[0], "\nUpdate on repository_orders\n  ->  Seq Scan on repository_orders")
+	}
+}
+
+// Test ContextWithExplain to verify the threshold of the bound context takes precedence over WithExplain
+func TestContextWithExplain(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithExplain(time.Millisecond)(tx)
+	ctx := ContextWithExplain(context.Background(), 0)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "repository_orders"`).WillDelayFor(5 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	var orders []repositoryOrder
+	assert.NoError(t, tx.ProviderWithContext(ctx).Find(&orders).Error)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
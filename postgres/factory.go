@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// TransactionContextFactory creates transaction contexts for the database holder it owns.
+// Unlike GetTransactionContext it does not depend on DbConfig, so it can be constructed explicitly
+// and injected (e.g. with fx or wire); several factories may coexist in one process.
+type TransactionContextFactory struct {
+	key      contextKey                 // Key of the transaction contexts of the factory in a context.
+	dbHolder func() *DatabaseHolder     // Resolves the holder of newly created transaction contexts.
+	opts     []TransactionContextOption // Options applied to every transaction context the factory creates.
+
+	mu    sync.RWMutex // Guards hooks.
+	hooks []Hook       // Hooks added to every transaction context the factory creates, see RegisterHook.
+}
+
+// NewTransactionContextFactory creates a factory for holder. opts are applied to every transaction context
+// the factory creates, before the options passed to GetTransactionContext.
+// Example:
+//
+//	factory := postgres.NewTransactionContextFactory(postgres.NewDBHolder(postgres.NewConnect(&config)))
+//	txContext, ctx := factory.GetTransactionContext(ctx)
+func NewTransactionContextFactory(holder *DatabaseHolder, opts ...TransactionContextOption) *TransactionContextFactory {
+	return &TransactionContextFactory{
+		key:      newFactoryKey(),
+		dbHolder: func() *DatabaseHolder { return holder },
+		opts:     opts,
+	}
+}
+
+// NewTransactionContextFactoryFromConfig creates a factory owning a new connection to the database described
+// by config. The connection is established when the first transaction context is created.
+func NewTransactionContextFactoryFromConfig(config *PgConfig, opts ...TransactionContextOption) *TransactionContextFactory {
+	return &TransactionContextFactory{
+		key:      newFactoryKey(),
+		dbHolder: sync.OnceValue(func() *DatabaseHolder { return newDBHolderFromConfig(config) }),
+		opts:     opts,
+	}
+}
+
+var (
+	// defaultFactory backs the package-level GetTransactionContext with the singleton configured by DbConfig.
+	defaultFactory = &TransactionContextFactory{key: TransactionContextKey, dbHolder: defaultDBHolder}
+
+	factoryCount atomic.Uint64 // Number of factories created, numbering their context keys.
+)
+
+// newFactoryKey returns the context key of the transaction contexts of a new factory.
+func newFactoryKey() contextKey {
+	return contextKey(fmt.Sprintf("%s:factory:%d", TransactionContextKey, factoryCount.Add(1)))
+}
+
+// GetTransactionContext retrieves the transaction context of the factory from ctx or creates one for the
+// factory's holder. Every factory keeps its own transaction context in ctx, so the context of another
+// factory, connected to another database, is never returned. The returned context is also stored under
+// TransactionContextKey, so RunInTransaction and the other helpers pick it up.
+func (f *TransactionContextFactory) GetTransactionContext(ctx context.Context, opts ...TransactionContextOption) (ITransactionContext, context.Context) {
+	if hooks := f.hookOptions(); len(f.opts) > 0 || len(hooks) > 0 {
+		opts = append(append(append([]TransactionContextOption{}, f.opts...), hooks...), opts...)
+	}
+	if f.key == TransactionContextKey {
+		return getTransactionContextWithDBHolder(ctx, TransactionContextKey, f.dbHolder, opts...)
+	}
+	if current, ok := ctx.Value(TransactionContextKey).(*transactionContext); ok && current.factoryKey == f.key {
+		return current, ctx // e.g. the fork running an attempt of RunWithRetry
+	}
+	txContext, ctx := getTransactionContextWithDBHolder(ctx, f.key, f.dbHolder, append(opts, func(c *transactionContext) {
+		c.factoryKey = f.key
+	})...)
+	if ctx.Value(TransactionContextKey) != txContext {
+		ctx = context.WithValue(ctx, TransactionContextKey, txContext)
+	}
+	return txContext, ctx
+}
+
+// RunInTransaction is the package-level RunInTransaction for a transaction context of the factory.
+func (f *TransactionContextFactory) RunInTransaction(ctx context.Context, fn TxFunc) error {
+	_, ctx = f.GetTransactionContext(ctx)
+	return RunInTransaction(ctx, fn)
+}
+
+// withNewTransactionContext returns ctx with a new transaction context of the factory, also if ctx
+// already carries one.
+func (f *TransactionContextFactory) withNewTransactionContext(ctx context.Context) context.Context {
+	_, ctx = f.GetTransactionContext(context.WithValue(context.WithValue(ctx, TransactionContextKey, nil), f.key, nil))
+	return ctx
+}
+
+// DBHolder returns the database holder owned by the factory.
+func (f *TransactionContextFactory) DBHolder() *DatabaseHolder {
+	return f.dbHolder()
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that a factory creates transaction contexts for its own holder with its default options
+func TestTransactionContextFactory_GetTransactionContext(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	holder := NewDBHolder(db)
+	factory := NewTransactionContextFactory(holder, WithAutoBegin())
+
+	txContext, ctx := factory.GetTransactionContext(context.Background())
+	c := txContext.(*transactionContext)
+	assert.Same(t, holder, c.dbHolder)
+	assert.True(t, c.autoBegin)
+	assert.Same(t, holder, factory.DBHolder())
+
+	again, _ := factory.GetTransactionContext(ctx)
+	assert.Same(t, txContext, again)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	assert.NoError(t, factory.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		return nil
+	}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that nested factories of two databases each run their work on their own database
+func TestTransactionContextFactory_Nested(t *testing.T) {
+	ordersDB, ordersMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	orders, err := gorm.Open("postgres", ordersDB)
+	assert.NoError(t, err)
+	defer orders.Close()
+	auditDB, auditMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	audit, err := gorm.Open("postgres", auditDB)
+	assert.NoError(t, err)
+	defer audit.Close()
+	ordersFactory := NewTransactionContextFactory(NewDBHolder(orders))
+	auditFactory := NewTransactionContextFactory(NewDBHolder(audit))
+
+	ordersMock.ExpectBegin()
+	auditMock.ExpectBegin()
+	auditMock.ExpectExec("INSERT INTO audit_log").WillReturnResult(sqlmock.NewResult(1, 1))
+	auditMock.ExpectCommit()
+	ordersMock.ExpectExec("UPDATE orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	ordersMock.ExpectCommit()
+
+	ordersContext, ctx := ordersFactory.GetTransactionContext(context.Background())
+	assert.NoError(t, ordersFactory.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		err := auditFactory.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+			txContext, _ := GetTransactionContext(ctx)
+			assert.NotSame(t, ordersContext, txContext)
+			return db.Exec("INSERT INTO audit_log (event) VALUES ('paid')").Error
+		})
+		if err != nil {
+			return err
+		}
+		again, _ := ordersFactory.GetTransactionContext(ctx)
+		assert.Same(t, ordersContext, again)
+		return again.(*transactionContext).Provider().Exec("UPDATE orders SET paid = true").Error
+	}))
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, auditMock.ExpectationsWereMet())
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// replicationLagQuery returns the replay lag of a standby in seconds, and 0 on a primary.
+const replicationLagQuery = `SELECT CASE WHEN pg_is_in_recovery()
+	THEN COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) ELSE 0 END`
+
+// Errors reported by Checker besides the connection errors.
+var (
+	ErrPoolSaturated  = errors.New("connection pool is saturated") // ErrPoolSaturated occurs when the share of connections in use exceeds CheckerConfig.MaxPoolUtilization.
+	ErrReplicationLag = errors.New("replication lag is too high")  // ErrReplicationLag occurs when a standby lags behind by more than CheckerConfig.MaxReplicationLag.
+)
+
+type (
+	// CheckerConfig holds the thresholds of a Checker; zero values disable the corresponding check.
+	CheckerConfig struct {
+		MaxPoolUtilization float64       // MaxPoolUtilization fails the check once this share (0-1] of MaxOpenConnections is in use.
+		MaxReplicationLag  time.Duration // MaxReplicationLag fails the check on a standby replaying changes older than this.
+	}
+
+	// Checker checks the database of a holder: connectivity, pool saturation and replication lag.
+	// Its Check method is a func(ctx) error and HealthCheck a func() error, the signatures most
+	// health-check libraries accept as is.
+	Checker struct {
+		holder *DatabaseHolder // Holder whose database is checked.
+		config CheckerConfig   // Thresholds of the checks.
+	}
+)
+
+// NewChecker creates a Checker for holder.
+// Example:
+//
+//	checker := NewChecker(holder, CheckerConfig{MaxPoolUtilization: 0.9, MaxReplicationLag: 30 * time.Second})
+//	health := healthcheck.NewHandler()
+//	health.AddReadinessCheck("database", checker.HealthCheck(time.Second))
+func NewChecker(holder *DatabaseHolder, config CheckerConfig) *Checker {
+	return &Checker{holder: holder, config: config}
+}
+
+// Check pings the database and fails with ErrPoolSaturated or ErrReplicationLag once the configured
+// thresholds are exceeded. The replay lag of a standby also grows while the primary receives no writes.
+func (c *Checker) Check(ctx context.Context) error {
+	if db := c.holder.currentConnection(); db != nil && c.config.MaxPoolUtilization > 0 {
+		// checked first, since the ping waits for a free connection
+		stats := db.DB().Stats()
+		if stats.MaxOpenConnections > 0 {
+			utilization := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+			if utilization >= c.config.MaxPoolUtilization {
+				return fmt.Errorf("%w: %d of %d connections in use", ErrPoolSaturated, stats.InUse, stats.MaxOpenConnections)
+			}
+		}
+	}
+	if err := c.holder.Ping(ctx); err != nil {
+		return err
+	}
+	db := c.holder.currentConnection().DB()
+
+	if c.config.MaxReplicationLag > 0 {
+		var seconds float64
+		if err := db.QueryRowContext(ctx, replicationLagQuery).Scan(&seconds); err != nil {
+			return err
+		}
+		if lag := time.Duration(seconds * float64(time.Second)); lag > c.config.MaxReplicationLag {
+			return fmt.Errorf("%w: %s behind", ErrReplicationLag, lag.Round(time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// HealthCheck returns Check as a func() error, each call limited to timeout.
+func (c *Checker) HealthCheck(timeout time.Duration) func() error {
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return c.Check(ctx)
+	}
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// newCheckerTestHolder returns a holder on top of sqlmock monitoring pings.
+func newCheckerTestHolder(t *testing.T) (*DatabaseHolder, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	mock.ExpectPing() // by gorm.Open
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return NewDBHolder(db), mock
+}
+
+// Test that the checker reports the replication lag of a standby
+func TestChecker_ReplicationLag(t *testing.T) {
+	holder, mock := newCheckerTestHolder(t)
+	checker := NewChecker(holder, CheckerConfig{MaxReplicationLag: 30 * time.Second})
+
+	mock.ExpectPing()
+	mock.ExpectQuery("pg_last_xact_replay_timestamp").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(2.5))
+	assert.NoError(t, checker.Check(context.Background()))
+
+	mock.ExpectPing()
+	mock.ExpectQuery("pg_last_xact_replay_timestamp").WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(42.0))
+	err := checker.HealthCheck(time.Second)()
+	assert.ErrorIs(t, err, ErrReplicationLag)
+	assert.ErrorContains(t, err, "42s behind")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that the checker reports a saturated pool
+func TestChecker_PoolSaturation(t *testing.T) {
+	holder, mock := newCheckerTestHolder(t)
+	sqlDB := holder.dbConnection.DB()
+	sqlDB.SetMaxOpenConns(2)
+	checker := NewChecker(holder, CheckerConfig{MaxPoolUtilization: 0.5})
+
+	mock.ExpectPing()
+	assert.NoError(t, checker.Check(context.Background()))
+
+	conn, err := sqlDB.Conn(context.Background())
+	assert.NoError(t, err)
+	defer conn.Close()
+	err = checker.Check(context.Background())
+	assert.ErrorIs(t, err, ErrPoolSaturated)
+	assert.ErrorContains(t, err, "1 of 2 connections in use")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
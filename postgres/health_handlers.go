@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Statuses reported in HealthResponse.Status.
+const (
+	HealthStatusOK          = "ok"          // HealthStatusOK is reported with 200 when all databases pass.
+	HealthStatusUnavailable = "unavailable" // HealthStatusUnavailable is reported with 503 when a database fails.
+)
+
+// HealthResponse is the JSON body written by LivenessHandler and ReadinessHandler.
+type HealthResponse struct {
+	Status    string        `json:"status"`    // Status is HealthStatusOK or HealthStatusUnavailable.
+	Databases []HealthState `json:"databases"` // Databases lists the state of every watched database.
+}
+
+// ReadinessHandler reports whether all databases watched by watchdogs are healthy: it answers 200, or
+// 503 as soon as one of them is unhealthy, with a HealthResponse naming the failing database.
+// Example:
+//
+//	http.Handle("/readyz", postgres.ReadinessHandler(billingWatchdog, auditWatchdog))
+func ReadinessHandler(watchdogs ...*HealthWatchdog) http.HandlerFunc {
+	return healthHandler(watchdogs, func(state HealthState) bool {
+		return state.Healthy
+	})
+}
+
+// LivenessHandler is ReadinessHandler tolerating databases that have been unhealthy for at most
+// maxUnhealthy, so a short outage takes the pods out of rotation without restarting them.
+// Example:
+//
+//	http.Handle("/livez", postgres.LivenessHandler(5*time.Minute, billingWatchdog))
+func LivenessHandler(maxUnhealthy time.Duration, watchdogs ...*HealthWatchdog) http.HandlerFunc {
+	return healthHandler(watchdogs, func(state HealthState) bool {
+		return state.Healthy || time.Since(state.Since) <= maxUnhealthy
+	})
+}
+
+// healthHandler writes the states of watchdogs, failing with 503 unless pass accepts every one of them.
+func healthHandler(watchdogs []*HealthWatchdog, pass func(HealthState) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		response := HealthResponse{Status: HealthStatusOK, Databases: make([]HealthState, 0, len(watchdogs))}
+		for _, watchdog := range watchdogs {
+			state := watchdog.State()
+			if !pass(state) {
+				response.Status = HealthStatusUnavailable
+			}
+			response.Databases = append(response.Databases, state)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if response.Status != HealthStatusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
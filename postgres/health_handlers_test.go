@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test that the readiness handler fails with the failing database while the liveness handler tolerates it
+func TestHealthHandlers(t *testing.T) {
+	billing := NewHealthWatchdog(NewDBHolder(nil), HealthWatchdogConfig{Name: "billing"})
+	audit := NewHealthWatchdog(NewDBHolder(nil), HealthWatchdogConfig{Name: "audit"})
+	audit.setHealthy(errors.New("connection refused"))
+
+	recorder := httptest.NewRecorder()
+	ReadinessHandler(billing, audit)(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var response HealthResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, HealthStatusUnavailable, response.Status)
+	assert.Len(t, response.Databases, 2)
+	assert.Equal(t, "billing", response.Databases[0].Name)
+	assert.True(t, response.Databases[0].Healthy)
+	assert.Equal(t, "audit", response.Databases[1].Name)
+	assert.False(t, response.Databases[1].Healthy)
+	assert.Equal(t, "connection refused", response.Databases[1].Error)
+
+	recorder = httptest.NewRecorder()
+	LivenessHandler(time.Minute, billing, audit)(recorder, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	LivenessHandler(0, billing, audit)(recorder, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	audit.setHealthy(nil)
+	recorder = httptest.NewRecorder()
+	ReadinessHandler(billing, audit)(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, HealthStatusOK, response.Status)
+}
@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	log "github.com/public-forge/go-logger"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthCheckInterval defines how often a healthy database is pinged when HealthWatchdogConfig.Interval is not set.
+	defaultHealthCheckInterval = 10 * time.Second
+	// defaultHealthCheckName names the watched database when HealthWatchdogConfig.Name is not set.
+	defaultHealthCheckName = "default"
+)
+
+type (
+	// HealthWatchdogConfig holds the settings of a HealthWatchdog. Zero values fall back to defaults.
+	HealthWatchdogConfig struct {
+		Name     string                        // Name identifies the database in logs and health responses; "default" if empty.
+		Interval time.Duration                 // Interval is the time between pings while the database is healthy.
+		Timeout  time.Duration                 // Timeout limits each ping.
+		Backoff  ConnectRetryPolicy            // Backoff spaces the reconnection attempts while the database is unhealthy; MaxAttempts is ignored.
+		OnChange func(healthy bool, err error) // OnChange, when set, is called whenever the health changes, with the error of the failed ping.
+		Logger   log.Logger                    // Logger used by the watchdog; defaults to the default logger.
+		Clock    Clock                         // Clock spaces the pings and dates the health changes; defaults to SystemClock.
+	}
+
+	// HealthWatchdog pings the database of a holder in the background and marks the holder unhealthy while
+	// the pings fail, see DatabaseHolder.Healthy. Each ping of an unhealthy database is a reconnection
+	// attempt: the pool discards broken connections and dials a new one.
+	HealthWatchdog struct {
+		holder *DatabaseHolder      // Holder whose database is watched.
+		config HealthWatchdogConfig // Settings of the watchdog.
+
+		mu    sync.Mutex  // Guards state.
+		state HealthState // Result of the latest ping.
+	}
+
+	// HealthState describes the health of a database watched by a HealthWatchdog.
+	HealthState struct {
+		Name    string    `json:"name"`            // Name is HealthWatchdogConfig.Name.
+		Healthy bool      `json:"healthy"`         // Healthy reports whether the latest ping succeeded.
+		Error   string    `json:"error,omitempty"` // Error is the error of the latest ping, if it failed.
+		Since   time.Time `json:"since"`           // Since is the time the database became healthy or unhealthy.
+	}
+)
+
+// NewHealthWatchdog creates a HealthWatchdog for holder.
+// Example:
+//
+//	watchdog := NewHealthWatchdog(holder, HealthWatchdogConfig{
+//	  OnChange: func(healthy bool, err error) { readiness.Set(healthy) },
+//	})
+//	go watchdog.Run(ctx)
+func NewHealthWatchdog(holder *DatabaseHolder, config HealthWatchdogConfig) *HealthWatchdog {
+	if config.Interval <= 0 {
+		config.Interval = defaultHealthCheckInterval
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultPingTimeout
+	}
+	if config.Name == "" {
+		config.Name = defaultHealthCheckName
+	}
+	if config.Logger == nil {
+		config.Logger = log.FromDefaultContext()
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+	return &HealthWatchdog{
+		holder: holder,
+		config: config,
+		state:  HealthState{Name: config.Name, Healthy: holder.Healthy(), Since: config.Clock.Now()},
+	}
+}
+
+// State returns the health of the database as of the latest ping.
+func (w *HealthWatchdog) State() HealthState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// Healthy reports whether the last ping of the watchdog succeeded; holders that are not watched are healthy.
+func (h *DatabaseHolder) Healthy() bool {
+	return !h.unhealthy.Load()
+}
+
+// Run pings the database until ctx is done. It blocks.
+func (w *HealthWatchdog) Run(ctx context.Context) {
+	failures := 0
+	for {
+		err := w.ping(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		w.setHealthy(err)
+
+		delay := w.config.Interval
+		if err != nil {
+			delay = w.config.Backoff.Delay(failures)
+			failures++
+		} else {
+			failures = 0
+		}
+		if w.config.Clock.Sleep(ctx, delay) != nil {
+			return
+		}
+	}
+}
+
+// ping pings the database of the holder; a lazy holder that has not connected yet is not pinged.
+func (w *HealthWatchdog) ping(ctx context.Context) error {
+	db := w.holder.currentConnection()
+	if db == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.config.Timeout)
+	defer cancel()
+	return db.DB().PingContext(ctx)
+}
+
+// setHealthy records the result of a ping and reports changes.
+func (w *HealthWatchdog) setHealthy(err error) {
+	healthy := err == nil
+	w.mu.Lock()
+	w.state.Error = ""
+	if err != nil {
+		w.state.Error = err.Error()
+	}
+	changed := w.state.Healthy != healthy
+	if changed {
+		w.state.Healthy, w.state.Since = healthy, w.config.Clock.Now()
+	}
+	w.mu.Unlock()
+
+	w.holder.unhealthy.Store(!healthy)
+	if !changed {
+		return
+	}
+	if healthy {
+		w.config.Logger.Infof("database %s is healthy again", w.config.Name)
+	} else {
+		w.config.Logger.Errorf("database %s is unhealthy: %s", w.config.Name, err)
+	}
+	if w.config.OnChange != nil {
+		w.config.OnChange(healthy, err)
+	}
+}
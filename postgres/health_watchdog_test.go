@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// healthChange is a call of HealthWatchdogConfig.OnChange.
+type healthChange struct {
+	healthy bool
+	err     error
+}
+
+// Test that the watchdog marks the holder unhealthy while pings fail and healthy once one succeeds again
+func TestHealthWatchdog(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	mock.ExpectPing() // by gorm.Open
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	pingErr := errors.New("connection refused")
+	mock.ExpectPing().WillReturnError(pingErr)
+	mock.ExpectPing().WillReturnError(pingErr)
+	mock.ExpectPing()
+
+	holder := NewDBHolder(db)
+	changes := make(chan healthChange, 2)
+	watchdog := NewHealthWatchdog(holder, HealthWatchdogConfig{
+		Interval: time.Hour,
+		Backoff:  ConnectRetryPolicy{Backoff: time.Millisecond, Jitter: -1},
+		OnChange: func(healthy bool, err error) { changes <- healthChange{healthy, err} },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watchdog.Run(ctx)
+		close(done)
+	}()
+
+	change := <-changes
+	assert.False(t, change.healthy)
+	assert.ErrorIs(t, change.err, pingErr)
+	change = <-changes
+	assert.True(t, change.healthy)
+	assert.NoError(t, change.err)
+
+	cancel()
+	<-done
+	assert.True(t, holder.Healthy())
+	assert.Empty(t, changes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// RegisterCallbacks adds register, which registers GORM callbacks such as model-level audit or soft-delete
+// handling, to the holder. It runs once for every connection of the holder: right away if the holder is
+// connected, otherwise when a lazy holder connects. The watchdog reconnects within the pool of that
+// connection, so the callbacks survive outages. Call it at startup, before the holder runs transactions,
+// as GORM callbacks must not be registered concurrently with queries.
+// Example:
+//
+//	holder.RegisterCallbacks(func(db *gorm.DB) {
+//	  db.Callback().Update().Before("gorm:update").Register("app:audit", auditUpdate)
+//	})
+func (h *DatabaseHolder) RegisterCallbacks(register func(*gorm.DB)) {
+	h.connectMu.Lock()
+	defer h.connectMu.Unlock()
+	h.callbacks = append(h.callbacks, register)
+	if h.dbConnection != nil {
+		register(h.dbConnection)
+	}
+}
+
+// RegisterCallbacks adds register to the holder of the factory, see DatabaseHolder.RegisterCallbacks.
+// A factory from NewTransactionContextFactoryFromConfig creates its holder on the first call.
+func (f *TransactionContextFactory) RegisterCallbacks(register func(*gorm.DB)) {
+	f.DBHolder().RegisterCallbacks(register)
+}
+
+// applyCallbacks runs the functions of RegisterCallbacks for db, a new connection of the holder.
+// The caller holds connectMu.
+func (h *DatabaseHolder) applyCallbacks(db *gorm.DB) {
+	for _, register := range h.callbacks {
+		register(db)
+	}
+}
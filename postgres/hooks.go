@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	"time"
+)
+
+const (
+	// hooksScopeKey holds the hookScope of the *gorm.DB returned by Provider when its context has hooks.
+	hooksScopeKey = "uow:hooks"
+	// queryStartScopeKey holds the time BeforeQuery hooks ran, for the Duration passed to AfterQuery.
+	queryStartScopeKey = "uow:query_start"
+)
+
+type (
+	// Hook is a plugin observing the operations of units of work, for cross-cutting concerns such as
+	// metrics, caching or tenancy. Hooks are registered on a TransactionContextFactory, or on a single
+	// context with WithHooks, and run in registration order. Embed NopHook to implement only some methods.
+	Hook interface {
+		// BeforeQuery runs before GORM executes a create, query, update, delete or row query; an error aborts
+		// the operation and is returned by it. The SQL of query is not built yet.
+		BeforeQuery(ctx context.Context, query *QueryInfo) error
+		// AfterQuery runs once the operation has been executed, with its SQL, outcome and duration.
+		AfterQuery(ctx context.Context, query *QueryInfo)
+		// BeforeCommit runs inside the transaction right before COMMIT is sent by the owner, after the
+		// functions of RegisterBeforeCommit; an error rolls the transaction back and is returned by Commit.
+		BeforeCommit(ctx context.Context, tx TxInfo) error
+		// AfterRollback runs once a transaction has been rolled back, including after a failed commit.
+		AfterRollback(ctx context.Context, tx TxInfo)
+	}
+
+	// hookScope holds the hooks of a transaction context and its context for the hook callbacks. It does
+	// not reference the transaction context, see txHandle.
+	hookScope struct {
+		ctx   context.Context // Context the transaction context was created for.
+		hooks []Hook          // Hooks of the transaction context.
+	}
+
+	// QueryInfo describes an operation passed to the BeforeQuery and AfterQuery hooks.
+	QueryInfo struct {
+		Operation    string        // Operation is "create", "query", "update", "delete" or "row_query".
+		Table        string        // Table is the table of the model of the operation.
+		SQL          string        // SQL is the statement text; set for AfterQuery.
+		Vars         []interface{} // Vars holds the bound parameter values; set for AfterQuery.
+		Duration     time.Duration // Duration is the time from BeforeQuery until the statement returned.
+		RowsAffected int64         // RowsAffected is the number of rows reported by the database.
+		Err          error         // Err is the error of the operation, if any.
+	}
+
+	// NopHook implements Hook with methods doing nothing, to be embedded by hooks.
+	NopHook struct{}
+)
+
+// BeforeQuery implements Hook.
+func (NopHook) BeforeQuery(context.Context, *QueryInfo) error { return nil }
+
+// AfterQuery implements Hook.
+func (NopHook) AfterQuery(context.Context, *QueryInfo) {}
+
+// BeforeCommit implements Hook.
+func (NopHook) BeforeCommit(context.Context, TxInfo) error { return nil }
+
+// AfterRollback implements Hook.
+func (NopHook) AfterRollback(context.Context, TxInfo) {}
+
+// WithHooks runs hooks for the operations of the context, after the hooks of its factory.
+// Raw Exec statements bypass the GORM callbacks and are not seen by BeforeQuery and AfterQuery.
+// Example:
+//
+//	type slowQueries struct{ postgres.NopHook }
+//
+//	func (slowQueries) AfterQuery(ctx context.Context, q *postgres.QueryInfo) {
+//	  if q.Duration > time.Second { log.FromContext(ctx).Warnf("slow %s on %s: %s", q.Operation, q.Table, q.SQL) }
+//	}
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithHooks(slowQueries{}))
+func WithHooks(hooks ...Hook) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.hooks = append(c.hooks, hooks...)
+	}
+}
+
+// RegisterHook adds hooks to the transaction contexts the factory creates from now on.
+// Example:
+//
+//	factory := postgres.NewTransactionContextFactory(holder)
+//	factory.RegisterHook(metricsHook{}, tenancyHook{})
+func (f *TransactionContextFactory) RegisterHook(hooks ...Hook) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hooks = append(f.hooks, hooks...)
+}
+
+// hookOptions returns the option adding the hooks of the factory, if it has any.
+func (f *TransactionContextFactory) hookOptions() []TransactionContextOption {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.hooks) == 0 {
+		return nil
+	}
+	return []TransactionContextOption{WithHooks(append([]Hook{}, f.hooks...)...)}
+}
+
+// registerHookCallbacks registers the callbacks running the BeforeQuery and AfterQuery hooks.
+func registerHookCallbacks(db *gorm.DB) {
+	for _, operation := range queryOperations(db) {
+		operation.processor().Before(operation.callback).Register("uow:before_"+operation.name+"_hooks", beforeQueryHooks(operation.name))
+		operation.processor().After(operation.callback).Register("uow:after_"+operation.name+"_hooks", afterQueryHooks(operation.name))
+	}
+}
+
+// queryOperation is an operation of QueryInfo.Operation with the GORM callback executing it.
+type queryOperation struct {
+	name      string                         // QueryInfo.Operation of the operation.
+	callback  string                         // GORM callback executing the statement.
+	processor func() *gorm.CallbackProcessor // Returns a new processor for a callback of the operation.
+}
+
+// queryOperations returns the operations of db observed by hooks and metrics sinks.
+func queryOperations(db *gorm.DB) []queryOperation {
+	callbacks := db.Callback()
+	return []queryOperation{
+		{name: "create", callback: "gorm:create", processor: callbacks.Create},
+		{name: "query", callback: "gorm:query", processor: callbacks.Query},
+		{name: "update", callback: "gorm:update", processor: callbacks.Update},
+		{name: "delete", callback: "gorm:delete", processor: callbacks.Delete},
+		{name: "row_query", callback: "gorm:row_query", processor: callbacks.RowQuery},
+	}
+}
+
+// beforeQueryHooks returns the callback running the BeforeQuery hooks for operation.
+func beforeQueryHooks(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		hooks, ctx, ok := hookContext(scope)
+		if !ok || scope.HasError() {
+			return
+		}
+		query := &QueryInfo{Operation: operation, Table: scope.TableName()}
+		for _, hook := range hooks {
+			if err := hook.BeforeQuery(ctx, query); err != nil {
+				scope.Err(err)
+				return
+			}
+		}
+		scope.Set(queryStartScopeKey, time.Now())
+	}
+}
+
+// afterQueryHooks returns the callback running the AfterQuery hooks for operation.
+func afterQueryHooks(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		hooks, ctx, ok := hookContext(scope)
+		if !ok {
+			return
+		}
+		start, ok := scope.Get(queryStartScopeKey)
+		if !ok {
+			return // a BeforeQuery hook failed
+		}
+		query := &QueryInfo{
+			Operation:    operation,
+			Table:        scope.TableName(),
+			SQL:          scope.SQL,
+			Vars:         scope.SQLVars,
+			Duration:     time.Since(start.(time.Time)),
+			RowsAffected: scope.DB().RowsAffected,
+			Err:          scope.DB().Error,
+		}
+		for _, hook := range hooks {
+			hook.AfterQuery(ctx, query)
+		}
+	}
+}
+
+// hookContext returns the hooks of the transaction context the operation of scope runs for, and the
+// context bound by ProviderWithContext or the one of the transaction context.
+func hookContext(scope *gorm.Scope) ([]Hook, context.Context, bool) {
+	value, ok := scope.Get(hooksScopeKey)
+	if !ok {
+		return nil, nil, false
+	}
+	hooks := value.(hookScope)
+	if ctx, ok := uow.ContextFromScope(scope); ok {
+		return hooks.hooks, ctx, true
+	}
+	return hooks.hooks, hooks.ctx, true
+}
+
+// withHooks marks db, returned by Provider, for the hook callbacks.
+func (c *transactionContext) withHooks(db *gorm.DB) *gorm.DB {
+	if len(c.hooks) == 0 || db == nil {
+		return db
+	}
+	return db.Set(hooksScopeKey, hookScope{ctx: c.ctx, hooks: c.hooks})
+}
+
+// runBeforeCommitHooks runs the BeforeCommit hooks, stopping at the first failure.
+func (c *transactionContext) runBeforeCommitHooks() error {
+	for _, hook := range c.hooks {
+		if err := uow.RunBeforeCommitHook(func() error { return hook.BeforeCommit(c.ctx, c.txInfo) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAfterRollbackHooks runs the AfterRollback hooks for the disposed transaction tx.
+func (c *transactionContext) runAfterRollbackHooks(tx TxInfo) {
+	for _, hook := range c.hooks {
+		c.runHooks("after-rollback", []func(){func() { hook.AfterRollback(c.ctx, tx) }})
+	}
+}
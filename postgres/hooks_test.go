@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// recordingHook records the calls of its methods and fails BeforeQuery for the table veto.
+type recordingHook struct {
+	calls       []string
+	commitError error
+}
+
+func (h *recordingHook) BeforeQuery(_ context.Context, q *QueryInfo) error {
+	h.calls = append(h.calls, "before "+q.Operation+" "+q.Table)
+	if q.Table == "veto" {
+		return errors.New("vetoed")
+	}
+	return nil
+}
+
+func (h *recordingHook) AfterQuery(_ context.Context, q *QueryInfo) {
+	h.calls = append(h.calls, "after "+q.Operation+" "+q.SQL)
+}
+
+func (h *recordingHook) BeforeCommit(context.Context, TxInfo) error {
+	h.calls = append(h.calls, "before commit")
+	return h.commitError
+}
+
+func (h *recordingHook) AfterRollback(context.Context, TxInfo) {
+	h.calls = append(h.calls, "after rollback")
+}
+
+// Test RegisterHook to verify hooks of the factory see the queries, commits and rollbacks of its contexts
+func TestTransactionContextFactory_RegisterHook(t *testing.T) {
+	_, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	factory := NewTransactionContextFactory(NewDBHolder(db))
+	hook := &recordingHook{commitError: errors.New("quota exceeded")}
+	factory.RegisterHook(hook)
+	txContext, _ := factory.GetTransactionContext(context.Background())
+
+	type order struct{ ID int }
+	mock.ExpectQuery(`SELECT * FROM "orders"`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var orders []order
+	assert.NoError(t, txContext.Provider().Table("orders").Find(&orders).Error)
+	assert.EqualError(t, txContext.Provider().Table("veto").Find(&orders).Error, "vetoed")
+	id, err := txContext.Begin()
+	assert.NoError(t, err)
+	assert.ErrorContains(t, txContext.Commit(id), "before-commit hook: quota exceeded")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, []string{
+		"before query orders", `after query SELECT * FROM "orders"  `,
+		"before query veto",
+		"before commit", "after rollback",
+	}, hook.calls)
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"strings"
+)
+
+// QuoteIdentifier quotes name as a Postgres identifier, doubling embedded double quotes.
+// A qualified name such as "schema.table" is quoted part by part.
+// Example:
+//
+//	QuoteIdentifier(`audit.Order "Items"`) // "audit"."Order ""Items"""
+func QuoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = quoteName(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteName quotes name as a single Postgres identifier, even if it contains dots.
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
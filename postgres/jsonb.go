@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+type (
+	// JSONB is a JSON object stored in a jsonb column. A nil JSONB is stored as NULL. GORM v1 does not know
+	// the column type of map fields, so declare it for AutoMigrate.
+	// Example:
+	//
+	//	type Product struct {
+	//	  ID         int
+	//	  Attributes postgres.JSONB `gorm:"type:jsonb"`
+	//	}
+	JSONB map[string]interface{}
+
+	// JSONBOf stores a value of any type as JSON in a jsonb column, typically a struct describing the document.
+	// Example:
+	//
+	//	type Order struct {
+	//	  ID      int
+	//	  Address postgres.JSONBOf[Address] `gorm:"type:jsonb"`
+	//	}
+	JSONBOf[T any] struct {
+		Data T // Data is the decoded document.
+	}
+
+	// jsonbContains is a Specification matching jsonb fields containing a document.
+	jsonbContains struct {
+		field string
+		value interface{}
+	}
+
+	// jsonbHasKey is a Specification matching jsonb fields with a top-level key.
+	jsonbHasKey struct {
+		field string
+		key   string
+	}
+)
+
+// Value implements driver.Valuer.
+func (j JSONB) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return marshalJSONB(map[string]interface{}(j))
+}
+
+// Scan implements sql.Scanner.
+func (j *JSONB) Scan(src interface{}) error {
+	if src == nil {
+		*j = nil
+		return nil
+	}
+	var object map[string]interface{}
+	if err := unmarshalJSONB(src, &object); err != nil {
+		return err
+	}
+	*j = object
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (j JSONBOf[T]) Value() (driver.Value, error) {
+	return marshalJSONB(j.Data)
+}
+
+// Scan implements sql.Scanner. NULL is scanned as the zero value of T.
+func (j *JSONBOf[T]) Scan(src interface{}) error {
+	var data T
+	if src != nil {
+		if err := unmarshalJSONB(src, &data); err != nil {
+			return err
+		}
+	}
+	j.Data = data
+	return nil
+}
+
+// marshalJSONB encodes value as the text of a jsonb parameter.
+func marshalJSONB(value interface{}) (driver.Value, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode jsonb: %w", err)
+	}
+	return string(data), nil
+}
+
+// unmarshalJSONB decodes a jsonb column into target.
+func unmarshalJSONB(src interface{}, target interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into jsonb", src)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("cannot decode jsonb: %w", err)
+	}
+	return nil
+}
+
+// ToSQL implements Specification.
+func (c jsonbContains) ToSQL() (string, []interface{}) {
+	return fmt.Sprintf("(%s @> ?::jsonb)", QuoteIdentifier(c.field)), []interface{}{JSONBOf[interface{}]{Data: c.value}}
+}
+
+// JSONBContains matches rows whose jsonb field contains value, encoded as JSON, with the @> operator; a GIN
+// index on the field serves it.
+// Example:
+//
+//	spec := JSONBContains("attributes", map[string]interface{}{"color": "red", "tags": []string{"sale"}})
+func JSONBContains(field string, value interface{}) Specification {
+	return jsonbContains{field: field, value: value}
+}
+
+// ToSQL implements Specification. It calls jsonb_exists, as GORM takes the ? operator for a placeholder.
+func (h jsonbHasKey) ToSQL() (string, []interface{}) {
+	return fmt.Sprintf("(jsonb_exists(%s, ?))", QuoteIdentifier(h.field)), []interface{}{h.key}
+}
+
+// JSONBHasKey matches rows whose jsonb field is an object with the top-level key.
+func JSONBHasKey(field string, key string) Specification {
+	return jsonbHasKey{field: field, key: key}
+}
+
+// WhereJSONBContains is a scope restricted to the rows whose jsonb field contains value, see JSONBContains,
+// for use with Repository.List or (*gorm.DB).Scopes.
+// Example:
+//
+//	err := tx.Provider().Scopes(WhereJSONBContains("attributes", JSONB{"color": "red"})).Find(&products).Error
+func WhereJSONBContains(field string, value interface{}) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		expression, args := JSONBContains(field, value).ToSQL()
+		return db.Where(expression, args...)
+	}
+}
+
+// JSONBPath returns the SQL expression extracting the element at path of the jsonb field as text, with the
+// #>> operator, for conditions, orderings and selections. It is NULL where the path does not exist. As GORM
+// takes ? for a placeholder, path elements must not contain it.
+// Example:
+//
+//	err := tx.Provider().Where(JSONBPath("address", "city")+" = ?", "Berlin").
+//	  Order(JSONBPath("address", "zip")).Find(&orders).Error
+func JSONBPath(field string, path ...string) string {
+	elements := make([]string, len(path))
+	for i, element := range path {
+		elements[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(element) + `"`
+	}
+	literal := "{" + strings.Join(elements, ",") + "}"
+	return fmt.Sprintf("(%s #>> '%s')", QuoteIdentifier(field), strings.ReplaceAll(literal, "'", "''"))
+}
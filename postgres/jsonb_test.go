@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// jsonbProduct is the model of the JSONB tests.
+type jsonbProduct struct {
+	ID         int
+	Attributes JSONB                                `gorm:"type:jsonb"`
+	Dimensions JSONBOf[struct{ Width, Height int }] `gorm:"type:jsonb"`
+}
+
+// Test JSONB and JSONBOf to verify they are written and scanned as JSON, with NULL for nil objects
+func TestJSONB_ValueScan(t *testing.T) {
+	value, err := JSONB{"color": "red"}.Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"color":"red"}`, value)
+	value, err = JSONB(nil).Value()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	var object JSONB
+	assert.NoError(t, object.Scan([]byte(`{"size":2}`)))
+	assert.Equal(t, JSONB{"size": float64(2)}, object)
+	assert.NoError(t, object.Scan(nil))
+	assert.Nil(t, object)
+	assert.ErrorContains(t, object.Scan(42), "cannot scan int into jsonb")
+
+	var dimensions JSONBOf[struct{ Width, Height int }]
+	assert.NoError(t, dimensions.Scan(`{"Width":3,"Height":4}`))
+	assert.Equal(t, 3, dimensions.Data.Width)
+	assert.ErrorContains(t, dimensions.Scan(`{`), "cannot decode jsonb")
+}
+
+// Test the JSONB specifications and JSONBPath to verify the SQL they render
+func TestJSONB_ToSQL(t *testing.T) {
+	expression, args := And(JSONBContains("attributes", []string{"sale"}), JSONBHasKey("attributes", "color")).ToSQL()
+	assert.Equal(t, `(("attributes" @> ?::jsonb) AND (jsonb_exists("attributes", ?)))`, expression)
+	assert.Len(t, args, 2)
+	value, err := args[0].(JSONBOf[interface{}]).Value()
+	assert.NoError(t, err)
+	assert.Equal(t, `["sale"]`, value)
+	assert.Equal(t, "color", args[1])
+
+	assert.Equal(t, `("address" #>> '{"city"}')`, JSONBPath("address", "city"))
+	assert.Equal(t, `("p"."doc" #>> '{"a\"b","it''s"}')`, JSONBPath("p.doc", `a"b`, "it's"))
+}
+
+// Test WhereJSONBContains to verify models are queried and decoded through the Provider
+func TestWhereJSONBContains(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT * FROM "jsonb_products"  WHERE (("attributes" @> $1::jsonb)) AND (("attributes" #>> '{"size"}') = $2)`).
+		WithArgs(`{"color":"red"}`, "L").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "attributes", "dimensions"}).AddRow(1, []byte(`{"color":"red","size":"L"}`), `{"Width":2}`))
+
+	var products []jsonbProduct
+	err := tx.Provider().Scopes(WhereJSONBContains("attributes", JSONB{"color": "red"})).
+		Where(JSONBPath("attributes", "size")+" = ?", "L").Find(&products).Error
+
+	assert.NoError(t, err)
+	assert.Equal(t, []jsonbProduct{{ID: 1, Attributes: JSONB{"color": "red", "size": "L"}, Dimensions: JSONBOf[struct{ Width, Height int }]{Data: struct{ Width, Height int }{Width: 2}}}}, products)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	log "github.com/public-forge/go-logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultLeaderRetryInterval defines how often leadership is checked when LeaderElectorConfig.RetryInterval is not set.
+const defaultLeaderRetryInterval = 5 * time.Second
+
+type (
+	// LeaderElectorConfig holds the settings of a LeaderElector. Zero values fall back to defaults.
+	LeaderElectorConfig struct {
+		Key           int64                     // Key is the advisory lock key all candidates compete for, e.g. AdvisoryLockKey("scheduler").
+		RetryInterval time.Duration             // RetryInterval is how often followers try to acquire the lock and the leader checks its connection.
+		OnElected     func(ctx context.Context) // OnElected, when set, runs in its own goroutine once elected; ctx is cancelled when leadership is lost.
+		OnRevoked     func()                    // OnRevoked, when set, is called when leadership is lost or given up.
+		Logger        log.Logger                // Logger used by the elector; defaults to the default logger.
+		Clock         Clock                     // Clock spaces the attempts and checks; defaults to SystemClock.
+	}
+
+	// LeaderElector elects a single leader among the instances of a service by holding a session-level
+	// advisory lock on a dedicated connection of the holder. If the connection breaks, Postgres releases
+	// the lock, the leader steps down and another instance (or the same one, once reconnected) takes over.
+	LeaderElector struct {
+		holder *DatabaseHolder     // Holder providing the dedicated connection.
+		config LeaderElectorConfig // Settings of the elector.
+		leader atomic.Bool         // Whether this instance currently leads.
+
+		mu     sync.Mutex         // Guards the fields below.
+		lock   *AdvisoryLock      // Lock held while leading.
+		cancel context.CancelFunc // Cancels the context passed to OnElected.
+	}
+)
+
+// NewLeaderElector creates a LeaderElector competing through holder.
+// Example:
+//
+//	elector := NewLeaderElector(holder, LeaderElectorConfig{
+//	  Key:       AdvisoryLockKey("billing:scheduler"),
+//	  OnElected: func(ctx context.Context) { scheduler.Run(ctx) },
+//	})
+//	go elector.Run(ctx)
+func NewLeaderElector(holder *DatabaseHolder, config LeaderElectorConfig) *LeaderElector {
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = defaultLeaderRetryInterval
+	}
+	if config.Logger == nil {
+		config.Logger = log.FromDefaultContext()
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+	return &LeaderElector{holder: holder, config: config}
+}
+
+// IsLeader reports whether this instance currently leads.
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run takes part in the election until ctx is done, then gives up leadership. It blocks.
+func (e *LeaderElector) Run(ctx context.Context) {
+	defer e.stepDown()
+	for {
+		if e.IsLeader() {
+			e.checkLeadership(ctx)
+		} else {
+			e.tryToLead(ctx)
+		}
+		if e.config.Clock.Sleep(ctx, e.config.RetryInterval) != nil {
+			return
+		}
+	}
+}
+
+// tryToLead acquires the lock if no other instance holds it.
+func (e *LeaderElector) tryToLead(ctx context.Context) {
+	db, err := e.holder.connection()
+	if err != nil {
+		e.config.Logger.Warnf("leader election %d: cannot connect: %s", e.config.Key, err)
+		return
+	}
+	lock, err := sessionAdvisoryLock(ctx, db.DB(), e.config.Key, "pg_try_advisory_lock")
+	if err != nil {
+		e.config.Logger.Warnf("leader election %d: cannot acquire lock: %s", e.config.Key, err)
+		return
+	}
+	if lock == nil {
+		return
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.mu.Lock()
+	e.lock, e.cancel = lock, cancel
+	e.mu.Unlock()
+	e.leader.Store(true)
+	e.config.Logger.Infof("leader election %d: elected", e.config.Key)
+	if e.config.OnElected != nil {
+		go e.config.OnElected(leaderCtx)
+	}
+}
+
+// checkLeadership steps down if the connection holding the lock is broken.
+func (e *LeaderElector) checkLeadership(ctx context.Context) {
+	e.mu.Lock()
+	lock := e.lock
+	e.mu.Unlock()
+	if lock == nil || lock.conn == nil {
+		return
+	}
+	if err := lock.conn.PingContext(ctx); err != nil && ctx.Err() == nil {
+		e.config.Logger.Warnf("leader election %d: lost connection, stepping down: %s", e.config.Key, err)
+		e.stepDown()
+	}
+}
+
+// stepDown gives up leadership, if held, releasing the lock and cancelling the leader context.
+func (e *LeaderElector) stepDown() {
+	e.mu.Lock()
+	lock, cancel := e.lock, e.cancel
+	e.lock, e.cancel = nil, nil
+	e.mu.Unlock()
+	if lock == nil {
+		return
+	}
+
+	e.leader.Store(false)
+	cancel()
+	if err := lock.Release(); err != nil {
+		e.config.Logger.Debugf("leader election %d: release: %s", e.config.Key, err)
+	}
+	if e.config.OnRevoked != nil {
+		e.config.OnRevoked()
+	}
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test that the elector leads while holding the lock and steps down when its connection breaks
+func TestLeaderElector(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual), sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	mock.ExpectPing() // by gorm.Open
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT pg_try_advisory_lock($1)").WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectPing().WillReturnError(errors.New("connection reset"))
+	mock.ExpectExec("SELECT pg_advisory_unlock($1)").WithArgs(int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	elected := make(chan context.Context, 1)
+	elector := NewLeaderElector(NewDBHolder(db), LeaderElectorConfig{
+		Key:           7,
+		RetryInterval: 10 * time.Millisecond,
+		OnElected:     func(ctx context.Context) { elected <- ctx },
+		OnRevoked:     cancel,
+	})
+	done := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(done)
+	}()
+
+	leaderCtx := <-elected
+	<-done
+	assert.Error(t, leaderCtx.Err())
+	assert.False(t, elector.IsLeader())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
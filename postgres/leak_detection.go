@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// LeakReport describes a transaction that was neither committed nor rolled back in time.
+	LeakReport struct {
+		TxInfo                  // TxInfo describes the leaked transaction.
+		Stack     string        // Stack is the stack trace captured at Begin().
+		Age       time.Duration // Age is the time the transaction has been open.
+		Collected bool          // Collected is set if the transaction context was garbage collected with the tx open.
+	}
+
+	// leakDetection configures the leak detection of a transaction context.
+	leakDetection struct {
+		maxAge time.Duration    // Transactions open for longer are reported; zero only reports collected ones.
+		hook   func(LeakReport) // Receives the reports; nil logs them.
+	}
+
+	// leakWatch watches a single transaction. It does not reference the transaction context,
+	// so a pending watchdog does not keep a leaked context from being garbage collected.
+	leakWatch struct {
+		report LeakReport       // Report handed to the hook.
+		hook   func(LeakReport) // Receives the report.
+		timer  *time.Timer      // Fires once the transaction exceeds maxAge.
+		done   atomic.Bool      // Set once the transaction is disposed or reported.
+	}
+)
+
+// WithLeakDetection reports transactions of the context that stay open for longer than maxAge, as well as
+// transaction contexts garbage collected with an open transaction; in the latter case the transaction is
+// rolled back to release its connection. Reports carry the stack trace captured at Begin() and are handed
+// to hook, or logged as errors if hook is nil. A zero maxAge only reports collected contexts.
+// Garbage collection is detected on a best-effort basis, since the runtime does not guarantee to run finalizers.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithLeakDetection(time.Minute, nil))
+func WithLeakDetection(maxAge time.Duration, hook func(LeakReport)) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.leakDetection = &leakDetection{maxAge: maxAge, hook: hook}
+	}
+}
+
+// watchForLeaks starts watching the transaction that has just begun.
+func (c *transactionContext) watchForLeaks() {
+	if c.leakDetection == nil {
+		return
+	}
+	hook := c.leakDetection.hook
+	if hook == nil {
+		logger := c.logger
+		hook = func(report LeakReport) {
+			logger.Errorf("leaked transaction %v (open for %s, collected: %t) begun at %s\n%s",
+				report.UUID, report.Age, report.Collected, report.Caller, report.Stack)
+		}
+	}
+
+	watch := &leakWatch{report: LeakReport{TxInfo: c.txInfo, Stack: string(debug.Stack())}, hook: hook}
+	if maxAge := c.leakDetection.maxAge; maxAge > 0 {
+		watch.timer = time.AfterFunc(maxAge, func() { watch.fire(false) })
+	}
+	if c.leakWatch == nil {
+		runtime.SetFinalizer(c, (*transactionContext).finalizeLeaked)
+	}
+	c.leakWatch = watch
+}
+
+// stopLeakWatch stops watching the disposed transaction.
+func (c *transactionContext) stopLeakWatch() {
+	if c.leakWatch == nil {
+		return
+	}
+	c.leakWatch.done.Store(true)
+	if c.leakWatch.timer != nil {
+		c.leakWatch.timer.Stop()
+	}
+}
+
+// finalizeLeaked reports and rolls back the transaction of a garbage collected transaction context.
+func (c *transactionContext) finalizeLeaked() {
+	if !c.inTransaction() {
+		return
+	}
+	c.leakWatch.done.Store(true)
+	c.leakWatch.emit(true) // reported even if the watchdog has already fired
+	_ = c.tx.Rollback()
+}
+
+// fire hands the report to the hook unless the transaction has been disposed or reported.
+func (w *leakWatch) fire(collected bool) {
+	if w.done.CompareAndSwap(false, true) {
+		w.emit(collected)
+	}
+}
+
+// emit hands the report to the hook.
+func (w *leakWatch) emit(collected bool) {
+	report := w.report
+	report.Age = time.Since(report.Start)
+	report.Collected = collected
+	w.hook(report)
+}
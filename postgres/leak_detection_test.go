@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// Test that a transaction open for longer than maxAge is reported with its Begin() stack
+func TestWithLeakDetection_ReportsOldTransaction(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	reports := make(chan LeakReport, 1)
+	WithLeakDetection(10*time.Millisecond, func(report LeakReport) { reports <- report })(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+
+	select {
+	case report := <-reports:
+		assert.Equal(t, id, report.UUID)
+		assert.False(t, report.Collected)
+		assert.Contains(t, report.Stack, "TestWithLeakDetection_ReportsOldTransaction")
+		assert.GreaterOrEqual(t, report.Age, 10*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("leaked transaction was not reported")
+	}
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that transactions disposed in time are not reported
+func TestWithLeakDetection_IgnoresDisposedTransaction(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	reported := false
+	WithLeakDetection(10*time.Millisecond, func(LeakReport) { reported = true })(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, _ := tx.Begin()
+	assert.NoError(t, tx.Commit(id))
+	time.Sleep(30 * time.Millisecond)
+	assert.False(t, reported)
+}
+
+// Test that a transaction context garbage collected with an open transaction is reported and rolled back,
+// also when the transaction has been used with hooks, tracing and the callbacks of the scope
+func TestWithLeakDetection_ReportsCollectedContext(t *testing.T) {
+	for name, opts := range map[string][]TransactionContextOption{
+		"plain":   nil,
+		"hooks":   {WithHooks(NopHook{})},
+		"tracing": {WithDatadogTracing(sdktrace.NewTracerProvider(), "billing")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			base, db, mock := getTestTransactionContext(t)
+			defer db.Close()
+			RegisterChangeCapture(db, ChangeCaptureConfig{}, func(context.Context, []RowChange) {})
+			reports := make(chan LeakReport, 1)
+			opts := append(opts, WithLeakDetection(0, func(report LeakReport) { reports <- report }))
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`INSERT INTO "leaked_models" ("name") VALUES ($1) RETURNING "leaked_models"."id"`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectRollback()
+			leakTransaction(t, newTransactionContext(base.logger, base.dbHolder, opts...))
+
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				runtime.GC()
+				select {
+				case report := <-reports:
+					assert.True(t, report.Collected)
+					assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			t.Fatal("collected transaction context was not reported")
+		})
+	}
+}
+
+// leakedModel is written by the leaked transactions of the leak detection tests.
+type leakedModel struct {
+	ID   int
+	Name string
+}
+
+// leakTransaction begins a transaction on tx, writes through it and drops tx without disposing of it.
+func leakTransaction(t *testing.T, tx *transactionContext) {
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Create(&leakedModel{Name: "leaked"}).Error)
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+type (
+	// TxInfo describes a transaction started by a transaction context of a holder.
+	TxInfo struct {
+		UUID   uuid.UUID // UUID is the identifier returned to the transaction owner by Begin().
+		Start  time.Time // Start is the time Begin() started the transaction.
+		Caller string    // Caller is the file:line outside this package that called Begin().
+		Name   string    // Name is TxOptions.Name of the transaction, empty if it was not named.
+	}
+
+	// txObserver is notified about the lifecycle of every transaction of a holder.
+	txObserver interface {
+		txBegun(tx TxInfo)
+		txEnded(tx TxInfo, committed bool)
+	}
+
+	// txObservers is the set of observers registered on a holder.
+	txObservers struct {
+		mu   sync.RWMutex // Guards list.
+		list []txObserver // Observers in registration order.
+	}
+)
+
+// add registers observer.
+func (o *txObservers) add(observer txObserver) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.list = append(o.list, observer)
+}
+
+// snapshot returns the registered observers.
+func (o *txObservers) snapshot() []txObserver {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.list
+}
+
+// notifyBegun records the start of the running transaction and informs the holder's observers.
+func (c *transactionContext) notifyBegun() {
+	c.txInfo = TxInfo{UUID: *c.transactionUUID, Start: time.Now(), Caller: callerOutsidePackage(), Name: c.txOptions.Name}
+	c.dbHolder.activeTransactions.txBegun(c.txInfo)
+	for _, observer := range c.dbHolder.txObservers.snapshot() {
+		observer.txBegun(c.txInfo)
+	}
+}
+
+// notifyEnded informs the holder's observers that the running transaction has been disposed.
+func (c *transactionContext) notifyEnded() {
+	if c.txInfo.UUID == uuid.Nil {
+		return // the transaction failed to begin
+	}
+	c.dbHolder.activeTransactions.txEnded(c.txInfo, c.txCommitted)
+	for _, observer := range c.dbHolder.txObservers.snapshot() {
+		observer.txEnded(c.txInfo, c.txCommitted)
+	}
+	c.txInfo = TxInfo{}
+}
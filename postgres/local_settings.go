@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+)
+
+type (
+	// ContextValueFunc extracts a setting value from the context a transaction context was created for.
+	// The second result reports whether the context carries one; settings without a value are not applied.
+	ContextValueFunc func(ctx context.Context) (string, bool)
+
+	// localSetting is a configuration parameter set with SET LOCAL semantics when a transaction begins.
+	localSetting struct {
+		name  string           // Name of the configuration parameter, e.g. "search_path".
+		value ContextValueFunc // Resolves the value from the context of the transaction context.
+	}
+)
+
+// withLocalSetting appends a setting applied at the beginning of every transaction of the context.
+func withLocalSetting(name string, value ContextValueFunc) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.localSettings = append(c.localSettings, localSetting{name: name, value: value})
+	}
+}
+
+// applyLocalSettings sets the local settings and the session variables of the context in the running
+// transaction. set_config with is_local = true behaves like SET LOCAL: the values last until the
+// transaction ends, so they never leak into the next user of the pooled connection.
+func (c *transactionContext) applyLocalSettings() error {
+	if c.ctx == nil {
+		return nil
+	}
+	for _, setting := range c.localSettings {
+		value, ok := setting.value(c.ctx)
+		if !ok {
+			continue
+		}
+		if err := c.setLocal(setting.name, value); err != nil {
+			return err
+		}
+	}
+	return c.applySessionVariables(c.ctx)
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	log "github.com/public-forge/go-logger"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceIDLogField is the log field carrying the OpenTelemetry trace ID, added by WithTraceLogFields.
+	TraceIDLogField = "trace_id"
+	// SpanIDLogField is the log field carrying the OpenTelemetry span ID, added by WithTraceLogFields.
+	SpanIDLogField = "span_id"
+	// TxIDLogField is the log field carrying the UUID of the running transaction.
+	TxIDLogField = "tx_id"
+	// TxNameLogField is the log field carrying TxOptions.Name of a named transaction.
+	TxNameLogField = "tx_name"
+)
+
+// logField is a request-scoped field added to the log lines of a transaction context.
+type logField struct {
+	key   string           // Key of the field.
+	value ContextValueFunc // Resolves the value from the context of the transaction context.
+}
+
+// WithLogField adds the field key, with the value returned by value for the context passed to
+// GetTransactionContext, to the log lines of the transaction context and to the SQL statements logged in
+// its transactions, so database logs can be joined with the logs of the request. Contexts without a value
+// are logged without the field.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(r.Context(), WithLogField("request_id", func(ctx context.Context) (string, bool) {
+//	  id := middleware.GetReqID(ctx)
+//	  return id, id != ""
+//	}))
+func WithLogField(key string, value ContextValueFunc) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.logFields = append(c.logFields, logField{key: key, value: value})
+	}
+}
+
+// WithTraceLogFields adds the trace and span IDs of the OpenTelemetry span of the context passed to
+// GetTransactionContext as TraceIDLogField and SpanIDLogField, as WithLogField does.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(r.Context(), WithTracing(nil), WithTraceLogFields())
+func WithTraceLogFields() TransactionContextOption {
+	return func(c *transactionContext) {
+		WithLogField(TraceIDLogField, func(ctx context.Context) (string, bool) {
+			spanContext := trace.SpanContextFromContext(ctx)
+			return spanContext.TraceID().String(), spanContext.HasTraceID()
+		})(c)
+		WithLogField(SpanIDLogField, func(ctx context.Context) (string, bool) {
+			spanContext := trace.SpanContextFromContext(ctx)
+			return spanContext.SpanID().String(), spanContext.HasSpanID()
+		})(c)
+	}
+}
+
+// withLogFields returns logger with the log fields of the transaction context resolved for ctx.
+func (c *transactionContext) withLogFields(logger log.Logger, ctx context.Context) log.Logger {
+	return applyLogFields(logger, ctx, c.logFields)
+}
+
+// withLogFields returns logger with the log fields of the transaction resolved for ctx.
+func (h *txHandle) withLogFields(logger log.Logger, ctx context.Context) log.Logger {
+	return applyLogFields(logger, ctx, h.logFields)
+}
+
+// applyLogFields returns logger with fields resolved for ctx.
+func applyLogFields(logger log.Logger, ctx context.Context, fields []logField) log.Logger {
+	for _, field := range fields {
+		if value, ok := field.value(ctx); ok {
+			logger = logger.WithField(field.key, value)
+		}
+	}
+	return logger
+}
+
+// useTxLogger derives the logger of the transaction that has just begun, carrying TxIDLogField, and
+// logs its statements through it.
+func (c *transactionContext) useTxLogger() {
+	c.baseLogger = c.logger
+	c.logger = c.logger.WithField(TxIDLogField, c.transactionUUID.String())
+	if c.txOptions.Name != "" {
+		c.logger = c.logger.WithField(TxNameLogField, c.txOptions.Name)
+	}
+	c.tx.SetLogger(newRedactingLogger(c.logger, c.dbHolder.logRedactions))
+}
+
+// restoreLogger drops the logger of the disposed transaction.
+func (c *transactionContext) restoreLogger() {
+	if c.baseLogger != nil {
+		c.logger, c.baseLogger = c.baseLogger, nil
+	}
+}
+
+// LoggerFromContext returns the logger of the running transaction of the transaction context in ctx,
+// which carries the TxIDLogField of the transaction and the fields added with WithLogField, so the logs
+// of repositories can be correlated with the unit of work. Outside a transaction it returns the logger
+// of ctx, as log.FromContext does.
+// Example:
+//
+//	LoggerFromContext(ctx).Infof("order %d created", order.ID)
+func LoggerFromContext(ctx context.Context) log.Logger {
+	if c, ok := ctx.Value(TransactionContextKey).(*transactionContext); ok && c.inTransaction() {
+		return c.logger
+	}
+	return log.FromContext(ctx)
+}
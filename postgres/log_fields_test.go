@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+// fieldsLogger records the fields added with WithField and the lines printed by GORM.
+type fieldsLogger struct {
+	log.Logger
+	fields []interface{}    // Fields added with WithField.
+	lines  *[][]interface{} // Lines printed through any logger derived from the fieldsLogger.
+}
+
+func (l *fieldsLogger) WithField(key string, value interface{}) log.Logger {
+	return &fieldsLogger{Logger: l.Logger, fields: append(append([]interface{}{}, l.fields...), key, value), lines: l.lines}
+}
+
+func (l *fieldsLogger) Print(v ...interface{}) {
+	*l.lines = append(*l.lines, append(append([]interface{}{}, l.fields...), v...))
+}
+
+// requestIDKey is the context key of the request ID in the tests.
+type requestIDKey struct{}
+
+// Test the log fields of a transaction context to verify they are resolved from its context and added to SQL logs
+func TestWithLogField(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open("postgres", db)
+	assert.NoError(t, err)
+	defer gormDB.Close()
+	gormDB.LogMode(true)
+	holder := NewDBHolder(gormDB)
+
+	var lines [][]interface{}
+	logger := &fieldsLogger{Logger: log.FromDefaultContext(), lines: &lines}
+	ctx := log.ToContext(context.WithValue(context.Background(), requestIDKey{}, "r-1"), logger)
+	txContext, _ := NewTransactionContextFactory(holder).GetTransactionContext(ctx,
+		WithLogField("request_id", func(ctx context.Context) (string, bool) {
+			id, ok := ctx.Value(requestIDKey{}).(string)
+			return id, ok
+		}),
+		WithLogField("tenant", func(ctx context.Context) (string, bool) { return "", false }),
+	)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT 1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	id, err := txContext.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, txContext.Provider().Exec("SELECT 1").Error)
+	assert.NoError(t, txContext.Commit(id))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, []interface{}{"request_id", "r-1", TxIDLogField, id.String(), "sql"}, lines[0][:5])
+	}
+}
+
+// Test the trace log fields to verify the IDs of the span of the context are logged
+func TestWithTraceLogFields(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	c := newTransactionContext(log.FromDefaultContext(), nil, WithTraceLogFields())
+
+	logger := c.withLogFields(&fieldsLogger{}, ctx).(*fieldsLogger)
+	assert.Equal(t, []interface{}{TraceIDLogField, traceID.String(), SpanIDLogField, spanID.String()}, logger.fields)
+
+	empty := &fieldsLogger{}
+	assert.Same(t, empty, c.withLogFields(empty, context.Background()))
+}
+
+// Test LoggerFromContext to verify the logger carries the tx_id of the running transaction only
+func TestLoggerFromContext(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	var lines [][]interface{}
+	logger := &fieldsLogger{Logger: log.FromDefaultContext(), lines: &lines}
+	tx.logger = logger
+	ctx := log.ToContext(context.WithValue(context.Background(), TransactionContextKey, tx), logger)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	assert.Same(t, logger, LoggerFromContext(ctx))
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	txLogger, ok := LoggerFromContext(ctx).(*fieldsLogger)
+	if assert.True(t, ok) {
+		assert.Equal(t, []interface{}{TxIDLogField, id.String()}, txLogger.fields)
+	}
+	assert.NoError(t, tx.Commit(id))
+	assert.Same(t, logger, LoggerFromContext(ctx))
+	assert.Same(t, logger, tx.logger)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
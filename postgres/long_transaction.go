@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"github.com/google/uuid"
+	log "github.com/public-forge/go-logger"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+type (
+	// LongTransactionReport describes a transaction that is still open after the configured age.
+	LongTransactionReport struct {
+		TxInfo               // TxInfo describes the transaction, including its start time.
+		Stack  string        // Stack is the stack trace captured at Begin().
+		Age    time.Duration // Age is the time the transaction has been open.
+	}
+
+	// longTransactionWatch is a txObserver reporting the transactions of a holder open for longer than maxAge.
+	longTransactionWatch struct {
+		maxAge time.Duration               // Transactions open for longer are reported.
+		hook   func(LongTransactionReport) // Receives the reports.
+
+		mu     sync.Mutex                // Guards timers.
+		timers map[uuid.UUID]*time.Timer // Pending reports of the running transactions.
+	}
+)
+
+// WarnLongTransactions reports the transactions of the holder that are still open maxAge after Begin(),
+// once per transaction, so long-running units of work are noticed before they hold back vacuum. Reports
+// carry the UUID, the start time and the stack trace captured at Begin(), and are handed to hook, or logged
+// as warnings if hook is nil. Unlike WithTxTimeout, the transactions are not rolled back.
+// PgConfig.LongTxWarningMS enables the warnings for holders created from a configuration.
+// Example:
+//
+//	holder.WarnLongTransactions(30*time.Second, func(report LongTransactionReport) {
+//	  alerts.Notify("transaction %v open for %s", report.UUID, report.Age)
+//	})
+func (h *DatabaseHolder) WarnLongTransactions(maxAge time.Duration, hook func(LongTransactionReport)) {
+	if hook == nil {
+		logger := log.FromDefaultContext()
+		hook = func(report LongTransactionReport) {
+			logger.Warnf("transaction %v still open after %s; begun at %s by %s\n%s",
+				report.UUID, report.Age, report.Start.Format(time.RFC3339Nano), report.Caller, report.Stack)
+		}
+	}
+	h.txObservers.add(&longTransactionWatch{maxAge: maxAge, hook: hook, timers: map[uuid.UUID]*time.Timer{}})
+}
+
+// txBegun implements txObserver; it is called by Begin(), so the captured stack shows its caller.
+func (w *longTransactionWatch) txBegun(tx TxInfo) {
+	report := LongTransactionReport{TxInfo: tx, Stack: string(debug.Stack())}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timers[tx.UUID] = time.AfterFunc(w.maxAge, func() {
+		w.mu.Lock()
+		_, running := w.timers[tx.UUID]
+		delete(w.timers, tx.UUID)
+		w.mu.Unlock()
+		if running {
+			report.Age = time.Since(report.Start)
+			w.hook(report)
+		}
+	})
+}
+
+// txEnded implements txObserver.
+func (w *longTransactionWatch) txEnded(tx TxInfo, _ bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if timer, ok := w.timers[tx.UUID]; ok {
+		timer.Stop()
+		delete(w.timers, tx.UUID)
+	}
+}
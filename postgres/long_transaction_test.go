@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test that a transaction still open after maxAge is reported once with its start time and Begin() stack
+func TestWarnLongTransactions_ReportsOpenTransaction(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	reports := make(chan LongTransactionReport, 2)
+	tx.dbHolder.WarnLongTransactions(10*time.Millisecond, func(report LongTransactionReport) { reports <- report })
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+
+	select {
+	case report := <-reports:
+		assert.Equal(t, id, report.UUID)
+		assert.False(t, report.Start.IsZero())
+		assert.Contains(t, report.Stack, "TestWarnLongTransactions_ReportsOpenTransaction")
+		assert.GreaterOrEqual(t, report.Age, 10*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("long transaction was not reported")
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, reports)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that transactions ending in time are not reported
+func TestWarnLongTransactions_IgnoresShortTransaction(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	var reported atomic.Bool
+	tx.dbHolder.WarnLongTransactions(10*time.Millisecond, func(LongTransactionReport) { reported.Store(true) })
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+	time.Sleep(20 * time.Millisecond)
+
+	assert.False(t, reported.Load())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const (
+	// metricsNamespace prefixes the metrics reported by Collector.
+	metricsNamespace = "uow"
+	// txNameLabel is the label of the transaction metrics carrying TxOptions.Name.
+	txNameLabel = "tx_name"
+)
+
+// Collector is a prometheus.Collector reporting the connection pool statistics of a DatabaseHolder
+// and the lifecycle of its transactions.
+type Collector struct {
+	holder *DatabaseHolder // Holder whose pool and transactions are reported.
+
+	begun      *prometheus.CounterVec   // Transactions started, by name.
+	committed  *prometheus.CounterVec   // Transactions committed, by name.
+	rolledBack *prometheus.CounterVec   // Transactions rolled back, including failed commits, by name.
+	duration   *prometheus.HistogramVec // Time from Begin() until commit or rollback, by name.
+
+	maxOpen           *prometheus.Desc // sql.DBStats.MaxOpenConnections
+	open              *prometheus.Desc // sql.DBStats.OpenConnections
+	inUse             *prometheus.Desc // sql.DBStats.InUse
+	idle              *prometheus.Desc // sql.DBStats.Idle
+	waitCount         *prometheus.Desc // sql.DBStats.WaitCount
+	waitDuration      *prometheus.Desc // sql.DBStats.WaitDuration
+	maxIdleClosed     *prometheus.Desc // sql.DBStats.MaxIdleClosed
+	maxIdleTimeClosed *prometheus.Desc // sql.DBStats.MaxIdleTimeClosed
+	maxLifetimeClosed *prometheus.Desc // sql.DBStats.MaxLifetimeClosed
+
+	stmtCacheHits      *prometheus.Desc // StmtCacheStats.Hits
+	stmtCacheMisses    *prometheus.Desc // StmtCacheStats.Misses
+	stmtCacheEvictions *prometheus.Desc // StmtCacheStats.Evictions
+}
+
+// NewCollector creates a Collector for holder. dbName is reported as the "db_name" label,
+// so collectors of several databases can be registered side by side. The transaction metrics carry
+// TxOptions.Name as the "tx_name" label, empty for unnamed transactions, so names should be static.
+// Example:
+//
+//	prometheus.MustRegister(postgres.NewCollector(dbHolder, "orders"))
+func NewCollector(holder *DatabaseHolder, dbName string) *Collector {
+	labels := prometheus.Labels{"db_name": dbName}
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace, Subsystem: "transactions", Name: name, Help: help, ConstLabels: labels,
+		}, []string{txNameLabel})
+	}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db", name), help, nil, labels)
+	}
+
+	c := &Collector{
+		holder:     holder,
+		begun:      counter("begun_total", "Number of transactions started."),
+		committed:  counter("committed_total", "Number of transactions committed."),
+		rolledBack: counter("rolled_back_total", "Number of transactions rolled back."),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace, Subsystem: "transactions", Name: "duration_seconds",
+			Help: "Duration of transactions from begin until commit or rollback.", ConstLabels: labels,
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{txNameLabel}),
+
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "The number of established connections both in use and idle."),
+		inUse:             desc("in_use_connections", "The number of connections currently in use."),
+		idle:              desc("idle_connections", "The number of idle connections."),
+		waitCount:         desc("wait_count_total", "The total number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns."),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime."),
+
+		stmtCacheHits:      desc("statement_cache_hits_total", "The total number of statements executed with a cached prepared statement."),
+		stmtCacheMisses:    desc("statement_cache_misses_total", "The total number of statements prepared for the statement cache."),
+		stmtCacheEvictions: desc("statement_cache_evictions_total", "The total number of prepared statements evicted from the statement cache."),
+	}
+	holder.txObservers.add(c)
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.begun.Describe(ch)
+	c.committed.Describe(ch)
+	c.rolledBack.Describe(ch)
+	c.duration.Describe(ch)
+	for _, desc := range []*prometheus.Desc{
+		c.maxOpen, c.open, c.inUse, c.idle, c.waitCount, c.waitDuration,
+		c.maxIdleClosed, c.maxIdleTimeClosed, c.maxLifetimeClosed,
+		c.stmtCacheHits, c.stmtCacheMisses, c.stmtCacheEvictions,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.begun.Collect(ch)
+	c.committed.Collect(ch)
+	c.rolledBack.Collect(ch)
+	c.duration.Collect(ch)
+
+	db := c.holder.currentConnection()
+	if db == nil || db.DB() == nil {
+		return
+	}
+	stats := db.DB().Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+
+	if cache, ok := StmtCacheStatsOf(db); ok {
+		ch <- prometheus.MustNewConstMetric(c.stmtCacheHits, prometheus.CounterValue, float64(cache.Hits))
+		ch <- prometheus.MustNewConstMetric(c.stmtCacheMisses, prometheus.CounterValue, float64(cache.Misses))
+		ch <- prometheus.MustNewConstMetric(c.stmtCacheEvictions, prometheus.CounterValue, float64(cache.Evictions))
+	}
+}
+
+// txBegun implements txObserver.
+func (c *Collector) txBegun(tx TxInfo) {
+	c.begun.WithLabelValues(tx.Name).Inc()
+}
+
+// txEnded implements txObserver.
+func (c *Collector) txEnded(tx TxInfo, committed bool) {
+	if committed {
+		c.committed.WithLabelValues(tx.Name).Inc()
+	} else {
+		c.rolledBack.WithLabelValues(tx.Name).Inc()
+	}
+	c.duration.WithLabelValues(tx.Name).Observe(time.Since(tx.Start).Seconds())
+}
+
+// Interface compliance check
+var _ prometheus.Collector = (*Collector)(nil)
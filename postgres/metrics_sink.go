@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync/atomic"
+	"time"
+)
+
+// metricsSinkCount numbers the sinks added by AddMetricsSink, for unique callback names.
+var metricsSinkCount atomic.Int64
+
+type (
+	// MetricsSink receives per-operation instrumentation of a holder, e.g. to build per-table latency
+	// dashboards. PrometheusMetricsSink exports it to Prometheus; other backends implement the interface.
+	// The methods are called on the goroutine of the operation and should not block.
+	MetricsSink interface {
+		// ObserveQuery records a create, query, update, delete or row query executed through GORM.
+		// The SQL and Vars of query are set, but should not be used as metric labels.
+		ObserveQuery(query QueryInfo)
+		// ObserveTransaction records a transaction that has been committed or rolled back.
+		ObserveTransaction(tx TxInfo, committed bool)
+	}
+
+	// metricsSinkObserver passes the transactions of a holder to a MetricsSink.
+	metricsSinkObserver struct {
+		sink MetricsSink // Sink the transactions are passed to.
+	}
+
+	// PrometheusMetricsSink is a MetricsSink and prometheus.Collector reporting query counts, durations and
+	// rows by operation and table, and commits and rollbacks by transaction name.
+	PrometheusMetricsSink struct {
+		queries   *prometheus.CounterVec   // Operations executed, by operation, table and status.
+		duration  *prometheus.HistogramVec // Duration of operations, by operation and table.
+		rows      *prometheus.CounterVec   // Rows affected or returned, by operation and table.
+		commits   *prometheus.CounterVec   // Transactions committed, by name.
+		rollbacks *prometheus.CounterVec   // Transactions rolled back, by name.
+	}
+)
+
+// AddMetricsSink passes the operations and transactions of the holder to sink. Raw Exec statements bypass
+// the GORM callbacks and are not observed. Call it at startup, see RegisterCallbacks.
+// Example:
+//
+//	sink := postgres.NewPrometheusMetricsSink("orders")
+//	prometheus.MustRegister(sink)
+//	holder.AddMetricsSink(sink)
+func (h *DatabaseHolder) AddMetricsSink(sink MetricsSink) {
+	h.txObservers.add(metricsSinkObserver{sink: sink})
+	h.RegisterCallbacks(func(db *gorm.DB) { registerMetricsSinkCallbacks(db, sink) })
+}
+
+// registerMetricsSinkCallbacks registers the callbacks timing the operations of db for sink.
+func registerMetricsSinkCallbacks(db *gorm.DB, sink MetricsSink) {
+	suffix := fmt.Sprint(metricsSinkCount.Add(1))
+	startKey := "uow:metrics_start_" + suffix
+	for _, operation := range queryOperations(db) {
+		name := operation.name
+		operation.processor().Before(operation.callback).Register("uow:before_"+name+"_metrics_"+suffix, func(scope *gorm.Scope) {
+			scope.Set(startKey, time.Now())
+		})
+		operation.processor().After(operation.callback).Register("uow:after_"+name+"_metrics_"+suffix, func(scope *gorm.Scope) {
+			start, ok := scope.Get(startKey)
+			if !ok {
+				return
+			}
+			sink.ObserveQuery(QueryInfo{
+				Operation:    name,
+				Table:        scope.TableName(),
+				SQL:          scope.SQL,
+				Vars:         scope.SQLVars,
+				Duration:     time.Since(start.(time.Time)),
+				RowsAffected: scope.DB().RowsAffected,
+				Err:          scope.DB().Error,
+			})
+		})
+	}
+}
+
+// txBegun implements txObserver.
+func (metricsSinkObserver) txBegun(TxInfo) {}
+
+// txEnded implements txObserver.
+func (o metricsSinkObserver) txEnded(tx TxInfo, committed bool) {
+	o.sink.ObserveTransaction(tx, committed)
+}
+
+// NewPrometheusMetricsSink creates a PrometheusMetricsSink. dbName is reported as the "db_name" label, as
+// by NewCollector; the metrics are prefixed with uow_operations_.
+func NewPrometheusMetricsSink(dbName string) *PrometheusMetricsSink {
+	opts := func(name, help string) prometheus.CounterOpts {
+		return prometheus.CounterOpts{
+			Namespace: metricsNamespace, Subsystem: "operations", Name: name, Help: help,
+			ConstLabels: prometheus.Labels{"db_name": dbName},
+		}
+	}
+	return &PrometheusMetricsSink{
+		queries: prometheus.NewCounterVec(opts("queries_total", "Number of operations executed."),
+			[]string{"operation", "table", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace, Subsystem: "operations", Name: "query_duration_seconds",
+			Help: "Duration of operations.", ConstLabels: prometheus.Labels{"db_name": dbName},
+			Buckets: prometheus.ExponentialBuckets(0.0005, 4, 10),
+		}, []string{"operation", "table"}),
+		rows:      prometheus.NewCounterVec(opts("rows_total", "Number of rows affected or returned by operations."), []string{"operation", "table"}),
+		commits:   prometheus.NewCounterVec(opts("commits_total", "Number of transactions committed."), []string{txNameLabel}),
+		rollbacks: prometheus.NewCounterVec(opts("rollbacks_total", "Number of transactions rolled back."), []string{txNameLabel}),
+	}
+}
+
+// ObserveQuery implements MetricsSink.
+func (s *PrometheusMetricsSink) ObserveQuery(query QueryInfo) {
+	status := "ok"
+	if query.Err != nil && !gorm.IsRecordNotFoundError(query.Err) {
+		status = "error"
+	}
+	s.queries.WithLabelValues(query.Operation, query.Table, status).Inc()
+	s.duration.WithLabelValues(query.Operation, query.Table).Observe(query.Duration.Seconds())
+	s.rows.WithLabelValues(query.Operation, query.Table).Add(float64(query.RowsAffected))
+}
+
+// ObserveTransaction implements MetricsSink.
+func (s *PrometheusMetricsSink) ObserveTransaction(tx TxInfo, committed bool) {
+	if committed {
+		s.commits.WithLabelValues(tx.Name).Inc()
+	} else {
+		s.rollbacks.WithLabelValues(tx.Name).Inc()
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (s *PrometheusMetricsSink) Describe(ch chan<- *prometheus.Desc) {
+	s.queries.Describe(ch)
+	s.duration.Describe(ch)
+	s.rows.Describe(ch)
+	s.commits.Describe(ch)
+	s.rollbacks.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (s *PrometheusMetricsSink) Collect(ch chan<- prometheus.Metric) {
+	s.queries.Collect(ch)
+	s.duration.Collect(ch)
+	s.rows.Collect(ch)
+	s.commits.Collect(ch)
+	s.rollbacks.Collect(ch)
+}
+
+// Interface compliance checks
+var (
+	_ MetricsSink          = (*PrometheusMetricsSink)(nil)
+	_ prometheus.Collector = (*PrometheusMetricsSink)(nil)
+)
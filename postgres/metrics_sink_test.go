@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test AddMetricsSink to verify the Prometheus sink reports queries by table and commits and rollbacks by name
+func TestDatabaseHolder_AddMetricsSink(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	sink := NewPrometheusMetricsSink("test")
+	tx.dbHolder.AddMetricsSink(sink)
+
+	type order struct{ ID int }
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "orders"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "orders"`).WillReturnError(errors.New("timeout"))
+	mock.ExpectRollback()
+
+	var orders []order
+	id, _ := tx.Begin()
+	assert.NoError(t, tx.Provider().Table("orders").Find(&orders).Error)
+	assert.NoError(t, tx.Commit(id))
+	_, _ = tx.BeginWithOptions(TxOptions{Name: "report"})
+	assert.Error(t, tx.Provider().Table("orders").Find(&orders).Error)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(sink.queries.WithLabelValues("query", "orders", "ok")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(sink.queries.WithLabelValues("query", "orders", "error")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(sink.rows.WithLabelValues("query", "orders")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(sink.commits.WithLabelValues("")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(sink.rollbacks.WithLabelValues("report")))
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(sink))
+	count, err := testutil.GatherAndCount(registry, "uow_operations_query_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
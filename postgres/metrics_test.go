@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that the collector counts committed and rolled back transactions and reports pool statistics
+func TestCollector(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	collector := NewCollector(tx.dbHolder, "test")
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, _ := tx.Begin()
+	assert.NoError(t, tx.Commit(id))
+	_, _ = tx.Begin()
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(collector.begun))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.committed))
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.rolledBack))
+
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(collector))
+	count, err := testutil.GatherAndCount(registry, "uow_db_open_connections", "uow_transactions_duration_seconds")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+}
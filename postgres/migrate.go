@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultMigrationsTable is the version table used when WithMigrationsTable is not given.
+	DefaultMigrationsTable = "uow_migrations"
+
+	// migrationsLockName names the advisory lock serializing the migrations of concurrently starting instances.
+	migrationsLockName = "uow:migrations"
+)
+
+// ErrInvalidMigration occurs when the files of a migrations file system do not follow the naming scheme,
+// define a version twice, or a migration to roll back has no down file.
+var ErrInvalidMigration = errors.New("invalid migration")
+
+type (
+	// Migration is a schema change read from a migrations file system.
+	Migration struct {
+		Version int64  // Version is the number the file names start with; migrations are applied in version order.
+		Name    string // Name is the rest of the file name, e.g. "create_orders".
+		Up      string // Up is the SQL of the .up.sql file.
+		Down    string // Down is the SQL of the .down.sql file; empty if there is none.
+	}
+
+	// MigrateOption configures Migrate and MigrateDown.
+	MigrateOption func(*migrateConfig)
+
+	// migrateConfig holds the settings of Migrate and MigrateDown.
+	migrateConfig struct {
+		table string // Version table, possibly schema-qualified.
+	}
+)
+
+// WithMigrationsTable records the applied versions in table, e.g. "audit.schema_versions", instead of
+// DefaultMigrationsTable.
+func WithMigrationsTable(table string) MigrateOption {
+	return func(c *migrateConfig) {
+		c.table = table
+	}
+}
+
+// Migrate applies the migrations of fsys that have not been applied yet, in version order. The migrations are
+// the files of the root of fsys named <version>_<name>.up.sql and <version>_<name>.down.sql. They run in one
+// transaction of the unit of work of ctx, under an advisory lock, so instances starting concurrently
+// apply every migration once, and a failing migration leaves the schema unchanged. The applied versions
+// are recorded in a version table, created if needed.
+// Example:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	dir, _ := fs.Sub(migrations, "migrations")
+//	if err := postgres.Migrate(ctx, dir); err != nil { return err }
+func Migrate(ctx context.Context, fsys fs.FS, opts ...MigrateOption) error {
+	migrations, err := ReadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+	cfg := newMigrateConfig(opts)
+	return RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		applied, err := lockMigrations(ctx, db, cfg)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if applied[migration.Version] {
+				continue
+			}
+			log.FromContext(ctx).Infof("applying migration %d %s", migration.Version, migration.Name)
+			if err := db.Exec(migration.Up).Error; err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			insert := fmt.Sprintf("INSERT INTO %s (version, name) VALUES (?, ?)", QuoteIdentifier(cfg.table))
+			if err := db.Exec(insert, migration.Version, migration.Name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the last steps applied migrations of fsys, latest first, with their down files,
+// the same way Migrate applies them.
+// Example:
+//
+//	err := postgres.MigrateDown(ctx, dir, 1) // undo the latest migration
+func MigrateDown(ctx context.Context, fsys fs.FS, steps int, opts ...MigrateOption) error {
+	migrations, err := ReadMigrations(fsys)
+	if err != nil {
+		return err
+	}
+	cfg := newMigrateConfig(opts)
+	return RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		applied, err := lockMigrations(ctx, db, cfg)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := migrations[i]
+			if !applied[migration.Version] {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d %s has no down file", ErrInvalidMigration, migration.Version, migration.Name)
+			}
+			log.FromContext(ctx).Infof("rolling back migration %d %s", migration.Version, migration.Name)
+			if err := db.Exec(migration.Down).Error; err != nil {
+				return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+			}
+			remove := fmt.Sprintf("DELETE FROM %s WHERE version = ?", QuoteIdentifier(cfg.table))
+			if err := db.Exec(remove, migration.Version).Error; err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// ReadMigrations returns the migrations of the root of fsys in version order; files not ending in .sql
+// are ignored.
+func ReadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || path.Ext(fileName) != ".sql" {
+			continue
+		}
+		base, direction, ok := strings.Cut(strings.TrimSuffix(fileName, ".sql"), ".")
+		versionText, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionText, 10, 64)
+		if !ok || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("%w: %s is not named <version>_<name>.up.sql or .down.sql", ErrInvalidMigration, fileName)
+		}
+		content, err := fs.ReadFile(fsys, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, found := byVersion[version]
+		if !found {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("%w: version %d is used by %s and %s", ErrInvalidMigration, version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: %d %s has no up file", ErrInvalidMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// newMigrateConfig applies opts to the defaults.
+func newMigrateConfig(opts []MigrateOption) migrateConfig {
+	cfg := migrateConfig{table: DefaultMigrationsTable}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// lockMigrations takes the migrations lock for the running transaction, creates the version table if
+// needed and returns the applied versions.
+func lockMigrations(ctx context.Context, db *gorm.DB, cfg migrateConfig) (map[int64]bool, error) {
+	if _, err := AcquireAdvisoryLock(ctx, AdvisoryLockKey(migrationsLockName)); err != nil {
+		return nil, err
+	}
+	table := QuoteIdentifier(cfg.table)
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s "+
+		"(version bigint PRIMARY KEY, name text NOT NULL, applied_at timestamptz NOT NULL DEFAULT now())", table)
+	if err := db.Exec(create).Error; err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Raw(fmt.Sprintf("SELECT version FROM %s", table)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int64]bool{}
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing/fstest"
+)
+
+// testMigrations are two migrations, the second without a down file.
+var testMigrations = fstest.MapFS{
+	"0001_create_orders.up.sql":   {Data: []byte("CREATE TABLE orders (id bigserial PRIMARY KEY)")},
+	"0001_create_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+	"0002_add_note.up.sql":        {Data: []byte("ALTER TABLE orders ADD note text")},
+	"README.md":                   {Data: []byte("not a migration")},
+}
+
+// expectMigrationsLock expects the lock and version table statements of a migration run.
+func expectMigrationsLock(mock sqlmock.Sqlmock, applied ...int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_advisory_xact_lock($1)").WithArgs(AdvisoryLockKey(migrationsLockName)).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_advisory_xact_lock"}).AddRow(""))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "uow_migrations" ` +
+		"(version bigint PRIMARY KEY, name text NOT NULL, applied_at timestamptz NOT NULL DEFAULT now())").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version"})
+	for _, version := range applied {
+		rows.AddRow(version)
+	}
+	mock.ExpectQuery(`SELECT version FROM "uow_migrations"`).WillReturnRows(rows)
+}
+
+// Test Migrate to verify pending migrations are applied in order and recorded in one locked transaction
+func TestMigrate(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	expectMigrationsLock(mock, 1)
+	mock.ExpectExec("ALTER TABLE orders ADD note text").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "uow_migrations" (version, name) VALUES ($1, $2)`).WithArgs(int64(2), "add_note").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, Migrate(ctx, testMigrations))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Migrate to verify a failing migration rolls the whole run back
+func TestMigrate_Failure(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	failure := errors.New("syntax error")
+	expectMigrationsLock(mock)
+	mock.ExpectExec("CREATE TABLE orders (id bigserial PRIMARY KEY)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "uow_migrations" (version, name) VALUES ($1, $2)`).WithArgs(int64(1), "create_orders").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("ALTER TABLE orders ADD note text").WillReturnError(failure)
+	mock.ExpectRollback()
+
+	err := Migrate(ctx, testMigrations)
+	assert.ErrorIs(t, err, failure)
+	assert.ErrorContains(t, err, "migration 2 add_note")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test MigrateDown to verify the latest applied migrations are rolled back and migrations without down files are rejected
+func TestMigrateDown(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	expectMigrationsLock(mock, 1)
+	mock.ExpectExec("DROP TABLE orders").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "uow_migrations" WHERE version = $1`).WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, MigrateDown(ctx, testMigrations, 1))
+
+	expectMigrationsLock(mock, 1, 2)
+	mock.ExpectRollback()
+	assert.ErrorIs(t, MigrateDown(ctx, testMigrations, 1), ErrInvalidMigration)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test ReadMigrations to verify misnamed files, duplicate versions and missing up files are rejected
+func TestReadMigrations_Invalid(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"misnamed":  {"create_orders.up.sql": {}},
+		"direction": {"0001_create_orders.sideways.sql": {}},
+		"duplicate": {"0001_a.up.sql": {Data: []byte("SELECT 1")}, "0001_b.up.sql": {Data: []byte("SELECT 1")}},
+		"no up":     {"0001_a.down.sql": {Data: []byte("SELECT 1")}},
+	} {
+		_, err := ReadMigrations(fsys)
+		assert.ErrorIs(t, err, ErrInvalidMigration, name)
+	}
+}
@@ -0,0 +1,254 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Values of PgConfig.TargetSessionAttrs.
+const (
+	TargetSessionAny       = "any"        // TargetSessionAny accepts the first host that can be reached.
+	TargetSessionReadWrite = "read-write" // TargetSessionReadWrite accepts hosts whose sessions are not read-only by default.
+	TargetSessionReadOnly  = "read-only"  // TargetSessionReadOnly accepts hosts whose sessions are read-only by default.
+	TargetSessionPrimary   = "primary"    // TargetSessionPrimary accepts hosts that are not in recovery.
+	TargetSessionStandby   = "standby"    // TargetSessionStandby accepts hosts that are in recovery.
+)
+
+// ErrNoSuitableHost occurs when none of the hosts of a multi-host PgConfig matches TargetSessionAttrs.
+var ErrNoSuitableHost = errors.New("no suitable postgres host")
+
+type (
+	// multiHostConnector dials the hosts of a multi-host PgConfig in order, as libpq does, and returns the
+	// first connection matching TargetSessionAttrs. Every new connection of the pool goes through it, so
+	// after a primary switch the pool fails over once the broken connections have been discarded, and
+	// connections are opened with the credentials current at that time.
+	multiHostConnector struct {
+		hosts       []string          // Hosts in the order they are tried.
+		ports       []int             // Ports of the hosts, 0 for the driver default.
+		attrs       string            // TargetSessionAttrs of the configuration.
+		config      PgConfig          // Configuration the connection strings of the hosts are built from.
+		dialer      dialerFunc        // Custom dialer, if any.
+		credentials credentialsSource // Source of the credentials of every new connection, if any.
+
+		mu      sync.Mutex // Guards current.
+		current string     // Host of the latest connection.
+	}
+
+	// multiHostDriver is the driver of a multiHostConnector; it lets CurrentHost find the connector of a pool.
+	multiHostDriver struct {
+		connector *multiHostConnector // Connector opening the connections.
+	}
+)
+
+// Hosts returns the hosts listed in Host, separated by commas as in libpq connection strings.
+func (c *PgConfig) Hosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(c.Host, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// CurrentHost returns the host the latest connection of db was established with, if db has been opened
+// for a multi-host PgConfig or one with TargetSessionAttrs, and "" otherwise.
+// Example:
+//
+//	logger.Infof("connected to %s", CurrentHost(holder.DB()))
+func CurrentHost(db *gorm.DB) string {
+	if d, ok := unwrapDriver(db.DB().Driver()).(multiHostDriver); ok {
+		return d.connector.currentHost()
+	}
+	return ""
+}
+
+// CurrentHost returns the host the latest connection of the holder was established with, see CurrentHost.
+func (h *DatabaseHolder) CurrentHost() string {
+	db := h.currentConnection()
+	if db == nil {
+		return ""
+	}
+	return CurrentHost(db)
+}
+
+// openGORM opens the GORM connection of cfg with the configured driver; lib/pq connections go through a
+// multiHostConnector when cfg lists several hosts or sets TargetSessionAttrs, which lib/pq does not support,
+// or needs a custom dialer or per-connection credentials, and are wrapped by a statement cache with
+// StatementCacheSize.
+func openGORM(cfg *PgConfig, credentials credentialsSource) (*gorm.DB, error) {
+	if cfg.Driver == DriverPgx {
+		return openPgx(cfg, credentials)
+	}
+	plain := len(cfg.Hosts()) <= 1 && (cfg.TargetSessionAttrs == "" || cfg.TargetSessionAttrs == TargetSessionAny) &&
+		cfg.dialer() == nil && credentials == nil
+	if plain && cfg.StatementCacheSize <= 0 && !cfg.readOnly() {
+		return gorm.Open("postgres", cfg.DSN())
+	}
+
+	var (
+		connector driver.Connector
+		err       error
+	)
+	if plain {
+		connector, err = pq.NewConnector(cfg.DSN())
+	} else {
+		connector, err = newMultiHostConnector(cfg, credentials)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.readOnly() {
+		connector = newReadOnlyConnector(connector)
+	}
+	if cfg.StatementCacheSize > 0 {
+		connector = newStmtCacheConnector(connector, cfg.StatementCacheSize)
+	}
+	sqlDB := sql.OpenDB(connector)
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// newMultiHostConnector creates a connector for the hosts of cfg.
+func newMultiHostConnector(cfg *PgConfig, credentials credentialsSource) (*multiHostConnector, error) {
+	switch cfg.TargetSessionAttrs {
+	case "", TargetSessionAny, TargetSessionReadWrite, TargetSessionReadOnly, TargetSessionPrimary, TargetSessionStandby:
+	default:
+		return nil, fmt.Errorf("invalid target_session_attrs %q", cfg.TargetSessionAttrs)
+	}
+	c := &multiHostConnector{
+		hosts:       cfg.Hosts(),
+		attrs:       cfg.TargetSessionAttrs,
+		config:      cfg.dialConfig(),
+		dialer:      cfg.dialer(),
+		credentials: credentials,
+	}
+	c.config.TargetSessionAttrs = ""
+	c.config.Ports = nil
+	if len(c.hosts) == 0 {
+		c.hosts = []string{""} // the driver default, or whatever the dialer connects to
+	}
+	for i := range c.hosts {
+		c.ports = append(c.ports, cfg.hostPort(i))
+	}
+	if _, err := pq.NewConnector(c.config.DSN()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Connect implements driver.Connector.
+func (c *multiHostConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var errs []error
+	for i, host := range c.hosts {
+		conn, err := c.connect(ctx, host, c.ports[i])
+		if err == nil {
+			var suitable bool
+			if suitable, err = c.suitable(ctx, conn); err == nil && suitable {
+				c.mu.Lock()
+				c.current = host
+				c.mu.Unlock()
+				return conn, nil
+			}
+			_ = conn.Close()
+			if err == nil {
+				err = fmt.Errorf("does not match target_session_attrs=%s", c.attrs)
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", host, err))
+	}
+	return nil, fmt.Errorf("%w: %w", ErrNoSuitableHost, errors.Join(errs...))
+}
+
+// connect opens a lib/pq connection to host and port, with the current credentials if the connector has a
+// source.
+func (c *multiHostConnector) connect(ctx context.Context, host string, port int) (driver.Conn, error) {
+	hostConfig := c.config
+	hostConfig.Host, hostConfig.Port = host, port
+	if c.credentials != nil {
+		var err error
+		if hostConfig.User, hostConfig.Password, err = c.credentials.credentials(ctx); err != nil {
+			return nil, fmt.Errorf("cannot get credentials: %w", err)
+		}
+	}
+	connector, err := pq.NewConnector(hostConfig.DSN())
+	if err != nil {
+		return nil, err
+	}
+	if c.dialer != nil {
+		connector.Dialer(c.dialer)
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver implements driver.Connector.
+func (c *multiHostConnector) Driver() driver.Driver {
+	return multiHostDriver{connector: c}
+}
+
+// currentHost returns the host of the latest connection.
+func (c *multiHostConnector) currentHost() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+// suitable reports whether conn matches the target session attributes.
+func (c *multiHostConnector) suitable(ctx context.Context, conn driver.Conn) (bool, error) {
+	switch c.attrs {
+	case TargetSessionReadWrite, TargetSessionReadOnly:
+		readOnly, err := queryValue(ctx, conn, "SHOW transaction_read_only")
+		return (readOnly == "on") == (c.attrs == TargetSessionReadOnly), err
+	case TargetSessionPrimary, TargetSessionStandby:
+		inRecovery, err := queryValue(ctx, conn, "SELECT pg_is_in_recovery()::text")
+		return (inRecovery == "true") == (c.attrs == TargetSessionStandby), err
+	default:
+		return true, nil
+	}
+}
+
+// queryValue runs a query returning a single text value on a driver connection.
+func queryValue(ctx context.Context, conn driver.Conn, query string) (string, error) {
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return "", errors.New("connection cannot run queries")
+	}
+	rows, err := queryer.QueryContext(ctx, query, nil)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	values := make([]driver.Value, 1)
+	if err = rows.Next(values); err != nil {
+		if err == io.EOF {
+			err = errors.New("no rows")
+		}
+		return "", err
+	}
+	switch value := values[0].(type) {
+	case []byte:
+		return string(value), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
+
+// Open implements driver.Driver; the name is ignored since the connector holds the configuration.
+func (d multiHostDriver) Open(string) (driver.Conn, error) {
+	return d.connector.Connect(context.Background())
+}
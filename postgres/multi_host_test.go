@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+// fakeConn is a driver connection answering every query with a single value.
+type fakeConn struct {
+	driver.Conn
+	value string
+}
+
+func (c fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{value: c.value}, nil
+}
+
+// fakeRows returns a single row holding value.
+type fakeRows struct {
+	value string
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done, dest[0] = true, []byte(r.value)
+	return nil
+}
+
+// Test Hosts to verify comma-separated hosts are split
+func TestPgConfig_Hosts(t *testing.T) {
+	assert.Equal(t, []string{"db-a", "db-b", "db-c"}, (&PgConfig{Host: "db-a, db-b,,db-c"}).Hosts())
+	assert.Equal(t, []string{"localhost"}, (&PgConfig{Host: "localhost"}).Hosts())
+}
+
+// Test ParseConfigFromURL to verify multi-host URLs and target_session_attrs
+func TestParseConfigFromURL_MultiHost(t *testing.T) {
+	cfg, err := ParseConfigFromURL("postgres://user@db-a:6432,db-b:6432/billing?target_session_attrs=read-write")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "db-a,db-b", cfg.Host)
+	assert.Equal(t, 6432, cfg.Port)
+	assert.Equal(t, TargetSessionReadWrite, cfg.TargetSessionAttrs)
+	assert.Nil(t, cfg.Params)
+	assert.Nil(t, cfg.Ports)
+}
+
+// Test ParseConfigFromURL to verify every host of a multi-host URL keeps its port, also IPv6 ones
+func TestParseConfigFromURL_MultiHostPorts(t *testing.T) {
+	cfg, err := ParseConfigFromURL("postgres://user@[2001:db8::1]:5432,db-b,[::1]:6432/billing")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "2001:db8::1,db-b,::1", cfg.Host)
+	assert.Equal(t, []int{5432, 0, 6432}, cfg.Ports)
+	assert.Equal(t, "billing", cfg.DBName)
+	assert.Equal(t, "host=2001:db8::1,db-b,::1 port=5432,5432,6432 user=user dbname=billing", cfg.DSN())
+
+	cfg, err = ParseConfigFromURL("postgres://[::1]:6432/billing")
+	assert.NoError(t, err)
+	assert.Equal(t, "::1", cfg.Host)
+	assert.Equal(t, 6432, cfg.Port)
+
+	cfg, err = ParseConfigFromURL("postgres:///billing?host=db-a,db-b&port=5432,6432")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5432, 6432}, cfg.Ports)
+
+	_, err = ParseConfigFromURL("postgres://db-a:5432,db-b:port/billing")
+	assert.ErrorIs(t, err, ErrInvalidURL)
+}
+
+// Test that the connector dials every host with its own port
+func TestMultiHostConnector_Ports(t *testing.T) {
+	connector, err := newMultiHostConnector(&PgConfig{Host: "db-a,db-b,db-c", Port: 6432, Ports: []int{5432, 0, 7432}}, nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []int{5432, 6432, 7432}, connector.ports)
+	assert.Nil(t, connector.config.Ports)
+}
+
+// Test that the connector only accepts hosts matching the target session attributes
+func TestMultiHostConnector_Suitable(t *testing.T) {
+	for _, test := range []struct {
+		attrs, value string
+		suitable     bool
+	}{
+		{TargetSessionReadWrite, "off", true},
+		{TargetSessionReadWrite, "on", false},
+		{TargetSessionReadOnly, "on", true},
+		{TargetSessionPrimary, "false", true},
+		{TargetSessionPrimary, "true", false},
+		{TargetSessionStandby, "true", true},
+		{TargetSessionAny, "", true},
+	} {
+		suitable, err := (&multiHostConnector{attrs: test.attrs}).suitable(context.Background(), fakeConn{value: test.value})
+		assert.NoError(t, err)
+		assert.Equal(t, test.suitable, suitable, "%s with %q", test.attrs, test.value)
+	}
+}
+
+// Test that the connector reports every host it could not connect to
+func TestMultiHostConnector_NoSuitableHost(t *testing.T) {
+	connector, err := newMultiHostConnector(&PgConfig{Host: "127.0.0.1,localhost", Port: 1, SSLMode: "disable"}, nil)
+	assert.NoError(t, err)
+
+	_, err = connector.Connect(context.Background())
+	assert.ErrorIs(t, err, ErrNoSuitableHost)
+	assert.ErrorContains(t, err, "127.0.0.1: ")
+	assert.ErrorContains(t, err, "localhost: ")
+	assert.Equal(t, "", connector.currentHost())
+
+	_, err = newMultiHostConnector(&PgConfig{Host: "a,b", TargetSessionAttrs: "writable"}, nil)
+	assert.ErrorContains(t, err, `invalid target_session_attrs "writable"`)
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DefaultTransactionName is the name of the transaction context returned by GetTransactionContext.
+const DefaultTransactionName = "primary"
+
+var (
+	namedDBHolders   = map[string]*DatabaseHolder{} // Holders registered by name; config-based ones connect lazily.
+	namedDBHoldersMu sync.RWMutex                   // Guards namedDBHolders.
+)
+
+// RegisterDatabase makes the database described by config available under name for GetTransactionContextFor.
+// The connection is established on first use, as with PgConfig.LazyConnect, and shared by all transaction
+// contexts of that name. A failed connection is returned by Begin, matching ErrConnectFailed, and the
+// next Begin connects again. Registering a name twice replaces the previous registration.
+// Example:
+//
+//	postgres.RegisterDatabase("billing", &postgres.PgConfig{Host: "billing-db", DBName: "billing", ...})
+//	billing, ctx := postgres.GetTransactionContextFor(ctx, "billing")
+func RegisterDatabase(name string, config *PgConfig) {
+	namedDBHoldersMu.Lock()
+	defer namedDBHoldersMu.Unlock()
+	lazy := *config
+	lazy.LazyConnect = true
+	namedDBHolders[name] = newDBHolderFromConfig(&lazy)
+}
+
+// RegisterDBHolder makes holder available under name for GetTransactionContextFor.
+// Registering a name twice replaces the previous holder.
+// Example:
+//
+//	postgres.RegisterDBHolder("audit", postgres.NewDBHolder(postgres.NewConnect(&auditConfig)))
+func RegisterDBHolder(name string, holder *DatabaseHolder) {
+	namedDBHoldersMu.Lock()
+	defer namedDBHoldersMu.Unlock()
+	namedDBHolders[name] = holder
+}
+
+// GetTransactionContextFor retrieves or creates the transaction context registered under name.
+// Every name has its own independent transaction context in ctx, so a request can commit to one
+// database while separately committing or rolling back another. DefaultTransactionName refers
+// to the context returned by GetTransactionContext.
+// It panics if neither a holder nor a database has been registered for name.
+// Example:
+//
+//	orders, ctx := GetTransactionContext(ctx)
+//	audit, ctx := GetTransactionContextFor(ctx, "audit")
+//	auditID, _ := audit.Begin()
+//	// ... write the audit record, then commit it regardless of the business transaction ...
+//	_ = audit.Commit(auditID)
+func GetTransactionContextFor(ctx context.Context, name string, opts ...TransactionContextOption) (ITransactionContext, context.Context) {
+	if name == DefaultTransactionName {
+		return GetTransactionContext(ctx, opts...)
+	}
+	return getTransactionContextWithDBHolder(ctx, transactionContextKeyFor(name), func() *DatabaseHolder {
+		return namedDBHolder(name)
+	}, opts...)
+}
+
+// transactionContextKeyFor returns the context key of the transaction context registered under name.
+func transactionContextKeyFor(name string) contextKey {
+	if name == DefaultTransactionName {
+		return TransactionContextKey
+	}
+	return contextKey(string(TransactionContextKey) + ":" + name)
+}
+
+// namedDBHolder returns the holder registered under name; it panics if there is none.
+func namedDBHolder(name string) *DatabaseHolder {
+	namedDBHoldersMu.RLock()
+	holder, ok := namedDBHolders[name]
+	namedDBHoldersMu.RUnlock()
+	if !ok {
+		panic(fmt.Sprintf("postgres: no database holder registered for %q", name))
+	}
+	return holder
+}
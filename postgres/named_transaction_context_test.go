@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that named transaction contexts are independent of each other
+func TestGetTransactionContextFor_Independent(t *testing.T) {
+	primary, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	audit, auditDB, auditMock := getTestTransactionContext(t)
+	defer auditDB.Close()
+	RegisterDBHolder("audit", audit.dbHolder)
+
+	ctx := context.WithValue(context.Background(), TransactionContextKey, primary)
+	auditTx, ctx := GetTransactionContextFor(ctx, "audit")
+	sameAuditTx, _ := GetTransactionContextFor(ctx, "audit")
+	primaryTx, _ := GetTransactionContextFor(ctx, DefaultTransactionName)
+	assert.Same(t, auditTx, sameAuditTx)
+	assert.Same(t, primary, primaryTx)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	auditMock.ExpectBegin()
+	auditMock.ExpectCommit()
+
+	_, err := primaryTx.Begin()
+	assert.NoError(t, err)
+	auditID, err := auditTx.Begin()
+	assert.NoError(t, err)
+
+	assert.NoError(t, primaryTx.Rollback())
+	assert.NoError(t, auditTx.Commit(auditID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, auditMock.ExpectationsWereMet())
+}
+
+// Test that a database registered by name under a new holder is used by the transaction contexts created afterwards
+func TestGetTransactionContextFor_ReplacedName(t *testing.T) {
+	first, db, _ := getTestTransactionContext(t)
+	defer db.Close()
+	second, secondDB, _ := getTestTransactionContext(t)
+	defer secondDB.Close()
+	other, otherDB, _ := getTestTransactionContext(t)
+	defer otherDB.Close()
+
+	RegisterDBHolder("archive", first.dbHolder)
+	RegisterDBHolder("reporting", other.dbHolder)
+	archiveTx, ctx := GetTransactionContextFor(context.Background(), "archive")
+	reportingTx, _ := GetTransactionContextFor(ctx, "reporting")
+	assert.Same(t, first.dbHolder, archiveTx.(*transactionContext).dbHolder)
+	assert.Same(t, other.dbHolder, reportingTx.(*transactionContext).dbHolder)
+
+	RegisterDBHolder("archive", second.dbHolder)
+	stillFirst, _ := GetTransactionContextFor(ctx, "archive")
+	replaced, _ := GetTransactionContextFor(context.Background(), "archive")
+	assert.Same(t, archiveTx, stillFirst)
+	assert.Same(t, second.dbHolder, replaced.(*transactionContext).dbHolder)
+}
+
+// Test that a database registered by config reports a failed connection from Begin and connects again on the next one
+func TestRegisterDatabase_ConnectFailure(t *testing.T) {
+	var calls int
+	providerErr := errors.New("secret not found")
+	RegisterDatabase("billing", &PgConfig{
+		Host:    "127.0.0.1",
+		Port:    1,
+		SSLMode: "disable",
+		Credentials: func(ctx context.Context) (string, string, error) {
+			calls++
+			return "", "", providerErr
+		},
+		ConnectRetry: ConnectRetryPolicy{MaxAttempts: 1},
+	})
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		billing, _ := GetTransactionContextFor(context.Background(), "billing")
+		_, err := billing.Begin()
+		assert.ErrorIs(t, err, ErrConnectFailed)
+		assert.ErrorContains(t, err, providerErr.Error())
+		assert.Equal(t, attempt, calls)
+	}
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+// notification is a pg_notify call queued by Notify.
+type notification struct {
+	channel string // Channel listeners subscribed to with LISTEN.
+	payload string // Payload delivered to the listeners.
+}
+
+// Notify queues a notification on channel for the running transaction of the transaction context in ctx.
+// Queued notifications are sent with pg_notify inside the transaction right before COMMIT, after the
+// other before-commit hooks, so listeners receive them only once the transaction has committed and never
+// for a rolled back one. Identical notifications of a transaction are sent once, as Postgres would fold
+// them anyway. Outside a transaction the notification is sent immediately.
+// Example:
+//
+//	if err := db.Create(&order).Error; err != nil { return err }
+//	return Notify(ctx, "orders", strconv.Itoa(order.ID))
+func Notify(ctx context.Context, channel, payload string) error {
+	txContext, _ := GetTransactionContext(ctx)
+	c, ok := txContext.(*transactionContext)
+	if !ok || !c.inTransaction() {
+		db := txContext.ProviderWithContext(ctx)
+		if db == nil {
+			return ErrTxWasRollbacked
+		}
+		return sendNotification(db, notification{channel: channel, payload: payload})
+	}
+
+	n := notification{channel: channel, payload: payload}
+	for _, queued := range c.notifications {
+		if queued == n {
+			return nil
+		}
+	}
+	c.notifications = append(c.notifications, n)
+	return nil
+}
+
+// flushNotifications sends the queued notifications in the running transaction; Commit calls it once
+// the before-commit hooks have run.
+func (c *transactionContext) flushNotifications() error {
+	for _, n := range c.notifications {
+		if err := sendNotification(c.tx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendNotification calls pg_notify through db.
+func sendNotification(db *gorm.DB, n notification) error {
+	return db.Exec("SELECT pg_notify(?, ?)", n.channel, n.payload).Error
+}
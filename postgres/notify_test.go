@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that notifications are sent once, after the before-commit hooks, right before COMMIT
+func TestNotify(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT 1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_notify($1, $2)`).WithArgs("orders", "7").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_notify($1, $2)`).WithArgs("orders", "8").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, Notify(ctx, "orders", "7"))
+	tx.RegisterBeforeCommit(func() error { return tx.Provider().Exec("SELECT 1").Error })
+	assert.NoError(t, Notify(ctx, "orders", "8"))
+	assert.NoError(t, Notify(ctx, "orders", "7"))
+	assert.NoError(t, tx.Commit(id))
+
+	_, err = tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, Notify(ctx, "orders", "9"))
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"net"
+)
+
+type (
+	// OpenOption configures how Open and OpenContext connect, beyond what a PgConfig can express.
+	OpenOption func(*openOptions)
+
+	// openOptions holds the settings of the OpenOptions.
+	openOptions struct {
+		dial  dialerFunc // Dials the connections instead of the driver, if set.
+		clock Clock      // Waits between the connection attempts, if set.
+	}
+)
+
+// WithDialer opens the connections with dial instead of the dialer of the driver, e.g. through an SSH tunnel
+// or a SOCKS proxy. dial receives the network and address of every host, as "tcp" and "host:port", or "unix"
+// and the socket path for hosts starting with a slash. It takes precedence over PgConfig.CloudSQL.
+// Example:
+//
+//	socks, _ := proxy.SOCKS5("tcp", "bastion:1080", nil, proxy.Direct)
+//	db, err := OpenContext(ctx, &config, WithDialer(socks.(proxy.ContextDialer).DialContext))
+func WithDialer(dial func(ctx context.Context, network, address string) (net.Conn, error)) OpenOption {
+	return func(o *openOptions) {
+		o.dial = dial
+	}
+}
+
+// applyOpenOptions returns cfg, or a copy of it carrying the settings of opts.
+func applyOpenOptions(cfg *PgConfig, opts []OpenOption) *PgConfig {
+	if len(opts) == 0 {
+		return cfg
+	}
+	var options openOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	applied := *cfg
+	applied.dial, applied.clock = options.dial, options.clock
+	return &applied
+}
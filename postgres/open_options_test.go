@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+)
+
+// Test WithDialer to verify the connections of both drivers are dialed through it, TCP and Unix socket hosts alike
+func TestOpenContext_WithDialer(t *testing.T) {
+	for _, driver := range []string{DriverPQ, DriverPgx} {
+		for host, expected := range map[string][2]string{
+			"db.internal":         {"tcp", "db.internal:6432"},
+			"/var/run/postgresql": {"unix", "/var/run/postgresql/.s.PGSQL.6432"},
+		} {
+			var dialed [][2]string
+			dialErr := errors.New("tunnel closed")
+			cfg := &PgConfig{Host: host, Port: 6432, SSLMode: "disable", Driver: driver, ConnectRetry: ConnectRetryPolicy{MaxAttempts: 1}}
+			db, err := OpenContext(context.Background(), cfg, WithDialer(func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialed = append(dialed, [2]string{network, address})
+				return nil, dialErr
+			}))
+
+			assert.ErrorIs(t, err, ErrConnectFailed, driver)
+			assert.ErrorContains(t, err, dialErr.Error(), driver)
+			assert.Nil(t, db)
+			assert.Contains(t, dialed, expected, driver)
+			assert.Nil(t, cfg.dial, "the configuration passed in is not modified")
+		}
+	}
+}
+
+// Test ParseConfigFromURL to verify the host and port may be passed as query parameters, e.g. for a Unix socket
+func TestParseConfigFromURL_UnixSocket(t *testing.T) {
+	cfg, err := ParseConfigFromURL("postgres:///billing?host=/var/run/postgresql&port=6432")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/run/postgresql", cfg.Host)
+	assert.Equal(t, 6432, cfg.Port)
+	assert.Equal(t, "billing", cfg.DBName)
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// versionTag marks the version column of a model: `uow:"version"`.
+	versionTag = "version"
+	// versionScopeKey holds the version an update started from.
+	versionScopeKey = "uow:version"
+)
+
+// ErrStaleObject occurs when an update of a versioned model matches no row because another writer
+// has changed or deleted it since it was read.
+var ErrStaleObject = errors.New("stale object: the row was modified concurrently")
+
+// RegisterOptimisticLocking registers GORM callbacks on db implementing optimistic locking for models with an
+// integer field tagged `uow:"version"`: every update is restricted to the version the model was read with and
+// increments it, and an update matching no row fails with ErrStaleObject, so the unit of work is rolled back
+// instead of overwriting a concurrent change.
+// It is applied automatically by Open when PgConfig.OptimisticLocking is set.
+// Example:
+//
+//	type Account struct {
+//	  ID      int
+//	  Balance int
+//	  Version int `uow:"version"`
+//	}
+//	err := db.Save(&account).Error // errors.Is(err, ErrStaleObject) on a concurrent update
+func RegisterOptimisticLocking(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Update().Before("gorm:update").Register("uow:increment_version", incrementVersion)
+	callbacks.Update().After("gorm:update").Register("uow:check_version", checkVersion)
+}
+
+// incrementVersion restricts the update to the current version and assigns the next one.
+func incrementVersion(scope *gorm.Scope) {
+	field, ok := versionField(scope)
+	if !ok || scope.HasError() {
+		return
+	}
+	current := field.Field.Int()
+	scope.Search.Where(fmt.Sprintf("%v.%v = ?", scope.QuotedTableName(), scope.Quote(field.DBName)), current)
+	scope.InstanceSet(versionScopeKey, current)
+	_ = scope.SetColumn(field.Name, current+1)
+}
+
+// checkVersion fails the update with ErrStaleObject if it matched no row and restores the version of the model.
+func checkVersion(scope *gorm.Scope) {
+	current, ok := scope.InstanceGet(versionScopeKey)
+	if !ok || scope.HasError() || scope.DB().RowsAffected > 0 {
+		return
+	}
+	if field, found := versionField(scope); found {
+		_ = field.Set(current)
+	}
+	_ = scope.Err(ErrStaleObject)
+}
+
+// versionField returns the field of the model tagged as version column.
+func versionField(scope *gorm.Scope) (*gorm.Field, bool) {
+	for _, field := range scope.Fields() {
+		if field.Tag.Get("uow") == versionTag && field.Field.IsValid() && field.Field.CanInt() {
+			return field, true
+		}
+	}
+	return nil, false
+}
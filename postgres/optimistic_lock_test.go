@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type versionedAccount struct {
+	ID      int
+	Balance int
+	Version int `uow:"version"`
+}
+
+// Test that updates are restricted to the read version, increment it and fail on a stale version
+func TestRegisterOptimisticLocking(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	RegisterOptimisticLocking(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "versioned_accounts" SET "balance" = \$1, "version" = \$2 WHERE "versioned_accounts"."id" = \$3 AND \(\("versioned_accounts"."version" = \$4\)\)`).
+		WithArgs(10, 4, 1, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	account := versionedAccount{ID: 1, Balance: 10, Version: 3}
+	assert.NoError(t, db.Save(&account).Error)
+	assert.Equal(t, 4, account.Version)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "versioned_accounts"`).WithArgs(20, 5, 1, 4).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	account.Balance = 20
+	assert.ErrorIs(t, db.Save(&account).Error, ErrStaleObject)
+	assert.Equal(t, 4, account.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jinzhu/gorm"
+	"golang.org/x/sync/errgroup"
+)
+
+// ParallelRead runs reads concurrently, each in its own read-only transaction on a separate pooled connection
+// of the database holder of the transaction context in ctx. Writes stay confined to the unit of work: the reads
+// run outside its transaction, so they cannot write and do not see its uncommitted changes.
+// The first error cancels the context of the remaining reads and is returned.
+// Example:
+//
+//	var orders, customers int
+//	err := ParallelRead(ctx,
+//	  func(ctx context.Context, db *gorm.DB) error { return db.Model(&Order{}).Count(&orders).Error },
+//	  func(ctx context.Context, db *gorm.DB) error { return db.Model(&Customer{}).Count(&customers).Error },
+//	)
+func ParallelRead(ctx context.Context, reads ...TxFunc) error {
+	txContext, ctx := GetTransactionContext(ctx)
+	var db *gorm.DB
+	if c, ok := txContext.(*transactionContext); ok {
+		db = c.providerWithoutTransaction()
+	} else {
+		db = txContext.Provider()
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, read := range reads {
+		read := read
+		group.Go(func() error {
+			return readOnly(groupCtx, db, read)
+		})
+	}
+	return group.Wait()
+}
+
+// readOnly executes read in a read-only transaction that is rolled back afterwards.
+func readOnly(ctx context.Context, db *gorm.DB, read TxFunc) error {
+	tx := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+	return read(ctx, tx)
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that every read runs in its own transaction and the first error is returned
+func TestParallelRead(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	mock.MatchExpectationsInOrder(false)
+	failure := errors.New("failed")
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var first, second bool
+	assert.NoError(t, ParallelRead(ctx,
+		func(ctx context.Context, db *gorm.DB) error { first = true; return nil },
+		func(ctx context.Context, db *gorm.DB) error { second = true; return nil },
+	))
+	assert.True(t, first && second)
+
+	assert.Equal(t, failure, ParallelRead(ctx, func(ctx context.Context, db *gorm.DB) error { return failure }))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
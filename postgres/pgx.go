@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+// Values of PgConfig.Driver.
+const (
+	DriverPQ  = "postgres" // DriverPQ connects through lib/pq, the default.
+	DriverPgx = "pgx"      // DriverPgx connects through the database/sql driver of pgx.
+)
+
+// ErrCopyRequiresPgx occurs when CopyFrom is called on a holder that is not connected with DriverPgx.
+var ErrCopyRequiresPgx = errors.New("COPY requires a connection opened with DriverPgx")
+
+// CopyFrom inserts rows into the columns of table, which may be schema-qualified, with the COPY protocol of pgx, which is much faster
+// than INSERT for bulk loads, and returns the number of rows copied. It runs on a connection of its
+// own, outside of any unit of work, and either copies all rows or none. The holder must be connected
+// with DriverPgx, otherwise ErrCopyRequiresPgx is returned.
+// Example:
+//
+//	n, err := holder.CopyFrom(ctx, "events", []string{"id", "payload"}, [][]interface{}{
+//	  {1, `{"type":"created"}`},
+//	  {2, `{"type":"deleted"}`},
+//	})
+func (h *DatabaseHolder) CopyFrom(ctx context.Context, table string, columns []string, rows [][]interface{}) (int64, error) {
+	db, err := h.connection()
+	if err != nil {
+		return 0, err
+	}
+	conn, err := db.DB().Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var copied int64
+	err = conn.Raw(func(driverConn interface{}) error {
+		if pipelined, ok := driverConn.(*pipelineConn); ok {
+			driverConn = pipelined.Conn
+		}
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return ErrCopyRequiresPgx
+		}
+		copied, err = pgxConn.Conn().CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns, pgx.CopyFromRows(rows))
+		return err
+	})
+	return copied, err
+}
+
+// openPgx opens a GORM connection through the database/sql driver of pgx, which handles multiple
+// hosts and target_session_attrs by itself, using the custom dialer and credentials of cfg if any.
+func openPgx(cfg *PgConfig, credentials credentialsSource) (*gorm.DB, error) {
+	dialConfig := cfg.dialConfig()
+	connConfig, err := pgx.ParseConfig(dialConfig.DSN())
+	if err != nil {
+		return nil, err
+	}
+	if dialer := cfg.dialer(); dialer != nil {
+		connConfig.DialFunc = pgconn.DialFunc(dialer)
+		connConfig.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+			return []string{host}, nil // resolved by the dialer, e.g. on the far side of a tunnel
+		}
+	}
+	var opts []stdlib.OptionOpenDB
+	if credentials != nil {
+		opts = append(opts, stdlib.OptionBeforeConnect(func(ctx context.Context, connConfig *pgx.ConnConfig) (err error) {
+			if connConfig.User, connConfig.Password, err = credentials.credentials(ctx); err != nil {
+				return fmt.Errorf("cannot get credentials: %w", err)
+			}
+			return nil
+		}))
+	}
+	var connector driver.Connector = newPipelineConnector(stdlib.GetConnector(*connConfig, opts...))
+	if cfg.readOnly() {
+		connector = newReadOnlyConnector(connector)
+	}
+	sqlDB := sql.OpenDB(connector)
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// validateDriver checks PgConfig.Driver.
+func validateDriver(driver string) error {
+	switch driver {
+	case "", DriverPQ, DriverPgx:
+		return nil
+	default:
+		return fmt.Errorf("unsupported postgres driver %q", driver)
+	}
+}
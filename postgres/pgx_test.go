@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that CopyFrom requires a connection of the pgx driver
+func TestDatabaseHolder_CopyFromRequiresPgx(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	_, err = NewDBHolder(db).CopyFrom(context.Background(), "events", []string{"id"}, [][]interface{}{{1}})
+	assert.ErrorIs(t, err, ErrCopyRequiresPgx)
+}
+
+// Test that OpenContext rejects unknown drivers without connecting
+func TestOpenContext_UnsupportedDriver(t *testing.T) {
+	db, err := OpenContext(context.Background(), &PgConfig{Host: "localhost", Driver: "pgx/v4"})
+
+	assert.ErrorContains(t, err, `unsupported postgres driver "pgx/v4"`)
+	assert.Nil(t, db)
+}
+
+// Test that connections of the pgx driver are retried like the ones of lib/pq
+func TestOpenContext_Pgx(t *testing.T) {
+	db, err := OpenContext(context.Background(), &PgConfig{
+		Host:         "127.0.0.1",
+		Port:         1,
+		SSLMode:      "disable",
+		Driver:       DriverPgx,
+		ConnectRetry: ConnectRetryPolicy{MaxAttempts: 1},
+	})
+
+	assert.ErrorIs(t, err, ErrConnectFailed)
+	assert.Nil(t, db)
+}
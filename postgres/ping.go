@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// defaultPingTimeout limits Ping when its context has no deadline.
+const defaultPingTimeout = 5 * time.Second
+
+// Ping checks that the database configured by DbConfig can be reached, see DatabaseHolder.Ping.
+func Ping(ctx context.Context) error {
+	return defaultDBHolder().Ping(ctx)
+}
+
+// Ping checks that the database of the holder can be reached, connecting a lazy holder first. Unlike
+// CheckConnection it reports the failure, and it gives up once ctx is done, or after 5 seconds if ctx
+// has no deadline.
+// Example:
+//
+//	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+//	  if err := holder.Ping(r.Context()); err != nil {
+//	    http.Error(w, err.Error(), http.StatusServiceUnavailable)
+//	  }
+//	})
+func (h *DatabaseHolder) Ping(ctx context.Context) error {
+	db, err := h.connection()
+	if err != nil {
+		return err
+	}
+	return pingDB(ctx, db, defaultPingTimeout)
+}
+
+// Ping checks that the database of the factory's holder can be reached, see DatabaseHolder.Ping.
+func (f *TransactionContextFactory) Ping(ctx context.Context) error {
+	return f.DBHolder().Ping(ctx)
+}
+
+// pingDB pings db, giving up after timeout unless ctx has a deadline.
+func pingDB(ctx context.Context, db *gorm.DB, timeout time.Duration) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return db.DB().PingContext(ctx)
+}
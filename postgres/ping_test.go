@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test that Ping reports a failed ping
+func TestDatabaseHolder_Ping(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	mock.ExpectPing() // by gorm.Open
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	holder := NewDBHolder(db)
+
+	mock.ExpectPing()
+	assert.NoError(t, holder.Ping(context.Background()))
+
+	pingErr := errors.New("connection refused")
+	mock.ExpectPing().WillReturnError(pingErr)
+	assert.ErrorIs(t, NewTransactionContextFactory(holder).Ping(context.Background()), pingErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Ping gives up once its context is done
+func TestDatabaseHolder_PingDeadline(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	mock.ExpectPing() // by gorm.Open
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPing().WillDelayFor(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, NewDBHolder(db).Ping(ctx))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,183 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"strconv"
+	"strings"
+)
+
+// errPipelineUnsupported occurs when a pipeline reaches a connection that is not a pgx connection.
+var errPipelineUnsupported = errors.New("pipeline requires a connection opened with DriverPgx")
+
+type (
+	// pipelineConnector wraps the pgx connections of another connector with pipelineConn.
+	pipelineConnector struct {
+		next driver.Connector // Connector opening the pgx connections.
+	}
+
+	// pipelineDriver is the driver of a pipelineConnector; it tells ExecBatch that the pool sends
+	// pipelines.
+	pipelineDriver struct {
+		driver.Driver
+	}
+
+	// pipelineConn sends the pipelines of ExecBatch with the batch protocol of pgx, also inside the
+	// transaction running on the connection.
+	pipelineConn struct {
+		forwardingConn
+	}
+
+	// pipeline is the argument ExecBatch executes together with the SQL of the batch on a pipelineConn.
+	pipeline struct {
+		ctx        context.Context  // Context of ExecBatch, as GORM executes the batch without one.
+		statements []batchStatement // Statements of the batch, in order.
+	}
+
+	// batchSender sends a batch of queries in one round trip; *pgx.Conn implements it.
+	batchSender interface {
+		SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	}
+)
+
+// newPipelineConnector wraps the connections of next with pipelineConn.
+func newPipelineConnector(next driver.Connector) *pipelineConnector {
+	return &pipelineConnector{next: next}
+}
+
+// Connect implements driver.Connector.
+func (c *pipelineConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.next.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &pipelineConn{forwardingConn{conn}}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *pipelineConnector) Driver() driver.Driver {
+	return pipelineDriver{c.next.Driver()}
+}
+
+// ExecContext implements driver.ExecerContext. A pipeline argument sends its statements as one pgx batch;
+// query, the statements joined, is only inspected by the connection wrappers around this one.
+func (c *pipelineConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) != 1 {
+		return c.forwardingConn.ExecContext(ctx, query, args)
+	}
+	p, ok := args[0].Value.(pipeline)
+	if !ok {
+		return c.forwardingConn.ExecContext(ctx, query, args)
+	}
+	sender := c.batchSender()
+	if sender == nil {
+		return nil, errPipelineUnsupported
+	}
+
+	batch := &pgx.Batch{}
+	for _, stmt := range p.statements {
+		batch.Queue(numberPlaceholders(stmt.sql), stmt.args...)
+	}
+	if p.ctx != nil {
+		ctx = p.ctx
+	}
+	results := sender.SendBatch(ctx, batch)
+	var affected int64
+	for i, stmt := range p.statements {
+		tag, err := results.Exec()
+		if err != nil {
+			_ = results.Close()
+			return nil, fmt.Errorf("batch statement %d (%s): %w", i+1, stmt.sql, err)
+		}
+		affected += tag.RowsAffected()
+	}
+	if err := results.Close(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+// String describes the pipeline in the statements logged by GORM, without the arguments of its statements.
+func (p pipeline) String() string {
+	return fmt.Sprintf("pipeline of %d statements", len(p.statements))
+}
+
+// CheckNamedValue implements driver.NamedValueChecker, passing pipelines through to ExecContext unchanged.
+func (c *pipelineConn) CheckNamedValue(value *driver.NamedValue) error {
+	if _, ok := value.Value.(pipeline); ok {
+		return nil
+	}
+	return c.forwardingConn.CheckNamedValue(value)
+}
+
+// batchSender returns the pgx connection sending the pipelines, or nil if the connection is not pgx.
+func (c *pipelineConn) batchSender() batchSender {
+	switch conn := c.Conn.(type) {
+	case *stdlib.Conn:
+		return conn.Conn()
+	case batchSender:
+		return conn
+	}
+	return nil
+}
+
+// numberPlaceholders replaces the ? placeholders of query with the numbered placeholders of Postgres, see
+// scanPlaceholders.
+func numberPlaceholders(query string) string {
+	converted, placeholders := scanPlaceholders(query)
+	var b strings.Builder
+	n, next := 0, 0
+	for i := 0; i < len(converted); i++ {
+		if converted[i] != '?' {
+			b.WriteByte(converted[i])
+			continue
+		}
+		if placeholders[next] {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteByte('?')
+		}
+		next++
+	}
+	return b.String()
+}
+
+// scanPlaceholders returns query with its \? escapes replaced by question marks, and reports for every
+// question mark of the result whether it is a placeholder. Question marks in strings, quoted identifiers,
+// dollar quotes and comments, escaped ones and the jsonb operators ?| and ?& are not placeholders; write
+// the jsonb ? operator as \?.
+func scanPlaceholders(query string) (string, []bool) {
+	var b strings.Builder
+	var placeholders []bool
+	for i := 0; i < len(query); i++ {
+		if end, what := literalEnd(query, i); what != "" {
+			if end < 0 {
+				end = len(query)
+			}
+			literal := query[i:end]
+			for range strings.Count(literal, "?") {
+				placeholders = append(placeholders, false)
+			}
+			b.WriteString(literal)
+			i = end - 1
+			continue
+		}
+		switch c := query[i]; {
+		case c == '\\' && i+1 < len(query) && query[i+1] == '?':
+			b.WriteByte('?')
+			placeholders = append(placeholders, false)
+			i++
+		case c == '?':
+			b.WriteByte('?')
+			placeholders = append(placeholders, i+1 >= len(query) || query[i+1] != '|' && query[i+1] != '&')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), placeholders
+}
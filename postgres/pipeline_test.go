@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type (
+	// pipelinedConn is a driver connection recording its transactions and the batches sent with SendBatch.
+	pipelinedConn struct {
+		driver.Conn
+		events *[]string
+		err    error // Error of the second statement of a batch.
+	}
+
+	// pipelinedConnector opens a pipelinedConn.
+	pipelinedConnector struct {
+		conn *pipelinedConn
+	}
+
+	// pipelinedResults are the results of a batch sent to a pipelinedConn.
+	pipelinedResults struct {
+		pgx.BatchResults
+		statements int
+		err        error
+		read       int
+	}
+)
+
+func (c *pipelinedConn) Begin() (driver.Tx, error) {
+	*c.events = append(*c.events, "BEGIN")
+	return c, nil
+}
+func (c *pipelinedConn) Commit() error {
+	*c.events = append(*c.events, "COMMIT")
+	return nil
+}
+func (c *pipelinedConn) Rollback() error {
+	*c.events = append(*c.events, "ROLLBACK")
+	return nil
+}
+func (c *pipelinedConn) Close() error { return nil }
+func (c *pipelinedConn) SendBatch(_ context.Context, b *pgx.Batch) pgx.BatchResults {
+	for _, query := range b.QueuedQueries {
+		*c.events = append(*c.events, fmt.Sprintf("%s %v", query.SQL, query.Arguments))
+	}
+	return &pipelinedResults{statements: b.Len(), err: c.err}
+}
+
+func (c *pipelinedConnector) Connect(context.Context) (driver.Conn, error) { return c.conn, nil }
+func (c *pipelinedConnector) Driver() driver.Driver                        { return nil }
+
+func (r *pipelinedResults) Exec() (pgconn.CommandTag, error) {
+	r.read++
+	if r.read == 2 && r.err != nil {
+		return pgconn.CommandTag{}, r.err
+	}
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+func (r *pipelinedResults) Close() error { return nil }
+
+// getPipelinedTransactionContext builds a transaction context on a pool of pipelinedConn.
+func getPipelinedTransactionContext(t *testing.T, conn *pipelinedConn) (*transactionContext, *gorm.DB) {
+	db, err := gorm.Open("postgres", sql.OpenDB(newPipelineConnector(&pipelinedConnector{conn: conn})))
+	assert.NoError(t, err)
+	return newTransactionContext(log.FromDefaultContext(), NewDBHolder(db)), db
+}
+
+// Test ExecBatch on a pgx pool to verify the batch is sent as one pipeline inside the transaction
+func TestExecBatch_Pipeline(t *testing.T) {
+	var events []string
+	tx, db := getPipelinedTransactionContext(t, &pipelinedConn{events: &events})
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	assert.True(t, tx.dbHolder.sendsPipelines())
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	batch := NewBatch().
+		Queue("UPDATE accounts SET frozen = true WHERE id = ? AND note <> '?'", 7).
+		Queue("DELETE FROM sessions WHERE expired")
+	assert.NoError(t, ExecBatch(ctx, batch))
+	assert.NoError(t, ExecBatch(ctx, NewBatch()))
+	assert.NoError(t, tx.Commit(id))
+
+	assert.Equal(t, []string{
+		"BEGIN",
+		"UPDATE accounts SET frozen = true WHERE id = $1 AND note <> '?' [7]",
+		"DELETE FROM sessions WHERE expired []",
+		"COMMIT",
+	}, events)
+}
+
+// Test that a failing statement of a pipeline fails ExecBatch
+func TestExecBatch_PipelineFailure(t *testing.T) {
+	var events []string
+	failure := errors.New("duplicate key")
+	tx, db := getPipelinedTransactionContext(t, &pipelinedConn{events: &events, err: failure})
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	err := ExecBatch(ctx, NewBatch().Queue("UPDATE a SET x = 1").Queue("INSERT INTO b VALUES (?)", 2))
+	assert.ErrorIs(t, err, failure)
+	assert.ErrorContains(t, err, "batch statement 2")
+}
+
+// Test that the batches of a lib/pq pool are not sent as pipelines
+func TestDatabaseHolder_SendsPipelines(t *testing.T) {
+	tx, db, _ := getTestTransactionContext(t)
+	defer db.Close()
+	assert.False(t, tx.dbHolder.sendsPipelines())
+}
+
+// Test that a pipeline is recorded by the dry-run report like the statements executed with db.Exec
+func TestExecBatch_PipelineDryRun(t *testing.T) {
+	var events []string
+	base, db := getPipelinedTransactionContext(t, &pipelinedConn{events: &events})
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithDryRun())
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, ExecBatch(ctx, NewBatch().Queue("UPDATE a SET x = ?", 1).Queue("DELETE FROM b")))
+	assert.NoError(t, tx.Commit(id))
+	assert.Equal(t, []string{"BEGIN", "UPDATE a SET x = $1 [1]", "DELETE FROM b []", "ROLLBACK"}, events)
+
+	report, ok := GetDryRunReport(ctx)
+	assert.True(t, ok)
+	if assert.Len(t, report.Statements(), 1) {
+		assert.Contains(t, report.Statements()[0].SQL, "UPDATE a SET x = $1;\nDELETE FROM b")
+		assert.NotContains(t, fmt.Sprint(report.Statements()[0].Vars...), "1]")
+	}
+}
+
+// Test that a batch is not sent when its context is done
+func TestExecBatch_PipelineCanceled(t *testing.T) {
+	var events []string
+	tx, db := getPipelinedTransactionContext(t, &pipelinedConn{events: &events})
+	defer db.Close()
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), TransactionContextKey, tx))
+	cancel()
+
+	assert.ErrorIs(t, ExecBatch(ctx, NewBatch().Queue("DELETE FROM b")), context.Canceled)
+	assert.NotContains(t, events, "DELETE FROM b []")
+}
+
+// Test numberPlaceholders to verify only the placeholders outside literals and comments are numbered
+func TestNumberPlaceholders(t *testing.T) {
+	for query, expected := range map[string]string{
+		"UPDATE a SET x = ? WHERE id = ?":                   "UPDATE a SET x = $1 WHERE id = $2",
+		"SELECT * FROM a WHERE tags ?| ? AND tags ?& ?":     "SELECT * FROM a WHERE tags ?| $1 AND tags ?& $2",
+		`SELECT * FROM a WHERE tags \? ? AND "x?" = ?`:      `SELECT * FROM a WHERE tags ? $1 AND "x?" = $2`,
+		"UPDATE a SET x = ? -- why?\nWHERE y = ? /* or? */": "UPDATE a SET x = $1 -- why?\nWHERE y = $2 /* or? */",
+		"SELECT $$?$$, $f$ ? $f$, E'\\'?', ?":               "SELECT $$?$$, $f$ ? $f$, E'\\'?', $1",
+	} {
+		assert.Equal(t, expected, numberPlaceholders(query), query)
+	}
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// WithPprofLabels attaches the pprof labels tx_id and tx_name (TxOptions.Name, if set) to the goroutine
+// running a unit of work of RunInTransaction, Execute and the other helpers, so CPU and block profiles
+// can be sliced by transaction. The labels are also set on the context passed to the function, so
+// goroutines it starts with pprof.Do or pprof.SetGoroutineLabels carry them; the previous labels of the
+// goroutine are restored when the unit of work returns. Transactions begun by hand are not labeled.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithPprofLabels())
+//	err := RunInTransactionWithOptions(ctx, TxOptions{Name: "checkout.placeOrder"}, placeOrder)
+//	// go tool pprof -tagfocus tx_name=checkout.placeOrder cpu.pprof
+func WithPprofLabels() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.pprofLabels = true
+	}
+}
+
+// labelGoroutine sets the pprof labels of the transaction just begun by txContext on the goroutine, if
+// enabled, and returns ctx with the labels together with the function restoring the labels of ctx.
+func labelGoroutine(ctx context.Context, txContext ITransactionContext) (context.Context, func()) {
+	c, ok := txContext.(*transactionContext)
+	if !ok || !c.pprofLabels || c.transactionUUID == nil {
+		return ctx, func() {}
+	}
+	labels := []string{TxIDLogField, c.transactionUUID.String()}
+	if c.txOptions.Name != "" {
+		labels = append(labels, TxNameLogField, c.txOptions.Name)
+	}
+	labeled := pprof.WithLabels(ctx, pprof.Labels(labels...))
+	pprof.SetGoroutineLabels(labeled)
+	return labeled, func() { pprof.SetGoroutineLabels(ctx) }
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"runtime/pprof"
+	"testing"
+)
+
+// Test WithPprofLabels to verify the unit of work carries the tx_id and tx_name labels, joined work included
+func TestWithPprofLabels(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithPprofLabels()(tx)
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	err := RunInTransactionWithOptions(ctx, TxOptions{Name: "checkout"}, func(ctx context.Context, db *gorm.DB) error {
+		id, ok := pprof.Label(ctx, TxIDLogField)
+		assert.True(t, ok)
+		assert.Equal(t, tx.transactionUUID.String(), id)
+		name, _ := pprof.Label(ctx, TxNameLogField)
+		assert.Equal(t, "checkout", name)
+
+		return RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+			joined, _ := pprof.Label(ctx, TxIDLogField)
+			assert.Equal(t, id, joined)
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	_, ok := pprof.Label(ctx, TxIDLogField)
+	assert.False(t, ok)
+}
@@ -0,0 +1,322 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultProjectionChangesTable is the change log used when ProjectorConfig.ChangesTable is empty.
+	DefaultProjectionChangesTable = "uow_projection_changes"
+	// DefaultProjectionCheckpointsTable is the checkpoint table used when ProjectorConfig.CheckpointsTable is empty.
+	DefaultProjectionCheckpointsTable = "uow_projection_checkpoints"
+
+	// Operations recorded in Change.Operation.
+	ChangeInsert = "INSERT"
+	ChangeUpdate = "UPDATE"
+	ChangeDelete = "DELETE"
+
+	// defaultProjectionBatchSize defines the number of changes per handler call when ProjectorConfig.BatchSize is not set.
+	defaultProjectionBatchSize = 500
+	// defaultProjectionPollInterval defines how long an idle projector waits when ProjectorConfig.PollInterval is not set.
+	defaultProjectionPollInterval = 5 * time.Second
+)
+
+// projectorCount numbers the projectors, so the callbacks of several projectors on one connection do not clash.
+var projectorCount atomic.Int64
+
+type (
+	// Change is a row of the change log of a Projector, recording a committed write to a subscribed table.
+	// The change log and the checkpoint table can be created with:
+	//
+	//	CREATE TABLE uow_projection_changes (
+	//	  id         bigserial   PRIMARY KEY,
+	//	  table_name text        NOT NULL,
+	//	  operation  text        NOT NULL,
+	//	  row_id     text        NOT NULL,
+	//	  tx_id      xid8        NOT NULL DEFAULT pg_current_xact_id(),
+	//	  created_at timestamptz NOT NULL DEFAULT now()
+	//	);
+	//	CREATE INDEX uow_projection_changes_position ON uow_projection_changes (tx_id, id);
+	//	CREATE TABLE uow_projection_checkpoints (
+	//	  name       text        PRIMARY KEY,
+	//	  tx_id      xid8        NOT NULL DEFAULT '0',
+	//	  change_id  bigint      NOT NULL DEFAULT 0,
+	//	  updated_at timestamptz NOT NULL DEFAULT now()
+	//	);
+	Change struct {
+		ID        uint64    `gorm:"primary_key"`       // ID identifies the change.
+		Table     string    `gorm:"column:table_name"` // Table is the table of the written row.
+		Operation string    // Operation is ChangeInsert, ChangeUpdate or ChangeDelete.
+		RowID     string    // RowID is the primary key of the written row; empty for statements without one, such as bulk updates.
+		TxID      uint64    // TxID is the ID of the transaction of the write; changes are delivered in TxID, ID order.
+		CreatedAt time.Time // CreatedAt is the time the change was recorded.
+	}
+
+	// ProjectionHandler refreshes a read model, e.g. a materialized view or a denormalized table, for changes
+	// of its subscribed tables. It runs in the transaction that advances the checkpoint of the projection,
+	// so db commits the refresh together with the checkpoint; if it fails, the changes are delivered again.
+	ProjectionHandler func(ctx context.Context, db *gorm.DB, changes []Change) error
+
+	// ProjectorConfig holds the settings of a Projector. Zero values fall back to defaults.
+	ProjectorConfig struct {
+		ChangesTable     string                     // ChangesTable is the change log; DefaultProjectionChangesTable if empty.
+		CheckpointsTable string                     // CheckpointsTable stores the position of every projection; DefaultProjectionCheckpointsTable if empty.
+		Factory          *TransactionContextFactory // Factory creates the transaction contexts of the handlers; nil uses GetTransactionContext.
+		BatchSize        int                        // BatchSize is the maximum number of changes passed to a handler at once.
+		PollInterval     time.Duration              // PollInterval is how long Run waits for changes when no commit wakes it up.
+		Logger           log.Logger                 // Logger used by Run; defaults to the default logger.
+		Clock            Clock                      // Clock waits for the PollInterval; defaults to SystemClock.
+	}
+
+	// Projector keeps read models up to date with the committed writes to the tables they are built from
+	// (CQRS). GORM callbacks record the creates, updates and deletes of subscribed models in a change log in
+	// the transaction of the write, and Run passes them asynchronously to the handlers of the projections,
+	// each with a checkpoint stored in Postgres. Raw Exec statements bypass the callbacks and are not recorded.
+	Projector struct {
+		config ProjectorConfig
+		name   string        // Prefix of the callbacks of the projector.
+		wakeUp chan struct{} // Signaled after the commit of a recorded change.
+
+		mu          sync.RWMutex    // Guards projections and tables.
+		projections []projection    // Subscribed projections.
+		tables      map[string]bool // Tables of the projections, resolved from their models; nil until resolved.
+	}
+
+	// projection is a subscribed read model.
+	projection struct {
+		name    string            // Name of the projection, the key of its checkpoint.
+		handler ProjectionHandler // Handler refreshing the read model.
+		models  []interface{}     // Models or table names the projection is subscribed to.
+		tables  []string          // Tables of models; nil until resolved.
+	}
+)
+
+// NewProjector creates a Projector and registers the callbacks recording changes on db, typically the
+// connection of the holder of config.Factory.
+// Example:
+//
+//	projector := postgres.NewProjector(db, postgres.ProjectorConfig{Factory: factory})
+//	projector.Subscribe("order_totals", func(ctx context.Context, db *gorm.DB, changes []postgres.Change) error {
+//	  return db.Exec("REFRESH MATERIALIZED VIEW order_totals").Error
+//	}, &Order{}, &OrderLine{})
+//	go projector.Run(ctx)
+func NewProjector(db *gorm.DB, config ProjectorConfig) *Projector {
+	if config.ChangesTable == "" {
+		config.ChangesTable = DefaultProjectionChangesTable
+	}
+	if config.CheckpointsTable == "" {
+		config.CheckpointsTable = DefaultProjectionCheckpointsTable
+	}
+	if config.Factory == nil {
+		config.Factory = defaultFactory
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultProjectionBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultProjectionPollInterval
+	}
+	if config.Logger == nil {
+		config.Logger = log.FromDefaultContext()
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+	p := &Projector{
+		config: config,
+		name:   fmt.Sprintf("uow:projector_%d", projectorCount.Add(1)),
+		wakeUp: make(chan struct{}, 1),
+	}
+
+	callbacks := db.Callback()
+	callbacks.Create().After("gorm:create").Register(p.name+"_create", p.recordChange(ChangeInsert))
+	callbacks.Update().After("gorm:update").Register(p.name+"_update", p.recordChange(ChangeUpdate))
+	callbacks.Delete().After("gorm:delete").Register(p.name+"_delete", p.recordChange(ChangeDelete))
+	return p
+}
+
+// Subscribe adds the projection name, refreshed by handler with the changes of models, given as model
+// values such as &Order{} or as table names. A new projection starts with the oldest change of the log.
+func (p *Projector) Subscribe(name string, handler ProjectionHandler, models ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.projections = append(p.projections, projection{name: name, handler: handler, models: models})
+	p.tables = nil
+}
+
+// Run passes the recorded changes to the handlers until ctx is done, waking up on the commits of recorded
+// changes and every PollInterval. Several instances may run it: every projection is processed by one of
+// them at a time. Errors are logged and the changes are delivered again on the next round.
+func (p *Projector) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		more := false
+		p.mu.RLock()
+		names := make([]string, len(p.projections))
+		for i, projection := range p.projections {
+			names[i] = projection.name
+		}
+		p.mu.RUnlock()
+		for _, name := range names {
+			processed, err := p.Project(ctx, name)
+			if err != nil {
+				p.config.Logger.Errorf("projection %s: cannot process changes: %s", name, err)
+			}
+			more = more || (err == nil && processed == p.config.BatchSize)
+		}
+		if more {
+			continue
+		}
+		sleepUnlessWoken(ctx, p.config.Clock, p.config.PollInterval, p.wakeUp)
+	}
+}
+
+// Project passes the next batch of changes of the projection name to its handler and advances its
+// checkpoint in the same unit of work. It skips the projection while another instance processes it, and
+// only reads changes of transactions older than every running one, so no change is ever skipped.
+// The first result is the number of changes processed.
+func (p *Projector) Project(ctx context.Context, name string) (int, error) {
+	handler, ok := p.handler(name)
+	if !ok {
+		return 0, fmt.Errorf("projection %s is not subscribed", name)
+	}
+	processed := 0
+	ctx = p.config.Factory.withNewTransactionContext(ctx)
+	err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		tables := p.tablesOf(db, name)
+		if len(tables) == 0 {
+			return nil
+		}
+		checkpoints := QuoteIdentifier(p.config.CheckpointsTable)
+		insert := fmt.Sprintf("INSERT INTO %s (name) VALUES (?) ON CONFLICT (name) DO NOTHING", checkpoints)
+		if err := db.Exec(insert, name).Error; err != nil {
+			return err
+		}
+		var txID, changeID uint64
+		lock := fmt.Sprintf("SELECT tx_id::text, change_id FROM %s WHERE name = ? FOR UPDATE SKIP LOCKED", checkpoints)
+		if err := db.Raw(lock, name).Row().Scan(&txID, &changeID); errors.Is(err, sql.ErrNoRows) {
+			return nil // processed by another instance
+		} else if err != nil {
+			return err
+		}
+
+		var changes []Change
+		err := db.Table(p.config.ChangesTable).
+			Where("table_name IN (?) AND (tx_id, id) > (?::xid8, ?) AND tx_id < pg_snapshot_xmin(pg_current_snapshot())", tables, txID, changeID).
+			Order("tx_id, id").Limit(p.config.BatchSize).
+			Find(&changes).Error
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		if err := handler(ctx, db, changes); err != nil {
+			return err
+		}
+		last := changes[len(changes)-1]
+		advance := fmt.Sprintf("UPDATE %s SET tx_id = ?::xid8, change_id = ?, updated_at = now() WHERE name = ?", checkpoints)
+		if err := db.Exec(advance, last.TxID, last.ID, name).Error; err != nil {
+			return err
+		}
+		processed = len(changes)
+		return nil
+	})
+	return processed, err
+}
+
+// handler returns the handler of the projection name.
+func (p *Projector) handler(name string) (ProjectionHandler, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, projection := range p.projections {
+		if projection.name == name {
+			return projection.handler, true
+		}
+	}
+	return nil, false
+}
+
+// tablesOf returns the tables of the projection name, resolving them with db if needed.
+func (p *Projector) tablesOf(db *gorm.DB, name string) []string {
+	p.resolveTables(db)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, projection := range p.projections {
+		if projection.name == name {
+			return projection.tables
+		}
+	}
+	return nil
+}
+
+// recordChange returns the callback recording the writes of operation to subscribed tables in the change
+// log, in the transaction of the write, and waking Run up once it commits.
+func (p *Projector) recordChange(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		if scope.HasError() || scope.DB().RowsAffected == 0 || !p.subscribed(scope) {
+			return
+		}
+		rowID := ""
+		if !scope.PrimaryKeyZero() {
+			rowID = fmt.Sprint(scope.PrimaryKeyValue())
+		}
+		insert := fmt.Sprintf("INSERT INTO %s (table_name, operation, row_id) VALUES (?, ?, ?)", QuoteIdentifier(p.config.ChangesTable))
+		if scope.Err(scope.NewDB().Exec(insert, scope.TableName(), operation, rowID).Error) != nil {
+			return
+		}
+		if txContext, ok := scope.Get(transactionContextScopeKey); ok {
+			txContext.(*txHandle).RegisterAfterCommit(p.wake)
+			return
+		}
+		p.wake()
+	}
+}
+
+// subscribed reports whether a projection is subscribed to the table of scope, resolving the tables of
+// the subscribed models with the connection of scope on first use.
+func (p *Projector) subscribed(scope *gorm.Scope) bool {
+	return p.resolveTables(scope.NewDB())[scope.TableName()]
+}
+
+// resolveTables returns the tables of all projections, resolving the tables of their models if needed.
+func (p *Projector) resolveTables(db *gorm.DB) map[string]bool {
+	p.mu.RLock()
+	tables := p.tables
+	p.mu.RUnlock()
+	if tables != nil {
+		return tables
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tables != nil {
+		return p.tables
+	}
+	p.tables = map[string]bool{}
+	for i, projection := range p.projections {
+		projection.tables = nil
+		for _, model := range projection.models {
+			table, ok := model.(string)
+			if !ok {
+				table = db.NewScope(model).TableName()
+			}
+			projection.tables = append(projection.tables, table)
+			p.tables[table] = true
+		}
+		p.projections[i] = projection
+	}
+	return p.tables
+}
+
+// wake wakes Run up without blocking.
+func (p *Projector) wake() {
+	select {
+	case p.wakeUp <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// projectedOrder is a model a projection subscribes to.
+type projectedOrder struct {
+	ID    int
+	Total int
+}
+
+// Test the callbacks of NewProjector to verify writes to subscribed tables are recorded in the transaction
+// of the write and wake the projector up once it commits
+func TestProjector_RecordChange(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	projector := NewProjector(db, ProjectorConfig{})
+	projector.Subscribe("order_totals", func(context.Context, *gorm.DB, []Change) error { return nil }, &projectedOrder{})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "projected_orders" ("total") VALUES ($1) RETURNING "projected_orders"."id"`).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`INSERT INTO "uow_projection_changes" (table_name, operation, row_id) VALUES ($1, $2, $3)`).
+		WithArgs("projected_orders", ChangeInsert, "7").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "other_rows" SET "a" = $1`).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Create(&projectedOrder{Total: 3}).Error)
+	assert.NoError(t, tx.Provider().Table("other_rows").Updates(map[string]interface{}{"a": 1}).Error)
+	assert.Empty(t, projector.wakeUp)
+	assert.NoError(t, tx.Commit(id))
+	assert.Len(t, projector.wakeUp, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Project to verify the changes after the checkpoint are passed to the handler and the checkpoint is
+// advanced in the same transaction
+func TestProjector_Project(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	projector := NewProjector(db, ProjectorConfig{Factory: NewTransactionContextFactory(tx.dbHolder), BatchSize: 2})
+	var received []Change
+	projector.Subscribe("order_totals", func(ctx context.Context, db *gorm.DB, changes []Change) error {
+		received = changes
+		return db.Exec("REFRESH MATERIALIZED VIEW order_totals").Error
+	}, &projectedOrder{}, "order_lines")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "uow_projection_checkpoints" (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`).
+		WithArgs("order_totals").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT tx_id::text, change_id FROM "uow_projection_checkpoints" WHERE name = $1 FOR UPDATE SKIP LOCKED`).
+		WithArgs("order_totals").WillReturnRows(sqlmock.NewRows([]string{"tx_id", "change_id"}).AddRow("40", 3))
+	mock.ExpectQuery(`SELECT * FROM "uow_projection_changes"  WHERE (table_name IN ($1,$2) AND (tx_id, id) > ($3::xid8, $4) AND tx_id < pg_snapshot_xmin(pg_current_snapshot())) ORDER BY tx_id, id LIMIT 2`).
+		WithArgs("projected_orders", "order_lines", 40, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "table_name", "operation", "row_id", "tx_id"}).
+			AddRow(4, "projected_orders", ChangeInsert, "7", "41").
+			AddRow(5, "order_lines", ChangeDelete, "9", "42"))
+	mock.ExpectExec("REFRESH MATERIALIZED VIEW order_totals").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "uow_projection_checkpoints" SET tx_id = $1::xid8, change_id = $2, updated_at = now() WHERE name = $3`).
+		WithArgs(42, 5, "order_totals").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	processed, err := projector.Project(context.Background(), "order_totals")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, processed)
+	assert.Equal(t, []Change{
+		{ID: 4, Table: "projected_orders", Operation: ChangeInsert, RowID: "7", TxID: 41},
+		{ID: 5, Table: "order_lines", Operation: ChangeDelete, RowID: "9", TxID: 42},
+	}, received)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Project to verify a projection whose checkpoint is locked by another instance is skipped
+func TestProjector_ProjectLocked(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	projector := NewProjector(db, ProjectorConfig{Factory: NewTransactionContextFactory(tx.dbHolder)})
+	projector.Subscribe("order_totals", func(context.Context, *gorm.DB, []Change) error {
+		t.Fatal("handler called")
+		return nil
+	}, "orders")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "uow_projection_checkpoints" (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`).
+		WithArgs("order_totals").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT tx_id::text, change_id FROM "uow_projection_checkpoints" WHERE name = $1 FOR UPDATE SKIP LOCKED`).
+		WithArgs("order_totals").WillReturnRows(sqlmock.NewRows([]string{"tx_id", "change_id"}))
+	mock.ExpectCommit()
+
+	processed, err := projector.Project(context.Background(), "order_totals")
+	assert.NoError(t, err)
+	assert.Zero(t, processed)
+	_, err = projector.Project(context.Background(), "unknown")
+	assert.ErrorContains(t, err, "projection unknown is not subscribed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,217 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultQueryCacheTTL defines how long results are cached when QueryCacheConfig.TTL is not set.
+	defaultQueryCacheTTL = time.Minute
+	// minMemorySweepAt is the smallest number of entries at which the memory store removes expired entries.
+	minMemorySweepAt = 1024
+)
+
+type (
+	// QueryCacheStore keeps the entries of a QueryCache. NewMemoryQueryCacheStore keeps them in the process;
+	// an adapter to a shared store such as Redis (GET and SET with EX) shares them between instances.
+	QueryCacheStore interface {
+		Get(ctx context.Context, key string) ([]byte, bool, error)                  // Get returns the value of key and whether it is present.
+		Set(ctx context.Context, key string, value []byte, ttl time.Duration) error // Set stores value under key; a ttl <= 0 never expires.
+	}
+
+	// QueryCacheConfig holds the settings of a QueryCache. Zero values fall back to defaults.
+	QueryCacheConfig struct {
+		TTL    time.Duration // TTL is how long results are cached.
+		Prefix string        // Prefix is prepended to every key, so several caches can share a store.
+		Logger log.Logger    // Logger reports failing stores; defaults to the default logger.
+	}
+
+	// QueryCache caches the results of SELECTs issued outside transactions. Every table has a version in the
+	// store, part of the keys of its cached results; Create, Update and Delete of a table replace its version
+	// once their transaction commits, so the results cached before are never read again and expire.
+	QueryCache struct {
+		store  QueryCacheStore  // Store keeping the versions and results.
+		config QueryCacheConfig // Settings of the cache.
+	}
+
+	// memoryQueryCacheStore is the QueryCacheStore of NewMemoryQueryCacheStore.
+	memoryQueryCacheStore struct {
+		mu      sync.Mutex                  // Guards the fields below.
+		entries map[string]memoryCacheEntry // Entries by key.
+		sweepAt int                         // Number of entries at which expired entries are removed.
+	}
+
+	// memoryCacheEntry is a value of the memory store.
+	memoryCacheEntry struct {
+		value   []byte    // Stored value.
+		expires time.Time // Expiry of the value; zero if it never expires.
+	}
+)
+
+// NewQueryCache creates a QueryCache keeping its entries in store. Writes invalidate it once it is
+// registered on the databases they go through, by RegisterInvalidation or PgConfig.QueryCache.
+// Example:
+//
+//	cache := NewQueryCache(NewMemoryQueryCacheStore(), QueryCacheConfig{TTL: 30 * time.Second})
+//	config.QueryCache = cache
+//	var products []Product
+//	err := cache.Find(ctx, txContext.ProviderWithContext(ctx).Where("active"), &products)
+func NewQueryCache(store QueryCacheStore, config QueryCacheConfig) *QueryCache {
+	if config.TTL <= 0 {
+		config.TTL = defaultQueryCacheTTL
+	}
+	if config.Logger == nil {
+		config.Logger = log.FromDefaultContext()
+	}
+	return &QueryCache{store: store, config: config}
+}
+
+// NewMemoryQueryCacheStore creates a QueryCacheStore keeping its entries in memory.
+func NewMemoryQueryCacheStore() QueryCacheStore {
+	return &memoryQueryCacheStore{entries: map[string]memoryCacheEntry{}, sweepAt: minMemorySweepAt}
+}
+
+// Find loads the records of db into out, as db.Find(out) does, reading them from the cache if a query with
+// the same SQL and arguments has been cached since the last committed write of its table. Inside a
+// transaction the cache is bypassed, so the transaction sees its own writes. Results are cached as JSON;
+// failing stores are logged and fall back to the database.
+func (c *QueryCache) Find(ctx context.Context, db *gorm.DB, out interface{}) error {
+	if _, inTx := db.Get(transactionContextScopeKey); inTx {
+		return db.Find(out).Error
+	}
+	table := db.NewScope(out).TableName()
+	version, err := c.version(ctx, table)
+	if err != nil {
+		c.config.Logger.Warnf("query cache: cannot read version of %s: %s", table, err)
+		return db.Find(out).Error
+	}
+	key := c.resultKey(table, version, db.Model(out).QueryExpr())
+
+	cached, found, err := c.store.Get(ctx, key)
+	if err != nil {
+		c.config.Logger.Warnf("query cache: cannot read %s: %s", table, err)
+	} else if found && json.Unmarshal(cached, out) == nil {
+		return nil
+	}
+
+	if err := db.Find(out).Error; err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(out)
+	if err == nil {
+		err = c.store.Set(ctx, key, encoded, c.config.TTL)
+	}
+	if err != nil {
+		c.config.Logger.Warnf("query cache: cannot cache %s: %s", table, err)
+	}
+	return nil
+}
+
+// Invalidate discards the cached results of the queries of tables.
+func (c *QueryCache) Invalidate(ctx context.Context, tables ...string) error {
+	for _, table := range tables {
+		if err := c.store.Set(ctx, c.versionKey(table), []byte(uuid.NewString()), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterInvalidation registers GORM callbacks on db invalidating the cached results of the tables written
+// by Create, Update and Delete: after the commit of the running transaction of a transaction context, not
+// at all if it rolls back, and right away outside transactions.
+// It is applied automatically by Open when PgConfig.QueryCache is set.
+func (c *QueryCache) RegisterInvalidation(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Create().After("gorm:commit_or_rollback_transaction").Register("uow:invalidate_query_cache_create", c.invalidateScope)
+	callbacks.Update().After("gorm:commit_or_rollback_transaction").Register("uow:invalidate_query_cache_update", c.invalidateScope)
+	callbacks.Delete().After("gorm:commit_or_rollback_transaction").Register("uow:invalidate_query_cache_delete", c.invalidateScope)
+}
+
+// invalidateScope invalidates the table written by scope, once its transaction commits.
+func (c *QueryCache) invalidateScope(scope *gorm.Scope) {
+	if scope.HasError() || scope.DB().RowsAffected == 0 {
+		return
+	}
+	table := scope.TableName()
+	invalidate := func() {
+		if err := c.Invalidate(context.Background(), table); err != nil {
+			c.config.Logger.Errorf("query cache: cannot invalidate %s: %s", table, err)
+		}
+	}
+	if txContext, ok := scope.Get(transactionContextScopeKey); ok {
+		txContext.(*txHandle).RegisterAfterCommit(invalidate)
+		return
+	}
+	invalidate()
+}
+
+// version returns the current version of table; empty if it has never been written.
+func (c *QueryCache) version(ctx context.Context, table string) (string, error) {
+	version, _, err := c.store.Get(ctx, c.versionKey(table))
+	return string(version), err
+}
+
+// versionKey returns the key of the version of table.
+func (c *QueryCache) versionKey(table string) string {
+	return c.config.Prefix + "version:" + table
+}
+
+// resultKey returns the key of the results of query on table at version.
+func (c *QueryCache) resultKey(table, version string, query interface{}) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v", query)))
+	return c.config.Prefix + "result:" + table + ":" + version + ":" + hex.EncodeToString(sum[:])
+}
+
+// Get implements QueryCacheStore.
+func (s *memoryQueryCacheStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if entry.expired(time.Now()) {
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+// Set implements QueryCacheStore; expired entries are removed whenever the number of entries has doubled.
+func (s *memoryQueryCacheStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := memoryCacheEntry{value: value}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	s.entries[key] = entry
+	if len(s.entries) >= s.sweepAt {
+		now := time.Now()
+		for key, entry := range s.entries {
+			if entry.expired(now) {
+				delete(s.entries, key)
+			}
+		}
+		s.sweepAt = 2 * len(s.entries)
+		if s.sweepAt < minMemorySweepAt {
+			s.sweepAt = minMemorySweepAt
+		}
+	}
+	return nil
+}
+
+// expired reports whether the entry has expired at now.
+func (e memoryCacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
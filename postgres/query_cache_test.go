@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type cachedProduct struct {
+	ID   int
+	Name string
+}
+
+// Test QueryCache.Find to verify it serves repeated queries from the cache until a write of their table
+func TestQueryCache_Find(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	cache := NewQueryCache(NewMemoryQueryCacheStore(), QueryCacheConfig{})
+	cache.RegisterInvalidation(db)
+	ctx := context.Background()
+	query := `SELECT * FROM "cached_products"  WHERE (name = $1)`
+
+	mock.ExpectQuery(query).WithArgs("tea").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "tea"))
+	for i := 0; i < 2; i++ {
+		var products []cachedProduct
+		assert.NoError(t, cache.Find(ctx, tx.Provider().Where("name = ?", "tea"), &products))
+		assert.Equal(t, []cachedProduct{{ID: 1, Name: "tea"}}, products)
+	}
+
+	mock.ExpectQuery(`SELECT * FROM "cached_products"  WHERE (name = $1)`).WithArgs("coffee").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	var products []cachedProduct
+	assert.NoError(t, cache.Find(ctx, tx.Provider().Where("name = ?", "coffee"), &products))
+	assert.Empty(t, products)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "cached_products" SET "name" = $1  WHERE "cached_products"."id" = $2`).
+		WithArgs("green tea", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, tx.Provider().Save(&cachedProduct{ID: 1, Name: "green tea"}).Error)
+
+	mock.ExpectQuery(query).WithArgs("tea").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	products = nil
+	assert.NoError(t, cache.Find(ctx, tx.Provider().Where("name = ?", "tea"), &products))
+	assert.Empty(t, products)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test QueryCache in transactions to verify reads bypass it and writes invalidate it only once committed
+func TestQueryCache_Transaction(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	cache := NewQueryCache(NewMemoryQueryCacheStore(), QueryCacheConfig{})
+	cache.RegisterInvalidation(db)
+	ctx := context.Background()
+	query := `SELECT * FROM "cached_products"`
+	find := func() {
+		var products []cachedProduct
+		assert.NoError(t, cache.Find(ctx, tx.Provider(), &products))
+	}
+	update := func() {
+		mock.ExpectExec(`UPDATE "cached_products" SET "name" = $1  WHERE "cached_products"."id" = $2`).
+			WithArgs("tea", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		assert.NoError(t, tx.Provider().Save(&cachedProduct{ID: 1, Name: "tea"}).Error)
+	}
+
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	find()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	find()
+	update()
+	mock.ExpectRollback()
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, tx.Reset())
+	find()
+
+	mock.ExpectBegin()
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	update()
+	mock.ExpectCommit()
+	assert.NoError(t, tx.Commit(id))
+	mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	find()
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test the memory store to verify entries expire after their TTL
+func TestMemoryQueryCacheStore(t *testing.T) {
+	store := NewMemoryQueryCacheStore()
+	ctx := context.Background()
+	assert.NoError(t, store.Set(ctx, "short", []byte("a"), time.Millisecond))
+	assert.NoError(t, store.Set(ctx, "forever", []byte("b"), 0))
+	time.Sleep(5 * time.Millisecond)
+
+	_, found, err := store.Get(ctx, "short")
+	assert.NoError(t, err)
+	assert.False(t, found)
+	value, found, err := store.Get(ctx, "forever")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("b"), value)
+}
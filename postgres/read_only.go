@@ -0,0 +1,233 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+// readOnlyScopeKey marks the *gorm.DB of a read-only transaction for the write-rejecting callbacks.
+const readOnlyScopeKey = "uow:read_only"
+
+// ErrReadOnlyTransaction is returned by Create/Update/Delete issued inside a read-only transaction
+// when the write-rejecting callbacks are registered.
+var ErrReadOnlyTransaction = errors.New("write operation rejected: the transaction is read-only")
+
+// ErrReadOnlyDatabase is returned by writes issued through a replica or another read-only database, see
+// PgConfig.ReadOnly, before they are sent to the server.
+var ErrReadOnlyDatabase = errors.New("write operation rejected: the database is read-only")
+
+// writeCommands are the leading keywords of the statements rejected on read-only databases.
+var writeCommands = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "TRUNCATE": true, "COPY": true,
+	"CREATE": true, "ALTER": true, "DROP": true, "COMMENT": true, "GRANT": true, "REVOKE": true,
+	"REFRESH": true, "REINDEX": true, "CLUSTER": true, "VACUUM": true,
+}
+
+type (
+	// readOnlyConnector wraps the connections of another connector with readOnlyConn.
+	readOnlyConnector struct {
+		next driver.Connector // Connector opening the physical connections.
+	}
+
+	// readOnlyConn rejects the write statements executed, queried or prepared on the physical connection.
+	readOnlyConn struct {
+		forwardingConn
+	}
+)
+
+// BeginReadOnly starts a read-only transaction (BEGIN READ ONLY) and returns its unique identifier.
+// The server rejects writes; with RegisterReadOnlyCallbacks GORM rejects them before they are sent.
+// Example:
+//
+//	id, err := txContext.BeginReadOnly()
+//	if err != nil { return err }
+//	defer txContext.Rollback()
+func (c *transactionContext) BeginReadOnly() (uuid.UUID, error) {
+	return c.BeginWithOptions(TxOptions{ReadOnly: true})
+}
+
+// RegisterReadOnlyCallbacks registers GORM callbacks on db that fail Create, Update and Delete with
+// ErrReadOnlyTransaction when they run inside a read-only transaction of a transaction context.
+// It is applied automatically by Open when PgConfig.RejectReadOnlyWrites is set.
+func RegisterReadOnlyCallbacks(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:begin_transaction").Register("uow:reject_read_only_create", rejectReadOnlyWrites)
+	callbacks.Update().Before("gorm:begin_transaction").Register("uow:reject_read_only_update", rejectReadOnlyWrites)
+	callbacks.Delete().Before("gorm:begin_transaction").Register("uow:reject_read_only_delete", rejectReadOnlyWrites)
+}
+
+// RegisterStrictReadOnly registers GORM callbacks on db that fail every Create, Update and Delete with
+// ErrReadOnlyDatabase, for databases backed by a replica or opened read-only.
+// It is applied automatically by Open when PgConfig.ReadOnly is set, which also rejects raw statements
+// such as db.Exec("DELETE ...") on the connections; on databases opened otherwise those reach the server.
+func RegisterStrictReadOnly(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:begin_transaction").Register("uow:reject_create_on_read_only_database", rejectAllWrites)
+	callbacks.Update().Before("gorm:begin_transaction").Register("uow:reject_update_on_read_only_database", rejectAllWrites)
+	callbacks.Delete().Before("gorm:begin_transaction").Register("uow:reject_delete_on_read_only_database", rejectAllWrites)
+}
+
+// rejectAllWrites aborts the operation and skips the remaining callbacks.
+func rejectAllWrites(scope *gorm.Scope) {
+	_ = scope.Err(fmt.Errorf("%w: cannot write %s", ErrReadOnlyDatabase, scope.TableName()))
+	scope.SkipLeft()
+}
+
+// rejectReadOnlyWrites aborts the operation if the scope belongs to a read-only transaction.
+func rejectReadOnlyWrites(scope *gorm.Scope) {
+	if readOnly, ok := scope.Get(readOnlyScopeKey); ok && readOnly == true {
+		_ = scope.Err(ErrReadOnlyTransaction)
+	}
+}
+
+// markReadOnly flags the running transaction for the write-rejecting callbacks.
+func (c *transactionContext) markReadOnly() {
+	if c.txOptions.ReadOnly {
+		c.tx = c.tx.Set(readOnlyScopeKey, true)
+	}
+}
+
+// newReadOnlyConnector wraps the connections of next with readOnlyConn.
+func newReadOnlyConnector(next driver.Connector) *readOnlyConnector {
+	return &readOnlyConnector{next: next}
+}
+
+// Connect implements driver.Connector.
+func (c *readOnlyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.next.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &readOnlyConn{forwardingConn{conn}}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *readOnlyConnector) Driver() driver.Driver {
+	return c.next.Driver()
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *readOnlyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := rejectWriteStatement(query); err != nil {
+		return nil, err
+	}
+	return c.forwardingConn.ExecContext(ctx, query, args)
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *readOnlyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := rejectWriteStatement(query); err != nil {
+		return nil, err
+	}
+	return c.forwardingConn.QueryContext(ctx, query, args)
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *readOnlyConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := rejectWriteStatement(query); err != nil {
+		return nil, err
+	}
+	return c.forwardingConn.PrepareContext(ctx, query)
+}
+
+// Prepare implements driver.Conn.
+func (c *readOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// rejectWriteStatement fails with ErrReadOnlyDatabase if a statement of query, e.g. of a batch, starts with a
+// write command or is a WITH query modifying data. Writes hidden in functions are left to the server.
+func rejectWriteStatement(query string) error {
+	statements, err := splitStatements(query, false)
+	if err != nil {
+		statements = []scriptStatement{{sql: query}} // unterminated text, which the server rejects
+	}
+	for _, statement := range statements {
+		command := leadingCommand(statement.sql)
+		if command == "WITH" {
+			command = modifyingCommand(statement.sql)
+		}
+		if writeCommands[command] {
+			return fmt.Errorf("%w: cannot run %s", ErrReadOnlyDatabase, command)
+		}
+	}
+	return nil
+}
+
+// modifyingCommand returns the INSERT, UPDATE, DELETE or MERGE of a WITH query, or "" if it only reads. The
+// commands are found after a parenthesis, starting a subquery or the main statement after the last one,
+// or after MATERIALIZED, outside of strings, quoted identifiers, dollar quotes and comments.
+func modifyingCommand(query string) string {
+	previous := ""
+	for i := 0; i < len(query); {
+		if end, what := literalEnd(query, i); what != "" {
+			if end < 0 {
+				return ""
+			}
+			if what != "comment" {
+				previous = ""
+			}
+			i = end
+			continue
+		}
+		switch c := query[i]; {
+		case isWordByte(c):
+			end := i + 1
+			for end < len(query) && isWordByte(query[end]) {
+				end++
+			}
+			word := strings.ToUpper(query[i:end])
+			switch word {
+			case "INSERT", "UPDATE", "DELETE", "MERGE":
+				if previous == "(" || previous == ")" || previous == "MATERIALIZED" {
+					return word
+				}
+			}
+			previous, i = word, end
+		case strings.IndexByte(" \t\r\n", c) >= 0:
+			i++
+		default:
+			previous, i = string(c), i+1
+		}
+	}
+	return ""
+}
+
+// isWordByte reports whether c belongs to a keyword or an unquoted identifier.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// leadingCommand returns the first keyword of query in upper case, skipping whitespace, comments and
+// opening parentheses.
+func leadingCommand(query string) string {
+	for {
+		trimmed := strings.TrimLeft(query, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(trimmed, "--"):
+			if end := strings.IndexByte(trimmed, '\n'); end >= 0 {
+				query = trimmed[end+1:]
+				continue
+			}
+			return ""
+		case strings.HasPrefix(trimmed, "/*"):
+			if end := strings.Index(trimmed, "*/"); end >= 0 {
+				query = trimmed[end+2:]
+				continue
+			}
+			return ""
+		}
+		end := strings.IndexFunc(trimmed, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		})
+		if end < 0 {
+			end = len(trimmed)
+		}
+		return strings.ToUpper(trimmed[:end])
+	}
+}
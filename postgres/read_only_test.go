@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// readOnlyModel is a minimal model used to exercise the read-only callbacks.
+type readOnlyModel struct {
+	ID   uint
+	Name string
+}
+
+// Test that writes are rejected inside a read-only transaction before reaching the database
+func TestBeginReadOnly_RejectsWrites(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	RegisterReadOnlyCallbacks(db)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	_, err := tx.BeginReadOnly()
+	assert.NoError(t, err)
+	err = tx.Provider().Create(&readOnlyModel{Name: "x"}).Error
+	assert.ErrorIs(t, err, ErrReadOnlyTransaction)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test RegisterStrictReadOnly to verify Create, Update and Delete fail before any statement is sent
+func TestRegisterStrictReadOnly(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	RegisterStrictReadOnly(db)
+
+	assert.ErrorIs(t, tx.Provider().Create(&readOnlyModel{Name: "x"}).Error, ErrReadOnlyDatabase)
+	assert.ErrorIs(t, tx.Provider().Save(&readOnlyModel{ID: 1, Name: "x"}).Error, ErrReadOnlyDatabase)
+	assert.ErrorIs(t, tx.Provider().Delete(&readOnlyModel{ID: 1}).Error, ErrReadOnlyDatabase)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test the read-only connections to verify raw write statements are rejected and reads pass through
+func TestReadOnlyConn(t *testing.T) {
+	mockDB, mock, err := sqlmock.NewWithDSN("read_only", sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer mockDB.Close()
+	sqlDB := sql.OpenDB(newReadOnlyConnector(dsnConnector{driver: mockDB.Driver(), dsn: "read_only"}))
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	assert.ErrorIs(t, db.Exec("DELETE FROM accounts").Error, ErrReadOnlyDatabase)
+	assert.ErrorIs(t, db.Exec("/* batch */ insert into accounts (id) values (?)", 1).Error, ErrReadOnlyDatabase)
+	assert.ErrorIs(t, db.Raw("INSERT INTO accounts DEFAULT VALUES RETURNING id").Scan(&struct{ ID int }{}).Error, ErrReadOnlyDatabase)
+	assert.ErrorIs(t, db.Exec("SELECT 1; DELETE FROM accounts").Error, ErrReadOnlyDatabase)
+	assert.ErrorIs(t, NewBatch().Queue("SELECT 1").Queue("DELETE FROM accounts").exec(db), ErrReadOnlyDatabase)
+	assert.ErrorIs(t, db.Exec("WITH gone AS (DELETE FROM accounts RETURNING id) SELECT count(*) FROM gone").Error, ErrReadOnlyDatabase)
+
+	mock.ExpectExec("SET statement_timeout = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id FROM accounts WHERE id = $1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	assert.NoError(t, db.Exec("SET statement_timeout = 0").Error)
+	var account struct{ ID int }
+	assert.NoError(t, db.Raw("SELECT id FROM accounts WHERE id = ?", 1).Scan(&account).Error)
+	assert.Equal(t, 1, account.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test leadingCommand to verify comments, whitespace and parentheses are skipped
+func TestLeadingCommand(t *testing.T) {
+	for query, command := range map[string]string{
+		"select 1":                        "SELECT",
+		"  \n\tUPDATE accounts SET x = 1": "UPDATE",
+		"-- note\ndelete from accounts":   "DELETE",
+		"/* a */ /* b */Insert INTO t":    "INSERT",
+		"(SELECT 1) UNION (SELECT 2)":     "SELECT",
+		"-- only a comment":               "",
+		"":                                "",
+	} {
+		assert.Equal(t, command, leadingCommand(query), query)
+	}
+}
+
+// Test rejectWriteStatement to verify every statement of a batch and data-modifying WITH queries are checked
+func TestRejectWriteStatement(t *testing.T) {
+	for _, query := range []string{
+		"SELECT 1; DELETE FROM orders",
+		"SET search_path = app;\nUPDATE orders SET total = 0",
+		"WITH gone AS (DELETE FROM orders RETURNING id) SELECT count(*) FROM gone",
+		"WITH ids AS (SELECT id FROM orders) DELETE FROM orders WHERE id IN (SELECT id FROM ids)",
+		"WITH moved AS MATERIALIZED (INSERT INTO archive SELECT * FROM orders RETURNING id) SELECT 1",
+	} {
+		assert.ErrorIs(t, rejectWriteStatement(query), ErrReadOnlyDatabase, query)
+	}
+	for _, query := range []string{
+		"SELECT 'a; DELETE FROM orders'",
+		"SELECT 1 -- ; DELETE FROM orders",
+		"SELECT $$; DELETE FROM orders$$",
+		`WITH recent AS (SELECT "update", note FROM orders WHERE note <> '(DELETE') SELECT * FROM recent`,
+		"WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 3) SELECT x FROM n",
+	} {
+		assert.NoError(t, rejectWriteStatement(query), query)
+	}
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// ReadReplica routes the reads of ProviderReplica to the replica of the context, the default.
+	ReadReplica ReadPreference = iota
+	// ReadPrimary routes the reads of ProviderReplica to the primary.
+	ReadPrimary
+)
+
+type (
+	// ReadPreference selects the database ProviderReplica reads from, see ContextWithReadPreference.
+	ReadPreference int
+
+	// readPreferenceKey is the context key of the preference stored by ContextWithReadPreference.
+	readPreferenceKey struct{}
+)
+
+// WithReplica routes the reads of ProviderReplica to replica, a holder of a read replica of the database
+// of the context, typically opened with PgConfig.ReadOnly. Once the context has committed a read-write
+// transaction, its reads stay on the primary, so a request reads its own writes.
+// Example:
+//
+//	replica := postgres.NewDBHolder(postgres.NewConnect(&replicaConfig))
+//	factory := postgres.NewTransactionContextFactory(primary, postgres.WithReplica(replica))
+func WithReplica(replica *DatabaseHolder) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.replica = replica
+	}
+}
+
+// ContextWithReadPreference returns a copy of ctx whose reads through ProviderReplica follow preference,
+// e.g. ReadPrimary for a request that must not observe replication lag.
+// Example:
+//
+//	ctx = postgres.ContextWithReadPreference(ctx, postgres.ReadPrimary)
+func ContextWithReadPreference(ctx context.Context, preference ReadPreference) context.Context {
+	return context.WithValue(ctx, readPreferenceKey{}, preference)
+}
+
+// ProviderPrimary returns the Provider() of the transaction context of ctx, which always uses the primary:
+// the running transaction, or the primary connection outside one.
+func ProviderPrimary(ctx context.Context) *gorm.DB {
+	txContext, _ := GetTransactionContext(ctx)
+	return txContext.Provider()
+}
+
+// ProviderReplica returns the connection of the replica of WithReplica for reads that tolerate replication
+// lag. It falls back to ProviderPrimary inside a transaction, after the context has committed a read-write
+// transaction, with ReadPrimary, without a replica or while a HealthWatchdog reports it unhealthy.
+// Example:
+//
+//	var orders []Order
+//	err := postgres.ProviderReplica(ctx).Where("customer_id = ?", id).Find(&orders).Error
+func ProviderReplica(ctx context.Context) *gorm.DB {
+	txContext, _ := GetTransactionContext(ctx)
+	c, ok := txContext.(*transactionContext)
+	if !ok || c.replica == nil || c.primaryReads || c.inTransaction() || !c.replica.Healthy() {
+		return txContext.Provider()
+	}
+	if preference, _ := ctx.Value(readPreferenceKey{}).(ReadPreference); preference == ReadPrimary {
+		return txContext.Provider()
+	}
+	db, err := c.replica.connection()
+	if err != nil {
+		c.logger.Warnf("cannot connect to the replica, reading from the primary: %s", err)
+		return txContext.Provider()
+	}
+	return c.withHooks(c.replica.withLogMode(db))
+}
+
+// stickToPrimary keeps the reads of the context on the primary once it has committed a transaction that
+// was not read-only.
+func (c *transactionContext) stickToPrimary(readOnly bool) {
+	if c.replica != nil && !readOnly {
+		c.primaryReads = true
+	}
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test ProviderReplica to verify reads go to the replica outside a transaction and stay on the primary
+// inside one, after a committed write, with ReadPrimary and while the replica is unhealthy
+func TestProviderReplica(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	replicaDB, replicaMock, err := sqlmock.New()
+	assert.NoError(t, err)
+	replicaGorm, err := gorm.Open("postgres", replicaDB)
+	assert.NoError(t, err)
+	defer replicaGorm.Close()
+	replica := NewDBHolder(replicaGorm)
+	WithReplica(replica)(tx)
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	assert.Equal(t, replicaGorm, ProviderReplica(ctx))
+	assert.Equal(t, db, ProviderReplica(ContextWithReadPreference(ctx, ReadPrimary)))
+	assert.Equal(t, db, ProviderPrimary(ctx))
+
+	replica.unhealthy.Store(true)
+	assert.Equal(t, db, ProviderReplica(ctx))
+	replica.unhealthy.Store(false)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.Equal(t, tx.Provider(), ProviderReplica(ctx))
+	assert.NoError(t, tx.Commit(id))
+	assert.Equal(t, db, ProviderReplica(ctx))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+// Test ProviderReplica to verify a read-only transaction does not keep later reads on the primary
+func TestProviderReplica_ReadOnlyTransaction(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	replicaDB, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	replicaGorm, err := gorm.Open("postgres", replicaDB)
+	assert.NoError(t, err)
+	defer replicaGorm.Close()
+	WithReplica(NewDBHolder(replicaGorm))(tx)
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, err := tx.BeginReadOnly()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+	assert.Equal(t, replicaGorm, ProviderReplica(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// redacted replaces secrets and masked bind parameters in log lines and errors.
+const redacted = "[REDACTED]"
+
+var (
+	// dsnPasswordPattern matches the password of a key=value DSN.
+	dsnPasswordPattern = regexp.MustCompile(`(?i)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+	// urlPasswordPattern matches the password of a URL.
+	urlPasswordPattern = regexp.MustCompile(`(://[^:/@\s]*:)[^@\s]*@`)
+	// comparedColumnPattern matches a column, optionally qualified by its table, compared with a bind
+	// parameter, e.g. "users"."ssn" = $3.
+	comparedColumnPattern = regexp.MustCompile(`(?i)(?:"?(\w+)"?\.)?"?(\w+)"?\s*(?:=|<>|!=|<=|>=|<|>|\bLIKE\b|\bILIKE\b)\s*\$(\d+)\b`)
+	// statementTablePattern matches the table a statement writes or first reads from.
+	statementTablePattern = regexp.MustCompile(`(?is)^\s*(?:INSERT\s+INTO|UPDATE|DELETE\s+FROM|SELECT\b.*?\bFROM)\s+(?:"?\w+"?\.)?"?(\w+)"?`)
+	// insertPattern matches the column list and the values of an INSERT statement.
+	insertPattern = regexp.MustCompile(`(?is)\bINSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES\s*(.*)`)
+	// tuplePattern matches a parenthesized list of values.
+	tuplePattern = regexp.MustCompile(`\(([^()]*)\)`)
+	// placeholderPattern matches a single bind parameter.
+	placeholderPattern = regexp.MustCompile(`^\$(\d+)$`)
+)
+
+type (
+	// redactedError masks secrets in the message of an error, which still unwraps to the original error.
+	redactedError struct {
+		err     error    // The original error.
+		secrets []string // Values masked in the message.
+	}
+
+	// redactionRules are the masking rules of PgConfig.RedactColumns and PgConfig.RedactPatterns.
+	redactionRules struct {
+		columns  []string         // Columns, or table.column pairs, whose bind parameters are masked.
+		patterns []*regexp.Regexp // Patterns whose matches are masked in the statements and their string parameters.
+	}
+
+	// redactingLogger masks sensitive values in the SQL logs of GORM.
+	redactingLogger struct {
+		next  gormLogger     // next receives the masked lines.
+		rules redactionRules // Rules of the masked values.
+	}
+)
+
+// RedactError returns err with the given secrets, DSN passwords and URL passwords masked in its message,
+// so it can be logged safely. The result still matches err with errors.Is and errors.As.
+// Example:
+//
+//	logger.Errorf("cannot connect: %s", RedactError(err, config.Password))
+func RedactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err, secrets: secrets}
+}
+
+// Error implements error.
+func (e *redactedError) Error() string {
+	return redactSecrets(e.err.Error(), e.secrets...)
+}
+
+// Unwrap returns the original error.
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactSecrets masks secrets, DSN passwords and URL passwords in s.
+func redactSecrets(s string, secrets ...string) string {
+	s = dsnPasswordPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = urlPasswordPattern.ReplaceAllString(s, "${1}"+redacted+"@")
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return s
+}
+
+// redactURL masks the password of a postgres:// URL.
+func redactURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Redacted()
+	}
+	return urlPasswordPattern.ReplaceAllString(rawURL, "${1}"+redacted+"@")
+}
+
+// RedactParams returns a copy of the bind parameters of the statement sql in which the values compared with
+// or inserted into the given columns are masked; params is returned as is if none are. Columns are matched
+// case-insensitively, by name regardless of their table, or as "table.column" only in that table: the
+// table qualifying the column in sql, or else the table the statement writes or first reads from.
+// Example:
+//
+//	params = RedactParams(`UPDATE "users" SET "password_hash" = $1 WHERE "id" = $2`, params, "password_hash", "accounts.token")
+func RedactParams(sql string, params []interface{}, columns ...string) []interface{} {
+	if len(columns) == 0 || len(params) == 0 {
+		return params
+	}
+	sensitive := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		sensitive[strings.ToLower(column)] = true
+	}
+
+	var table string
+	if match := statementTablePattern.FindStringSubmatch(sql); match != nil {
+		table = strings.ToLower(match[1])
+	}
+
+	var masked []interface{}
+	mask := func(qualifier, column, placeholder string) {
+		column = strings.ToLower(strings.Trim(strings.TrimSpace(column), `"`))
+		if qualifier = strings.ToLower(qualifier); qualifier == "" {
+			qualifier = table
+		}
+		if !sensitive[column] && !sensitive[qualifier+"."+column] {
+			return
+		}
+		index, err := strconv.Atoi(placeholder)
+		if err != nil || index < 1 || index > len(params) {
+			return
+		}
+		if masked == nil {
+			masked = append([]interface{}(nil), params...)
+		}
+		masked[index-1] = redacted
+	}
+
+	for _, match := range comparedColumnPattern.FindAllStringSubmatch(sql, -1) {
+		mask(match[1], match[2], match[3])
+	}
+	if insert := insertPattern.FindStringSubmatch(sql); insert != nil {
+		names := strings.Split(insert[1], ",")
+		for _, tuple := range tuplePattern.FindAllStringSubmatch(insert[2], -1) {
+			for i, value := range strings.Split(tuple[1], ",") {
+				if match := placeholderPattern.FindStringSubmatch(strings.TrimSpace(value)); match != nil && i < len(names) {
+					mask("", names[i], match[1])
+				}
+			}
+		}
+	}
+	if masked == nil {
+		return params
+	}
+	return masked
+}
+
+// newRedactionRules compiles the masking rules of config.
+func newRedactionRules(config *PgConfig) (redactionRules, error) {
+	rules := redactionRules{columns: config.RedactColumns}
+	for _, pattern := range config.RedactPatterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return redactionRules{}, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		rules.patterns = append(rules.patterns, compiled)
+	}
+	return rules, nil
+}
+
+// empty reports whether the rules mask nothing.
+func (r redactionRules) empty() bool {
+	return len(r.columns) == 0 && len(r.patterns) == 0
+}
+
+// maskPatterns masks the matches of the patterns in s.
+func (r redactionRules) maskPatterns(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// newRedactingLogger returns next masking the values selected by rules, or next itself if there are none.
+func newRedactingLogger(next gormLogger, rules redactionRules) gormLogger {
+	if rules.empty() {
+		return next
+	}
+	return redactingLogger{next: next, rules: rules}
+}
+
+// Print implements the logger interface of GORM, masking the statements and bind parameters of "sql" lines.
+func (l redactingLogger) Print(values ...interface{}) {
+	if len(values) > 4 && values[0] == "sql" {
+		sql, _ := values[3].(string)
+		params, _ := values[4].([]interface{})
+		params = RedactParams(sql, params, l.rules.columns...)
+		if len(l.rules.patterns) > 0 {
+			params = append([]interface{}(nil), params...)
+			for i, param := range params {
+				if s, ok := param.(string); ok {
+					params[i] = l.rules.maskPatterns(s)
+				}
+			}
+			sql = l.rules.maskPatterns(sql)
+		}
+		values = append([]interface{}(nil), values...)
+		values[3], values[4] = sql, params
+	}
+	l.next.Print(values...)
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// recordingGORMLogger records the values passed to Print.
+type recordingGORMLogger struct {
+	lines [][]interface{}
+}
+
+func (l *recordingGORMLogger) Print(v ...interface{}) {
+	l.lines = append(l.lines, v)
+}
+
+// Test RedactError to verify passwords are masked while the error still matches
+func TestRedactError(t *testing.T) {
+	cause := errors.New(`cannot connect to "host=db user=app password=s3cr3t" or postgres://app:s3cr3t@db/app: pq: password authentication failed for s3cr3t`)
+	err := RedactError(cause, "s3cr3t")
+
+	assert.ErrorIs(t, err, cause)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+	assert.Contains(t, err.Error(), "password=[REDACTED]")
+	assert.Contains(t, err.Error(), "postgres://app:[REDACTED]@db/app")
+	assert.Nil(t, RedactError(nil))
+}
+
+// Test ParseConfigFromURL to verify invalid URLs do not leak the password
+func TestParseConfigFromURL_RedactsPassword(t *testing.T) {
+	_, err := ParseConfigFromURL("postgres://app:s3cr3t@db:port/app")
+
+	assert.ErrorIs(t, err, ErrInvalidURL)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+}
+
+// Test RedactParams to verify only the parameters of sensitive columns are masked
+func TestRedactParams(t *testing.T) {
+	params := []interface{}{"alice", "hash", 42}
+
+	masked := RedactParams(`UPDATE "users" SET "name" = $1, "password_hash" = $2 WHERE "users"."id" = $3`, params, "PASSWORD_HASH")
+	assert.Equal(t, []interface{}{"alice", "[REDACTED]", 42}, masked)
+	assert.Equal(t, "hash", params[1])
+
+	masked = RedactParams(`INSERT INTO "users" ("name","ssn","id") VALUES ($1,$2,$3),($4,$5,$6)`,
+		[]interface{}{"alice", "123", 1, "bob", "456", 2}, "ssn")
+	assert.Equal(t, []interface{}{"alice", "[REDACTED]", 1, "bob", "[REDACTED]", 2}, masked)
+
+	assert.Equal(t, params, RedactParams(`SELECT * FROM "users" WHERE "id" = $3`, params, "ssn"))
+	assert.Equal(t, params, RedactParams(`SELECT * FROM "users" WHERE "ssn" = $7`, params, "ssn"))
+}
+
+// Test RedactParams to verify table.column rules only mask the columns of that table
+func TestRedactParams_TableColumn(t *testing.T) {
+	params := []interface{}{"a@example.com", "b@example.com"}
+
+	masked := RedactParams(`SELECT * FROM "users" JOIN "teams" ON "teams"."id" = "users"."team_id" WHERE "users"."email" = $1 AND "teams"."email" = $2`,
+		params, "Users.Email")
+	assert.Equal(t, []interface{}{"[REDACTED]", "b@example.com"}, masked)
+
+	masked = RedactParams(`UPDATE "public"."users" SET "email" = $1 WHERE "id" = $2`, []interface{}{"a@example.com", 1}, "users.email")
+	assert.Equal(t, []interface{}{"[REDACTED]", 1}, masked)
+
+	masked = RedactParams(`INSERT INTO "users" ("email","name") VALUES ($1,$2)`, []interface{}{"a@example.com", "alice"}, "users.email")
+	assert.Equal(t, []interface{}{"[REDACTED]", "alice"}, masked)
+
+	assert.Equal(t, params, RedactParams(`DELETE FROM "teams" WHERE "email" = $1`, params, "users.email"))
+}
+
+// Test the redacting logger to verify pattern rules mask the statement and the string parameters
+func TestRedactingLogger_Patterns(t *testing.T) {
+	rules, err := newRedactionRules(&PgConfig{RedactPatterns: []string{`[\w.+-]+@[\w-]+\.[\w.]+`, `tok_[0-9a-f]+`}})
+	assert.NoError(t, err)
+	next := &recordingGORMLogger{}
+	logger := newRedactingLogger(next, rules)
+
+	params := []interface{}{"bearer tok_3fa9", 7}
+	logger.Print("sql", "file.go:1", 0, `SELECT * FROM "users" WHERE "email" = 'a@example.com' AND "token" = $1 AND "id" = $2`, params, int64(1))
+
+	assert.Equal(t, `SELECT * FROM "users" WHERE "email" = '[REDACTED]' AND "token" = $1 AND "id" = $2`, next.lines[0][3])
+	assert.Equal(t, []interface{}{"bearer [REDACTED]", 7}, next.lines[0][4])
+	assert.Equal(t, "bearer tok_3fa9", params[0])
+
+	_, err = newRedactionRules(&PgConfig{RedactPatterns: []string{"("}})
+	assert.ErrorContains(t, err, `invalid redact pattern "("`)
+}
+
+// Test the redacting logger to verify statements are logged with masked parameters
+func TestRedactingLogger(t *testing.T) {
+	next := &recordingGORMLogger{}
+	logger := newRedactingLogger(next, redactionRules{columns: []string{"ssn"}})
+
+	logger.Print("sql", "file.go:1", 0, `SELECT * FROM "users" WHERE "ssn" = $1`, []interface{}{"123"}, int64(1))
+	logger.Print("log", "file.go:2", "record not found")
+
+	assert.Equal(t, []interface{}{"[REDACTED]"}, next.lines[0][4])
+	assert.Equal(t, "record not found", next.lines[1][2])
+	assert.Same(t, next, newRedactingLogger(next, redactionRules{}))
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+// Errors of the reference-counted nesting mode of WithRefCountedNesting.
+var (
+	ErrUnbalancedNesting = errors.New("commit does not match the innermost open Begin")            // ErrUnbalancedNesting occurs when Commit is not called with the UUID of the innermost open Begin.
+	ErrRollbackOnly      = errors.New("transaction was marked rollback-only by a nested rollback") // ErrRollbackOnly occurs when the outermost Commit finds the transaction marked rollback-only.
+)
+
+// WithRefCountedNesting switches the context to reference-counted nesting, like the REQUIRED propagation
+// of Spring: every Begin opens a level, and Commit must close the innermost open level, otherwise it fails
+// with ErrUnbalancedNesting. Inner Commits do nothing but close their level; the Commit of the outermost
+// level commits. An inner Rollback closes its level and marks the transaction rollback-only instead of
+// rolling it back; statements keep running in it, and the outermost Commit rolls it back and returns
+// ErrRollbackOnly. Rollback on the outermost level, and Reset, roll back at once.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithRefCountedNesting())
+//	err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+//	  _ = RunInTransaction(ctx, reserveStock) // a failure here makes the outer commit fail
+//	  return db.Create(&order).Error
+//	}) // ErrRollbackOnly if reserveStock failed
+func WithRefCountedNesting() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.refCounted = true
+	}
+}
+
+// refCountedNesting reports whether txContext nests by reference counting, so joined units of work close
+// their level with Commit.
+func refCountedNesting(txContext ITransactionContext) bool {
+	c, ok := txContext.(*transactionContext)
+	return ok && c.refCounted
+}
+
+// openLevel records the level opened by a Begin in reference-counted mode.
+func (c *transactionContext) openLevel(id uuid.UUID) {
+	if c.refCounted {
+		c.levels = append(c.levels, id)
+	}
+}
+
+// closeLevel closes the level of id in reference-counted mode. It returns true if Commit is done: the
+// level was an inner one, the call was unbalanced, or the transaction was rolled back as rollback-only.
+func (c *transactionContext) closeLevel(id uuid.UUID) (bool, error) {
+	if !c.refCounted {
+		return false, nil
+	}
+	innermost := len(c.levels) - 1
+	if innermost < 0 || c.levels[innermost] != id {
+		return true, fmt.Errorf("%w: %v with %d levels open", ErrUnbalancedNesting, id, len(c.levels))
+	}
+	c.levels = c.levels[:innermost]
+	switch {
+	case innermost > 0:
+		return true, nil
+	case c.rollbackOnly:
+		if err := c.Rollback(); err != nil {
+			return true, err
+		}
+		return true, ErrRollbackOnly
+	}
+	return false, nil
+}
+
+// rollbackLevel closes an inner level in reference-counted mode and marks the transaction rollback-only.
+// It returns false if the transaction is to be rolled back at once.
+func (c *transactionContext) rollbackLevel() bool {
+	if !c.refCounted || len(c.levels) < 2 {
+		return false
+	}
+	c.levels = c.levels[:len(c.levels)-1]
+	c.rollbackOnly = true
+	c.logger.Debugf("nested rollback, transaction marked rollback-only: %v", c.transactionUUID)
+	return true
+}
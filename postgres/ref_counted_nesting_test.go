@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test WithRefCountedNesting to verify only the outermost Commit commits and unbalanced Commits fail
+func TestRefCountedNesting_Commit(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithRefCountedNesting()(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	outer, err := tx.Begin()
+	assert.NoError(t, err)
+	inner, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, tx.Commit(outer), ErrUnbalancedNesting)
+	assert.NoError(t, tx.Commit(inner))
+	assert.True(t, tx.inTransaction())
+	assert.ErrorIs(t, tx.Commit(inner), ErrUnbalancedNesting)
+	assert.NoError(t, tx.Commit(outer))
+	assert.False(t, tx.inTransaction())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test WithRefCountedNesting to verify a failed nested unit of work makes the outermost commit roll back
+func TestRefCountedNesting_RollbackOnly(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithRefCountedNesting()(tx)
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	failure := errors.New("out of stock")
+	err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error { return failure })
+		assert.ErrorIs(t, err, failure)
+		assert.True(t, tx.inTransaction())
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrRollbackOnly)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.NoError(t, tx.Reset())
+	assert.False(t, tx.rollbackOnly)
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	log "github.com/public-forge/go-logger"
+	"time"
+)
+
+const (
+	// slowQueryStartScopeKey holds the time an operation started, for the slow query log of PgConfig.SlowQueryMS.
+	slowQueryStartScopeKey = "uow:slow_query_start"
+	// logModeScopeKey holds the log mode applied by withLogMode to the *gorm.DB returned by Provider.
+	logModeScopeKey = "uow:log_mode"
+)
+
+// holderSettings are the settings of a holder taken from its configuration that Reload may change.
+type holderSettings struct {
+	logMode   bool          // Mirrors PgConfig.LogMode so observed transactions keep logging SQL when enabled.
+	txTimeout time.Duration // Mirrors PgConfig.TxTimeoutMS; zero means transactions may stay open indefinitely.
+	slowQuery time.Duration // Mirrors PgConfig.SlowQueryMS; zero disables the slow query log.
+	pool      *PgConfig     // Configuration of the last Reload, applied to a lazy holder once it connects.
+}
+
+// newHolderSettings returns the settings of config.
+func newHolderSettings(config *PgConfig) holderSettings {
+	return holderSettings{
+		logMode:   config.LogMode,
+		txTimeout: time.Duration(config.TxTimeoutMS) * time.Millisecond,
+		slowQuery: time.Duration(config.SlowQueryMS) * time.Millisecond,
+	}
+}
+
+// Reload applies the runtime-tunable settings of config to the holder without reconnecting: the pool
+// sizes and connection lifetimes (MaxOpenConnections, MaxIdleConnections, ConnectionMaxLifetimeMS,
+// ConnectionMaxIdleTimeMS), LogMode, TxTimeoutMS and SlowQueryMS. Running transactions keep their log
+// mode and timeout, and LogMode applies to the *gorm.DB returned by Provider afterwards; the other settings
+// of config are ignored, as they require a new connection.
+// Example:
+//
+//	signal.Notify(reload, syscall.SIGHUP)
+//	for range reload {
+//	  if cfg, err := loadConfig(); err == nil { holder.Reload(cfg) }
+//	}
+func (h *DatabaseHolder) Reload(config *PgConfig) {
+	settings := newHolderSettings(config)
+	settings.pool = config
+	h.settingsMu.Lock()
+	h.settings = settings
+	h.settingsMu.Unlock()
+
+	if db := h.currentConnection(); db != nil {
+		h.applyReloadedPool(db)
+	}
+	log.FromDefaultContext().Infof("reloaded postgres %s@%s: max open connections %d, log mode %t, slow queries %s",
+		config.DBName, config.Host, config.MaxOpenConnections, config.LogMode, settings.slowQuery)
+}
+
+// Reload reloads the settings of the factory's holder, see DatabaseHolder.Reload.
+func (f *TransactionContextFactory) Reload(config *PgConfig) {
+	f.DBHolder().Reload(config)
+}
+
+// currentSettings returns the settings of the holder.
+func (h *DatabaseHolder) currentSettings() holderSettings {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.settings
+}
+
+// applyReloadedPool applies the pool settings of the last Reload to db.
+func (h *DatabaseHolder) applyReloadedPool(db *gorm.DB) {
+	config := h.currentSettings().pool
+	if config == nil {
+		return
+	}
+	setSQLSettings(db.DB(), config)
+}
+
+// withLogMode returns a copy of db logging SQL according to the log mode of the last Reload, or db itself
+// if the holder was never reloaded. The shared connection of the holder is never changed, as GORM reads
+// its log mode without synchronization.
+func (h *DatabaseHolder) withLogMode(db *gorm.DB) *gorm.DB {
+	settings := h.currentSettings()
+	if db == nil || settings.pool == nil {
+		return db
+	}
+	return db.Set(logModeScopeKey, settings.logMode).LogMode(settings.logMode)
+}
+
+// registerSlowQueryLog registers the callbacks logging GORM operations slower than PgConfig.SlowQueryMS.
+func (h *DatabaseHolder) registerSlowQueryLog(db *gorm.DB) {
+	for _, operation := range queryOperations(db) {
+		name := operation.name
+		operation.processor().Before(operation.callback).Register("uow:before_"+name+"_slow_query", func(scope *gorm.Scope) {
+			scope.Set(slowQueryStartScopeKey, time.Now())
+		})
+		operation.processor().After(operation.callback).Register("uow:after_"+name+"_slow_query", func(scope *gorm.Scope) {
+			threshold := h.currentSettings().slowQuery
+			start, ok := scope.Get(slowQueryStartScopeKey)
+			if threshold <= 0 || !ok {
+				return
+			}
+			if elapsed := time.Since(start.(time.Time)); elapsed >= threshold {
+				logger := log.FromDefaultContext()
+				if ctx, ok := uow.ContextFromScope(scope); ok {
+					logger = log.FromContext(ctx)
+				}
+				logger.Warnf("slow %s on %s took %s: %s", name, scope.TableName(), elapsed, scope.SQL)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reloadedAccount is the model queried while the holder is reloaded.
+type reloadedAccount struct {
+	ID uint
+}
+
+// Test Reload to verify the pool size and transaction timeout change, for a lazy holder once it connects
+func TestDatabaseHolder_Reload(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	holder := NewDBHolder(db)
+	holder.Reload(&PgConfig{MaxOpenConnections: 3, TxTimeoutMS: 1500, SlowQueryMS: 200})
+	assert.Equal(t, 3, holder.Stats().MaxOpenConnections)
+	assert.Equal(t, 1500*time.Millisecond, newTransactionContext(nil, holder).effectiveTxTimeout())
+	assert.Equal(t, 200*time.Millisecond, holder.currentSettings().slowQuery)
+
+	lazy := NewLazyDBHolder(func() (*gorm.DB, error) { return db, nil })
+	lazy.Reload(&PgConfig{MaxOpenConnections: 7, LogMode: true})
+	assert.True(t, lazy.currentSettings().logMode)
+	_, err = lazy.connection()
+	assert.NoError(t, err)
+	assert.Equal(t, 7, lazy.Stats().MaxOpenConnections)
+}
+
+// Test Reload while queries run to verify the log mode is applied to the copies returned by Provider, not
+// to the shared connection; run with -race
+func TestDatabaseHolder_ReloadConcurrentQueries(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	holder := NewDBHolder(db)
+	logger := log.FromDefaultContext()
+
+	const workers, queries = 4, 25
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < workers*queries; i++ {
+		mock.ExpectQuery(`SELECT`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	}
+
+	done := make(chan struct{})
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+				holder.Reload(&PgConfig{MaxOpenConnections: 4, LogMode: i%2 == 0})
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx := newTransactionContext(logger, holder)
+			for j := 0; j < queries; j++ {
+				var accounts []reloadedAccount
+				assert.NoError(t, tx.Provider().Find(&accounts).Error)
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	<-reloaded
+
+	holder.Reload(&PgConfig{MaxOpenConnections: 4, LogMode: true})
+	value, ok := newTransactionContext(log.FromDefaultContext(), holder).Provider().Get(logModeScopeKey)
+	assert.True(t, ok)
+	assert.Equal(t, true, value)
+	_, ok = db.Get(logModeScopeKey)
+	assert.False(t, ok)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+// Repository provides the basic persistence operations for models of type T. Every operation uses the
+// Provider of the transaction context in the passed context, so it joins the running unit of work.
+// Example:
+//
+//	type OrderRepository struct{ *postgres.Repository[Order] }
+//
+//	orders := OrderRepository{postgres.NewRepository[Order](factory)}
+//	order, err := orders.Get(ctx, id)
+type Repository[T any] struct {
+	factory *TransactionContextFactory // Creates transaction contexts; nil uses GetTransactionContext.
+}
+
+// NewRepository creates a Repository whose transaction contexts come from factory.
+// A nil factory uses GetTransactionContext.
+func NewRepository[T any](factory *TransactionContextFactory) *Repository[T] {
+	return &Repository[T]{factory: factory}
+}
+
+// DB returns the *gorm.DB of the unit of work in ctx, bound to ctx, for queries beyond the basic operations.
+// If the transaction context has been rolled back, its Error is ErrTxWasRollbacked.
+func (r *Repository[T]) DB(ctx context.Context) *gorm.DB {
+	var txContext ITransactionContext
+	if r.factory != nil {
+		txContext, ctx = r.factory.GetTransactionContext(ctx)
+	} else {
+		txContext, ctx = GetTransactionContext(ctx)
+	}
+	db := txContext.ProviderWithContext(ctx)
+	if c, ok := txContext.(*transactionContext); ok && db == nil {
+		// The transaction context has been rolled back: fail the operation instead of panicking on nil.
+		db = c.providerWithoutTransaction().New()
+		_ = db.AddError(ErrTxWasRollbacked)
+	}
+	return db
+}
+
+// Create inserts entity.
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	db := r.DB(ctx)
+	if db.Error != nil {
+		return db.Error
+	}
+	return db.Create(entity).Error
+}
+
+// Get loads the entity with the given primary key; it fails with gorm.ErrRecordNotFound if there is none.
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	db := r.DB(ctx)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var entity T
+	if err := db.First(&entity, id).Error; err != nil {
+		return nil, err
+	}
+	return &entity, nil
+}
+
+// Update saves all fields of entity.
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	db := r.DB(ctx)
+	if db.Error != nil {
+		return db.Error
+	}
+	return db.Save(entity).Error
+}
+
+// Delete deletes entity by its primary key.
+func (r *Repository[T]) Delete(ctx context.Context, entity *T) error {
+	db := r.DB(ctx)
+	if db.Error != nil {
+		return db.Error
+	}
+	return db.Delete(entity).Error
+}
+
+// List loads the entities matching scopes, e.g. conditions, ordering and limits.
+// Example:
+//
+//	open, err := orders.List(ctx, func(db *gorm.DB) *gorm.DB { return db.Where("status = ?", "open") })
+func (r *Repository[T]) List(ctx context.Context, scopes ...func(*gorm.DB) *gorm.DB) ([]T, error) {
+	db := r.DB(ctx)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	var entities []T
+	if err := db.Scopes(scopes...).Find(&entities).Error; err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type repositoryOrder struct {
+	ID     int
+	Status string
+}
+
+// Test that the repository operates on the transaction of the context
+func TestRepository(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	orders := NewRepository[repositoryOrder](nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "repository_orders" WHERE ("repository_orders"."id" = 7) ORDER BY "repository_orders"."id" ASC LIMIT 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(7, "open"))
+	mock.ExpectQuery(`SELECT * FROM "repository_orders"  WHERE (status = $1)`).
+		WithArgs("open").WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(7, "open").AddRow(8, "open"))
+	mock.ExpectRollback()
+
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+
+	order, err := orders.Get(ctx, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, "open", order.Status)
+
+	open, err := orders.List(ctx, func(db *gorm.DB) *gorm.DB { return db.Where("status = ?", "open") })
+	assert.NoError(t, err)
+	assert.Len(t, open, 2)
+
+	assert.NoError(t, tx.Rollback())
+	assert.ErrorIs(t, orders.Delete(ctx, order), ErrTxWasRollbacked)
+}
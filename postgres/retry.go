@@ -0,0 +1,160 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	// SQLStateSerializationFailure is reported when a SERIALIZABLE transaction cannot be serialized.
+	SQLStateSerializationFailure = "40001"
+	// SQLStateDeadlockDetected is reported when Postgres aborts a transaction to resolve a deadlock.
+	SQLStateDeadlockDetected = "40P01"
+
+	// defaultRetryMaxAttempts defines how often a unit of work is executed before giving up.
+	defaultRetryMaxAttempts = 5
+	// defaultRetryBackoff defines the delay before the first retry; it doubles on each attempt.
+	defaultRetryBackoff = 20 * time.Millisecond
+)
+
+// RetryPolicy describes when and how often a failed unit of work is executed again.
+type RetryPolicy struct {
+	MaxAttempts int              // MaxAttempts is the total number of executions, including the first one.
+	Backoff     time.Duration    // Backoff is the delay before the first retry; it doubles on each attempt.
+	SQLStates   []string         // SQLStates lists the error codes that make the work retryable.
+	Retryable   func(error) bool // Retryable replaces the SQLStates check when set.
+	Sleeper     Sleeper          // Sleeper waits for the backoff between the attempts; SystemClock if nil.
+}
+
+// DefaultRetryPolicy is used by RunWithRetry unless the transaction context was created WithRetryPolicy.
+// It retries serialization failures and deadlocks.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: defaultRetryMaxAttempts,
+	Backoff:     defaultRetryBackoff,
+	SQLStates:   []string{SQLStateSerializationFailure, SQLStateDeadlockDetected},
+}
+
+// DefaultSerializableRetryPolicy is used by RunSerializable.
+var DefaultSerializableRetryPolicy = RetryPolicy{
+	MaxAttempts: defaultRetryMaxAttempts,
+	Backoff:     defaultRetryBackoff,
+	SQLStates:   []string{SQLStateSerializationFailure},
+}
+
+// WithRetryPolicy sets the policy RunWithRetry applies to units of work started from the context.
+// Example:
+//
+//	policy := DefaultRetryPolicy
+//	policy.MaxAttempts = 10
+//	txContext, ctx := GetTransactionContext(ctx, WithRetryPolicy(policy))
+func WithRetryPolicy(policy RetryPolicy) TransactionContextOption {
+	return func(c *transactionContext) {
+		c.retryPolicy = &policy
+	}
+}
+
+// RunWithRetry executes fn in a transaction and retries it on deadlocks and serialization failures according
+// to the policy of the transaction context in ctx (see WithRetryPolicy), or DefaultRetryPolicy.
+// If ctx already carries a running transaction, fn joins it and is not retried, since only the
+// outermost unit of work can be executed again.
+// Example:
+//
+//	err := RunWithRetry(ctx, func(ctx context.Context, db *gorm.DB) error {
+//	  return db.Model(&account).Update("balance", gorm.Expr("balance - ?", amount)).Error
+//	})
+func RunWithRetry(ctx context.Context, fn TxFunc) error {
+	policy := DefaultRetryPolicy
+	if c, ok := ctx.Value(TransactionContextKey).(*transactionContext); ok && c.retryPolicy != nil {
+		policy = *c.retryPolicy
+	}
+	return runWithRetry(ctx, policy, TxOptions{}, fn)
+}
+
+// RunSerializable executes fn in a SERIALIZABLE transaction and retries it on serialization
+// failures (SQLSTATE 40001) according to DefaultSerializableRetryPolicy.
+// Example:
+//
+//	err := RunSerializable(ctx, func(ctx context.Context, db *gorm.DB) error {
+//	  return transfer(ctx, db, from, to, amount)
+//	})
+func RunSerializable(ctx context.Context, fn TxFunc) error {
+	return RunSerializableWithPolicy(ctx, DefaultSerializableRetryPolicy, fn)
+}
+
+// RunSerializableWithPolicy is RunSerializable with a custom retry policy.
+// If ctx already carries a running transaction, fn joins it and is not retried, since only the
+// outermost unit of work can be executed again.
+func RunSerializableWithPolicy(ctx context.Context, policy RetryPolicy, fn TxFunc) error {
+	return runWithRetry(ctx, policy, TxOptions{Isolation: sql.LevelSerializable}, fn)
+}
+
+// runWithRetry executes fn in a fresh transaction per attempt while the error is retryable under policy.
+func runWithRetry(ctx context.Context, policy RetryPolicy, opts TxOptions, fn TxFunc) error {
+	attempts := policy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	backoff, sleeper := policy.Backoff, policy.Sleeper
+	if sleeper == nil {
+		sleeper = SystemClock{}
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		attemptCtx, retryable := freshTransactionContext(ctx)
+		if err = runInTransaction(attemptCtx, opts, fn); err == nil {
+			mergeFork(ctx, attemptCtx)
+			return nil
+		}
+		if !retryable || !policy.retryable(err) {
+			return err
+		}
+		if attempt == attempts {
+			break
+		}
+
+		txContext, _ := GetTransactionContext(attemptCtx)
+		if c, ok := txContext.(*transactionContext); ok {
+			c.logger.Warnf("retrying unit of work (attempt %d of %d): %s", attempt+1, attempts, err)
+		}
+		if err := sleeper.Sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+	return err
+}
+
+// retryable reports whether err carries one of the policy's SQLSTATEs, or satisfies its Retryable func.
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	code := SQLState(err)
+	if code == "" {
+		return false
+	}
+	for _, state := range p.SQLStates {
+		if state == code {
+			return true
+		}
+	}
+	return false
+}
+
+// SQLState returns the SQLSTATE code of a Postgres error of lib/pq or pgx, or "" if err is not a Postgres error.
+func SQLState(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// Test that serialization failures are retried in a fresh transaction
+func TestRunSerializable_RetriesSerializationFailures(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, base)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	attempts := 0
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond, SQLStates: []string{SQLStateSerializationFailure}}
+	err := RunSerializableWithPolicy(ctx, policy, func(ctx context.Context, db *gorm.DB) error {
+		attempts++
+		if attempts == 1 {
+			return &pq.Error{Code: SQLStateSerializationFailure}
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.False(t, base.wasRollbacked())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that RunWithRetry applies the context's policy to deadlocks and gives up after MaxAttempts
+func TestRunWithRetry_DeadlockPolicyFromContext(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithRetryPolicy(RetryPolicy{MaxAttempts: 2, SQLStates: []string{SQLStateDeadlockDetected}})(base)
+	ctx := context.WithValue(context.Background(), TransactionContextKey, base)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	attempts := 0
+	err := RunWithRetry(ctx, func(ctx context.Context, db *gorm.DB) error {
+		attempts++
+		return &pq.Error{Code: SQLStateDeadlockDetected}
+	})
+
+	assert.Equal(t, SQLStateDeadlockDetected, SQLState(err))
+	assert.Equal(t, 2, attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that the consistency token of a unit of work committed by RunWithRetry is returned for its context
+func TestRunWithRetry_GetConsistencyToken(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithConsistencyTokens())
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT pg_current_wal_lsn()::text").
+		WillReturnRows(sqlmock.NewRows([]string{"pg_current_wal_lsn"}).AddRow("0/16B3748"))
+
+	assert.NoError(t, RunWithRetry(ctx, func(ctx context.Context, db *gorm.DB) error { return nil }))
+
+	token, ok := GetConsistencyToken(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, ConsistencyToken("0/16B3748"), token)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that reads of the context stay on the primary after RunWithRetry committed a write
+func TestRunWithRetry_ProviderReplica(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	replicaDB, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	replicaGorm, err := gorm.Open("postgres", replicaDB)
+	assert.NoError(t, err)
+	defer replicaGorm.Close()
+	WithReplica(NewDBHolder(replicaGorm))(tx)
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	assert.Same(t, replicaGorm, ProviderReplica(ctx))
+	assert.NoError(t, RunWithRetry(ctx, func(ctx context.Context, db *gorm.DB) error { return nil }))
+	assert.Same(t, db, ProviderReplica(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,23 @@
+package postgres
+
+// WithRole switches every transaction of the context to the role returned by role, as SET LOCAL ROLE does,
+// so row-level security policies apply to the unit of work. The role is reset by Postgres when the
+// transaction commits or rolls back; transactions whose context carries no role keep the login role.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithRole(func(ctx context.Context) (string, bool) {
+//	  return "app_user", true
+//	}))
+func WithRole(role ContextValueFunc) TransactionContextOption {
+	return withLocalSetting("role", role)
+}
+
+// WithLocalSetting sets the configuration parameter name to the value returned by value in every transaction
+// of the context, as SET LOCAL does, typically a custom parameter read by row-level security policies.
+// Example:
+//
+//	// CREATE POLICY tenant_isolation ON orders USING (tenant_id = current_setting('rls.tenant_id'));
+//	txContext, ctx := GetTransactionContext(ctx, WithRole(appUser), WithLocalSetting("rls.tenant_id", TenantFromContext))
+func WithLocalSetting(name string, value ContextValueFunc) TransactionContextOption {
+	return withLocalSetting(name, value)
+}
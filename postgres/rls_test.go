@@ -0,0 +1,27 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that the role and the policy settings are applied in order at Begin
+func TestWithRoleAndLocalSetting(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	appUser := func(context.Context) (string, bool) { return "app_user", true }
+	tx := newTransactionContext(base.logger, base.dbHolder, WithRole(appUser), WithLocalSetting("rls.tenant_id", TenantFromContext))
+	tx.ctx = ContextWithTenant(context.Background(), "42")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("role", "app_user").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("rls.tenant_id", "42").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+// LockOption changes how a row lock waits for rows locked by other transactions.
+type LockOption string
+
+const (
+	// NoWait fails with ErrLockNotAvailable instead of waiting for locked rows.
+	NoWait LockOption = "NOWAIT"
+	// SkipLocked leaves out rows locked by other transactions.
+	SkipLocked LockOption = "SKIP LOCKED"
+
+	// lockScopeKey marks queries issued through LockForUpdate or LockForShare.
+	lockScopeKey = "uow:row_lock"
+)
+
+// LockForUpdate returns the transactional Provider() of the transaction context in ctx with queries
+// locking the selected rows FOR UPDATE. Failing to acquire the lock with NoWait is reported as
+// ErrLockNotAvailable. Outside a transaction the returned *gorm.DB carries ErrNotInTransaction.
+// Example:
+//
+//	var account Account
+//	err := LockForUpdate(ctx, NoWait).First(&account, id).Error
+//	if errors.Is(err, ErrLockNotAvailable) { return ErrAccountBusy }
+func LockForUpdate(ctx context.Context, opts ...LockOption) *gorm.DB {
+	return lockRows(ctx, "FOR UPDATE", opts)
+}
+
+// LockForShare is LockForUpdate with a FOR SHARE lock, blocking writers but not other readers.
+// Example:
+//
+//	err := LockForShare(ctx).Where("id IN (?)", ids).Find(&products).Error
+func LockForShare(ctx context.Context, opts ...LockOption) *gorm.DB {
+	return lockRows(ctx, "FOR SHARE", opts)
+}
+
+// lockRows applies the lock clause to the transactional Provider().
+func lockRows(ctx context.Context, clause string, opts []LockOption) *gorm.DB {
+	txContext, _ := GetTransactionContext(ctx)
+	if c, ok := txContext.(*transactionContext); ok && !c.wasRollbacked() && !c.inTransaction() {
+		db := c.providerWithoutTransaction().New()
+		_ = db.AddError(ErrNotInTransaction)
+		return db
+	}
+	db := txContext.Provider()
+	if db == nil {
+		return nil
+	}
+
+	parts := []string{clause}
+	for _, opt := range opts {
+		parts = append(parts, string(opt))
+	}
+	return db.Set("gorm:query_option", strings.Join(parts, " ")).Set(lockScopeKey, true)
+}
+
+// registerLockErrorTranslation registers callbacks translating the errors of row-locking queries,
+// so ErrLockNotAvailable is reported without PgConfig.TranslateErrors.
+func registerLockErrorTranslation(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Query().After("gorm:query").Register("uow:translate_lock_error", translateLockError)
+}
+
+// translateLockError translates the error of a query issued through LockForUpdate or LockForShare.
+func translateLockError(scope *gorm.Scope) {
+	if _, ok := scope.Get(lockScopeKey); ok {
+		translateScopeError(scope)
+	}
+}
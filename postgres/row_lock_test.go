@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that LockForUpdate appends the lock clause and reports unavailable locks as ErrLockNotAvailable
+func TestLockForUpdate(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	type account struct{ ID int }
+
+	assert.ErrorIs(t, LockForUpdate(ctx).First(&account{}).Error, ErrNotInTransaction)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "accounts"  ORDER BY "accounts"."id" ASC LIMIT 1 FOR UPDATE NOWAIT`).
+		WillReturnError(&pq.Error{Code: SQLStateLockNotAvailable})
+	mock.ExpectRollback()
+
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	err = LockForUpdate(ctx, NoWait).First(&account{}).Error
+	assert.True(t, errors.Is(err, ErrLockNotAvailable))
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+// TxFunc is a unit of work executed inside a transaction.
+// ctx carries the transaction context and db is its transactional Provider().
+type TxFunc func(ctx context.Context, db *gorm.DB) error
+
+// RunInTransaction executes fn in a transaction of the context: it begins the transaction, rolls it back
+// if fn returns an error or panics, and commits it otherwise.
+// If ctx already carries a running transaction, fn joins it and the outer owner decides about the commit.
+// Example:
+//
+//	err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+//	  if err := db.Create(&order).Error; err != nil {
+//	    return err
+//	  }
+//	  return inventory.Reserve(ctx, order.Items)
+//	})
+func RunInTransaction(ctx context.Context, fn TxFunc) error {
+	return runInTransaction(ctx, TxOptions{}, fn)
+}
+
+// RunInTransactionWithOptions is RunInTransaction for a transaction started with opts.
+func RunInTransactionWithOptions(ctx context.Context, opts TxOptions, fn TxFunc) error {
+	return runInTransaction(ctx, opts, fn)
+}
+
+// runInTransaction executes fn in a transaction started with opts, propagating panics.
+func runInTransaction(ctx context.Context, opts TxOptions, fn TxFunc) error {
+	return execute(ctx, executeConfig{txOptions: opts}, fn)
+}
+
+// freshTransactionContext returns ctx with a transaction context that has no transaction yet, so a unit
+// of work can be retried from scratch. An idle transaction context in ctx is forked, keeping its options;
+// a running transaction (or a foreign ITransactionContext such as a mock) is kept so the work joins it.
+// The second result reports whether the work may be retried.
+func freshTransactionContext(ctx context.Context) (context.Context, bool) {
+	existing, found := ctx.Value(TransactionContextKey).(ITransactionContext)
+	if !found {
+		_, ctx = GetTransactionContext(ctx)
+		return ctx, true
+	}
+	c, ok := existing.(*transactionContext)
+	if !ok || c.inTransaction() {
+		return ctx, false
+	}
+	return context.WithValue(ctx, TransactionContextKey, c.fork()), true
+}
+
+// fork creates an idle transaction context sharing the configuration of c.
+func (c *transactionContext) fork() *transactionContext {
+	return &transactionContext{
+		ctx:              c.ctx,
+		logger:           c.logger,
+		dbHolder:         c.dbHolder,
+		factoryKey:       c.factoryKey,
+		autoBegin:        c.autoBegin,
+		strictCommit:     c.strictCommit,
+		txNameComments:   c.txNameComments,
+		refCounted:       c.refCounted,
+		rollbackOnCancel: c.rollbackOnCancel,
+		pprofLabels:      c.pprofLabels,
+		dryRun:           c.dryRun,
+		txTimeout:        c.txTimeout,
+		commitFloor:      c.commitFloor,
+		trackConsistency: c.trackConsistency,
+		retryPolicy:      c.retryPolicy,
+		replica:          c.replica,
+		primaryReads:     c.primaryReads,
+		tracer:           c.tracer,
+		datadogService:   c.datadogService,
+		leakDetection:    c.leakDetection,
+		localSettings:    c.localSettings,
+		hooks:            c.hooks,
+		logFields:        c.logFields,
+		explainThreshold: c.explainThreshold,
+	}
+}
+
+// mergeFork copies the state the fork of freshTransactionContext in attemptCtx has gathered by committing
+// back to the transaction context in ctx, so GetConsistencyToken and ProviderReplica see the commit of the
+// unit of work.
+func mergeFork(ctx, attemptCtx context.Context) {
+	parent, ok := ctx.Value(TransactionContextKey).(*transactionContext)
+	if !ok {
+		return
+	}
+	fork, ok := attemptCtx.Value(TransactionContextKey).(*transactionContext)
+	if !ok || fork == parent {
+		return
+	}
+	if fork.consistencyToken != "" {
+		parent.consistencyToken = fork.consistencyToken
+	}
+	parent.primaryReads = parent.primaryReads || fork.primaryReads
+}
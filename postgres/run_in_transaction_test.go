@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that RunInTransaction commits on success
+func TestRunInTransaction_Commit(t *testing.T) {
+	txContext, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, txContext)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		assert.True(t, txContext.inTransaction())
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that RunInTransaction rolls back on error and on panic
+func TestRunInTransaction_Rollback(t *testing.T) {
+	txContext, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, txContext)
+	failure := errors.New("failure")
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		return failure
+	})
+	assert.Equal(t, failure, err)
+
+	txContext, db, mock = getTestTransactionContext(t)
+	defer db.Close()
+	ctx = context.WithValue(context.Background(), TransactionContextKey, txContext)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	assert.Panics(t, func() {
+		_ = RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+			panic("boom")
+		})
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Execute WithPanicAsError rolls back and returns the panic as an error
+func TestExecute_PanicAsError(t *testing.T) {
+	txContext, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, txContext)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := Execute(ctx, func(ctx context.Context, db *gorm.DB) error {
+		panic("boom")
+	}, WithPanicAsError())
+
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test fork to verify a retried unit of work keeps the hooks, nesting mode and log fields of its context
+func TestTransactionContext_Fork(t *testing.T) {
+	tx, db, _ := getTestTransactionContext(t)
+	defer db.Close()
+	WithHooks(NopHook{})(tx)
+	WithRefCountedNesting()(tx)
+	WithTxNameComments()(tx)
+	WithTraceLogFields()(tx)
+
+	forked := tx.fork()
+	assert.Equal(t, tx.hooks, forked.hooks)
+	assert.True(t, forked.refCounted)
+	assert.True(t, forked.txNameComments)
+	assert.Len(t, forked.logFields, len(tx.logFields))
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/public-forge/go-logger"
+)
+
+// ErrCompensationFailed occurs when a compensation of a failed Saga fails too, leaving the effects of its
+// step in place; the error of Run wraps it together with the errors of the step and the compensation.
+var ErrCompensationFailed = errors.New("saga compensation failed")
+
+type (
+	// Saga is a workflow spanning several aggregates, made of steps that each commit in a transaction of
+	// their own. A step registers a compensating action undoing it; if a step fails, the compensations of
+	// the completed steps run in reverse order, each in a new transaction.
+	Saga struct {
+		name    string                     // Name of the saga, used in errors and logs.
+		factory *TransactionContextFactory // Factory creating the transaction contexts of the steps.
+		steps   []sagaStep                 // Steps in execution order.
+	}
+
+	// sagaStep is a step of a Saga.
+	sagaStep struct {
+		name       string // Name of the step, used in errors and logs.
+		action     TxFunc // Action of the step.
+		compensate TxFunc // Compensation undoing action; nil if there is nothing to undo.
+	}
+)
+
+// NewSaga creates an empty saga whose steps run in transaction contexts of factory; a nil factory uses
+// the one behind GetTransactionContext.
+// Example:
+//
+//	saga := postgres.NewSaga("place-order", factory).
+//	  Step("reserve-stock", reserveStock, releaseStock).
+//	  Step("charge-customer", charge, refund).
+//	  Step("confirm-order", confirm, nil)
+//	err := saga.Run(ctx)
+func NewSaga(name string, factory *TransactionContextFactory) *Saga {
+	if factory == nil {
+		factory = defaultFactory
+	}
+	return &Saga{name: name, factory: factory}
+}
+
+// Step appends a step running action, with compensate undoing it if a later step fails. compensate may be
+// nil for a step with nothing to undo, typically the last one. It returns s for chaining.
+func (s *Saga) Step(name string, action, compensate TxFunc) *Saga {
+	s.steps = append(s.steps, sagaStep{name: name, action: action, compensate: compensate})
+	return s
+}
+
+// Run executes the steps in order, each in a new transaction that never joins one running in ctx. If a
+// step fails or panics, its transaction is rolled back and the compensations of the completed steps run
+// in reverse order, each in a new transaction, even if ctx has been canceled. The error of the step is
+// returned, joined with an ErrCompensationFailed error for every failing compensation; a panic is
+// propagated once the compensations have run, whose failures are logged.
+func (s *Saga) Run(ctx context.Context) error {
+	completed := 0
+	defer func() {
+		if r := recover(); r != nil {
+			for _, err := range s.compensate(ctx, completed) {
+				log.FromContext(ctx).Errorf("compensating panicked step: %s", err)
+			}
+			panic(r)
+		}
+	}()
+	for _, step := range s.steps {
+		if err := s.runStep(ctx, step.action); err != nil {
+			err = fmt.Errorf("saga %s: step %s: %w", s.name, step.name, err)
+			return errors.Join(append([]error{err}, s.compensate(ctx, completed)...)...)
+		}
+		completed++
+	}
+	return nil
+}
+
+// compensate runs the compensations of the first completed steps in reverse order and returns their errors.
+func (s *Saga) compensate(ctx context.Context, completed int) []error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for i := completed - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if step.compensate == nil {
+			continue
+		}
+		if err := s.runStep(ctx, step.compensate); err != nil {
+			errs = append(errs, fmt.Errorf("%w: saga %s: step %s: %w", ErrCompensationFailed, s.name, step.name, err))
+		}
+	}
+	return errs
+}
+
+// runStep runs fn in a new transaction context of the factory.
+func (s *Saga) runStep(ctx context.Context, fn TxFunc) error {
+	return RunInTransaction(s.factory.withNewTransactionContext(ctx), fn)
+}
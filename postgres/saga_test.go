@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// sagaRecorder returns a TxFunc appending name to calls and failing with err.
+func sagaRecorder(calls *[]string, name string, err error) TxFunc {
+	return func(ctx context.Context, db *gorm.DB) error {
+		*calls = append(*calls, name)
+		return err
+	}
+}
+
+// Test Run to verify each step commits in its own transaction
+func TestSaga_Run(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	var calls []string
+	saga := NewSaga("order", NewTransactionContextFactory(tx.dbHolder)).
+		Step("reserve", sagaRecorder(&calls, "reserve", nil), sagaRecorder(&calls, "release", nil)).
+		Step("charge", sagaRecorder(&calls, "charge", nil), nil)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	assert.NoError(t, saga.Run(context.Background()))
+	assert.Equal(t, []string{"reserve", "charge"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Run to verify a failing step is rolled back and the completed steps are compensated in reverse
+// order in new transactions, reporting failing compensations with ErrCompensationFailed
+func TestSaga_Compensate(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	declined, lost := errors.New("card declined"), errors.New("stock service down")
+	var calls []string
+	saga := NewSaga("order", NewTransactionContextFactory(tx.dbHolder)).
+		Step("reserve", sagaRecorder(&calls, "reserve", nil), sagaRecorder(&calls, "release", lost)).
+		Step("notify", sagaRecorder(&calls, "notify", nil), nil).
+		Step("hold", sagaRecorder(&calls, "hold", nil), sagaRecorder(&calls, "unhold", nil)).
+		Step("charge", sagaRecorder(&calls, "charge", declined), sagaRecorder(&calls, "refund", nil))
+
+	for i := 0; i < 3; i++ {
+		mock.ExpectBegin()
+		mock.ExpectCommit()
+	}
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := saga.Run(ctx)
+	assert.ErrorIs(t, err, declined)
+	assert.ErrorIs(t, err, ErrCompensationFailed)
+	assert.ErrorIs(t, err, lost)
+	assert.ErrorContains(t, err, "saga order: step charge: card declined")
+	assert.Equal(t, []string{"reserve", "notify", "hold", "charge", "unhold", "release"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Run to verify the completed steps are compensated before a panic of a step is propagated
+func TestSaga_Panic(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	var calls []string
+	saga := NewSaga("order", NewTransactionContextFactory(tx.dbHolder)).
+		Step("reserve", sagaRecorder(&calls, "reserve", nil), sagaRecorder(&calls, "release", nil)).
+		Step("charge", func(ctx context.Context, db *gorm.DB) error { panic("boom") }, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	assert.PanicsWithValue(t, "boom", func() { _ = saga.Run(context.Background()) })
+	assert.Equal(t, []string{"reserve", "release"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
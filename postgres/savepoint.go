@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+)
+
+// Errors of the savepoints set with SavePoint.
+var (
+	ErrInvalidSavePoint = uow.ErrInvalidSavePoint // ErrInvalidSavePoint occurs when a savepoint name is not a plain identifier.
+	ErrUnknownSavePoint = uow.ErrUnknownSavePoint // ErrUnknownSavePoint occurs when RollbackTo names a savepoint that was not set.
+)
+
+// savepoint is a savepoint set in the running transaction.
+type savepoint struct {
+	name      string            // Name the savepoint was set with.
+	variables map[string]string // Settings applied in the transaction when the savepoint was set.
+}
+
+// SavePoint sets the savepoint name in the running transaction, so the work done after it can be undone
+// with RollbackTo while the transaction goes on. Setting a name again moves it to the current point.
+// Outside a transaction it fails with ErrNotInTransaction.
+// Example:
+//
+//	for _, item := range batch {
+//	  if err := txContext.SavePoint("item"); err != nil { return err }
+//	  if err := process(ctx, item); err != nil {
+//	    if err := txContext.RollbackTo("item"); err != nil { return err }
+//	    failed = append(failed, item)
+//	  }
+//	}
+func (c *transactionContext) SavePoint(name string) error {
+	if err := c.checkSavePoint(name); err != nil {
+		return err
+	}
+	if err := c.tx.Exec("SAVEPOINT " + name).Error; err != nil {
+		return fmt.Errorf("savepoint %s: %w", name, err)
+	}
+	variables := make(map[string]string, len(c.appliedVariables))
+	for variable, value := range c.appliedVariables {
+		variables[variable] = value
+	}
+	c.savepoints = append(c.savepoints, savepoint{name: name, variables: variables})
+	return nil
+}
+
+// RollbackTo undoes the work done in the running transaction since the savepoint name was last set, also
+// after a failed statement, and keeps the savepoint, so it can be rolled back to again. Savepoints set
+// after it are released. A name that was not set fails with ErrUnknownSavePoint without affecting the
+// transaction.
+func (c *transactionContext) RollbackTo(name string) error {
+	if err := c.checkSavePoint(name); err != nil {
+		return err
+	}
+	i := len(c.savepoints) - 1
+	for ; i >= 0 && c.savepoints[i].name != name; i-- {
+	}
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownSavePoint, name)
+	}
+	if err := c.tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error; err != nil {
+		return fmt.Errorf("rollback to savepoint %s: %w", name, err)
+	}
+	c.savepoints = c.savepoints[:i+1]
+	// The settings applied after the savepoint have been rolled back with it
+	c.appliedVariables = make(map[string]string, len(c.savepoints[i].variables))
+	for variable, value := range c.savepoints[i].variables {
+		c.appliedVariables[variable] = value
+	}
+	return nil
+}
+
+// checkSavePoint verifies name and that a transaction is running.
+func (c *transactionContext) checkSavePoint(name string) error {
+	if err := uow.CheckSavePointName(name); err != nil {
+		return err
+	}
+	if c.wasRollbacked() {
+		return ErrTxWasRollbacked
+	}
+	if !c.inTransaction() {
+		return ErrNotInTransaction
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test SavePoint and RollbackTo to verify rolled back session variables are set again by the next unit of work
+func TestTransactionContext_SavePoint(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	item := ContextWithSessionVariables(ctx, map[string]string{"application.item": "1"})
+	join := func(ctx context.Context, db *gorm.DB) error { return nil }
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("application.item", "1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("application.item", "1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, tx.SavePoint("item"), ErrNotInTransaction)
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, tx.SavePoint("item; COMMIT"), ErrInvalidSavePoint)
+	assert.NoError(t, tx.SavePoint("item"))
+	assert.NoError(t, RunInTransaction(item, join))
+	assert.ErrorIs(t, tx.RollbackTo("batch"), ErrUnknownSavePoint)
+	assert.NoError(t, tx.RollbackTo("item"))
+	assert.NoError(t, RunInTransaction(item, join))
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"sort"
+	"strings"
+)
+
+// schemaPrivileges are the privileges SchemaBootstrap.Grants may grant on a schema.
+var schemaPrivileges = map[string]bool{"USAGE": true, "CREATE": true, "ALL": true, "ALL PRIVILEGES": true}
+
+// SchemaBootstrap describes how EnsureSchema prepares the schema of a new environment.
+type SchemaBootstrap struct {
+	Owner  string              // Owner, when set, is made the owner of the schema, e.g. the migration role.
+	Grants map[string][]string // Grants maps roles to the privileges granted to them on the schema, e.g. {"reporting": {"USAGE"}}.
+}
+
+// EnsureSchema creates the schemas of search_path, a schema name or a comma-separated list such as
+// PgConfig.Schema, if they do not exist yet, then sets the owner and grants of bootstrap (which may be nil).
+// It runs in a transaction under an advisory lock, so instances starting together do not race.
+// Example:
+//
+//	err := EnsureSchema(db, "billing", &SchemaBootstrap{Owner: "billing_owner", Grants: map[string][]string{"billing_app": {"USAGE"}}})
+func EnsureSchema(db *gorm.DB, searchPath string, bootstrap *SchemaBootstrap) error {
+	if bootstrap == nil {
+		bootstrap = &SchemaBootstrap{}
+	}
+	var statements []string
+	for _, schema := range parseSearchPath(searchPath) {
+		if schema == "" || schema == "$user" {
+			continue
+		}
+		quoted := quoteName(schema)
+		statements = append(statements, "SELECT pg_advisory_xact_lock("+fmt.Sprint(AdvisoryLockKey("uow:schema:"+schema))+")",
+			"CREATE SCHEMA IF NOT EXISTS "+quoted)
+		if bootstrap.Owner != "" {
+			statements = append(statements, "ALTER SCHEMA "+quoted+" OWNER TO "+quoteName(bootstrap.Owner))
+		}
+
+		roles := make([]string, 0, len(bootstrap.Grants))
+		for role := range bootstrap.Grants {
+			roles = append(roles, role)
+		}
+		sort.Strings(roles)
+		for _, role := range roles {
+			privileges := make([]string, len(bootstrap.Grants[role]))
+			for i, privilege := range bootstrap.Grants[role] {
+				privileges[i] = strings.ToUpper(strings.TrimSpace(privilege))
+				if !schemaPrivileges[privileges[i]] {
+					return fmt.Errorf("ensure schema %s: invalid privilege %q", schema, privilege)
+				}
+			}
+			if len(privileges) > 0 {
+				statements = append(statements, "GRANT "+strings.Join(privileges, ", ")+" ON SCHEMA "+quoted+" TO "+quoteName(role))
+			}
+		}
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("ensure schema: %w", err)
+		}
+	}
+	return tx.Commit().Error
+}
+
+// ensureConfiguredSchema runs EnsureSchema for the search path of cfg when cfg.EnsureSchema is set.
+func ensureConfiguredSchema(db *gorm.DB, cfg *PgConfig) error {
+	searchPath := formatSearchPath(cfg.searchPath())
+	if cfg.EnsureSchema == nil || searchPath == "" {
+		return nil
+	}
+	if err := EnsureSchema(db, searchPath, cfg.EnsureSchema); err != nil {
+		log.FromDefaultContext().Errorf("Ensuring schema %s of postgres %s@%s FAILED: %s", searchPath, cfg.DBName, cfg.Host, err)
+		return err
+	}
+	return nil
+}
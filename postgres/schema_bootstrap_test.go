@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test EnsureSchema to verify the schemas of the search path are created with their owner and grants in one transaction
+func TestEnsureSchema(t *testing.T) {
+	_, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fmt.Sprintf("SELECT pg_advisory_xact_lock(%d)", AdvisoryLockKey("uow:schema:billing"))).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE SCHEMA IF NOT EXISTS "billing"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER SCHEMA "billing" OWNER TO "billing_owner"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`GRANT USAGE, CREATE ON SCHEMA "billing" TO "billing_app"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`GRANT USAGE ON SCHEMA "billing" TO "reporting"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := EnsureSchema(db, "$user, billing", &SchemaBootstrap{
+		Owner:  "billing_owner",
+		Grants: map[string][]string{"reporting": {"usage"}, "billing_app": {"USAGE", "CREATE"}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test EnsureSchema to verify unknown privileges are rejected before any statement
+func TestEnsureSchema_InvalidPrivilege(t *testing.T) {
+	_, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	err := EnsureSchema(db, "billing", &SchemaBootstrap{Grants: map[string][]string{"app": {"USAGE; DROP SCHEMA billing"}}})
+	assert.ErrorContains(t, err, "invalid privilege")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
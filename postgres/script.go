@@ -0,0 +1,192 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrInvalidScript occurs when ExecScript cannot split a script: an unterminated string, quoted identifier,
+// dollar quote or comment, a psql meta-command other than \g, or a statement ending the transaction.
+var ErrInvalidScript = errors.New("invalid SQL script")
+
+// transactionCommands are the statements a script must not contain, as it runs in the unit of work.
+var transactionCommands = map[string]bool{"BEGIN": true, "START": true, "COMMIT": true, "END": true, "ROLLBACK": true, "ABORT": true}
+
+// scriptStatement is a statement of a script together with the line it starts on.
+type scriptStatement struct {
+	sql  string // SQL text, without the terminating semicolon.
+	line int    // Line of the script the statement starts on, counting from 1.
+}
+
+// ExecScript executes the statements of a SQL script, e.g. an operator's data fix or backfill, one by one
+// in a transaction of the unit of work of ctx, joining a running one. Statements are separated the way
+// psql does it: by semicolons outside of strings, quoted identifiers, dollar quotes and comments, or by a
+// \g meta-command. Progress is logged per statement, and a failing statement rolls the whole script back.
+// Example:
+//
+//	f, _ := os.Open("fixes/2024-05-backfill-totals.sql")
+//	defer f.Close()
+//	err := ExecScript(ctx, f)
+func ExecScript(ctx context.Context, script io.Reader) error {
+	text, err := io.ReadAll(script)
+	if err != nil {
+		return err
+	}
+	statements, err := splitScript(string(text))
+	if err != nil {
+		return err
+	}
+
+	logger := log.FromContext(ctx)
+	return RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		for i, statement := range statements {
+			started := time.Now()
+			result := db.Exec(statement.sql)
+			if result.Error != nil {
+				return fmt.Errorf("script statement %d at line %d: %w", i+1, statement.line, result.Error)
+			}
+			logger.Infof("script statement %d of %d at line %d: %d rows affected in %s",
+				i+1, len(statements), statement.line, result.RowsAffected, time.Since(started))
+		}
+		return nil
+	})
+}
+
+// splitScript splits a script into its statements, leaving out empty ones.
+func splitScript(text string) ([]scriptStatement, error) {
+	statements, err := splitStatements(text, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, statement := range statements {
+		if command := leadingCommand(statement.sql); transactionCommands[command] {
+			return nil, fmt.Errorf("%w: %s at line %d would end the unit of work", ErrInvalidScript, command, statement.line)
+		}
+	}
+	return statements, nil
+}
+
+// splitStatements splits text at the semicolons outside of strings, quoted identifiers, dollar quotes and
+// comments, leaving out empty statements. With metaCommands, a \g meta-command separates statements too
+// and any other backslash outside of quotes is rejected as a psql meta-command.
+func splitStatements(text string, metaCommands bool) ([]scriptStatement, error) {
+	var statements []scriptStatement
+	line, start, startLine := 1, 0, 0
+	flush := func(end int) {
+		if sql := strings.TrimSpace(text[start:end]); leadingCommand(sql) != "" {
+			statements = append(statements, scriptStatement{sql: sql, line: startLine})
+		}
+		startLine = 0
+	}
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if startLine == 0 && !strings.ContainsRune(" \t\r\n;", rune(c)) &&
+			!strings.HasPrefix(text[i:], "--") && !strings.HasPrefix(text[i:], "/*") {
+			startLine = line
+		}
+		if end, what := literalEnd(text, i); what != "" {
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated %s starting at line %d", ErrInvalidScript, what, line)
+			}
+			line += strings.Count(text[i:end], "\n")
+			i = end - 1
+			continue
+		}
+		switch {
+		case c == '\n':
+			line++
+		case c == ';':
+			flush(i)
+			start = i + 1
+		case c == '\\' && metaCommands:
+			end := strings.IndexAny(text[i:], " \t\r\n")
+			if end < 0 {
+				end = len(text) - i
+			}
+			if command := text[i : i+end]; command != `\g` {
+				return nil, fmt.Errorf("%w: psql meta-command %s at line %d is not supported", ErrInvalidScript, command, line)
+			}
+			flush(i)
+			i += end - 1
+			start = i + 1
+		}
+	}
+	flush(len(text))
+	return statements, nil
+}
+
+// literalEnd returns the end of the string, quoted identifier, dollar quote or comment starting at
+// text[i] together with what it is, or -1 if it is unterminated. what is empty if none starts at text[i].
+// A comment ends before its newline.
+func literalEnd(text string, i int) (end int, what string) {
+	c := text[i]
+	switch {
+	case c == '\'' || c == '"':
+		escapes := c == '\'' && i > 0 && (text[i-1] == 'e' || text[i-1] == 'E')
+		for end = i + 1; end < len(text); end++ {
+			if escapes && text[end] == '\\' {
+				end++
+			} else if text[end] == c {
+				if end+1 < len(text) && text[end+1] == c {
+					end++ // doubled quote
+					continue
+				}
+				return end + 1, "quoted text"
+			}
+		}
+		return -1, "quoted text"
+	case c == '$':
+		tag, ok := dollarQuoteTag(text[i:])
+		if !ok {
+			return i, ""
+		}
+		what = "dollar quote " + tag
+		if end = strings.Index(text[i+len(tag):], tag); end < 0 {
+			return -1, what
+		}
+		return end + i + len(tag) + len(tag), what
+	case strings.HasPrefix(text[i:], "--"):
+		if end = strings.IndexByte(text[i:], '\n'); end < 0 {
+			return len(text), "comment"
+		}
+		return i + end, "comment"
+	case strings.HasPrefix(text[i:], "/*"):
+		depth := 1
+		for end = i + 2; end < len(text) && depth > 0; end++ {
+			if strings.HasPrefix(text[end:], "/*") {
+				depth, end = depth+1, end+1
+			} else if strings.HasPrefix(text[end:], "*/") {
+				depth, end = depth-1, end+1
+			}
+		}
+		if depth > 0 {
+			return -1, "comment"
+		}
+		return end, "comment"
+	}
+	return i, ""
+}
+
+// dollarQuoteTag returns the opening tag of the dollar quote text starts with, e.g. "$$" or "$body$".
+// Positional parameters such as $1 are not dollar quotes.
+func dollarQuoteTag(text string) (string, bool) {
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '$':
+			return text[:i+1], true
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+		case c >= '0' && c <= '9' && i > 1:
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
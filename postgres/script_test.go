@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// testScript separates statements by semicolons and \g, with separators inside quotes and comments.
+const testScript = `-- backfill totals; run once
+UPDATE orders SET note = 'a;b' WHERE note IS NULL;
+
+/* recompute; all */ UPDATE "odd;table" SET total = 0;
+CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN NEW.updated_at = now(); RETURN NEW; END
+$body$ LANGUAGE plpgsql
+\g
+SELECT E'it\'s;' ;
+`
+
+// Test splitScript to verify psql-style separation and the start lines of the statements
+func TestSplitScript(t *testing.T) {
+	statements, err := splitScript(testScript)
+	assert.NoError(t, err)
+	assert.Equal(t, []scriptStatement{
+		{sql: "-- backfill totals; run once\nUPDATE orders SET note = 'a;b' WHERE note IS NULL", line: 2},
+		{sql: `/* recompute; all */ UPDATE "odd;table" SET total = 0`, line: 4},
+		{sql: "CREATE FUNCTION touch() RETURNS trigger AS $body$\nBEGIN NEW.updated_at = now(); RETURN NEW; END\n$body$ LANGUAGE plpgsql", line: 5},
+		{sql: `SELECT E'it\'s;'`, line: 9},
+	}, statements)
+}
+
+// Test splitScript to verify unterminated text, meta-commands and transaction control are rejected
+func TestSplitScript_Invalid(t *testing.T) {
+	for _, script := range []string{
+		"SELECT 'open",
+		"SELECT $$open",
+		"SELECT 1 /* open",
+		"\\set ON_ERROR_STOP on\nSELECT 1;",
+		"UPDATE orders SET total = 0;\nCOMMIT;",
+	} {
+		_, err := splitScript(script)
+		assert.ErrorIs(t, err, ErrInvalidScript, script)
+	}
+}
+
+// Test ExecScript to verify the statements run in one transaction that a failure rolls back
+func TestExecScript(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders SET total = 0").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM carts").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.NoError(t, ExecScript(ctx, strings.NewReader("UPDATE orders SET total = 0;\nDELETE FROM carts;\n")))
+
+	failure := errors.New("relation does not exist")
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders SET total = 0").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM carts").WillReturnError(failure)
+	mock.ExpectRollback()
+	err := ExecScript(ctx, strings.NewReader("UPDATE orders SET total = 0;\nDELETE FROM carts;\n"))
+	assert.ErrorIs(t, err, failure)
+	assert.ErrorContains(t, err, "script statement 2 at line 2")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
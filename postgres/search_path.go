@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxIdentifierLength is the maximum length of a Postgres identifier in bytes (NAMEDATALEN - 1).
+const maxIdentifierLength = 63
+
+// plainSchemaPattern matches the schema names a search_path holds without quotes.
+var plainSchemaPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// searchPath returns the schemas of the search path of the configuration: SearchPath, or else the entries
+// of Schema.
+func (c *PgConfig) searchPath() []string {
+	if len(c.SearchPath) > 0 {
+		return c.SearchPath
+	}
+	return parseSearchPath(c.Schema)
+}
+
+// validateSearchPath rejects schema names of the search path that Postgres cannot hold.
+func (c *PgConfig) validateSearchPath() error {
+	for _, schema := range c.searchPath() {
+		switch {
+		case schema == "":
+			return fmt.Errorf("invalid search path %q: empty schema name", formatSearchPath(c.searchPath()))
+		case len(schema) > maxIdentifierLength:
+			return fmt.Errorf("invalid search path: schema name %q is longer than %d bytes", schema, maxIdentifierLength)
+		case strings.ContainsRune(schema, 0):
+			return fmt.Errorf("invalid search path: schema name %q contains a NUL byte", schema)
+		}
+	}
+	return nil
+}
+
+// parseSearchPath splits a search_path setting into its schema names, following Postgres: unquoted names
+// are folded to lower case, double-quoted ones are kept as they are, with doubled quotes unescaped.
+func parseSearchPath(searchPath string) []string {
+	if strings.TrimSpace(searchPath) == "" {
+		return nil
+	}
+	var schemas []string
+	var name strings.Builder
+	quoted, inQuotes := false, false
+	flush := func() {
+		schema := name.String()
+		if !quoted {
+			schema = strings.ToLower(strings.TrimSpace(schema))
+		}
+		schemas = append(schemas, schema)
+		name.Reset()
+		quoted = false
+	}
+	for i := 0; i < len(searchPath); i++ {
+		char := searchPath[i]
+		switch {
+		case inQuotes && char == '"' && i+1 < len(searchPath) && searchPath[i+1] == '"':
+			name.WriteByte('"')
+			i++
+		case inQuotes && char == '"':
+			inQuotes = false
+		case inQuotes:
+			name.WriteByte(char)
+		case char == '"':
+			name.Reset() // drops the spaces before the quote
+			inQuotes, quoted = true, true
+		case char == ',':
+			flush()
+		case !quoted:
+			name.WriteByte(char)
+		}
+	}
+	flush()
+	return schemas
+}
+
+// formatSearchPath returns the search_path setting of schemas, quoting the names that need it.
+// Example:
+//
+//	formatSearchPath([]string{"Tenant X", "public"}) // "Tenant X",public
+func formatSearchPath(schemas []string) string {
+	names := make([]string, len(schemas))
+	for i, schema := range schemas {
+		if plainSchemaPattern.MatchString(schema) {
+			names[i] = schema
+		} else {
+			names[i] = quoteName(schema)
+		}
+	}
+	return strings.Join(names, ",")
+}
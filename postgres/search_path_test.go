@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// Test parseSearchPath and formatSearchPath to verify names are folded, unquoted and quoted as Postgres does
+func TestSearchPath_ParseFormat(t *testing.T) {
+	assert.Equal(t, []string{"$user", "billing", "Tenant X", `a"b`}, parseSearchPath(`$user, Billing ,"Tenant X", "a""b"`))
+	assert.Nil(t, parseSearchPath(" "))
+	assert.Equal(t, []string{"a", ""}, parseSearchPath("a,"))
+
+	assert.Equal(t, `"$user",public,"Tenant X","a""b"`, formatSearchPath([]string{"$user", "public", "Tenant X", `a"b`}))
+}
+
+// Test DSN to verify SearchPath takes precedence over Schema and is quoted for the connection string
+func TestPgConfig_DSN_SearchPath(t *testing.T) {
+	cfg := &PgConfig{Host: "db", Schema: "ignored", SearchPath: []string{"tenant x", "public"}}
+	assert.Equal(t, `host=db search_path='"tenant x",public'`, cfg.DSN())
+
+	cfg = &PgConfig{Host: "db", Schema: `Billing, "O'Brien"`}
+	assert.Equal(t, `host=db search_path='billing,"O\'Brien"'`, cfg.DSN())
+}
+
+// Test OpenContext to verify invalid schema names are rejected before connecting
+func TestOpenContext_InvalidSearchPath(t *testing.T) {
+	_, err := OpenContext(context.Background(), &PgConfig{Host: "db", SearchPath: []string{"tenant", ""}})
+	assert.ErrorContains(t, err, "empty schema name")
+
+	_, err = OpenContext(context.Background(), &PgConfig{Host: "db", Schema: strings.Repeat("s", 64)})
+	assert.ErrorContains(t, err, "longer than 63 bytes")
+}
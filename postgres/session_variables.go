@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"sort"
+)
+
+// sessionVariablesKey is the context key of the variables stored by ContextWithSessionVariables.
+type sessionVariablesKey struct{}
+
+// ContextWithSessionVariables returns a copy of ctx carrying configuration parameters, e.g. request metadata
+// for triggers and audit extensions, merged with the ones ctx already carries. They are set with SET LOCAL
+// semantics in the transactions begun for ctx, and in running transactions joined with ctx through
+// RunInTransaction or Execute.
+// Example:
+//
+//	ctx = ContextWithSessionVariables(ctx, map[string]string{
+//	  "application.request_id": requestID,
+//	  "application.actor":      userID,
+//	})
+//	// in a trigger: current_setting('application.request_id', true)
+func ContextWithSessionVariables(ctx context.Context, variables map[string]string) context.Context {
+	merged := make(map[string]string, len(variables))
+	for name, value := range sessionVariablesFromContext(ctx) {
+		merged[name] = value
+	}
+	for name, value := range variables {
+		merged[name] = value
+	}
+	return context.WithValue(ctx, sessionVariablesKey{}, merged)
+}
+
+// SetLocal sets the configuration parameter name to value in the running transaction of the transaction
+// context in ctx, as SET LOCAL does. Outside a transaction it fails with ErrNotInTransaction.
+// Example:
+//
+//	if err := SetLocal(ctx, "application.batch_id", batchID); err != nil { return err }
+func SetLocal(ctx context.Context, name, value string) error {
+	txContext, _ := GetTransactionContext(ctx)
+	c, ok := txContext.(*transactionContext)
+	if !ok {
+		return ErrNotInTransaction
+	}
+	if c.wasRollbacked() {
+		return ErrTxWasRollbacked
+	}
+	if !c.inTransaction() {
+		return ErrNotInTransaction
+	}
+	return c.setLocal(name, value)
+}
+
+// sessionVariablesFromContext returns the variables stored by ContextWithSessionVariables.
+func sessionVariablesFromContext(ctx context.Context) map[string]string {
+	variables, _ := ctx.Value(sessionVariablesKey{}).(map[string]string)
+	return variables
+}
+
+// applySessionVariables sets the variables carried by ctx that the running transaction does not hold yet,
+// in name order.
+func (c *transactionContext) applySessionVariables(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	variables := sessionVariablesFromContext(ctx)
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if applied, ok := c.appliedVariables[name]; ok && applied == variables[name] {
+			continue
+		}
+		if err := c.setLocal(name, variables[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setLocal sets a configuration parameter for the rest of the running transaction and remembers its value.
+func (c *transactionContext) setLocal(name, value string) error {
+	if err := c.tx.Exec("SELECT set_config(?, ?, true)", name, value).Error; err != nil {
+		return err
+	}
+	if c.appliedVariables == nil {
+		c.appliedVariables = map[string]string{}
+	}
+	c.appliedVariables[name] = value
+	return nil
+}
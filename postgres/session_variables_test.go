@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that session variables are set at Begin and when a nested unit of work joins with new ones
+func TestSessionVariables(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := ContextWithSessionVariables(context.Background(), map[string]string{"application.request_id": "r-1"})
+	tx.ctx = ctx
+	ctx = context.WithValue(ctx, TransactionContextKey, tx)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("application.request_id", "r-1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("application.actor", "ann").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("application.step", "2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, SetLocal(ctx, "application.step", "1"), ErrNotInTransaction)
+	err := RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		inner := ContextWithSessionVariables(ctx, map[string]string{"application.actor": "ann"})
+		return RunInTransaction(inner, func(ctx context.Context, db *gorm.DB) error {
+			return SetLocal(ctx, "application.step", "2")
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// shutdownPollInterval defines how often Shutdown checks whether the running transactions have finished.
+const shutdownPollInterval = 10 * time.Millisecond
+
+// ErrShuttingDown occurs when a transaction is begun on a holder that is being shut down.
+var ErrShuttingDown = errors.New("database holder is shutting down")
+
+// Shutdown stops the holder from beginning new transactions, which fail with ErrShuttingDown, waits until
+// the running ones have been committed or rolled back, and closes the connection. Nested units of work
+// still join the running transactions while waiting. If ctx is done first, the connection is closed anyway
+// and the error of ctx is returned, along with the number of transactions still running.
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+//	defer cancel()
+//	if err := holder.Shutdown(ctx); err != nil { logger.Warnf("database shutdown: %s", err) }
+func (h *DatabaseHolder) Shutdown(ctx context.Context) error {
+	h.shuttingDown.Store(true)
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for h.TxStats().Active > 0 {
+		select {
+		case <-ctx.Done():
+			return errors.Join(
+				fmt.Errorf("%w: %d transactions still running", ctx.Err(), h.TxStats().Active),
+				h.Close(),
+			)
+		case <-ticker.C:
+		}
+	}
+	return h.Close()
+}
+
+// Shutdown shuts the factory's holder down, see DatabaseHolder.Shutdown.
+func (f *TransactionContextFactory) Shutdown(ctx context.Context) error {
+	return f.DBHolder().Shutdown(ctx)
+}
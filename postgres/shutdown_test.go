@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// newShutdownTestHolder returns a holder on top of sqlmock.
+func newShutdownTestHolder(t *testing.T) (*DatabaseHolder, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	return NewDBHolder(db), mock
+}
+
+// Test that Shutdown rejects new transactions and waits for the running one before closing
+func TestDatabaseHolder_Shutdown(t *testing.T) {
+	holder, mock := newShutdownTestHolder(t)
+	running := newTransactionContext(log.FromDefaultContext(), holder)
+
+	mock.ExpectBegin()
+	id, err := running.Begin()
+	assert.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- holder.Shutdown(context.Background()) }()
+	assert.Eventually(t, holder.shuttingDown.Load, time.Second, time.Millisecond)
+
+	_, err = newTransactionContext(log.FromDefaultContext(), holder).Begin()
+	assert.ErrorIs(t, err, ErrShuttingDown)
+	_, err = running.Begin() // nested units of work still join
+	assert.NoError(t, err)
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned while a transaction was running")
+	case <-time.After(5 * shutdownPollInterval):
+	}
+
+	mock.ExpectCommit()
+	mock.ExpectClose()
+	assert.NoError(t, running.Commit(id))
+	assert.NoError(t, <-done)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Shutdown closes the connection once the context is done, reporting the running transactions
+func TestDatabaseHolder_ShutdownDeadline(t *testing.T) {
+	holder, mock := newShutdownTestHolder(t)
+	mock.ExpectBegin()
+	_, err := newTransactionContext(log.FromDefaultContext(), holder).Begin()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = holder.Shutdown(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "1 transactions still running")
+	assert.ErrorContains(t, holder.dbConnection.DB().Ping(), "database is closed")
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// softDeleteTag marks the soft delete column of a model that does not use gorm's DeletedAt: `uow:"soft_delete"`.
+	softDeleteTag = "soft_delete"
+	// onlyDeletedScopeKey marks queries restricted to soft-deleted rows by OnlyDeleted.
+	onlyDeletedScopeKey = "uow:only_deleted"
+)
+
+// WithDeleted is a scope including soft-deleted rows, for use with Repository.List or (*gorm.DB).Scopes.
+// Deletes through it remove rows physically, as with (*gorm.DB).Unscoped.
+// Example:
+//
+//	all, err := orders.List(ctx, WithDeleted)
+func WithDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// OnlyDeleted is a scope restricted to soft-deleted rows, for use with Repository.List or (*gorm.DB).Scopes.
+// Example:
+//
+//	trash, err := orders.List(ctx, OnlyDeleted, NewQuery(Eq("customer_id", id)).Scope())
+func OnlyDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Set(onlyDeletedScopeKey, true)
+}
+
+// registerSoftDelete registers GORM callbacks on db applying soft delete to the models with a nullable
+// time field tagged `uow:"soft_delete"`, the way gorm handles DeletedAt: queries and updates leave out
+// rows where the column is set, and deletes set it to the current time. They also implement OnlyDeleted
+// for both kinds of models.
+// Example:
+//
+//	type Document struct {
+//	  ID         int
+//	  ArchivedAt *time.Time `uow:"soft_delete"`
+//	}
+func registerSoftDelete(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Query().Before("gorm:query").Register("uow:soft_delete_query", scopeSoftDeleted)
+	callbacks.RowQuery().Before("gorm:row_query").Register("uow:soft_delete_row_query", scopeSoftDeleted)
+	callbacks.Update().Before("gorm:update").Register("uow:soft_delete_update", scopeSoftDeleted)
+
+	deleteCallback := callbacks.Delete().Get("gorm:delete")
+	callbacks.Delete().Replace("gorm:delete", func(scope *gorm.Scope) {
+		softDelete(scope, deleteCallback)
+	})
+}
+
+// scopeSoftDeleted restricts the statement to the rows that are not soft-deleted, or to the soft-deleted
+// ones for OnlyDeleted.
+func scopeSoftDeleted(scope *gorm.Scope) {
+	if scope.HasError() {
+		return
+	}
+	if _, onlyDeleted := scope.Get(onlyDeletedScopeKey); onlyDeleted {
+		field, ok := softDeleteField(scope)
+		if !ok {
+			field, ok = scope.FieldByName("DeletedAt")
+		}
+		if ok {
+			scope.Search.Where(fmt.Sprintf("%v.%v IS NOT NULL", scope.QuotedTableName(), scope.Quote(field.DBName)))
+		}
+		return
+	}
+	if field, ok := softDeleteField(scope); ok && !scope.Search.Unscoped {
+		scope.Search.Where(fmt.Sprintf("%v.%v IS NULL", scope.QuotedTableName(), scope.Quote(field.DBName)))
+	}
+}
+
+// softDelete sets the soft delete column of tagged models and runs deleteCallback for all others.
+func softDelete(scope *gorm.Scope, deleteCallback func(*gorm.Scope)) {
+	field, ok := softDeleteField(scope)
+	if !ok || scope.Search.Unscoped {
+		deleteCallback(scope)
+		return
+	}
+	if scope.HasError() {
+		return
+	}
+	scopeSoftDeleted(scope)
+
+	var extraOption string
+	if option, ok := scope.Get("gorm:delete_option"); ok {
+		extraOption = " " + fmt.Sprint(option)
+	}
+	scope.Raw(fmt.Sprintf(
+		"UPDATE %v SET %v=%v %v%v",
+		scope.QuotedTableName(),
+		scope.Quote(field.DBName),
+		scope.AddToVars(gorm.NowFunc()),
+		scope.CombinedConditionSql(),
+		extraOption,
+	)).Exec()
+}
+
+// softDeleteField returns the field of the model tagged as soft delete column.
+func softDeleteField(scope *gorm.Scope) (*gorm.Field, bool) {
+	for _, field := range scope.Fields() {
+		if field.Tag.Get("uow") == softDeleteTag && field.IsNormal {
+			return field, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type softDeleteDocument struct {
+	ID         int
+	ArchivedAt *time.Time `uow:"soft_delete"`
+}
+
+// Test that tagged models are soft-deleted and left out of queries unless asked for
+func TestSoftDelete(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	documents := NewRepository[softDeleteDocument](nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "soft_delete_documents"  WHERE ("soft_delete_documents"."archived_at" IS NULL)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(`UPDATE "soft_delete_documents" SET "archived_at"=$1 WHERE "soft_delete_documents"."id" = $2 AND (("soft_delete_documents"."archived_at" IS NULL))`).
+		WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT * FROM "soft_delete_documents"  WHERE ("soft_delete_documents"."archived_at" IS NOT NULL)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "archived_at"}).AddRow(1, time.Now()))
+	mock.ExpectQuery(`SELECT * FROM "soft_delete_documents"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+
+	active, err := documents.List(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, documents.Delete(ctx, &active[0]))
+	deleted, err := documents.List(ctx, OnlyDeleted)
+	assert.NoError(t, err)
+	all, err := documents.List(ctx, WithDeleted)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+
+	assert.Len(t, deleted, 1)
+	assert.Len(t, all, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+// Specification is a composable query condition. It renders to a SQL expression with ? placeholders and
+// its arguments, so the query a repository builds can be asserted without a database.
+// Example:
+//
+//	spec := And(Eq("status", "open"), Or(Gt("total", 100), Not(IsNull("coupon"))))
+//	expr, args := spec.ToSQL() // (("status" = ?) AND (("total" > ?) OR (NOT ("coupon" IS NULL)))), [open 100]
+type Specification interface {
+	ToSQL() (string, []interface{}) // ToSQL returns the SQL expression and its arguments.
+}
+
+// comparison is a Specification comparing a field with a value.
+type comparison struct {
+	field    string
+	operator string
+	value    interface{}
+}
+
+// ToSQL implements Specification.
+func (c comparison) ToSQL() (string, []interface{}) {
+	return fmt.Sprintf("(%s %s ?)", QuoteIdentifier(c.field), c.operator), []interface{}{c.value}
+}
+
+// Eq matches rows whose field equals value.
+func Eq(field string, value interface{}) Specification {
+	return comparison{field: field, operator: "=", value: value}
+}
+
+// Ne matches rows whose field differs from value.
+func Ne(field string, value interface{}) Specification {
+	return comparison{field: field, operator: "<>", value: value}
+}
+
+// Gt matches rows whose field is greater than value.
+func Gt(field string, value interface{}) Specification {
+	return comparison{field: field, operator: ">", value: value}
+}
+
+// Gte matches rows whose field is greater than or equal to value.
+func Gte(field string, value interface{}) Specification {
+	return comparison{field: field, operator: ">=", value: value}
+}
+
+// Lt matches rows whose field is less than value.
+func Lt(field string, value interface{}) Specification {
+	return comparison{field: field, operator: "<", value: value}
+}
+
+// Lte matches rows whose field is less than or equal to value.
+func Lte(field string, value interface{}) Specification {
+	return comparison{field: field, operator: "<=", value: value}
+}
+
+// Like matches rows whose field matches the LIKE pattern.
+func Like(field string, pattern string) Specification {
+	return comparison{field: field, operator: "LIKE", value: pattern}
+}
+
+// in is a Specification matching fields with one of a list of values.
+type in struct {
+	field  string
+	values []interface{}
+}
+
+// ToSQL implements Specification. An empty list matches no rows.
+func (i in) ToSQL() (string, []interface{}) {
+	if len(i.values) == 0 {
+		return "(1 = 0)", nil
+	}
+	return fmt.Sprintf("(%s IN (?))", QuoteIdentifier(i.field)), []interface{}{i.values}
+}
+
+// In matches rows whose field is one of values.
+func In(field string, values ...interface{}) Specification {
+	return in{field: field, values: values}
+}
+
+// isNull is a Specification matching NULL fields.
+type isNull struct {
+	field string
+}
+
+// ToSQL implements Specification.
+func (n isNull) ToSQL() (string, []interface{}) {
+	return fmt.Sprintf("(%s IS NULL)", QuoteIdentifier(n.field)), nil
+}
+
+// IsNull matches rows whose field is NULL.
+func IsNull(field string) Specification {
+	return isNull{field: field}
+}
+
+// junction is a Specification joining its parts with AND or OR.
+type junction struct {
+	operator string
+	parts    []Specification
+}
+
+// ToSQL implements Specification. An empty junction matches all rows for AND and none for OR.
+func (j junction) ToSQL() (string, []interface{}) {
+	if len(j.parts) == 0 {
+		if j.operator == "AND" {
+			return "(1 = 1)", nil
+		}
+		return "(1 = 0)", nil
+	}
+	expressions := make([]string, 0, len(j.parts))
+	var args []interface{}
+	for _, part := range j.parts {
+		expression, partArgs := part.ToSQL()
+		expressions = append(expressions, expression)
+		args = append(args, partArgs...)
+	}
+	return "(" + strings.Join(expressions, " "+j.operator+" ") + ")", args
+}
+
+// And matches rows matching all specs.
+func And(specs ...Specification) Specification {
+	return junction{operator: "AND", parts: specs}
+}
+
+// Or matches rows matching any of specs.
+func Or(specs ...Specification) Specification {
+	return junction{operator: "OR", parts: specs}
+}
+
+// negation is a Specification negating another one.
+type negation struct {
+	spec Specification
+}
+
+// ToSQL implements Specification.
+func (n negation) ToSQL() (string, []interface{}) {
+	expression, args := n.spec.ToSQL()
+	return "(NOT " + expression + ")", args
+}
+
+// Not matches rows not matching spec.
+func Not(spec Specification) Specification {
+	return negation{spec: spec}
+}
+
+// Query combines a Specification with ordering and limits. Its zero value matches all rows.
+// Example:
+//
+//	query := NewQuery(Eq("status", "open")).OrderBy("created_at", true).Limit(20).Offset(40)
+//	open, err := orders.Find(ctx, query)
+type Query struct {
+	where  Specification // The condition; nil matches all rows.
+	orders []string      // The rendered ORDER BY expressions.
+	limit  int           // The maximum number of rows; 0 means no limit.
+	offset int           // The number of rows skipped.
+}
+
+// NewQuery creates a Query for the rows matching spec; a nil spec matches all rows.
+func NewQuery(spec Specification) *Query {
+	return &Query{where: spec}
+}
+
+// Where adds spec to the condition of the query with AND.
+func (q *Query) Where(spec Specification) *Query {
+	if q.where == nil {
+		q.where = spec
+	} else {
+		q.where = And(q.where, spec)
+	}
+	return q
+}
+
+// OrderBy appends an ordering by field, descending if desc is set.
+func (q *Query) OrderBy(field string, desc bool) *Query {
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	q.orders = append(q.orders, QuoteIdentifier(field)+" "+direction)
+	return q
+}
+
+// Limit sets the maximum number of rows returned.
+func (q *Query) Limit(limit int) *Query {
+	q.limit = limit
+	return q
+}
+
+// Offset sets the number of rows skipped.
+func (q *Query) Offset(offset int) *Query {
+	q.offset = offset
+	return q
+}
+
+// ToSQL returns the condition of the query and its arguments; the condition is empty if there is none.
+func (q *Query) ToSQL() (string, []interface{}) {
+	if q.where == nil {
+		return "", nil
+	}
+	return q.where.ToSQL()
+}
+
+// Scope returns a GORM scope applying the query, for use with Repository.List or (*gorm.DB).Scopes.
+func (q *Query) Scope() func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if expression, args := q.ToSQL(); expression != "" {
+			db = db.Where(expression, args...)
+		}
+		for _, order := range q.orders {
+			db = db.Order(order)
+		}
+		if q.limit > 0 {
+			db = db.Limit(q.limit)
+		}
+		if q.offset > 0 {
+			db = db.Offset(q.offset)
+		}
+		return db
+	}
+}
+
+// Find loads the entities matching query.
+func (r *Repository[T]) Find(ctx context.Context, query *Query) ([]T, error) {
+	return r.List(ctx, query.Scope())
+}
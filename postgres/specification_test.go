@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that specifications render to SQL without a database
+func TestSpecification_ToSQL(t *testing.T) {
+	spec := And(Eq("status", "open"), Or(Gt("orders.total", 100), Not(IsNull("coupon"))), In("region"))
+
+	expression, args := spec.ToSQL()
+
+	assert.Equal(t, `(("status" = ?) AND (("orders"."total" > ?) OR (NOT ("coupon" IS NULL))) AND (1 = 0))`, expression)
+	assert.Equal(t, []interface{}{"open", 100}, args)
+}
+
+// Test that a query is applied by the repository with its ordering and limits
+func TestRepository_Find(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	orders := NewRepository[repositoryOrder](nil)
+
+	mock.ExpectQuery(`SELECT * FROM "repository_orders"  WHERE ((("status" IN ($1,$2)) AND ("id" >= $3))) ORDER BY "id" DESC LIMIT 10 OFFSET 20`).
+		WithArgs("open", "paid", 7).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow(8, "open"))
+
+	query := NewQuery(In("status", "open", "paid")).Where(Gte("id", 7)).OrderBy("id", true).Limit(10).Offset(20)
+	found, err := orders.Find(ctx, query)
+
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
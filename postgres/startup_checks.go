@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"strconv"
+	"strings"
+)
+
+// ErrStartupChecksFailed is returned by Open when StartupChecks.RefuseToStart is set and a check fails.
+var ErrStartupChecksFailed = errors.New("database startup checks failed")
+
+// errNoCheckedSchema fails the SchemaPrivileges checks when there is no schema to check them on.
+var errNoCheckedSchema = errors.New("no schema to check: set StartupChecks.Schema or PgConfig.Schema")
+
+type (
+	// StartupChecks describes the environment the service expects from the database server.
+	// Empty fields are skipped, so only the expectations that matter need to be filled in.
+	StartupChecks struct {
+		RequiredExtensions []string // RequiredExtensions lists extensions that must be installed (e.g., "uuid-ossp").
+		SchemaPrivileges   []string // SchemaPrivileges lists privileges the current role needs on the schema (e.g., "USAGE", "CREATE").
+		Schema             string   // Schema is the schema privileges are checked on; defaults to the first schema of the PgConfig search path. Without one SchemaPrivileges fail.
+		MinServerVersion   int      // MinServerVersion is the minimum server_version_num (e.g., 120000 for 12.0).
+		TimeZone           string   // TimeZone is the expected value of the TimeZone setting (e.g., "UTC").
+		Encoding           string   // Encoding is the expected server_encoding (e.g., "UTF8").
+		RefuseToStart      bool     // RefuseToStart makes Open return ErrStartupChecksFailed when any check fails.
+	}
+
+	// StartupCheckResult is the outcome of a single startup check.
+	StartupCheckResult struct {
+		Name     string // Name identifies the check (e.g., "extension:pgcrypto").
+		Expected string // Expected describes the expected value.
+		Actual   string // Actual describes the value reported by the server.
+		Passed   bool   // Passed is true if the expectation is met.
+		Err      error  // Err holds the error if the check could not be executed.
+	}
+
+	// StartupReport is the structured result of running StartupChecks.
+	StartupReport struct {
+		Results []StartupCheckResult // Results holds one entry per executed check, in execution order.
+	}
+)
+
+// Passed returns true if every check in the report passed.
+func (r *StartupReport) Passed() bool {
+	return len(r.Failures()) == 0
+}
+
+// Failures returns the checks that did not pass.
+func (r *StartupReport) Failures() []StartupCheckResult {
+	var failures []StartupCheckResult
+	for _, result := range r.Results {
+		if !result.Passed {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Err returns nil if all checks passed, otherwise an error wrapping ErrStartupChecksFailed that lists the failures.
+func (r *StartupReport) Err() error {
+	failures := r.Failures()
+	if len(failures) == 0 {
+		return nil
+	}
+	details := make([]string, 0, len(failures))
+	for _, f := range failures {
+		if f.Err != nil {
+			details = append(details, fmt.Sprintf("%s: %v", f.Name, f.Err))
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s: expected %s, got %s", f.Name, f.Expected, f.Actual))
+	}
+	return fmt.Errorf("%w: %s", ErrStartupChecksFailed, strings.Join(details, "; "))
+}
+
+// RunStartupChecks executes the configured checks against db and returns a report.
+// Example:
+//
+//	report := RunStartupChecks(db, &StartupChecks{RequiredExtensions: []string{"pgcrypto"}, MinServerVersion: 130000})
+//	if !report.Passed() { logger.Warn(report.Err()) }
+func RunStartupChecks(db *gorm.DB, checks *StartupChecks) *StartupReport {
+	report := &StartupReport{}
+
+	if len(checks.RequiredExtensions) > 0 {
+		report.Results = append(report.Results, checkExtensions(db, checks.RequiredExtensions)...)
+	}
+	for _, privilege := range checks.SchemaPrivileges {
+		if checks.Schema == "" {
+			// has_schema_privilege would fail on the empty name
+			report.Results = append(report.Results, StartupCheckResult{Name: "privilege:" + privilege, Expected: "granted", Err: errNoCheckedSchema})
+			continue
+		}
+		report.Results = append(report.Results, checkSchemaPrivilege(db, checks.Schema, privilege))
+	}
+	if checks.MinServerVersion > 0 {
+		report.Results = append(report.Results, checkServerVersion(db, checks.MinServerVersion))
+	}
+	if checks.TimeZone != "" {
+		report.Results = append(report.Results, checkSetting(db, "TimeZone", checks.TimeZone))
+	}
+	if checks.Encoding != "" {
+		report.Results = append(report.Results, checkSetting(db, "server_encoding", checks.Encoding))
+	}
+
+	return report
+}
+
+// runConfiguredStartupChecks runs cfg.StartupChecks (if any) after connecting and logs the outcome.
+// It returns an error only when the checks fail and RefuseToStart is set.
+func runConfiguredStartupChecks(db *gorm.DB, cfg *PgConfig) error {
+	if cfg.StartupChecks == nil {
+		return nil
+	}
+	checks := *cfg.StartupChecks
+	if searchPath := cfg.searchPath(); checks.Schema == "" && len(searchPath) > 0 {
+		checks.Schema = searchPath[0]
+	}
+
+	report := RunStartupChecks(db, &checks)
+	err := report.Err()
+	if err == nil {
+		log.FromDefaultContext().Infof("Startup checks passed for postgres %s@%s", cfg.DBName, cfg.Host)
+		return nil
+	}
+	log.FromDefaultContext().Warnf("Startup checks failed for postgres %s@%s: %v", cfg.DBName, cfg.Host, err)
+	if checks.RefuseToStart {
+		return err
+	}
+	return nil
+}
+
+// checkExtensions verifies that every required extension is installed.
+func checkExtensions(db *gorm.DB, required []string) []StartupCheckResult {
+	installed := map[string]string{}
+	rows, err := db.Raw("SELECT extname, extversion FROM pg_extension").Rows()
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var name, version string
+			if err = rows.Scan(&name, &version); err != nil {
+				break
+			}
+			installed[name] = version
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+	}
+
+	results := make([]StartupCheckResult, 0, len(required))
+	for _, ext := range required {
+		result := StartupCheckResult{Name: "extension:" + ext, Expected: "installed", Err: err}
+		if err == nil {
+			if version, ok := installed[ext]; ok {
+				result.Actual = "installed " + version
+				result.Passed = true
+			} else {
+				result.Actual = "missing"
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkSchemaPrivilege verifies that the current role holds privilege on schema.
+func checkSchemaPrivilege(db *gorm.DB, schema, privilege string) StartupCheckResult {
+	result := StartupCheckResult{Name: fmt.Sprintf("privilege:%s:%s", schema, privilege), Expected: "granted"}
+	var granted bool
+	if result.Err = db.Raw("SELECT has_schema_privilege(current_user, ?, ?)", schema, privilege).Row().Scan(&granted); result.Err != nil {
+		return result
+	}
+	result.Passed = granted
+	result.Actual = "denied"
+	if granted {
+		result.Actual = "granted"
+	}
+	return result
+}
+
+// checkServerVersion verifies that server_version_num is at least minVersion.
+func checkServerVersion(db *gorm.DB, minVersion int) StartupCheckResult {
+	result := StartupCheckResult{Name: "server_version", Expected: fmt.Sprintf(">= %d", minVersion)}
+	var raw string
+	if result.Err = db.Raw("SHOW server_version_num").Row().Scan(&raw); result.Err != nil {
+		return result
+	}
+	result.Actual = raw
+	version, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Passed = version >= minVersion
+	return result
+}
+
+// checkSetting verifies that a server setting equals the expected value (case-insensitive).
+func checkSetting(db *gorm.DB, setting, expected string) StartupCheckResult {
+	result := StartupCheckResult{Name: "setting:" + setting, Expected: expected}
+	if result.Err = db.Raw("SHOW " + setting).Row().Scan(&result.Actual); result.Err != nil {
+		return result
+	}
+	result.Passed = strings.EqualFold(result.Actual, expected)
+	return result
+}
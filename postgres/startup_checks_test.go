@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test RunStartupChecks reports passing and failing checks
+func TestRunStartupChecks(t *testing.T) {
+	_, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT extname, extversion FROM pg_extension").
+		WillReturnRows(sqlmock.NewRows([]string{"extname", "extversion"}).AddRow("pgcrypto", "1.3"))
+	mock.ExpectQuery("SELECT has_schema_privilege(current_user, $1, $2)").WithArgs("public", "CREATE").
+		WillReturnRows(sqlmock.NewRows([]string{"has_schema_privilege"}).AddRow(false))
+	mock.ExpectQuery("SHOW server_version_num").
+		WillReturnRows(sqlmock.NewRows([]string{"server_version_num"}).AddRow("150002"))
+	mock.ExpectQuery("SHOW TimeZone").
+		WillReturnRows(sqlmock.NewRows([]string{"TimeZone"}).AddRow("UTC"))
+
+	report := RunStartupChecks(db, &StartupChecks{
+		RequiredExtensions: []string{"pgcrypto", "postgis"},
+		SchemaPrivileges:   []string{"CREATE"},
+		Schema:             "public",
+		MinServerVersion:   120000,
+		TimeZone:           "utc",
+	})
+
+	assert.False(t, report.Passed())
+	assert.Len(t, report.Results, 5)
+	failures := report.Failures()
+	assert.Len(t, failures, 2)
+	assert.Equal(t, "extension:postgis", failures[0].Name)
+	assert.Equal(t, "privilege:public:CREATE", failures[1].Name)
+	assert.True(t, errors.Is(report.Err(), ErrStartupChecksFailed))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that privileges are checked on the first schema of the search path, and fail without a schema
+func TestRunConfiguredStartupChecks_Schema(t *testing.T) {
+	_, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT has_schema_privilege(current_user, $1, $2)").WithArgs("tenant_x", "USAGE").
+		WillReturnRows(sqlmock.NewRows([]string{"has_schema_privilege"}).AddRow(true))
+
+	checks := &StartupChecks{SchemaPrivileges: []string{"USAGE"}, RefuseToStart: true}
+	assert.NoError(t, runConfiguredStartupChecks(db, &PgConfig{Schema: "tenant_x, public", StartupChecks: checks}))
+	err := runConfiguredStartupChecks(db, &PgConfig{StartupChecks: checks})
+	assert.ErrorIs(t, err, ErrStartupChecksFailed)
+	assert.ErrorContains(t, err, "privilege:USAGE: no schema to check")
+	report := RunStartupChecks(db, checks)
+	if assert.Len(t, report.Results, 1) {
+		assert.False(t, report.Results[0].Passed)
+		assert.ErrorIs(t, report.Results[0].Err, errNoCheckedSchema)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
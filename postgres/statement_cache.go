@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"container/list"
+	"context"
+	"database/sql/driver"
+	"github.com/jinzhu/gorm"
+	"sync/atomic"
+)
+
+type (
+	// StmtCacheStats reports the effectiveness of the prepared statement cache of a pool, see
+	// PgConfig.StatementCacheSize.
+	StmtCacheStats struct {
+		Hits      uint64 // Hits counts statements executed with an already prepared statement.
+		Misses    uint64 // Misses counts statements that had to be prepared.
+		Evictions uint64 // Evictions counts prepared statements closed to make room for others.
+	}
+
+	// stmtCacheCounters are the counters shared by the connections of a pool.
+	stmtCacheCounters struct {
+		hits, misses, evictions atomic.Uint64
+	}
+
+	// stmtCacheConnector wraps the connections of another connector with a prepared statement cache.
+	stmtCacheConnector struct {
+		next     driver.Connector   // Connector opening the physical connections.
+		size     int                // Maximum number of prepared statements per connection.
+		counters *stmtCacheCounters // Counters of the pool.
+	}
+
+	// stmtCacheDriver is the driver of a stmtCacheConnector, giving access to its counters.
+	stmtCacheDriver struct {
+		driver.Driver                    // Driver of the wrapped connector.
+		counters      *stmtCacheCounters // Counters of the pool.
+	}
+
+	// stmtCacheConn executes the statements with arguments through prepared statements cached by SQL
+	// text, least recently used ones being closed once there are more than size. Statements without
+	// arguments use the simple query protocol, so they may contain several commands.
+	stmtCacheConn struct {
+		forwardingConn                          // The physical connection; explicitly prepared statements are not cached.
+		size           int                      // Maximum number of prepared statements.
+		counters       *stmtCacheCounters       // Counters of the pool.
+		stmts          map[string]*list.Element // Cached statements by SQL text.
+		lru            *list.List               // Cached statements, most recently used first.
+	}
+
+	// cachedStmt is a prepared statement of a stmtCacheConn.
+	cachedStmt struct {
+		query string      // SQL text the statement was prepared for.
+		stmt  driver.Stmt // The prepared statement.
+	}
+)
+
+// HitRate returns the share of statements executed with an already prepared statement, 0 if none ran.
+func (s StmtCacheStats) HitRate() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// StmtCacheStatsOf returns the statistics of the prepared statement cache of db; the second result is
+// false if db has been opened without PgConfig.StatementCacheSize.
+// Example:
+//
+//	stats, _ := StmtCacheStatsOf(holder.DB())
+//	logger.Infof("statement cache hit rate: %.2f", stats.HitRate())
+func StmtCacheStatsOf(db *gorm.DB) (StmtCacheStats, bool) {
+	d, ok := db.DB().Driver().(stmtCacheDriver)
+	if !ok {
+		return StmtCacheStats{}, false
+	}
+	return StmtCacheStats{
+		Hits:      d.counters.hits.Load(),
+		Misses:    d.counters.misses.Load(),
+		Evictions: d.counters.evictions.Load(),
+	}, true
+}
+
+// StmtCacheStats returns the statistics of the prepared statement cache of the holder, see StmtCacheStatsOf.
+func (h *DatabaseHolder) StmtCacheStats() (StmtCacheStats, bool) {
+	db := h.currentConnection()
+	if db == nil {
+		return StmtCacheStats{}, false
+	}
+	return StmtCacheStatsOf(db)
+}
+
+// newStmtCacheConnector wraps the connections of next with a cache of up to size prepared statements.
+func newStmtCacheConnector(next driver.Connector, size int) *stmtCacheConnector {
+	return &stmtCacheConnector{next: next, size: size, counters: &stmtCacheCounters{}}
+}
+
+// Connect implements driver.Connector.
+func (c *stmtCacheConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.next.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &stmtCacheConn{forwardingConn: forwardingConn{conn}, size: c.size, counters: c.counters, stmts: map[string]*list.Element{}, lru: list.New()}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *stmtCacheConnector) Driver() driver.Driver {
+	return stmtCacheDriver{Driver: c.next.Driver(), counters: c.counters}
+}
+
+// unwrapDriver returns the driver a stmtCacheDriver wraps, or d itself.
+func unwrapDriver(d driver.Driver) driver.Driver {
+	if cache, ok := d.(stmtCacheDriver); ok {
+		return cache.Driver
+	}
+	return d
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *stmtCacheConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) == 0 {
+		return c.forwardingConn.ExecContext(ctx, query, args)
+	}
+	stmt, err := c.prepared(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	result, err := stmt.(driver.StmtExecContext).ExecContext(ctx, args)
+	c.evictIfInvalid(query, err)
+	return result, err
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *stmtCacheConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) == 0 {
+		return c.forwardingConn.QueryContext(ctx, query, args)
+	}
+	stmt, err := c.prepared(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.(driver.StmtQueryContext).QueryContext(ctx, args)
+	c.evictIfInvalid(query, err)
+	return rows, err
+}
+
+// Close closes the cached statements and the connection.
+func (c *stmtCacheConn) Close() error {
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		_ = element.Value.(*cachedStmt).stmt.Close()
+	}
+	c.stmts, c.lru = nil, list.New()
+	return c.Conn.Close()
+}
+
+// prepared returns the cached statement of query, preparing it on a miss.
+func (c *stmtCacheConn) prepared(ctx context.Context, query string) (driver.Stmt, error) {
+	if element, ok := c.stmts[query]; ok {
+		c.counters.hits.Add(1)
+		c.lru.MoveToFront(element)
+		return element.Value.(*cachedStmt).stmt, nil
+	}
+	c.counters.misses.Add(1)
+	stmt, err := c.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = c.lru.PushFront(&cachedStmt{query: query, stmt: stmt})
+	for c.lru.Len() > c.size {
+		c.evict(c.lru.Back())
+		c.counters.evictions.Add(1)
+	}
+	return stmt, nil
+}
+
+// evictIfInvalid drops the statement of query if err reports that it cannot be used anymore, e.g. after
+// the result type of a cached plan has changed with the schema.
+func (c *stmtCacheConn) evictIfInvalid(query string, err error) {
+	switch SQLState(err) {
+	case "0A000", "26000": // feature_not_supported (cached plan must not change result type), invalid_sql_statement_name
+		if element, ok := c.stmts[query]; ok {
+			c.evict(element)
+		}
+	}
+}
+
+// evict closes and forgets a cached statement.
+func (c *stmtCacheConn) evict(element *list.Element) {
+	cached := c.lru.Remove(element).(*cachedStmt)
+	delete(c.stmts, cached.query)
+	_ = cached.stmt.Close()
+}
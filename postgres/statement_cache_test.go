@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// dsnConnector opens connections of a driver by DSN.
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+// getTestStmtCacheDB opens a GORM connection caching up to size statements over sqlmock.
+func getTestStmtCacheDB(t *testing.T, dsn string, size int) (*gorm.DB, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = mockDB.Close() })
+	sqlDB := sql.OpenDB(newStmtCacheConnector(dsnConnector{driver: mockDB.Driver(), dsn: dsn}, size))
+	sqlDB.SetMaxOpenConns(1)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	return db, mock
+}
+
+// Test the statement cache to verify statements are prepared once per connection and evicted when full
+func TestStmtCache(t *testing.T) {
+	db, mock := getTestStmtCacheDB(t, "stmt_cache", 1)
+	defer db.Close()
+
+	update := mock.ExpectPrepare(`UPDATE accounts SET balance = $1`)
+	update.ExpectExec().WithArgs(10).WillReturnResult(sqlmock.NewResult(0, 1))
+	update.ExpectExec().WithArgs(20).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectPrepare(`SELECT balance FROM accounts WHERE id = $1`)
+	update.WillBeClosed()
+	mock.ExpectQuery(`SELECT balance FROM accounts WHERE id = $1`).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(20))
+	mock.ExpectExec(`SET LOCAL lock_timeout = '1s'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, db.Exec(`UPDATE accounts SET balance = ?`, 10).Error)
+	assert.NoError(t, db.Exec(`UPDATE accounts SET balance = ?`, 20).Error)
+	var balance int
+	assert.NoError(t, db.Raw(`SELECT balance FROM accounts WHERE id = ?`, 1).Row().Scan(&balance))
+	assert.NoError(t, db.Exec(`SET LOCAL lock_timeout = '1s'`).Error)
+
+	assert.Equal(t, 20, balance)
+	stats, ok := StmtCacheStatsOf(db)
+	assert.True(t, ok)
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 2, Evictions: 1}, stats)
+	assert.InDelta(t, 1.0/3, stats.HitRate(), 0.001)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test the statement cache to verify statements invalidated by a schema change are prepared again
+func TestStmtCache_EvictsInvalidStatement(t *testing.T) {
+	db, mock := getTestStmtCacheDB(t, "stmt_cache_invalid", 10)
+	defer db.Close()
+
+	query := `SELECT * FROM accounts WHERE id = $1`
+	stale := mock.ExpectPrepare(query)
+	stale.ExpectQuery().WithArgs(1).WillReturnError(&pq.Error{Code: "0A000", Message: "cached plan must not change result type"})
+	stale.WillBeClosed()
+	mock.ExpectPrepare(query).ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	var id int
+	assert.Error(t, db.Raw(`SELECT * FROM accounts WHERE id = ?`, 1).Row().Scan(&id))
+	assert.NoError(t, db.Raw(`SELECT * FROM accounts WHERE id = ?`, 1).Row().Scan(&id))
+
+	assert.Equal(t, 1, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test StmtCacheStatsOf to verify pools without a statement cache report none
+func TestStmtCacheStatsOf_Disabled(t *testing.T) {
+	_, db, _ := getTestTransactionContext(t)
+	defer db.Close()
+
+	_, ok := StmtCacheStatsOf(db)
+	assert.False(t, ok)
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"time"
+)
+
+type (
+	// Statement describes a single SQL statement executed through GORM.
+	Statement struct {
+		SQL          string        // SQL is the statement text as sent to the database.
+		Vars         []interface{} // Vars holds the bound parameter values.
+		Duration     time.Duration // Duration is the time the statement took to execute.
+		RowsAffected int64         // RowsAffected is the number of rows reported by the database.
+		Source       string        // Source is the file:line of the caller that issued the statement.
+	}
+
+	// gormLogger is the logging interface expected by gorm.DB.SetLogger.
+	gormLogger interface {
+		Print(v ...interface{})
+	}
+
+	// statementLogger is a gorm logger that reports every executed statement to observers
+	// and forwards log lines to the wrapped logger.
+	statementLogger struct {
+		next       gormLogger        // next receives the log lines.
+		logQueries bool              // logQueries forwards "sql" lines to next; errors are always forwarded.
+		observers  []func(Statement) // observers are called for every executed statement.
+	}
+)
+
+// newStatementLogger creates a statementLogger forwarding to next.
+func newStatementLogger(next gormLogger, logQueries bool, observers ...func(Statement)) *statementLogger {
+	return &statementLogger{next: next, logQueries: logQueries, observers: observers}
+}
+
+// Print implements the gorm logger interface.
+// GORM reports statements as: "sql", source, duration, sql, vars, rowsAffected.
+func (l *statementLogger) Print(v ...interface{}) {
+	if stmt, ok := parseStatement(v); ok {
+		for _, observe := range l.observers {
+			observe(stmt)
+		}
+		if !l.logQueries {
+			return
+		}
+	}
+	l.next.Print(v...)
+}
+
+// parseStatement extracts a Statement from the values GORM passes to its logger.
+func parseStatement(v []interface{}) (stmt Statement, ok bool) {
+	if len(v) < 6 || v[0] != "sql" {
+		return
+	}
+	stmt.Source, _ = v[1].(string)
+	stmt.Duration, _ = v[2].(time.Duration)
+	stmt.SQL, _ = v[3].(string)
+	stmt.Vars, _ = v[4].([]interface{})
+	stmt.RowsAffected, _ = v[5].(int64)
+	return stmt, true
+}
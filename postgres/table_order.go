@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"github.com/jinzhu/gorm"
+	"sort"
+)
+
+// SortTablesByForeignKeys returns tables of schema sorted so that referenced tables come before the tables
+// referencing them, the order rows can be inserted in. Tables referencing each other in a cycle follow in
+// alphabetical order; their rows only load if the foreign keys are DEFERRABLE.
+// Example:
+//
+//	ordered, err := SortTablesByForeignKeys(db, "public", []string{"orders", "customers"}) // customers, orders
+func SortTablesByForeignKeys(db *gorm.DB, schema string, tables []string) ([]string, error) {
+	rows, err := db.Raw(`
+		SELECT child.relname, parent.relname
+		FROM pg_constraint c
+		JOIN pg_class child ON child.oid = c.conrelid
+		JOIN pg_class parent ON parent.oid = c.confrelid
+		JOIN pg_namespace n ON n.oid = child.relnamespace
+		WHERE c.contype = 'f' AND n.nspname = ?`, schema).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parents := map[string][]string{}
+	for rows.Next() {
+		var child, parent string
+		if err := rows.Scan(&child, &parent); err != nil {
+			return nil, err
+		}
+		if child != parent {
+			parents[child] = append(parents[child], parent)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sortByDependencies(tables, parents), nil
+}
+
+// sortByDependencies orders tables so that every table follows the tables it depends on.
+// Tables involved in a cycle are appended in alphabetical order once no further progress is possible.
+func sortByDependencies(tables []string, dependsOn map[string][]string) []string {
+	known := map[string]bool{}
+	for _, table := range tables {
+		known[table] = true
+	}
+
+	placed := map[string]bool{}
+	ordered := make([]string, 0, len(tables))
+	for len(ordered) < len(tables) {
+		progress := false
+		for _, table := range tables {
+			if placed[table] {
+				continue
+			}
+			ready := true
+			for _, parent := range dependsOn[table] {
+				if known[parent] && !placed[parent] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[table] = true
+				ordered = append(ordered, table)
+				progress = true
+			}
+		}
+		if !progress {
+			var rest []string
+			for _, table := range tables {
+				if !placed[table] {
+					rest = append(rest, table)
+				}
+			}
+			sort.Strings(rest)
+			return append(ordered, rest...)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,17 @@
+package postgres
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test sortByDependencies to verify referenced tables come first and cycles are appended by name
+func TestSortByDependencies(t *testing.T) {
+	ordered := sortByDependencies([]string{"order_items", "orders", "customers", "b", "a"}, map[string][]string{
+		"order_items": {"orders"},
+		"orders":      {"customers", "unknown"},
+		"a":           {"b"},
+		"b":           {"a"},
+	})
+	assert.Equal(t, []string{"customers", "orders", "order_items", "a", "b"}, ordered)
+}
@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/public-forge/go-logger"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTaskRunnerWorkers defines the number of workers when TaskRunnerConfig.Workers is not set.
+	defaultTaskRunnerWorkers = 4
+	// defaultTaskRunnerQueueSize defines the queue capacity when TaskRunnerConfig.QueueSize is not set.
+	defaultTaskRunnerQueueSize = 1024
+	// defaultTaskRunnerMaxAttempts defines how often a task is tried when TaskRunnerConfig.MaxAttempts is not set.
+	defaultTaskRunnerMaxAttempts = 3
+	// defaultTaskRunnerRetryBackoff defines the delay before the first retry when TaskRunnerConfig.RetryBackoff is not set.
+	defaultTaskRunnerRetryBackoff = 200 * time.Millisecond
+)
+
+// Errors reported to the dead-letter handler for tasks that were committed but could not be queued.
+var (
+	ErrTaskRunnerStopped = errors.New("task runner has been stopped") // ErrTaskRunnerStopped occurs when a task is committed after Stop.
+	ErrTaskQueueFull     = errors.New("task runner queue is full")    // ErrTaskQueueFull occurs when no queue slot is available.
+)
+
+// ErrNoTransactionContext is returned by TaskRunner.EnqueueAfterCommit for a context without a transaction context.
+var ErrNoTransactionContext = errors.New("no transaction context in context")
+
+type (
+	// Task is a unit of asynchronous follow-up work executed after a transaction commits.
+	Task func(ctx context.Context) error
+
+	// TaskRunnerConfig holds the settings of a TaskRunner. Zero values fall back to defaults.
+	TaskRunnerConfig struct {
+		Workers      int                          // Workers is the number of tasks executed concurrently.
+		QueueSize    int                          // QueueSize is the number of committed tasks that may wait for a worker.
+		MaxAttempts  int                          // MaxAttempts is the number of executions before a task is dead-lettered.
+		RetryBackoff time.Duration                // RetryBackoff is the delay before the first retry; it doubles on each attempt.
+		DeadLetter   func(name string, err error) // DeadLetter, when set, is called for tasks that could not be completed.
+		Logger       log.Logger                   // Logger used by the workers; defaults to the default logger.
+		Clock        Clock                        // Clock waits for the backoff between the attempts; defaults to SystemClock.
+	}
+
+	// TaskRunner executes tasks enqueued during a transaction once it has committed,
+	// using a bounded pool of workers. Tasks of rolled-back transactions never run.
+	TaskRunner struct {
+		config  TaskRunnerConfig   // Effective configuration.
+		queue   chan queuedTask    // Committed tasks waiting for a worker.
+		mu      sync.RWMutex       // Guards stopped against concurrent sends on queue.
+		stopped bool               // Set once Stop has been called.
+		wg      sync.WaitGroup     // Tracks running workers.
+		ctx     context.Context    // Context the workers wait for the backoff with; Stop cancels it.
+		cancel  context.CancelFunc // Cancels ctx.
+	}
+
+	// queuedTask is a committed task waiting for execution.
+	queuedTask struct {
+		name string          // Name used in logs and dead-letter reports.
+		ctx  context.Context // Context detached from the request's cancellation.
+		task Task            // The work itself.
+	}
+)
+
+// NewTaskRunner creates a TaskRunner and starts its workers.
+// Example:
+//
+//	runner := NewTaskRunner(TaskRunnerConfig{Workers: 8})
+//	defer runner.Stop(context.Background())
+func NewTaskRunner(config TaskRunnerConfig) *TaskRunner {
+	if config.Workers <= 0 {
+		config.Workers = defaultTaskRunnerWorkers
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultTaskRunnerQueueSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultTaskRunnerMaxAttempts
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultTaskRunnerRetryBackoff
+	}
+	if config.Logger == nil {
+		config.Logger = log.FromDefaultContext()
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+
+	r := &TaskRunner{config: config, queue: make(chan queuedTask, config.QueueSize)}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	for i := 0; i < config.Workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	return r
+}
+
+// EnqueueAfterCommit schedules task to run asynchronously once the transaction of the transaction context in
+// ctx commits. Without an active transaction the task is queued immediately; without a transaction context
+// it is not queued and ErrNoTransactionContext is returned.
+// Example:
+//
+//	err := runner.EnqueueAfterCommit(ctx, "reindex-order", func(ctx context.Context) error {
+//	  return search.IndexOrder(ctx, orderID)
+//	})
+func (r *TaskRunner) EnqueueAfterCommit(ctx context.Context, name string, task Task) error {
+	txContext, ok := ctx.Value(TransactionContextKey).(ITransactionContext)
+	if !ok {
+		return fmt.Errorf("%w: enqueue task %s", ErrNoTransactionContext, name)
+	}
+	taskCtx := context.WithoutCancel(ctx)
+	txContext.RegisterAfterCommit(func() {
+		r.submit(queuedTask{name: name, ctx: taskCtx, task: task})
+	})
+	return nil
+}
+
+// Stop stops accepting tasks and waits for queued tasks to finish or ctx to be done. Once Stop returns,
+// failing tasks are no longer retried after a backoff but dead-lettered.
+func (r *TaskRunner) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.stopped {
+		r.stopped = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	defer r.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// submit puts a committed task on the queue without blocking the committing goroutine.
+func (r *TaskRunner) submit(t queuedTask) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.stopped {
+		r.deadLetter(t.name, ErrTaskRunnerStopped)
+		return
+	}
+
+	select {
+	case r.queue <- t:
+	default:
+		r.deadLetter(t.name, ErrTaskQueueFull)
+	}
+}
+
+// work executes queued tasks until the queue is closed.
+func (r *TaskRunner) work() {
+	defer r.wg.Done()
+	for t := range r.queue {
+		r.execute(t)
+	}
+}
+
+// execute runs a task with retries and exponential backoff, dead-lettering it after the last attempt.
+func (r *TaskRunner) execute(t queuedTask) {
+	backoff := r.config.RetryBackoff
+	var err error
+	for attempt := 1; attempt <= r.config.MaxAttempts; attempt++ {
+		if err = r.run(t); err == nil {
+			return
+		}
+		r.config.Logger.Warnf("task %s failed (attempt %d of %d): %s", t.name, attempt, r.config.MaxAttempts, err)
+		if attempt < r.config.MaxAttempts {
+			if r.config.Clock.Sleep(r.ctx, backoff) != nil {
+				break // stopped
+			}
+			backoff *= 2
+		}
+	}
+	r.deadLetter(t.name, err)
+}
+
+// run executes a single attempt of a task, converting panics into errors.
+func (r *TaskRunner) run(t queuedTask) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("task panicked: %v", p)
+		}
+	}()
+	return t.task(t.ctx)
+}
+
+// deadLetter logs a task that could not be completed and passes it to the configured handler.
+func (r *TaskRunner) deadLetter(name string, err error) {
+	r.config.Logger.Errorf("task %s dead-lettered: %s", name, err)
+	if r.config.DeadLetter != nil {
+		r.config.DeadLetter(name, err)
+	}
+}
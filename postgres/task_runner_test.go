@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test that committed tasks run and tasks of rolled-back transactions do not
+func TestTaskRunner_RunsOnlyCommittedTasks(t *testing.T) {
+	runner := NewTaskRunner(TaskRunnerConfig{Workers: 2, RetryBackoff: time.Millisecond})
+	var executed int32
+	task := func(ctx context.Context) error {
+		atomic.AddInt32(&executed, 1)
+		return nil
+	}
+
+	committed, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, committed)
+	id, err := committed.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, runner.EnqueueAfterCommit(ctx, "committed", task))
+	assert.NoError(t, committed.Commit(id))
+
+	rolledBack, db2, mock2 := getTestTransactionContext(t)
+	defer db2.Close()
+	mock2.ExpectBegin()
+	mock2.ExpectRollback()
+	ctx = context.WithValue(context.Background(), TransactionContextKey, rolledBack)
+	_, err = rolledBack.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, runner.EnqueueAfterCommit(ctx, "rolled-back", task))
+	assert.NoError(t, rolledBack.Rollback())
+
+	assert.NoError(t, runner.Stop(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&executed))
+}
+
+// Test that failing tasks are retried and finally dead-lettered
+func TestTaskRunner_DeadLetter(t *testing.T) {
+	var deadLettered string
+	runner := NewTaskRunner(TaskRunnerConfig{
+		Workers:      1,
+		MaxAttempts:  2,
+		RetryBackoff: time.Millisecond,
+		DeadLetter:   func(name string, err error) { deadLettered = name },
+	})
+
+	var attempts int32
+	runner.submit(queuedTask{name: "flaky", ctx: context.Background(), task: func(ctx context.Context) error {
+		atomic.AddInt32(&attempts, 1)
+		panic("boom")
+	}})
+
+	assert.NoError(t, runner.Stop(context.Background()))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, "flaky", deadLettered)
+}
+
+// Test that EnqueueAfterCommit refuses a context without a transaction context
+func TestTaskRunner_NoTransactionContext(t *testing.T) {
+	runner := NewTaskRunner(TaskRunnerConfig{Workers: 1})
+	var executed int32
+	err := runner.EnqueueAfterCommit(context.Background(), "orphan", func(ctx context.Context) error {
+		atomic.AddInt32(&executed, 1)
+		return nil
+	})
+
+	assert.ErrorIs(t, err, ErrNoTransactionContext)
+	assert.NoError(t, runner.Stop(context.Background()))
+	assert.Zero(t, atomic.LoadInt32(&executed))
+}
+
+// Test that a task waiting for its retry backoff is dead-lettered once Stop gives up waiting
+func TestTaskRunner_StopInterruptsBackoff(t *testing.T) {
+	deadLettered := make(chan string, 1)
+	runner := NewTaskRunner(TaskRunnerConfig{
+		Workers:      1,
+		MaxAttempts:  2,
+		RetryBackoff: time.Hour,
+		DeadLetter:   func(name string, err error) { deadLettered <- name },
+	})
+	runner.submit(queuedTask{name: "flaky", ctx: context.Background(), task: func(ctx context.Context) error {
+		return errors.New("unavailable")
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, runner.Stop(ctx), context.DeadlineExceeded)
+	select {
+	case name := <-deadLettered:
+		assert.Equal(t, "flaky", name)
+	case <-time.After(time.Second):
+		t.Fatal("the task was not dead-lettered")
+	}
+}
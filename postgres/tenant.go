@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+)
+
+// tenantKey is the context key of the tenant stored by ContextWithTenant.
+type tenantKey struct{}
+
+// ContextWithTenant returns a copy of ctx carrying the identifier of the tenant the request acts for.
+// Example:
+//
+//	ctx = postgres.ContextWithTenant(r.Context(), r.Header.Get("X-Tenant"))
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant stored by ContextWithTenant. It is a ContextValueFunc.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// WithTenantSearchPath isolates schema-per-tenant applications: every transaction begun for a context
+// carrying a tenant sets its search_path, for that transaction only, to the schema returned by schema
+// for the tenant, or to the schema named after the tenant if schema is nil. Statements issued outside
+// a transaction use the default search_path; combine with WithAutoBegin to run all of them in one.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithTenantSearchPath(func(tenant string) string {
+//	  return "tenant_" + tenant
+//	}))
+func WithTenantSearchPath(schema func(tenant string) string) TransactionContextOption {
+	return withLocalSetting("search_path", func(ctx context.Context) (string, bool) {
+		tenant, ok := TenantFromContext(ctx)
+		if !ok {
+			return "", false
+		}
+		if schema != nil {
+			tenant = schema(tenant)
+		}
+		return quoteName(tenant), true
+	})
+}
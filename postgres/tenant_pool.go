@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	log "github.com/public-forge/go-logger"
+	"sync"
+	"time"
+)
+
+// defaultTenantPoolIdleTimeout defines how long a pool must be unused before eviction may close it when
+// TenantPoolConfig.IdleTimeout is not set.
+const defaultTenantPoolIdleTimeout = time.Minute
+
+// ErrNoTenant occurs when a TenantPoolManager is asked for the database of a context without tenant.
+var ErrNoTenant = errors.New("no tenant in context")
+
+type (
+	// TenantPoolConfig holds the settings of a TenantPoolManager.
+	TenantPoolConfig struct {
+		Config      func(tenant string) (*PgConfig, error) // Config returns the connection settings of the database of tenant.
+		MaxPools    int                                    // MaxPools caps the number of open pools; zero means no limit.
+		IdleTimeout time.Duration                          // IdleTimeout is how long a pool must be unused before eviction may close it; defaultTenantPoolIdleTimeout if zero.
+		Options     []TransactionContextOption             // Options applied to every transaction context of the manager.
+		Clock       Clock                                  // Clock tells how long the pools have been unused; defaults to SystemClock.
+	}
+
+	// TenantPoolManager serves database-per-tenant deployments: it keeps one DatabaseHolder per tenant,
+	// created lazily on first use and connecting on the first transaction, and closes the least recently
+	// used ones once more than MaxPools are open. A pool is only closed once it has neither been returned
+	// by Holder nor begun or ended a transaction for IdleTimeout, so holders handed out to running units of
+	// work are not closed under them.
+	TenantPoolManager struct {
+		config TenantPoolConfig // Settings of the manager.
+
+		mu      sync.Mutex               // Guards holders and lru.
+		holders map[string]*list.Element // Cached holders by tenant.
+		lru     *list.List               // Cached holders, most recently used first.
+	}
+
+	// tenantPool is a holder cached by a TenantPoolManager. It observes the transactions of the holder to
+	// tell how long the holder has been unused.
+	tenantPool struct {
+		tenant string          // Tenant the holder connects to.
+		holder *DatabaseHolder // The holder of the tenant's database.
+		clock  Clock           // Clock of the manager.
+
+		mu       sync.Mutex // Guards lastUsed.
+		lastUsed time.Time  // Last time the holder was returned by Holder or began or ended a transaction.
+	}
+)
+
+// NewTenantPoolManager creates a TenantPoolManager.
+// Example:
+//
+//	tenants := postgres.NewTenantPoolManager(postgres.TenantPoolConfig{
+//	  Config: func(tenant string) (*postgres.PgConfig, error) {
+//	    return &postgres.PgConfig{Host: "db-" + tenant, DBName: tenant, ...}, nil
+//	  },
+//	  MaxPools: 50,
+//	})
+//	ctx = postgres.ContextWithTenant(r.Context(), r.Header.Get("X-Tenant"))
+//	txContext, ctx := tenants.GetTransactionContext(ctx)
+func NewTenantPoolManager(config TenantPoolConfig) *TenantPoolManager {
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultTenantPoolIdleTimeout
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock{}
+	}
+	return &TenantPoolManager{config: config, holders: map[string]*list.Element{}, lru: list.New()}
+}
+
+// GetTransactionContext retrieves the transaction context from ctx or creates one for the database of the
+// tenant of ctx, see ContextWithTenant, like TransactionContextFactory.GetTransactionContext. Without a
+// tenant, or if its configuration cannot be resolved, the error is returned by Begin() and Provider() is nil.
+func (m *TenantPoolManager) GetTransactionContext(ctx context.Context, opts ...TransactionContextOption) (ITransactionContext, context.Context) {
+	if txContext, found := ctx.Value(TransactionContextKey).(ITransactionContext); found {
+		return txContext, ctx
+	}
+	holder, err := m.HolderFor(ctx)
+	if err != nil {
+		log.FromContext(ctx).Errorf("cannot resolve tenant database: %s", err)
+		holder = NewLazyDBHolder(func() (*gorm.DB, error) { return nil, err })
+	}
+	return NewTransactionContextFactory(holder, m.config.Options...).GetTransactionContext(ctx, opts...)
+}
+
+// RunInTransaction is the package-level RunInTransaction for a transaction context of the tenant of ctx.
+func (m *TenantPoolManager) RunInTransaction(ctx context.Context, fn TxFunc) error {
+	_, ctx = m.GetTransactionContext(ctx)
+	return RunInTransaction(ctx, fn)
+}
+
+// HolderFor returns the holder of the database of the tenant of ctx.
+func (m *TenantPoolManager) HolderFor(ctx context.Context) (*DatabaseHolder, error) {
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return m.Holder(tenant)
+}
+
+// Holder returns the holder of the database of tenant, creating it on first use; it connects on its
+// first transaction. The configuration of a new tenant is resolved without blocking the other tenants.
+func (m *TenantPoolManager) Holder(tenant string) (*DatabaseHolder, error) {
+	if holder, ok := m.cachedHolder(tenant); ok {
+		return holder, nil
+	}
+
+	config, err := m.config.Config(tenant)
+	if err != nil {
+		return nil, fmt.Errorf("database of tenant %s: %w", tenant, err)
+	}
+	lazy := *config
+	lazy.LazyConnect = true
+	pool := &tenantPool{tenant: tenant, holder: newDBHolderFromConfig(&lazy), clock: m.config.Clock}
+	pool.touch()
+	pool.holder.txObservers.add(pool)
+
+	m.mu.Lock()
+	if element, ok := m.holders[tenant]; ok {
+		// Created concurrently: the new holder has not connected yet, so it is dropped without closing.
+		m.lru.MoveToFront(element)
+		cached := element.Value.(*tenantPool)
+		cached.touch()
+		m.mu.Unlock()
+		return cached.holder, nil
+	}
+	m.holders[tenant] = m.lru.PushFront(pool)
+	evicted := m.evict()
+	m.mu.Unlock()
+
+	for _, pool := range evicted {
+		if err := pool.holder.Close(); err != nil {
+			log.FromDefaultContext().Warnf("closing the database of tenant %s: %s", pool.tenant, err)
+		}
+	}
+	return pool.holder, nil
+}
+
+// cachedHolder returns the cached holder of tenant, marking it as the most recently used one.
+func (m *TenantPoolManager) cachedHolder(tenant string) (*DatabaseHolder, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	element, ok := m.holders[tenant]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(element)
+	pool := element.Value.(*tenantPool)
+	pool.touch()
+	return pool.holder, true
+}
+
+// evict removes least recently used idle holders while more than MaxPools are open, keeping the most
+// recently used one, and returns them to be closed once m.mu is released. Removed holders reject new
+// transactions with ErrShuttingDown.
+func (m *TenantPoolManager) evict() []*tenantPool {
+	var evicted []*tenantPool
+	for element := m.lru.Back(); element != m.lru.Front() && m.config.MaxPools > 0 && m.lru.Len() > m.config.MaxPools; {
+		pool, previous := element.Value.(*tenantPool), element.Prev()
+		if pool.shutDownIfIdle(m.config.IdleTimeout) {
+			m.lru.Remove(element)
+			delete(m.holders, pool.tenant)
+			evicted = append(evicted, pool)
+		}
+		element = previous
+	}
+	return evicted
+}
+
+// touch records a use of the holder.
+func (p *tenantPool) touch() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastUsed = p.clock.Now()
+}
+
+// shutDownIfIdle rejects new transactions of the holder if it has been unused for idleTimeout and runs
+// no transaction, and reports whether it did.
+func (p *tenantPool) shutDownIfIdle(idleTimeout time.Duration) bool {
+	p.mu.Lock()
+	idle := p.clock.Now().Sub(p.lastUsed) >= idleTimeout
+	p.mu.Unlock()
+	if !idle || p.holder.TxStats().Active > 0 {
+		return false
+	}
+	p.holder.shuttingDown.Store(true)
+	return true
+}
+
+// txBegun implements txObserver.
+func (p *tenantPool) txBegun(TxInfo) {
+	p.touch()
+}
+
+// txEnded implements txObserver.
+func (p *tenantPool) txEnded(TxInfo, bool) {
+	p.touch()
+}
+
+// Close closes the holders of all tenants.
+func (m *TenantPoolManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs []error
+	for tenant, element := range m.holders {
+		holder := element.Value.(*tenantPool).holder
+		holder.shuttingDown.Store(true)
+		errs = append(errs, holder.Close())
+		delete(m.holders, tenant)
+	}
+	m.lru.Init()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// getTestTenantPoolManager returns a manager of lazily connecting pools, failing for the tenant "unknown".
+func getTestTenantPoolManager(maxPools int, clock Clock) *TenantPoolManager {
+	return NewTenantPoolManager(TenantPoolConfig{
+		Config: func(tenant string) (*PgConfig, error) {
+			if tenant == "unknown" {
+				return nil, errors.New("not provisioned")
+			}
+			return &PgConfig{Host: "db-" + tenant, DBName: tenant}, nil
+		},
+		MaxPools: maxPools,
+		Clock:    clock,
+	})
+}
+
+// Test Holder to verify holders are cached per tenant and the least recently used idle ones are evicted
+func TestTenantPoolManager_Holder(t *testing.T) {
+	clock := &fakeClock{}
+	m := getTestTenantPoolManager(2, clock)
+	defer m.Close()
+
+	acme, err := m.Holder("acme")
+	assert.NoError(t, err)
+	globex, _ := m.Holder("globex")
+	again, _ := m.Holder("acme")
+	assert.Same(t, acme, again)
+
+	// The pools have just been handed out: none is closed although there are more than MaxPools
+	_, _ = m.Holder("initech")
+	assert.Len(t, m.holders, 3)
+
+	_ = clock.Sleep(context.Background(), defaultTenantPoolIdleTimeout)
+	_, _ = m.Holder("hooli")
+	assert.Len(t, m.holders, 2)
+	assert.Contains(t, m.holders, "hooli")
+	assert.Contains(t, m.holders, "initech")
+	_, err = newTransactionContext(log.FromDefaultContext(), globex).Begin()
+	assert.ErrorIs(t, err, ErrShuttingDown)
+	_, err = newTransactionContext(log.FromDefaultContext(), acme).Begin()
+	assert.ErrorIs(t, err, ErrShuttingDown)
+
+	_, err = m.Holder("unknown")
+	assert.ErrorContains(t, err, "not provisioned")
+}
+
+// Test Holder to verify a pool running a transaction is not evicted however long it has been idle
+func TestTenantPoolManager_HolderRunningTransaction(t *testing.T) {
+	clock := &fakeClock{}
+	m := getTestTenantPoolManager(1, clock)
+	defer m.Close()
+
+	acme, _ := m.Holder("acme")
+	acme.activeTransactions.txBegun(TxInfo{UUID: uuid.New()})
+	_ = clock.Sleep(context.Background(), time.Hour)
+
+	_, _ = m.Holder("globex")
+	assert.Contains(t, m.holders, "acme")
+	assert.False(t, acme.shuttingDown.Load())
+}
+
+// Test Holder to verify the configuration of a tenant is resolved without blocking the other tenants, and
+// concurrent first uses of a tenant share one holder
+func TestTenantPoolManager_HolderResolvesConfigOutsideLock(t *testing.T) {
+	resolving, release := make(chan struct{}), make(chan struct{})
+	m := NewTenantPoolManager(TenantPoolConfig{
+		Config: func(tenant string) (*PgConfig, error) {
+			if tenant == "slow" {
+				resolving <- struct{}{}
+				<-release
+			}
+			return &PgConfig{Host: "db-" + tenant, DBName: tenant}, nil
+		},
+	})
+	defer m.Close()
+
+	holders := make(chan *DatabaseHolder, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			holder, _ := m.Holder("slow")
+			holders <- holder
+		}()
+	}
+	<-resolving
+	<-resolving
+
+	_, err := m.Holder("acme")
+	assert.NoError(t, err)
+
+	close(release)
+	assert.Same(t, <-holders, <-holders)
+	assert.Len(t, m.holders, 2)
+}
+
+// Test GetTransactionContext to verify the tenant of the context selects the holder
+func TestTenantPoolManager_GetTransactionContext(t *testing.T) {
+	m := getTestTenantPoolManager(0, nil)
+	defer m.Close()
+
+	txContext, ctx := m.GetTransactionContext(ContextWithTenant(context.Background(), "acme"))
+	acme, _ := m.Holder("acme")
+	assert.Same(t, acme, txContext.(*transactionContext).dbHolder)
+	same, _ := m.GetTransactionContext(ctx)
+	assert.Same(t, txContext, same)
+
+	txContext, _ = m.GetTransactionContext(context.Background())
+	_, err := txContext.Begin()
+	assert.ErrorIs(t, err, ErrNoTenant)
+}
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that transactions of a tenant context run with the tenant's search_path
+func TestWithTenantSearchPath(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithTenantSearchPath(func(tenant string) string {
+		return "tenant_" + tenant
+	}))
+	tx.ctx = ContextWithTenant(context.Background(), "acme")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("search_path", `"tenant_acme"`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a failing setting aborts Begin without poisoning the transaction context
+func TestWithTenantSearchPath_Failure(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithTenantSearchPath(nil))
+	tx.ctx = ContextWithTenant(context.Background(), "acme")
+	failure := errors.New("invalid value for parameter")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT set_config($1, $2, true)`).WithArgs("search_path", `"acme"`).WillReturnError(failure)
+	mock.ExpectRollback()
+
+	_, err := tx.Begin()
+	assert.ErrorIs(t, err, failure)
+	assert.False(t, tx.inTransaction())
+	assert.False(t, tx.wasRollbacked())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"reflect"
+	"time"
+)
+
+// maxTimeZoneDepth limits how deep RegisterTimeZone descends into nested structs and associations.
+const maxTimeZoneDepth = 8
+
+// timeType is the reflected type of time.Time.
+var timeType = reflect.TypeOf(time.Time{})
+
+// RegisterTimeZone registers a callback converting the timestamps loaded by Find, First, Scan and the
+// other queries of db, including those of nested structs and preloaded associations, into location. The
+// drivers return timestamps in the session or process time zone, so without it services running in
+// different zones see the same instant differently. Row and Rows return the driver values unchanged.
+// It is applied automatically by Open when PgConfig.TimeZone is set, which also sets the session time zone.
+// Example:
+//
+//	berlin, _ := time.LoadLocation("Europe/Berlin")
+//	RegisterTimeZone(db, berlin)
+func RegisterTimeZone(db *gorm.DB, location *time.Location) {
+	db.Callback().Query().After("gorm:query").Register("uow:time_zone", func(scope *gorm.Scope) {
+		if !scope.HasError() {
+			convertTimes(reflect.ValueOf(scope.Value), location, 0)
+		}
+	})
+}
+
+// timeLocation returns the location of TimeZone, or nil if it is empty.
+func (c *PgConfig) timeLocation() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return nil, nil
+	}
+	location, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TimeZone %q: %w", c.TimeZone, err)
+	}
+	return location, nil
+}
+
+// convertTimes converts the settable time.Time values reachable from v into location.
+func convertTimes(v reflect.Value, location *time.Location, depth int) {
+	if depth > maxTimeZoneDepth {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			convertTimes(v.Elem(), location, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			convertTimes(v.Index(i), location, depth+1)
+		}
+	case reflect.Struct:
+		if v.Type() == timeType {
+			if t := v.Interface().(time.Time); v.CanSet() && !t.IsZero() {
+				v.Set(reflect.ValueOf(t.In(location)))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.CanSet() {
+				convertTimes(field, location, depth+1)
+			}
+		}
+	}
+}
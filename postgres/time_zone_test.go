@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// timeZoneEvent is a model with direct, optional and nested timestamps.
+type timeZoneEvent struct {
+	ID         int
+	OccurredAt time.Time
+	DeletedAt  *time.Time
+	Window     struct{ From time.Time } `gorm:"-"`
+}
+
+// Test RegisterTimeZone to verify loaded timestamps are converted into the configured location
+func TestRegisterTimeZone(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+	RegisterTimeZone(db, berlin)
+
+	occurred := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT \* FROM "time_zone_events"`).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "occurred_at", "deleted_at"}).
+			AddRow(1, occurred, occurred).
+			AddRow(2, time.Time{}, nil))
+
+	var events []timeZoneEvent
+	assert.NoError(t, db.Find(&events).Error)
+
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, berlin, events[0].OccurredAt.Location())
+		assert.True(t, occurred.Equal(events[0].OccurredAt))
+		assert.Equal(t, "13:00", events[0].OccurredAt.Format("15:04"))
+		assert.Equal(t, berlin, events[0].DeletedAt.Location())
+		assert.True(t, events[1].OccurredAt.IsZero())
+		assert.Nil(t, events[1].DeletedAt)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test the conversion to verify nested structs are converted as well
+func TestConvertTimes_Nested(t *testing.T) {
+	event := timeZoneEvent{Window: struct{ From time.Time }{From: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}}
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	convertTimes(reflect.ValueOf(&event), tokyo, 0)
+
+	assert.Equal(t, "21:00", event.Window.From.Format("15:04"))
+}
+
+// Test OpenContext to verify an unknown time zone fails without connecting
+func TestOpenContext_InvalidTimeZone(t *testing.T) {
+	db, err := OpenContext(context.Background(), &PgConfig{Host: "localhost", TimeZone: "Mars/Olympus_Mons"})
+
+	assert.ErrorContains(t, err, `invalid TimeZone "Mars/Olympus_Mons"`)
+	assert.Nil(t, db)
+}
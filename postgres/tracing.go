@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const (
+	// tracerName identifies the spans emitted by this package.
+	tracerName = "github.com/public-forge/go-gorm-unit-of-work/postgres"
+
+	// TransactionUUIDAttribute is the span attribute carrying the transaction UUID, as logged by the context.
+	TransactionUUIDAttribute = attribute.Key("uow.transaction.uuid")
+	// TransactionNameAttribute is the span attribute carrying TxOptions.Name of a named transaction.
+	TransactionNameAttribute = attribute.Key("uow.transaction.name")
+)
+
+// WithTracing emits OpenTelemetry spans for the transactions of the context: a "uow.transaction" span from
+// Begin() until the transaction is disposed, with "uow.begin", "uow.commit", "uow.rollback" and one
+// "uow.statement" child per SQL statement. Spans are parented to the trace of the context passed to
+// GetTransactionContext. A nil provider uses the global one.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(r.Context(), WithTracing(nil))
+func WithTracing(provider trace.TracerProvider) TransactionContextOption {
+	return func(c *transactionContext) {
+		if provider == nil {
+			provider = otel.GetTracerProvider()
+		}
+		c.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// startTxSpan starts the transaction span and the begin span of a new transaction.
+// The returned func ends the begin span, and the transaction span as well if err is set.
+func (c *transactionContext) startTxSpan() func(err error) {
+	if c.tracer == nil {
+		return func(error) {}
+	}
+	_, c.txSpan = c.tracer.Start(c.ctx, "uow.transaction",
+		trace.WithAttributes(TransactionUUIDAttribute.String(c.transactionUUID.String())),
+		trace.WithAttributes(c.datadogAttributes("uow.transaction", "")...))
+	if c.txOptions.Name != "" {
+		c.txSpan.SetAttributes(TransactionNameAttribute.String(c.txOptions.Name))
+	}
+	if c.txOptions.ReadOnly {
+		c.txSpan.SetAttributes(attribute.Bool("uow.transaction.read_only", true))
+	}
+	if c.txOptions.Isolation != 0 {
+		c.txSpan.SetAttributes(attribute.String("db.transaction.isolation", c.txOptions.Isolation.String()))
+	}
+	endBegin := c.childSpan("uow.begin")
+	return func(err error) {
+		endBegin(err)
+		if err != nil {
+			endSpan(c.txSpan, err)
+			c.txSpan = nil
+		}
+	}
+}
+
+// childSpan starts a child of the transaction span; the returned func ends it, recording err.
+func (c *transactionContext) childSpan(name string) func(err error) {
+	if c.txSpan == nil {
+		return func(error) {}
+	}
+	_, span := c.tracer.Start(trace.ContextWithSpan(c.ctx, c.txSpan), name,
+		trace.WithAttributes(TransactionUUIDAttribute.String(c.transactionUUID.String())),
+		trace.WithAttributes(c.datadogAttributes(name, "")...))
+	return func(err error) {
+		endSpan(span, err)
+	}
+}
+
+// statementTracer returns the observer recording the executed statements as children of the transaction
+// span. It does not reference the transaction context, as the logger of the transaction holds it, see txHandle.
+func (c *transactionContext) statementTracer() func(Statement) {
+	tracer, ctx, id, service := c.tracer, trace.ContextWithSpan(c.ctx, c.txSpan), c.transactionUUID.String(), c.datadogService
+	return func(stmt Statement) {
+		end := time.Now()
+		_, statementSpan := tracer.Start(ctx, "uow.statement",
+			trace.WithTimestamp(end.Add(-stmt.Duration)),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				TransactionUUIDAttribute.String(id),
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.statement", stmt.SQL),
+				attribute.Int64("db.rows_affected", stmt.RowsAffected),
+				attribute.String("code.source", stmt.Source),
+			),
+			trace.WithAttributes(datadogSpanAttributes(service, "", "uow.statement", stmt.SQL)...))
+		statementSpan.End(trace.WithTimestamp(end))
+	}
+}
+
+// endTxSpan ends the transaction span of the disposed transaction.
+func (c *transactionContext) endTxSpan() {
+	if c.txSpan == nil {
+		return
+	}
+	if c.rollbacked {
+		c.txSpan.SetAttributes(attribute.Bool("uow.transaction.rolled_back", true))
+	}
+	c.txSpan.End()
+	c.txSpan = nil
+}
+
+// endSpan ends span, marking it as failed if err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
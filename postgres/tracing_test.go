@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+// Test that a committed transaction emits its span with begin, statement and commit children
+func TestWithTracing_Commit(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	recorder := tracetest.NewSpanRecorder()
+	WithTracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "orders"`).WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Provider().Exec(`DELETE FROM "orders"`).Error)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+		assert.Contains(t, span.Attributes(), TransactionUUIDAttribute.String(id.String()))
+	}
+	assert.Equal(t, []string{"uow.begin", "uow.statement", "uow.commit", "uow.transaction"}, names)
+}
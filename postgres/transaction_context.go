@@ -2,10 +2,13 @@ package postgres
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
 	log "github.com/public-forge/go-logger"
+	"go.opentelemetry.io/otel/trace"
+	"time"
 )
 
 type contextKey string
@@ -13,53 +16,125 @@ type contextKey string
 // TransactionContextKey is used as the context key to store transaction contexts.
 const TransactionContextKey = contextKey("TransactionContextKey")
 
-// Important errors related to transaction handling.
+// transactionContextScopeKey holds the txHandle of a running transaction on its *gorm.DB, for callbacks.
+const transactionContextScopeKey = "uow:transaction_context"
+
+// Important errors related to transaction handling, shared with the other backends through the uow package.
 var (
-	ErrTxWasRollbacked  = errors.New("the transaction has been rollbacked")               // ErrTxWasRollbacked occurs when a rollback has already been performed.
-	ErrNotInTransaction = errors.New("not in a transaction, Begin() has not been called") // ErrNotInTransaction occurs when a transaction is expected but not started.
+	ErrTxWasRollbacked  = uow.ErrTxWasRollbacked  // ErrTxWasRollbacked occurs when a rollback has already been performed.
+	ErrNotInTransaction = uow.ErrNotInTransaction // ErrNotInTransaction occurs when a transaction is expected but not started.
+
+	ErrIncompatibleTxOptions = uow.ErrIncompatibleTxOptions // ErrIncompatibleTxOptions occurs when a nested Begin asks for stricter options.
+	ErrNotTransactionOwner   = uow.ErrNotTransactionOwner   // ErrNotTransactionOwner occurs in strict-commit mode when a non-owner commits.
 
-	DbConfig *PgConfig = nil // Global database configuration.
+	// DbConfig is the global database configuration used by GetTransactionContext.
+	//
+	// Deprecated: construct a TransactionContextFactory and inject it instead.
+	DbConfig *PgConfig = nil
 )
 
-//go:generate mockgen -source=transaction_context.go -destination=./mock_transaction_context.go -package=postgres
 type (
 	// ITransactionContext provides methods for handling transactions, including nested transactions.
-	//
-	// Begin() starts a new transaction and returns a UUID to identify it.
-	// Example:
-	//   txContext, _ := GetTransactionContext(ctx)
-	//   id, err := txContext.Begin()
-	//   if err != nil { return err }
-	//
-	// Commit() expects the transaction ID to confirm the transaction’s ownership.
-	//   err := txContext.Commit(id)
-	//   if err != nil { return err }
-	//
-	// Rollback() affects the transaction at any level and is recommended to handle any errors.
-	//   defer txContext.Rollback() // ensure rollback on any error
-	//
-	// Provider() returns the *gorm.DB instance, used for database operations within the transaction.
-	//   db := txContext.Provider()
-	//   db.Create(&modelInstance)
-	//
-	ITransactionContext interface {
-		Begin() (uuid.UUID, error) // Begins a transaction and returns its UUID.
-		Commit(uuid.UUID) error    // Commits the transaction if the caller holds the transaction UUID.
-		Rollback() error           // Rolls back the transaction.
-		Provider() *gorm.DB        // Returns the *gorm.DB instance for performing database operations.
-	}
+	// It is an alias of uow.ITransactionContext, see its documentation for usage.
+	ITransactionContext = uow.ITransactionContext
+
+	// TxOptions holds the isolation level and access mode a transaction is started with.
+	TxOptions = uow.TxOptions
 
 	// transactionContext contains transaction details and management logic.
 	transactionContext struct {
-		logger          log.Logger      // Logger for transaction activity.
-		dbHolder        *DatabaseHolder // Database holder providing the connection.
-		tx              *gorm.DB        // Database transaction instance.
-		transactionUUID *uuid.UUID      // Unique identifier for the transaction.
-		rollbacked      bool            // Indicates if the transaction has been rolled back.
+		ctx              context.Context // Context the transaction context was created for.
+		logger           log.Logger      // Logger for transaction activity.
+		dbHolder         *DatabaseHolder // Database holder providing the connection.
+		factoryKey       contextKey      // Key of the transaction contexts of the factory that created the context, if any.
+		tx               *gorm.DB        // Database transaction instance.
+		transactionUUID  *uuid.UUID      // Unique identifier for the transaction.
+		rollbacked       bool            // Indicates if the transaction has been rolled back.
+		txOptions        TxOptions       // Options the running transaction was started with.
+		handle           *txHandle       // State of the running transaction shared with its callbacks.
+		autoBegin        bool            // Begins a transaction on the first write through Provider().
+		autoTxUUID       *uuid.UUID      // Owner UUID of the implicitly begun transaction.
+		strictCommit     bool            // Makes Commit with a non-owner UUID return ErrNotTransactionOwner.
+		txNameComments   bool            // Tags the statements of named transactions with a SQL comment.
+		refCounted       bool            // Makes Begin and Commit nest by reference counting, see WithRefCountedNesting.
+		levels           []uuid.UUID     // UUIDs of the open Begins in reference-counted mode, outermost first.
+		rollbackOnly     bool            // Set by a nested Rollback in reference-counted mode.
+		rollbackOnCancel bool            // Rolls back the transaction when ctx is done.
+		commitFloor      time.Duration   // Minimum time left until the deadline for Commit to send COMMIT.
+		pprofLabels      bool            // Labels the goroutine of a unit of work with the transaction, see WithPprofLabels.
+		dryRun           *DryRunReport   // Collects statements when the context runs in dry-run mode.
+
+		txTimeout   *time.Duration     // Overrides the holder's transaction timeout when set.
+		txCtx       context.Context    // Context the running transaction was started with.
+		beginCtx    context.Context    // Context passed to BeginContext for the running transaction, if any.
+		cancelTx    context.CancelFunc // Releases txCtx once the transaction is disposed.
+		stopTxWatch func() bool        // Stops reporting the running transaction exceeding its timeout.
+
+		trackConsistency bool             // Captures a consistency token after every commit.
+		consistencyToken ConsistencyToken // Token captured after the last commit.
+
+		retryPolicy *RetryPolicy // Policy used by RunWithRetry for units of work started from this context.
+
+		replica      *DatabaseHolder // Replica serving the reads of ProviderReplica, see WithReplica.
+		primaryReads bool            // Set once a read-write transaction has been committed, keeping reads on the primary.
+
+		tracer         trace.Tracer // Emits transaction and statement spans when set.
+		datadogService string       // Datadog service of the spans, see WithDatadogTracing.
+		txSpan         trace.Span   // Span of the running transaction.
+
+		txInfo      TxInfo // Describes the running transaction to the holder's observers.
+		txCommitted bool   // Set once the running transaction has been committed.
+
+		leakDetection *leakDetection // Reports transactions that are not disposed in time when set.
+		leakWatch     *leakWatch     // Watches the running transaction for leaks.
+
+		beforeCommit  []func() error // Hooks executed inside the transaction before COMMIT is sent.
+		afterRollback []func()       // Hooks executed after the transaction has been rolled back.
+
+		localSettings    []localSetting    // Settings applied with SET LOCAL semantics when a transaction begins.
+		appliedVariables map[string]string // Settings set in the running transaction, by name.
+		savepoints       []savepoint       // Savepoints set in the running transaction, oldest first.
+
+		notifications []notification // Notifications sent right before COMMIT.
+		hooks         []Hook         // Plugins observing the operations of the context, see Hook.
+
+		logFields  []logField // Request-scoped fields added to the log lines of the context.
+		baseLogger log.Logger // Logger of the context without the tx_id of the running transaction.
+
+		explainThreshold time.Duration // Explains the statements of the transactions taking at least this long, see WithExplain.
 	}
+
+	// txHandle holds the state of a running transaction its GORM callbacks use; it is stored on the
+	// *gorm.DB of the transaction under transactionContextScopeKey. It must not reference the transaction
+	// context: the cycle through the *gorm.DB would keep the finalizer of WithLeakDetection from running.
+	txHandle struct {
+		ctx              context.Context                 // Context the transaction context was created for.
+		logger           log.Logger                      // Logger of the transaction.
+		logFields        []logField                      // Request-scoped fields of the log lines, see WithLogField.
+		explainThreshold time.Duration                   // Threshold of WithExplain.
+		afterCommit      []func()                        // Hooks executed after a successful commit.
+		changeSets       map[*changeCapture]*[]RowChange // Changes captured in the transaction, see RegisterChangeCapture.
+	}
+
+	// TransactionContextOption configures a transaction context when GetTransactionContext creates it.
+	// Options are ignored if the context already carries a transaction context.
+	TransactionContextOption func(*transactionContext)
 )
 
+// WithStrictCommit makes Commit return ErrNotTransactionOwner instead of nil when called with a UUID
+// other than the owner's, so inner scopes mistaking themselves for the owner are detected.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithStrictCommit())
+func WithStrictCommit() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.strictCommit = true
+	}
+}
+
 // GetTransactionContext retrieves or creates a transaction context and its associated context for use within functions.
+// New transaction contexts use the singleton holder configured by DbConfig; see TransactionContextFactory
+// for an injectable alternative.
 // Example:
 //
 //	func doSomething(ctx context.Context) {
@@ -70,8 +145,8 @@ type (
 //	  // ... perform operations ...
 //	  return txContext.Commit(id) // Commit if no errors
 //	}
-func GetTransactionContext(ctx context.Context) (ITransactionContext, context.Context) {
-	return getTransactionContextWithDBHolder(ctx)
+func GetTransactionContext(ctx context.Context, opts ...TransactionContextOption) (ITransactionContext, context.Context) {
+	return defaultFactory.GetTransactionContext(ctx, opts...)
 }
 
 // getTransactionContextWithDBHolder retrieves an existing transaction context from the provided context.
@@ -79,6 +154,9 @@ func GetTransactionContext(ctx context.Context) (ITransactionContext, context.Co
 //
 // Parameters:
 //   - ctx: The current context from which to retrieve or add a transaction context.
+//   - key: The context key the transaction context is stored under.
+//   - dbHolder: Resolves the database holder for a newly created transaction context.
+//   - opts: Options applied to a newly created transaction context.
 //
 // Returns:
 //   - ITransactionContext: An interface representing the transaction context for managing database transactions.
@@ -86,19 +164,28 @@ func GetTransactionContext(ctx context.Context) (ITransactionContext, context.Co
 //
 // The function checks if an `ITransactionContext` already exists in the provided context. If not, it creates a new
 // instance of `transactionContext`, stores it in a new context, and returns both.
-func getTransactionContextWithDBHolder(ctx context.Context) (ITransactionContext, context.Context) {
+func getTransactionContextWithDBHolder(
+	ctx context.Context, key contextKey, dbHolder func() *DatabaseHolder, opts ...TransactionContextOption,
+) (ITransactionContext, context.Context) {
 	// Check for the presence of an existing ITransactionContext in the context.
-	transactionContext, found := ctx.Value(TransactionContextKey).(ITransactionContext)
+	transactionContext, found := ctx.Value(key).(ITransactionContext)
 	if !found {
 		// If not found, create a new instance of transactionContext.
-		transactionContext := newTransactionContext(log.FromContext(ctx), NewDBHolderInstance(DbConfig))
-		newContext := context.WithValue(ctx, TransactionContextKey, transactionContext)
+		transactionContext := newTransactionContext(log.FromContext(ctx), dbHolder(), opts...)
+		transactionContext.ctx = ctx
+		transactionContext.logger = transactionContext.withLogFields(transactionContext.logger, ctx)
+		newContext := context.WithValue(ctx, key, transactionContext)
 		return transactionContext, newContext
 	}
 	// Return the existing ITransactionContext.
 	return transactionContext, ctx
 }
 
+// defaultDBHolder returns the singleton DatabaseHolder configured by DbConfig.
+func defaultDBHolder() *DatabaseHolder {
+	return NewDBHolderInstance(DbConfig)
+}
+
 // Begin starts a new transaction and returns its unique identifier.
 // Example:
 //
@@ -107,6 +194,38 @@ func getTransactionContextWithDBHolder(ctx context.Context) (ITransactionContext
 //	if err != nil { return err }
 //	defer txContext.Rollback()
 func (c *transactionContext) Begin() (id uuid.UUID, err error) {
+	return c.BeginWithOptions(TxOptions{})
+}
+
+// BeginWithOptions starts a new transaction with the given isolation level and access mode and returns
+// its unique identifier. A nested call joins the running transaction and fails with ErrIncompatibleTxOptions
+// if it asks for a stricter isolation level or for writes inside a read-only transaction.
+// Example:
+//
+//	id, err := txContext.BeginWithOptions(TxOptions{Isolation: sql.LevelSerializable})
+//	if err != nil { return err }
+//	defer txContext.Rollback()
+func (c *transactionContext) BeginWithOptions(opts TxOptions) (id uuid.UUID, err error) {
+	return c.begin(nil, opts)
+}
+
+// BeginContext starts a new transaction like Begin, but gives up with the error of ctx once ctx is done
+// before the transaction has begun, e.g. while waiting for a connection of an exhausted pool. In
+// rollback-on-cancel mode the transaction is bound to ctx instead of the context of GetTransactionContext,
+// and its deadline counts for WithCommitDeadlineFloor. A nested call joins the running transaction.
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+//	defer cancel()
+//	id, err := txContext.BeginContext(ctx)
+//	if err != nil { return err }
+//	defer txContext.Rollback()
+func (c *transactionContext) BeginContext(ctx context.Context) (uuid.UUID, error) {
+	return c.begin(ctx, TxOptions{})
+}
+
+// begin starts or joins a transaction with opts; a non-nil ctx bounds the start of a new transaction.
+func (c *transactionContext) begin(ctx context.Context, opts TxOptions) (id uuid.UUID, err error) {
 	if c.wasRollbacked() {
 		err = ErrTxWasRollbacked
 		return
@@ -118,22 +237,88 @@ func (c *transactionContext) Begin() (id uuid.UUID, err error) {
 	}
 
 	if !c.inTransaction() {
+		if c.dbHolder.shuttingDown.Load() {
+			err = ErrShuttingDown
+			return
+		}
+		if ctx != nil && ctx.Err() != nil {
+			err = fmt.Errorf("begin transaction %v: %w", id, ctx.Err())
+			return
+		}
+		var db *gorm.DB
+		if db, err = c.dbHolder.connection(); err != nil {
+			c.logger.Errorf("cannot connect to begin transaction (%v): %s", id, err)
+			err = fmt.Errorf("begin transaction %v: %w", id, err)
+			return
+		}
+		if ctx != nil {
+			opts = opts.WithDefaults(uow.TxOptionsFromContext(ctx))
+		}
+		opts = opts.WithDefaults(uow.TxOptionsFromContext(c.ctx))
 		c.transactionUUID = &id
-		c.tx = c.dbHolder.dbConnection.Begin()
+		c.txOptions = opts
+		c.beginCtx = ctx
+		c.txCtx, c.cancelTx = c.beginContext()
+		begun := func() error { return nil }
+		if ctx != nil {
+			begun = uow.UntilBegun(ctx, c.cancelTx)
+		}
+		endBegin := c.startTxSpan()
+		c.tx = db.BeginTx(c.txCtx, opts.SQLOptions())
+		err = c.tx.Error
+		if doneErr := begun(); doneErr != nil {
+			if err == nil {
+				_ = c.tx.Rollback()
+			}
+			err = doneErr
+		}
+		endBegin(err)
 
-		if err = c.tx.Error; err != nil {
-			c.logger.Errorf("cannot begin transaction (%v)", id)
+		if err != nil {
+			c.logger.Errorf("cannot begin transaction (%v): %s", id, err)
+			err = fmt.Errorf("begin transaction %v: %w", id, err)
+			c.releaseTxContext()
+			c.tx, c.transactionUUID = nil, nil
+			return
+		}
+		if err = c.setUp(id); err != nil {
 			return
 		}
-
-		c.logger.Debugf("new transaction: %v", c.transactionUUID)
 	} else {
+		if !opts.CompatibleWith(c.txOptions) {
+			err = ErrIncompatibleTxOptions
+			return
+		}
 		c.logger.Debugf("use existing transaction: %v", c.transactionUUID)
 	}
 
+	c.openLevel(id)
 	return
 }
 
+// setUp prepares c.tx, the begun transaction id, for the statements of the unit of work. If the local
+// settings cannot be applied, the transaction is rolled back and disposed of.
+func (c *transactionContext) setUp(id uuid.UUID) error {
+	c.useTxLogger()
+	c.handle = &txHandle{ctx: c.ctx, logger: c.logger, logFields: c.logFields, explainThreshold: c.explainThreshold}
+	c.notifyBegun()
+	c.watchForLeaks()
+	c.markReadOnly()
+	c.tx = c.withHooks(c.dbHolder.withLogMode(c.tx.Set(transactionContextScopeKey, c.handle)))
+	c.commentStatements()
+	c.observeStatements()
+	c.watchTxTimeout(c.txCtx)
+	if err := c.applyLocalSettings(); err != nil {
+		c.logger.Errorf("cannot apply local settings (%v): %s", id, err)
+		_ = c.tx.Rollback()
+		c.dispose()
+		return fmt.Errorf("begin transaction %v: apply local settings: %w", id, err)
+	}
+
+	c.logger.Debugf("new transaction: %v", c.transactionUUID)
+	return nil
+}
+
 // Provider returns the *gorm.DB instance for database operations within the transaction.
 // Example:
 //
@@ -150,6 +335,10 @@ func (c *transactionContext) Provider() *gorm.DB {
 		return c.tx
 	}
 
+	if c.autoBegin {
+		return c.providerWithAutoBegin()
+	}
+
 	return c.providerWithoutTransaction()
 }
 
@@ -167,21 +356,139 @@ func (c *transactionContext) Commit(id uuid.UUID) error {
 		return ErrNotInTransaction
 	}
 
+	if done, err := c.closeLevel(id); done {
+		return err
+	}
+
 	// Only the transaction owner can commit.
 	if *c.transactionUUID != id {
+		if c.strictCommit {
+			return ErrNotTransactionOwner
+		}
 		return nil
 	}
 
-	defer c.dispose()
+	if err := c.runBeforeCommit(); err != nil {
+		err = fmt.Errorf("commit transaction %v: before-commit hook: %w", id, err)
+		_ = c.Rollback()
+		return err
+	}
+	if err := c.flushNotifications(); err != nil {
+		err = fmt.Errorf("commit transaction %v: notify: %w", id, err)
+		_ = c.Rollback()
+		return err
+	}
 
-	if err := c.tx.Commit().Error; err != nil {
-		c.logger.Errorf("cannot commit transaction: %v; err: %s", c.transactionUUID, err)
+	if c.dryRun != nil {
+		defer c.dispose()
+		return c.rollbackDryRun()
+	}
+
+	if err := c.checkCommitDeadline(); err != nil {
+		c.logger.Warnf("not committing transaction %v: %s", c.transactionUUID, err)
+		_ = c.Rollback()
 		return err
 	}
 
+	afterCommit, readOnly := c.handle.afterCommit, c.txOptions.ReadOnly
+	if err := c.commit(); err != nil {
+		return err
+	}
+	c.captureConsistencyToken()
+	c.stickToPrimary(readOnly)
+	c.runHooks("after-commit", afterCommit)
+
 	return nil
 }
 
+// ProviderWithContext returns Provider() bound to ctx: operations started after ctx is done fail with
+// ctx.Err(), and statements are logged through the logger of ctx, so request-scoped fields are included.
+// GORM v1 does not pass contexts to the driver, so a statement already running is not interrupted.
+// Example:
+//
+//	db := txContext.ProviderWithContext(r.Context())
+//	db.Where("customer_id = ?", id).Find(&orders)
+func (c *transactionContext) ProviderWithContext(ctx context.Context) *gorm.DB {
+	db := c.Provider()
+	if db == nil {
+		return nil
+	}
+	db = uow.WithContext(db, ctx)
+	observers := c.statementObservers()
+	db.SetLogger(newStatementLogger(newRedactingLogger(c.withLogFields(log.FromContext(ctx), ctx), c.dbHolder.logRedactions), c.dbHolder.currentSettings().logMode, observers...))
+	if len(observers) > 0 {
+		db.LogMode(true)
+	}
+	return db
+}
+
+// RegisterAfterCommit registers fn to run once the current transaction has been committed.
+// Hooks run in registration order after the transaction is disposed, so they may start new transactions.
+// They are discarded on rollback. Without an active transaction fn runs immediately.
+// Example:
+//
+//	txContext.RegisterAfterCommit(func() { cache.Invalidate(orderID) })
+func (c *transactionContext) RegisterAfterCommit(fn func()) {
+	if !c.inTransaction() {
+		c.runHooks("after-commit", []func(){fn})
+		return
+	}
+	c.handle.RegisterAfterCommit(fn)
+}
+
+// RegisterAfterCommit registers fn to run once the transaction has been committed, for callbacks.
+func (h *txHandle) RegisterAfterCommit(fn func()) {
+	h.afterCommit = append(h.afterCommit, fn)
+}
+
+// Reset rolls back the running transaction, if any, and clears the rolled back state, so the context can
+// start new transactions, e.g. to write an audit record after a failed business transaction.
+// Example:
+//
+//	if err := placeOrder(ctx); err != nil {
+//	  _ = txContext.Reset()
+//	  _ = audit.RecordFailure(ctx, err)
+//	}
+func (c *transactionContext) Reset() error {
+	var err error
+	if !c.wasRollbacked() && c.inTransaction() {
+		c.levels = nil // roll back at once in reference-counted mode
+		err = c.Rollback()
+	}
+	c.rollbacked = false
+	return err
+}
+
+// RegisterBeforeCommit registers fn to run inside the current transaction right before COMMIT is sent
+// by the owner. Hooks run in registration order; the first error (or panic) rolls the transaction back
+// and is returned by Commit. Without an active transaction fn runs immediately and its error is logged.
+// Example:
+//
+//	txContext.RegisterBeforeCommit(func() error { return order.Validate(txContext.Provider()) })
+func (c *transactionContext) RegisterBeforeCommit(fn func() error) {
+	if !c.inTransaction() {
+		if err := uow.RunBeforeCommitHook(fn); err != nil {
+			c.logger.Errorf("before-commit hook failed: %s", err)
+		}
+		return
+	}
+	c.beforeCommit = append(c.beforeCommit, fn)
+}
+
+// RegisterAfterRollback registers fn to run once the current transaction has been rolled back, including
+// rollbacks caused by a failed commit. Hooks run in registration order after the transaction is disposed.
+// They are discarded on commit. Without an active transaction fn is discarded.
+// Example:
+//
+//	txContext.RegisterAfterRollback(func() { _ = os.Remove(tmpFile) })
+func (c *transactionContext) RegisterAfterRollback(fn func()) {
+	if !c.inTransaction() {
+		c.logger.Debug("no active transaction, discarding after-rollback hook")
+		return
+	}
+	c.afterRollback = append(c.afterRollback, fn)
+}
+
 // Rollback cancels the transaction and discards changes made within it.
 // Example:
 //
@@ -194,17 +501,55 @@ func (c *transactionContext) Rollback() error {
 		c.logger.Debug("no active transaction to roll back")
 		return nil
 	}
+	if c.rollbackLevel() {
+		return nil
+	}
 
 	defer c.disposeAfterRollback()
 
-	if err := c.tx.Rollback().Error; err != nil {
+	endRollback := c.childSpan("uow.rollback")
+	err := c.tx.Rollback().Error
+	endRollback(err)
+	if err != nil {
 		c.logger.Errorf("cannot rollback (%v): %s", c.transactionUUID, err)
-		return err
+		return fmt.Errorf("rollback transaction %v: %w", c.transactionUUID, err)
+	}
+
+	return nil
+}
+
+// commit sends COMMIT and disposes of the transaction regardless of the outcome.
+func (c *transactionContext) commit() error {
+	defer c.dispose()
+
+	endCommit := c.childSpan("uow.commit")
+	err := c.tx.Commit().Error
+	endCommit(err)
+	if err != nil {
+		c.logger.Errorf("cannot commit transaction: %v; err: %s", c.transactionUUID, err)
+		return fmt.Errorf("commit transaction %v: %w", c.transactionUUID, err)
 	}
+	c.txCommitted = true
 
 	return nil
 }
 
+// runHooks executes hooks in order, see uow.RunHooks.
+func (c *transactionContext) runHooks(kind string, hooks []func()) {
+	uow.RunHooks(c.logger, kind, hooks)
+}
+
+// runBeforeCommit executes the before-commit hooks in order, stopping at the first failure.
+// Hooks registered by a running hook are executed in the same pass.
+func (c *transactionContext) runBeforeCommit() error {
+	for i := 0; i < len(c.beforeCommit); i++ {
+		if err := uow.RunBeforeCommitHook(c.beforeCommit[i]); err != nil {
+			return err
+		}
+	}
+	return c.runBeforeCommitHooks()
+}
+
 // inTransaction checks if a transaction is currently active.
 func (c *transactionContext) inTransaction() bool {
 	return c.tx != nil && c.transactionUUID != nil
@@ -213,8 +558,28 @@ func (c *transactionContext) inTransaction() bool {
 // dispose clears transaction data after a successful commit or rollback.
 func (c *transactionContext) dispose() {
 	c.logger.Debugf("disposing transaction (%v)", c.transactionUUID)
+	afterRollback, committed, info := c.afterRollback, c.txCommitted, c.txInfo
+	c.releaseTxContext()
+	c.endTxSpan()
+	c.stopLeakWatch()
+	c.notifyEnded()
 	c.tx = nil
 	c.transactionUUID = nil
+	c.handle = nil
+	c.autoTxUUID = nil
+	c.beforeCommit = nil
+	c.afterRollback = nil
+	c.txCommitted = false
+	c.appliedVariables = nil
+	c.savepoints = nil
+	c.levels = nil
+	c.rollbackOnly = false
+	c.notifications = nil
+	c.restoreLogger()
+	if !committed {
+		c.runHooks("after-rollback", afterRollback)
+		c.runAfterRollbackHooks(info)
+	}
 }
 
 // disposeAfterRollback marks the transaction as rolled back and disposes of it.
@@ -223,19 +588,64 @@ func (c *transactionContext) disposeAfterRollback() {
 	c.dispose()
 }
 
-// wasRollbacked returns true if the transaction has already been rolled back.
+// wasRollbacked returns true if the transaction has already been rolled back,
+// including by the cancellation of the bound context.
 func (c *transactionContext) wasRollbacked() bool {
+	c.rollbackIfCancelled()
 	return c.rollbacked
 }
 
-// providerWithoutTransaction returns the dbConnection without starting a new transaction.
+// observeStatements routes the statements of the current transaction through a statementLogger
+// when the context has statement observers (e.g., dry-run mode or tracing).
+func (c *transactionContext) observeStatements() {
+	observers := c.statementObservers()
+	if len(observers) == 0 {
+		return
+	}
+	c.tx.SetLogger(newStatementLogger(newRedactingLogger(c.logger, c.dbHolder.logRedactions), c.dbHolder.currentSettings().logMode, observers...))
+	c.tx.LogMode(true)
+}
+
+// statementObservers returns the observers of the statements of the current transaction.
+func (c *transactionContext) statementObservers() []func(Statement) {
+	var observers []func(Statement)
+	if c.dryRun != nil {
+		observers = append(observers, c.dryRun.record)
+	}
+	if c.txSpan != nil {
+		observers = append(observers, c.statementTracer())
+	}
+	return observers
+}
+
+// rollbackDryRun rolls back a dry-run transaction in place of committing it.
+func (c *transactionContext) rollbackDryRun() error {
+	if err := c.tx.Rollback().Error; err != nil {
+		c.logger.Errorf("cannot rollback dry-run transaction: %v; err: %s", c.transactionUUID, err)
+		return fmt.Errorf("rollback dry-run transaction %v: %w", c.transactionUUID, err)
+	}
+	c.logger.Infof("dry-run transaction rolled back: %v", c.transactionUUID)
+	return nil
+}
+
+// providerWithoutTransaction returns the dbConnection without starting a new transaction, or nil if a lazy
+// holder cannot connect.
 func (c *transactionContext) providerWithoutTransaction() *gorm.DB {
-	return c.dbHolder.dbConnection
+	db, err := c.dbHolder.connection()
+	if err != nil {
+		c.logger.Errorf("cannot connect: %s", err)
+		return nil
+	}
+	return c.withHooks(c.dbHolder.withLogMode(db))
 }
 
-// newTransactionContext creates a new instance of transactionContext with the given logger and dbHolder.
-func newTransactionContext(logger log.Logger, dbHolder *DatabaseHolder) *transactionContext {
-	return &transactionContext{logger: logger, dbHolder: dbHolder}
+// newTransactionContext creates a new instance of transactionContext with the given logger, dbHolder and options.
+func newTransactionContext(logger log.Logger, dbHolder *DatabaseHolder, opts ...TransactionContextOption) *transactionContext {
+	c := &transactionContext{ctx: context.Background(), logger: logger, dbHolder: dbHolder}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Interface compliance check
@@ -0,0 +1,356 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// getTestTransactionContext builds a transaction context backed by go-sqlmock.
+func getTestTransactionContext(t *testing.T) (*transactionContext, *gorm.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open("postgres", db)
+	assert.NoError(t, err)
+
+	return newTransactionContext(log.FromDefaultContext(), NewDBHolder(gormDB)), gormDB, mock
+}
+
+// Test Begin and Commit by the transaction owner
+func TestTransactionContext_BeginCommit(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.True(t, tx.inTransaction())
+	assert.NoError(t, tx.Commit(id))
+	assert.False(t, tx.inTransaction())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a nested Begin reuses the transaction and only the owner commits
+func TestTransactionContext_NestedCommit(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	outer, err := tx.Begin()
+	assert.NoError(t, err)
+	inner, err := tx.Begin()
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Commit(inner))
+	assert.True(t, tx.inTransaction())
+	assert.NoError(t, tx.Commit(outer))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that the context is poisoned after a rollback
+func TestTransactionContext_Rollback(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+	assert.ErrorIs(t, tx.Commit(id), ErrTxWasRollbacked)
+	assert.Nil(t, tx.Provider())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that auto-begin mode starts a transaction on the first write and Complete commits it
+func TestTransactionContext_AutoBegin(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithAutoBegin())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "leaked_models" ("name") VALUES ($1) RETURNING "leaked_models"."id"`).
+		WithArgs("first").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`INSERT INTO "leaked_models" ("name") VALUES ($1) RETURNING "leaked_models"."id"`).
+		WithArgs("second").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	assert.NoError(t, tx.Provider().Create(&leakedModel{Name: "first"}).Error)
+	assert.True(t, tx.inTransaction())
+	assert.NoError(t, tx.Provider().Create(&leakedModel{Name: "second"}).Error)
+
+	inner, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(inner))
+	assert.True(t, tx.inTransaction())
+
+	assert.NoError(t, tx.Complete())
+	assert.False(t, tx.inTransaction())
+	assert.NoError(t, tx.Complete())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that reads in auto-begin mode run on the pool without beginning a transaction
+func TestTransactionContext_AutoBeginRead(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithAutoBegin())
+
+	mock.ExpectQuery(`SELECT * FROM "leaked_models"`).WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "read"))
+
+	var models []leakedModel
+	assert.NoError(t, tx.Provider().Find(&models).Error)
+	assert.Len(t, models, 1)
+	assert.False(t, tx.inTransaction())
+	assert.NoError(t, tx.Complete())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a *gorm.DB returned by Provider before the first write fails once the write began the transaction
+func TestTransactionContext_AutoBeginStaleProvider(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithAutoBegin())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "leaked_models" ("name") VALUES ($1) RETURNING "leaked_models"."id"`).
+		WithArgs("first").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	// GORM runs the rejected write of the stale *gorm.DB in a transaction of its own and rolls it back
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectRollback()
+
+	stale := tx.Provider()
+	assert.NoError(t, stale.Create(&leakedModel{Name: "first"}).Error)
+	var models []leakedModel
+	assert.ErrorIs(t, stale.Find(&models).Error, ErrStaleProvider)
+	assert.ErrorIs(t, stale.Create(&leakedModel{Name: "second"}).Error, ErrStaleProvider)
+	assert.NoError(t, tx.Rollback())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Provider in auto-begin mode with transaction options reports a failed Begin instead of panicking
+func TestTransactionContext_AutoBeginFailedBegin(t *testing.T) {
+	ctx := uow.WithTxOptions(context.Background(), TxOptions{ReadOnly: true})
+	refused := errors.New("connection refused")
+	tx := newTransactionContext(log.FromDefaultContext(), NewLazyDBHolder(func() (*gorm.DB, error) { return nil, refused }), WithAutoBegin())
+	tx.ctx = ctx
+
+	assert.NotPanics(t, func() { assert.Nil(t, tx.Provider()) })
+	assert.False(t, tx.inTransaction())
+
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx = newTransactionContext(base.logger, base.dbHolder, WithAutoBegin())
+	tx.ctx = ctx
+	mock.ExpectBegin().WillReturnError(refused)
+
+	assert.ErrorIs(t, tx.Provider().Error, refused)
+	assert.False(t, tx.inTransaction())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that cancelling the bound context moves the transaction context into the rolled back state
+func TestTransactionContext_RollbackOnCancel(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := newTransactionContext(base.logger, base.dbHolder, WithRollbackOnCancel())
+	tx.ctx = ctx
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	cancel()
+
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, tx.Commit(id), ErrTxWasRollbacked)
+	assert.False(t, tx.inTransaction())
+}
+
+// Test that a failing before-commit hook aborts the commit and runs the after-rollback hooks
+func TestTransactionContext_BeforeCommitAndAfterRollbackHooks(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	failure := errors.New("invalid order")
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var calls []string
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	tx.RegisterBeforeCommit(func() error { calls = append(calls, "before-commit"); return failure })
+	tx.RegisterAfterCommit(func() { calls = append(calls, "after-commit") })
+	tx.RegisterAfterRollback(func() { calls = append(calls, "after-rollback") })
+
+	assert.ErrorIs(t, tx.Commit(id), failure)
+	assert.True(t, tx.wasRollbacked())
+	assert.Equal(t, []string{"before-commit", "after-rollback"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a non-owner commit is reported in strict-commit mode
+func TestTransactionContext_StrictCommit(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithStrictCommit()(tx)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	innerID, err := tx.Begin()
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, tx.Commit(innerID), ErrNotTransactionOwner)
+	assert.True(t, tx.inTransaction())
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Reset makes a rolled back context usable again
+func TestTransactionContext_Reset(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+	_, err = tx.Begin()
+	assert.ErrorIs(t, err, ErrTxWasRollbacked)
+
+	assert.NoError(t, tx.Reset())
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that operations through ProviderWithContext fail once the context is cancelled
+func TestTransactionContext_ProviderWithContext(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	type order struct{ ID int }
+	err := tx.ProviderWithContext(ctx).First(&order{}).Error
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Begin and Commit wrap driver errors with the operation and UUID, and a failed Begin leaves no transaction
+func TestTransactionContext_WrappedErrors(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	failure := errors.New("connection reset")
+
+	mock.ExpectBegin().WillReturnError(failure)
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(failure)
+
+	id, err := tx.Begin()
+	assert.ErrorIs(t, err, failure)
+	assert.EqualError(t, err, "begin transaction "+id.String()+": connection reset")
+	assert.False(t, tx.inTransaction())
+
+	id, err = tx.Begin()
+	assert.NoError(t, err)
+	err = tx.Commit(id)
+	assert.ErrorIs(t, err, failure)
+	assert.EqualError(t, err, "commit transaction "+id.String()+": connection reset")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test BeginContext to verify it gives up once its context is done before the transaction has begun
+func TestTransactionContext_BeginContext(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := tx.BeginContext(cancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, tx.inTransaction())
+
+	mock.ExpectBegin().WillDelayFor(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = tx.BeginContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+	assert.False(t, tx.inTransaction())
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, err := tx.BeginContext(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, tx.inTransaction())
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test BeginContext in rollback-on-cancel mode to verify the transaction is bound to the context of BeginContext
+func TestTransactionContext_BeginContextRollbackOnCancel(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	tx := newTransactionContext(base.logger, base.dbHolder, WithRollbackOnCancel())
+	tx.ctx = context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	id, err := tx.BeginContext(ctx)
+	assert.NoError(t, err)
+	cancel()
+
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, 10*time.Millisecond)
+	assert.ErrorIs(t, tx.Commit(id), ErrTxWasRollbacked)
+}
+
+// Test uow.WithTxOptions to verify new transactions start with the options of the context, and joined ones keep theirs
+func TestTransactionContext_ContextTxOptions(t *testing.T) {
+	base, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := uow.WithTxOptions(context.Background(), TxOptions{Isolation: sql.LevelSerializable, Name: "reports"})
+	txContext, ctx := getTransactionContextWithDBHolder(ctx, TransactionContextKey, func() *DatabaseHolder { return base.dbHolder })
+	tx := txContext.(*transactionContext)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, err := tx.BeginWithOptions(TxOptions{Isolation: sql.LevelRepeatableRead})
+	assert.NoError(t, err)
+	assert.Equal(t, TxOptions{Isolation: sql.LevelRepeatableRead, Name: "reports"}, tx.txOptions)
+	_, err = tx.BeginContext(uow.WithTxOptions(ctx, TxOptions{Name: "nested"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "reports", tx.txOptions.Name)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"strings"
+)
+
+// statementOptionKeys are the GORM settings appended to the statements GORM generates.
+var statementOptionKeys = []string{"gorm:query_option", "gorm:insert_option", "gorm:update_option", "gorm:delete_option"}
+
+// WithTxNameComments appends the TxOptions.Name of named transactions as a SQL comment to the statements
+// GORM generates in them, e.g. SELECT ... /* tx:checkout.placeOrder */, so pg_stat_activity and the
+// server logs show which unit of work runs a statement. Raw statements, and statements of a *gorm.DB with
+// its own query, insert, update or delete option (such as ForUpdate), are not tagged.
+// Example:
+//
+//	txContext, ctx := GetTransactionContext(ctx, WithTxNameComments())
+//	err := RunInTransactionWithOptions(ctx, TxOptions{Name: "checkout.placeOrder"}, placeOrder)
+func WithTxNameComments() TransactionContextOption {
+	return func(c *transactionContext) {
+		c.txNameComments = true
+	}
+}
+
+// commentStatements sets the comment of WithTxNameComments on the running transaction.
+func (c *transactionContext) commentStatements() {
+	if !c.txNameComments || c.txOptions.Name == "" {
+		return
+	}
+	comment := txNameComment(c.txOptions.Name)
+	for _, key := range statementOptionKeys {
+		c.tx = c.tx.Set(key, comment)
+	}
+}
+
+// txNameComment returns the SQL comment tagging statements with name; comment delimiters in name are dropped.
+func txNameComment(name string) string {
+	name = strings.NewReplacer("/*", "", "*/", "").Replace(name)
+	return "/* tx:" + name + " */"
+}
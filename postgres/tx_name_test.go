@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test TxOptions.Name to verify named transactions are tagged in TxStats, metrics and statement comments
+func TestTxName(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	WithTxNameComments()(tx)
+	collector := NewCollector(tx.dbHolder, "test")
+
+	type order struct{ ID int }
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT * FROM "orders"   /* tx:checkout.placeOrder */`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE "orders" SET "id" = $1  /* tx:checkout.placeOrder */`).WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	id, err := tx.BeginWithOptions(TxOptions{Name: "checkout.placeOrder"})
+	assert.NoError(t, err)
+	inner, err := tx.BeginWithOptions(TxOptions{Name: "inventory.reserve"})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(inner))
+
+	stats := tx.dbHolder.TxStats()
+	assert.Len(t, stats.Transactions, 1)
+	assert.Equal(t, "checkout.placeOrder", stats.Transactions[0].Name)
+	var orders []order
+	assert.NoError(t, tx.Provider().Table("orders").Find(&orders).Error)
+	assert.NoError(t, tx.Provider().Table("orders").Updates(map[string]interface{}{"id": 2}).Error)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(collector.committed.WithLabelValues("checkout.placeOrder")))
+}
+
+// Test txNameComment to verify names cannot end the comment
+func TestTxNameComment(t *testing.T) {
+	assert.Equal(t, "/* tx:a  b */", txNameComment("a */ b"))
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+)
+
+type (
+	// TransactionStats is a snapshot of the transactions running on a database holder.
+	TransactionStats struct {
+		Active       int      // Active is the number of running transactions.
+		Transactions []TxInfo // Transactions lists the running transactions, oldest first.
+	}
+
+	// activeTransactions tracks the running transactions of a holder.
+	activeTransactions struct {
+		mu      sync.Mutex           // Guards running.
+		running map[uuid.UUID]TxInfo // Running transactions keyed by UUID.
+	}
+)
+
+// TxStats returns the transactions running on the holder configured by DbConfig.
+// Example:
+//
+//	http.HandleFunc("/admin/transactions", func(w http.ResponseWriter, r *http.Request) {
+//	  _ = json.NewEncoder(w).Encode(postgres.TxStats())
+//	})
+func TxStats() TransactionStats {
+	return defaultDBHolder().TxStats()
+}
+
+// TxStats returns the transactions running on the holder.
+func (h *DatabaseHolder) TxStats() TransactionStats {
+	return h.activeTransactions.stats()
+}
+
+// TxStats returns the transactions running on the factory's holder.
+func (f *TransactionContextFactory) TxStats() TransactionStats {
+	return f.DBHolder().TxStats()
+}
+
+// txBegun implements txObserver.
+func (a *activeTransactions) txBegun(tx TxInfo) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.running == nil {
+		a.running = map[uuid.UUID]TxInfo{}
+	}
+	a.running[tx.UUID] = tx
+}
+
+// txEnded implements txObserver.
+func (a *activeTransactions) txEnded(tx TxInfo, _ bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.running, tx.UUID)
+}
+
+// stats returns a snapshot of the running transactions.
+func (a *activeTransactions) stats() TransactionStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := TransactionStats{Active: len(a.running), Transactions: make([]TxInfo, 0, len(a.running))}
+	for _, tx := range a.running {
+		stats.Transactions = append(stats.Transactions, tx)
+	}
+	sort.Slice(stats.Transactions, func(i, j int) bool {
+		return stats.Transactions[i].Start.Before(stats.Transactions[j].Start)
+	})
+	return stats
+}
@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test that TxStats reports running transactions with their caller until they end
+func TestDatabaseHolder_TxStats(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+
+	stats := tx.dbHolder.TxStats()
+	assert.Equal(t, 1, stats.Active)
+	assert.Equal(t, id, stats.Transactions[0].UUID)
+	assert.Contains(t, stats.Transactions[0].Caller, "tx_stats_test.go")
+	assert.False(t, stats.Transactions[0].Start.IsZero())
+
+	assert.NoError(t, tx.Commit(id))
+	assert.Equal(t, 0, tx.dbHolder.TxStats().Active)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
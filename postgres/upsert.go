@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jinzhu/gorm"
+	"strings"
+)
+
+// conflictTag is the value of the uow struct tag marking the conflict target columns of Upsert.
+const conflictTag = "conflict"
+
+// UpsertOption configures the ON CONFLICT clause built by Upsert.
+type UpsertOption func(*upsertConfig)
+
+// upsertConfig collects the UpsertOption settings.
+type upsertConfig struct {
+	conflictColumns []string // Conflict target; empty uses the tagged columns or the primary key.
+	updateColumns   []string // Columns updated on conflict; empty updates all other columns.
+	doNothing       bool     // Whether conflicting rows are left unchanged.
+}
+
+// OnConflict sets the columns of the unique index or constraint the conflict is detected on,
+// overriding the columns tagged with `uow:"conflict"` and the primary key.
+func OnConflict(columns ...string) UpsertOption {
+	return func(c *upsertConfig) {
+		c.conflictColumns = columns
+	}
+}
+
+// DoUpdate limits the columns overwritten with the inserted values on conflict.
+func DoUpdate(columns ...string) UpsertOption {
+	return func(c *upsertConfig) {
+		c.updateColumns = columns
+	}
+}
+
+// DoNothing leaves conflicting rows unchanged; the primary key of value is not set for them.
+func DoNothing() UpsertOption {
+	return func(c *upsertConfig) {
+		c.doNothing = true
+	}
+}
+
+// Upsert inserts value through db, typically the Provider() of a transaction context, and resolves
+// conflicts with an ON CONFLICT clause. The conflict target is taken from OnConflict, else from the
+// columns tagged with `uow:"conflict"`, else from the primary key. On conflict all other columns but
+// created_at are updated, unless DoUpdate or DoNothing is passed.
+// Example:
+//
+//	type Customer struct {
+//	  ID    int
+//	  Email string `uow:"conflict"`
+//	  Name  string
+//	}
+//
+//	err := Upsert(txContext.Provider(), &customer).Error // ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name"
+//	err = Upsert(txContext.Provider(), &visit, OnConflict("day", "page"), DoUpdate("hits")).Error
+func Upsert(db *gorm.DB, value interface{}, opts ...UpsertOption) *gorm.DB {
+	config := upsertConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	result := db.Set("gorm:insert_option", onConflictClause(db.NewScope(value), config)).Create(value)
+	if config.doNothing && result.Error == sql.ErrNoRows {
+		// RETURNING yields no row when the insert was skipped.
+		result.Error = nil
+	}
+	return result
+}
+
+// onConflictClause builds the ON CONFLICT clause for the model of scope.
+func onConflictClause(scope *gorm.Scope, config upsertConfig) string {
+	conflictColumns := config.conflictColumns
+	if len(conflictColumns) == 0 {
+		conflictColumns = tagged(scope, conflictTag)
+	}
+	if len(conflictColumns) == 0 {
+		for _, field := range scope.PrimaryFields() {
+			conflictColumns = append(conflictColumns, field.DBName)
+		}
+	}
+
+	clause := "ON CONFLICT"
+	if len(conflictColumns) > 0 {
+		clause += " (" + quoteColumns(scope, conflictColumns) + ")"
+	}
+	if config.doNothing {
+		return clause + " DO NOTHING"
+	}
+
+	updateColumns := config.updateColumns
+	if len(updateColumns) == 0 {
+		updateColumns = otherColumns(scope, conflictColumns)
+	}
+	if len(updateColumns) == 0 {
+		return clause + " DO NOTHING"
+	}
+	assignments := make([]string, 0, len(updateColumns))
+	for _, column := range updateColumns {
+		quoted := scope.Quote(column)
+		assignments = append(assignments, quoted+" = EXCLUDED."+quoted)
+	}
+	return clause + " DO UPDATE SET " + strings.Join(assignments, ", ")
+}
+
+// tagged returns the columns of the model of scope whose uow tag is tag.
+func tagged(scope *gorm.Scope, tag string) []string {
+	var columns []string
+	for _, field := range scope.Fields() {
+		if field.Tag.Get("uow") == tag && field.IsNormal && !field.IsIgnored {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+// otherColumns returns the columns of the model of scope updated on conflict: all but the primary key,
+// the conflict target and created_at.
+func otherColumns(scope *gorm.Scope, conflictColumns []string) []string {
+	excluded := map[string]bool{"created_at": true}
+	for _, column := range conflictColumns {
+		excluded[column] = true
+	}
+	var columns []string
+	for _, field := range scope.Fields() {
+		if field.IsNormal && !field.IsIgnored && !field.IsPrimaryKey && !excluded[field.DBName] {
+			columns = append(columns, field.DBName)
+		}
+	}
+	return columns
+}
+
+// quoteColumns quotes and joins column names.
+func quoteColumns(scope *gorm.Scope, columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, scope.Quote(column))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Upsert inserts entity or resolves the conflict as configured by opts, see Upsert.
+func (r *Repository[T]) Upsert(ctx context.Context, entity *T, opts ...UpsertOption) error {
+	db := r.DB(ctx)
+	if db.Error != nil {
+		return db.Error
+	}
+	return Upsert(db, entity, opts...).Error
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type upsertCustomer struct {
+	ID    int
+	Email string `uow:"conflict"`
+	Name  string
+}
+
+// Test that Upsert builds the ON CONFLICT clause from the struct tags and the options
+func TestUpsert(t *testing.T) {
+	tx, db, mock := getTestTransactionContext(t)
+	defer db.Close()
+	ctx := context.WithValue(context.Background(), TransactionContextKey, tx)
+	customers := NewRepository[upsertCustomer](nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "upsert_customers" ("email","name") VALUES ($1,$2) ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name" RETURNING "upsert_customers"."id"`).
+		WithArgs("ann@example.com", "Ann").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery(`INSERT INTO "upsert_customers" ("email","name") VALUES ($1,$2) ON CONFLICT ("id") DO NOTHING RETURNING "upsert_customers"."id"`).
+		WithArgs("bob@example.com", "Bob").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+
+	ann := upsertCustomer{Email: "ann@example.com", Name: "Ann"}
+	assert.NoError(t, customers.Upsert(ctx, &ann))
+	assert.Equal(t, 3, ann.ID)
+
+	bob := upsertCustomer{Email: "bob@example.com", Name: "Bob"}
+	assert.NoError(t, customers.Upsert(ctx, &bob, OnConflict("id"), DoNothing()))
+	assert.Zero(t, bob.ID)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
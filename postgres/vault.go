@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultVaultMount is the path of the database secrets engine when VaultConfig.Mount is not set.
+const defaultVaultMount = "database"
+
+type (
+	// VaultConfig requests dynamic database credentials from the database secrets engine of HashiCorp
+	// Vault. New connections are opened with the credentials of the current lease; new credentials are
+	// requested once half of the lease has passed, and connections are not reused for longer than a
+	// third of the lease, so they are closed before Vault revokes their user.
+	VaultConfig struct {
+		Address    string       // Address of the Vault server, e.g. "https://vault:8200"; VAULT_ADDR if empty.
+		Token      string       // Token authenticating the requests; VAULT_TOKEN if empty.
+		Mount      string       // Mount is the path of the database secrets engine; "database" if empty.
+		Role       string       // Role whose credentials are requested, i.e. GET /v1/<Mount>/creds/<Role>.
+		HTTPClient *http.Client // HTTPClient sends the requests; http.DefaultClient if nil.
+	}
+
+	// vaultCredentials caches the credentials of the current lease.
+	vaultCredentials struct {
+		config VaultConfig // Settings of the secrets engine.
+
+		mu            sync.Mutex    // Guards the fields below.
+		user          string        // User of the current lease.
+		password      string        // Password of the current lease.
+		leaseDuration time.Duration // Duration of the current lease.
+		renewAt       time.Time     // Time new credentials are requested.
+	}
+
+	// vaultCredentialsResponse is the response of the creds endpoint.
+	vaultCredentialsResponse struct {
+		LeaseID       string `json:"lease_id"`
+		LeaseDuration int    `json:"lease_duration"` // in seconds
+		Data          struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+)
+
+// newVaultCredentials creates the credentials source of config, filling defaults from the environment.
+func newVaultCredentials(config VaultConfig) *vaultCredentials {
+	if config.Address == "" {
+		config.Address = os.Getenv("VAULT_ADDR")
+	}
+	if config.Token == "" {
+		config.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if config.Mount == "" {
+		config.Mount = defaultVaultMount
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &vaultCredentials{config: config}
+}
+
+// credentials implements credentialsSource.
+func (v *vaultCredentials) credentials(ctx context.Context) (string, string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.user == "" || !time.Now().Before(v.renewAt) {
+		if err := v.fetch(ctx); err != nil {
+			return "", "", err
+		}
+	}
+	return v.user, v.password, nil
+}
+
+// maxConnLifetime implements expiringCredentials.
+func (v *vaultCredentials) maxConnLifetime() time.Duration {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.leaseDuration / 3
+}
+
+// fetch requests new credentials from Vault.
+func (v *vaultCredentials) fetch(ctx context.Context) error {
+	url := fmt.Sprintf("%s/v1/%s/creds/%s", strings.TrimRight(v.config.Address, "/"), strings.Trim(v.config.Mount, "/"), v.config.Role)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("X-Vault-Token", v.config.Token)
+	response, err := v.config.HTTPClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("vault: %w", err)
+	}
+	defer response.Body.Close()
+
+	var body vaultCredentialsResponse
+	if err = json.NewDecoder(response.Body).Decode(&body); err != nil && response.StatusCode == http.StatusOK {
+		return fmt.Errorf("vault: invalid response: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault: %s: %s", response.Status, strings.Join(body.Errors, "; "))
+	}
+
+	now := time.Now()
+	v.user, v.password = body.Data.Username, body.Data.Password
+	v.leaseDuration = time.Duration(body.LeaseDuration) * time.Second
+	v.renewAt = now.Add(v.leaseDuration / 2)
+	return nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newVaultServer starts a Vault stub issuing a new user for every request, counted in requests.
+func newVaultServer(t *testing.T, leaseSeconds int, requests *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		assert.Equal(t, "/v1/database/creds/billing", r.URL.Path)
+		*requests++
+		_, _ = fmt.Fprintf(w, `{"lease_id":"database/creds/billing/%d","lease_duration":%d,"data":{"username":"v-billing-%d","password":"secret"}}`,
+			*requests, leaseSeconds, *requests)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test credentials of Vault to verify they are cached for half of the lease
+func TestVaultCredentials(t *testing.T) {
+	var requests int
+	server := newVaultServer(t, 3600, &requests)
+	source := newVaultCredentials(VaultConfig{Address: server.URL, Token: "s.token", Role: "billing"})
+
+	user, password, err := source.credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "v-billing-1", user)
+	assert.Equal(t, "secret", password)
+	assert.Equal(t, 20*time.Minute, source.maxConnLifetime())
+
+	user, _, _ = source.credentials(context.Background())
+	assert.Equal(t, "v-billing-1", user)
+	assert.Equal(t, 1, requests)
+
+	source.renewAt = time.Now().Add(-time.Second)
+	user, _, _ = source.credentials(context.Background())
+	assert.Equal(t, "v-billing-2", user)
+	assert.Equal(t, 2, requests)
+}
+
+// Test credentials of Vault to verify errors of Vault are returned
+func TestVaultCredentials_Error(t *testing.T) {
+	var requests int
+	server := newVaultServer(t, 3600, &requests)
+	source := newVaultCredentials(VaultConfig{Address: server.URL, Token: "s.expired", Role: "billing"})
+
+	_, _, err := source.credentials(context.Background())
+	assert.ErrorContains(t, err, "403 Forbidden: permission denied")
+	assert.Equal(t, 0, requests)
+}
+
+// Test the lifetime limit to verify connections are closed before their lease expires
+func TestLimitConnLifetime(t *testing.T) {
+	var requests int
+	server := newVaultServer(t, 300, &requests)
+	source := newVaultCredentials(VaultConfig{Address: server.URL, Token: "s.token", Role: "billing"})
+	_, _, err := source.credentials(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Duration(0), connLifetimeLimit(&PgConfig{ConnectionMaxLifetimeMS: 60000}, source))
+	assert.Equal(t, 100*time.Second, connLifetimeLimit(&PgConfig{ConnectionMaxLifetimeMS: 3600000}, source))
+	assert.Equal(t, 100*time.Second, connLifetimeLimit(&PgConfig{}, source))
+	assert.Equal(t, time.Duration(0), connLifetimeLimit(&PgConfig{}, nil))
+}
+
+// Test opening a connection with Vault to verify the credentials of the lease are used
+func TestOpenContext_Vault(t *testing.T) {
+	var requests int
+	server := newVaultServer(t, 3600, &requests)
+	for _, driver := range []string{DriverPQ, DriverPgx} {
+		db, err := OpenContext(context.Background(), &PgConfig{
+			Host:         "127.0.0.1",
+			Port:         1,
+			SSLMode:      "disable",
+			Driver:       driver,
+			Vault:        &VaultConfig{Address: server.URL, Token: "s.token", Role: "billing"},
+			ConnectRetry: ConnectRetryPolicy{MaxAttempts: 1},
+		})
+
+		assert.ErrorIs(t, err, ErrConnectFailed, driver)
+		assert.Nil(t, db)
+	}
+	assert.Equal(t, 2, requests)
+}
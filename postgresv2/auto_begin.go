@@ -0,0 +1,66 @@
+package postgresv2
+
+import "gorm.io/gorm"
+
+// autoBeginKey marks the *gorm.DB returned by Provider in auto-begin mode outside a transaction; it holds
+// the transaction context.
+const autoBeginKey = "uow:auto_begin"
+
+// registerAutoBegin registers the callbacks beginning the implicit transaction of auto-begin mode on the
+// first write, and moving the statements of the *gorm.DB marked by Provider into the running transaction.
+// Registering twice on the same connection is a no-op.
+func registerAutoBegin(db *gorm.DB) {
+	callbacks := db.Callback()
+	if callbacks.Create().Get("uow:auto_begin_create") != nil {
+		return
+	}
+	_ = callbacks.Create().Before("gorm:begin_transaction").Register("uow:auto_begin_create", autoBeginOnWrite)
+	_ = callbacks.Update().Before("gorm:begin_transaction").Register("uow:auto_begin_update", autoBeginOnWrite)
+	_ = callbacks.Delete().Before("gorm:begin_transaction").Register("uow:auto_begin_delete", autoBeginOnWrite)
+	_ = callbacks.Query().Before("gorm:query").Register("uow:auto_begin_query", joinAutoBegun)
+	_ = callbacks.Row().Before("gorm:row").Register("uow:auto_begin_row", joinAutoBegun)
+	_ = callbacks.Raw().Before("gorm:raw").Register("uow:auto_begin_raw", joinAutoBegun)
+}
+
+// autoBeginOnWrite begins the implicit transaction of the context of a marked *gorm.DB, unless it is
+// running, and sends the write through it. GORM then skips its own transaction for the write, as the
+// connection is already a transaction.
+func autoBeginOnWrite(db *gorm.DB) {
+	c, ok := autoBeginContext(db)
+	if !ok {
+		return
+	}
+	if !c.InTransaction() {
+		id, err := c.Begin()
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		c.BeganImplicitly(id)
+		c.Logger.Debugf("auto-began transaction on first write: %v", id)
+	}
+	db.Statement.ConnPool = c.tx.Statement.ConnPool
+}
+
+// joinAutoBegun sends the reads and raw statements of a marked *gorm.DB through the transaction of its
+// context once a write has begun it; before that they run on the pool.
+func joinAutoBegun(db *gorm.DB) {
+	if c, ok := autoBeginContext(db); ok && c.InTransaction() {
+		db.Statement.ConnPool = c.tx.Statement.ConnPool
+	}
+}
+
+// autoBeginContext returns the transaction context marking db, failing the statement if the context has
+// been rolled back.
+func autoBeginContext(db *gorm.DB) (*transactionContext, bool) {
+	value, ok := db.Get(autoBeginKey)
+	if !ok || db.Error != nil {
+		return nil, false
+	}
+	c := value.(*transactionContext)
+	if c.WasRollbacked() {
+		_ = db.AddError(ErrTxWasRollbacked)
+		return nil, false
+	}
+	return c, true
+}
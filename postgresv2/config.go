@@ -0,0 +1,88 @@
+// Package postgresv2 mirrors the postgres package on top of GORM v2 (gorm.io/gorm).
+//
+// The API is kept identical to the postgres package (PgConfig, Open, NewConnect, DatabaseHolder,
+// GetTransactionContext, ITransactionContext) so services can migrate by switching the import path,
+// as long as their PgConfig only uses the settings this package supports, see PgConfig.
+// Both packages can be used side by side while repositories are migrated one at a time; their
+// transaction contexts are stored under different context keys and do not share transactions.
+package postgresv2
+
+import (
+	"errors"
+	"fmt"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"strings"
+)
+
+// ErrUnsupportedConfig occurs when OpenContext is given a PgConfig using a setting of the postgres package
+// that this package does not implement, instead of silently ignoring it.
+var ErrUnsupportedConfig = errors.New("postgres settings not supported by postgresv2")
+
+// PgConfig holds the configuration settings required to connect to a PostgreSQL database.
+// It is shared with the postgres package so existing configuration can be reused. OpenContext honors the
+// connection settings sent in the DSN (Host and Port, DBName, Schema and SearchPath, User and Password,
+// the SSL settings, the timeouts, TargetSessionAttrs, ApplicationName, TimeZone and Params), LogMode,
+// RedactColumns, RedactPatterns, the pool settings and ConnectRetry; Driver and StatementCacheSize do not
+// apply to the pgx driver of GORM v2. Any other setting fails with ErrUnsupportedConfig.
+type PgConfig = postgres.PgConfig
+
+// ConnectionConfig is the connection configuration accepted by Open and NewConnect: a *PgConfig, or a
+// postgres:// URL as parsed by ParseConfigFromURL.
+type ConnectionConfig = postgres.ConnectionConfig
+
+// ParseConfigFromURL parses a postgres:// (or postgresql://) URL into a PgConfig, see postgres.ParseConfigFromURL.
+func ParseConfigFromURL(rawURL string) (*PgConfig, error) {
+	return postgres.ParseConfigFromURL(rawURL)
+}
+
+// resolveConfig returns the PgConfig of a ConnectionConfig, parsing URLs.
+func resolveConfig[C ConnectionConfig](config C) (*PgConfig, error) {
+	switch c := any(config).(type) {
+	case string:
+		return ParseConfigFromURL(c)
+	default:
+		return c.(*PgConfig), nil
+	}
+}
+
+// checkSupported returns an error matching ErrUnsupportedConfig naming the settings of cfg that OpenContext
+// would ignore.
+func checkSupported(cfg *PgConfig) error {
+	var unsupported []string
+	for _, setting := range []struct {
+		name string
+		set  bool
+	}{
+		{"SlowQueryMS", cfg.SlowQueryMS > 0},
+		{"CloudSQL", cfg.CloudSQL != nil},
+		{"Vault", cfg.Vault != nil},
+		{"Credentials", cfg.Credentials != nil},
+		{"QueryCache", cfg.QueryCache != nil},
+		{"Encryptor", cfg.Encryptor != nil},
+		{"EnsureSchema", cfg.EnsureSchema != nil},
+		{"StartupChecks", cfg.StartupChecks != nil},
+		{"TxTimeoutMS", cfg.TxTimeoutMS > 0},
+		{"LongTxWarningMS", cfg.LongTxWarningMS > 0},
+		{"LazyConnect", cfg.LazyConnect},
+		{"ReadOnly", cfg.ReadOnly},
+		{"RejectReadOnlyWrites", cfg.RejectReadOnlyWrites},
+		{"TranslateErrors", cfg.TranslateErrors},
+		{"OptimisticLocking", cfg.OptimisticLocking},
+		{"AuditFields", cfg.AuditFields},
+	} {
+		if setting.set {
+			unsupported = append(unsupported, setting.name)
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupportedConfig, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// ConnectRetryPolicy describes how often and how long Open and OpenContext keep trying to connect,
+// see postgres.ConnectRetryPolicy.
+type ConnectRetryPolicy = postgres.ConnectRetryPolicy
+
+// ErrConnectFailed occurs when OpenContext has used up all connection attempts of the ConnectRetryPolicy.
+var ErrConnectFailed = postgres.ErrConnectFailed
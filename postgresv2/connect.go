@@ -0,0 +1,194 @@
+package postgresv2
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	log "github.com/public-forge/go-logger"
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"regexp"
+	"time"
+)
+
+// NewConnect establishes a new connection to the PostgreSQL database using the provided configuration,
+// a *PgConfig or a postgres:// URL. It retries on failure and panics if connection attempts are exhausted.
+//
+// Deprecated: use OpenContext, which reports failures as errors instead of panicking.
+func NewConnect[C ConnectionConfig](config C) *gorm.DB {
+	logger := log.FromDefaultContext()
+	db, err := Open(config)
+	if err != nil {
+		logger.Infof("can't connect to db (connect error): %v", err)
+		panic(err)
+	}
+	return db
+}
+
+// CheckConnection executes a basic query to verify the database connection is still active.
+func CheckConnection(db *gorm.DB) {
+	db.Exec("SELECT 1;")
+}
+
+// Open attempts to open a database connection using the provided PgConfig settings, or the settings
+// parsed from a postgres:// URL by ParseConfigFromURL.
+// If the connection fails, it will retry according to PgConfig.ConnectRetry.
+// On success, it applies the SQL pool settings.
+// Once all attempts have failed it terminates the process with logger.Fatalf.
+// Example:
+//
+//	db, err := Open(os.Getenv("DATABASE_URL"))
+//
+// Deprecated: use OpenContext, which reports failures as errors instead of terminating the process.
+func Open[C ConnectionConfig](config C) (db *gorm.DB, err error) {
+	if db, err = OpenContext(context.Background(), config); errors.Is(err, ErrConnectFailed) {
+		log.FromDefaultContext().Fatalf("%s", err)
+	}
+	return
+}
+
+// OpenContext is Open stopping the retries, and returning the error of ctx, once ctx is done. It never
+// panics or terminates the process: once all attempts have failed it returns an error matching
+// ErrConnectFailed and wrapping the error of the last attempt. A PgConfig using settings this package does
+// not support fails with ErrUnsupportedConfig without connecting.
+func OpenContext[C ConnectionConfig](ctx context.Context, config C) (db *gorm.DB, err error) {
+	logger := log.FromDefaultContext()
+	cfg, err := resolveConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSupported(cfg); err != nil {
+		return nil, err
+	}
+	var patterns []*regexp.Regexp
+	for _, pattern := range cfg.RedactPatterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		patterns = append(patterns, compiled)
+	}
+	attempts := cfg.ConnectRetry.Attempts()
+	for retry := 0; retry < attempts; retry++ {
+		logger.Infof("Connecting to postgres %s@%s... (retry %d of %d)",
+			cfg.DBName, cfg.Host, retry, attempts)
+
+		db, err = gorm.Open(pgdriver.Open(cfg.DSN()), &gorm.Config{
+			Logger: newGORMLogger(logger, cfg.LogMode, cfg.RedactColumns, patterns),
+		})
+
+		// Log and retry on failure
+		if err != nil {
+			err = postgres.RedactError(err, cfg.Password)
+			logger.Errorf("Connecting to postgres %s@%s FAILED: %s",
+				cfg.DBName, cfg.Host, err)
+
+			if retry < attempts-1 {
+				if err = waitForRetry(ctx, cfg.ConnectRetry, retry); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		// Log on successful connection
+		logger.Infof("Successfully connected to postgres %s@%s", cfg.DBName, cfg.Host)
+
+		var sqlDB *sql.DB
+		if sqlDB, err = db.DB(); err != nil {
+			return nil, err
+		}
+		setSQLSettings(sqlDB, cfg)
+
+		return
+	}
+	return nil, fmt.Errorf("%w: %s@%s after %d attempts: %w", ErrConnectFailed, cfg.DBName, cfg.Host, attempts, err)
+}
+
+// newGORMLogger adapts the service logger to GORM v2; all statements are logged when logMode is set,
+// otherwise only errors are reported, matching the behaviour of GORM v1. The bind parameters of
+// redactColumns are masked, as well as the matches of redactPatterns in the statements and their string
+// parameters.
+func newGORMLogger(logger log.Logger, logMode bool, redactColumns []string, redactPatterns []*regexp.Regexp) gormlogger.Interface {
+	level := gormlogger.Error
+	if logMode {
+		level = gormlogger.Info
+	}
+	gormLogger := gormlogger.New(printfWriter{logger}, gormlogger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      level,
+	})
+	if len(redactColumns) == 0 && len(redactPatterns) == 0 {
+		return gormLogger
+	}
+	return redactingLogger{Interface: gormLogger, columns: redactColumns, patterns: redactPatterns}
+}
+
+// redactingLogger masks sensitive values before GORM v2 logs a statement.
+type redactingLogger struct {
+	gormlogger.Interface
+	columns  []string         // Columns, or table.column pairs, whose bind parameters are masked.
+	patterns []*regexp.Regexp // Patterns whose matches are masked in the statements and their string parameters.
+}
+
+// LogMode implements gormlogger.Interface.
+func (l redactingLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return redactingLogger{Interface: l.Interface.LogMode(level), columns: l.columns, patterns: l.patterns}
+}
+
+// ParamsFilter implements gorm.ParamsFilter.
+func (l redactingLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	params = postgres.RedactParams(sql, params, l.columns...)
+	if len(l.patterns) == 0 {
+		return sql, params
+	}
+	params = append([]interface{}(nil), params...)
+	for i, param := range params {
+		if s, ok := param.(string); ok {
+			params[i] = l.mask(s)
+		}
+	}
+	return l.mask(sql), params
+}
+
+// mask masks the matches of the patterns in s.
+func (l redactingLogger) mask(s string) string {
+	for _, pattern := range l.patterns {
+		s = pattern.ReplaceAllString(s, "[REDACTED]")
+	}
+	return s
+}
+
+// printfWriter exposes the service logger through the Printf interface expected by GORM v2.
+type printfWriter struct {
+	logger log.Logger // logger receives the formatted lines.
+}
+
+// Printf implements gormlogger.Writer.
+func (w printfWriter) Printf(format string, args ...interface{}) {
+	w.logger.Infof(format, args...)
+}
+
+// setSQLSettings applies SQL settings, including max open and idle connections and connection lifetimes.
+func setSQLSettings(db *sql.DB, pgConfig *PgConfig) {
+	db.SetMaxOpenConns(pgConfig.MaxOpenConnections)
+	db.SetConnMaxLifetime(time.Duration(pgConfig.ConnectionMaxLifetimeMS) * time.Millisecond)
+	if pgConfig.MaxIdleConnections != 0 {
+		db.SetMaxIdleConns(pgConfig.MaxIdleConnections)
+	}
+	db.SetConnMaxIdleTime(time.Duration(pgConfig.ConnectionMaxIdleTimeMS) * time.Millisecond)
+}
+
+// waitForRetry waits for the delay after the given failed attempt, or returns the error of ctx once it is done.
+func waitForRetry(ctx context.Context, policy ConnectRetryPolicy, attempt int) error {
+	timer := time.NewTimer(policy.Delay(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
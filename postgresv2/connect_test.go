@@ -0,0 +1,22 @@
+package postgresv2
+
+import (
+	"context"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test OpenContext to verify settings of the postgres package that GORM v2 does not implement are rejected
+func TestOpenContext_UnsupportedConfig(t *testing.T) {
+	db, err := OpenContext(context.Background(), &PgConfig{
+		Host:         "localhost",
+		ReadOnly:     true,
+		Encryptor:    postgres.NewAESGCMEncryptor(postgres.StaticKeys{}),
+		ConnectRetry: ConnectRetryPolicy{MaxAttempts: 1},
+	})
+
+	assert.ErrorIs(t, err, ErrUnsupportedConfig)
+	assert.EqualError(t, err, "postgres settings not supported by postgresv2: Encryptor, ReadOnly")
+	assert.Nil(t, db)
+}
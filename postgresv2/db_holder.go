@@ -0,0 +1,89 @@
+package postgresv2
+
+import (
+	"database/sql"
+	"gorm.io/gorm"
+	"sync"
+)
+
+// NewDBHolderInstance initializes and returns a singleton instance of DatabaseHolder.
+// It ensures that only one instance of DatabaseHolder is created, even in concurrent contexts.
+func NewDBHolderInstance(config *PgConfig) *DatabaseHolder {
+	holderMu.Lock()
+	defer holderMu.Unlock()
+	onceDBHolder.Do(func() {
+		connect := NewConnect(config)   // Establishes a new database connection.
+		dbHolder = NewDBHolder(connect) // Creates a new DatabaseHolder with the connection.
+	})
+
+	return dbHolder
+}
+
+var (
+	dbHolder     *DatabaseHolder // Singleton instance of DatabaseHolder
+	onceDBHolder sync.Once       // Ensures single initialization of dbHolder
+	holderMu     sync.Mutex      // Guards dbHolder and onceDBHolder against ResetDBHolderForTesting
+)
+
+// DatabaseHolder wraps a gorm.DB database connection, providing a centralized way to access it.
+type DatabaseHolder struct {
+	dbConnection *gorm.DB // Holds the actual database connection.
+}
+
+// NewDBHolder creates a new DatabaseHolder with the given gorm.DB connection.
+func NewDBHolder(db *gorm.DB) *DatabaseHolder {
+	if db != nil {
+		registerAutoBegin(db) // Begins the transactions of WithAutoBegin on the first write.
+	}
+	return &DatabaseHolder{dbConnection: db} // Initializes DatabaseHolder with the provided db connection.
+}
+
+// Close closes the database connection of the holder.
+func (h *DatabaseHolder) Close() error {
+	if h.dbConnection == nil {
+		return nil
+	}
+	sqlDB, err := h.dbConnection.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// SQLDB returns the *sql.DB of the connection pool of the holder, e.g. for custom health checks, or nil if
+// the holder has no connection.
+func (h *DatabaseHolder) SQLDB() *sql.DB {
+	if h.dbConnection == nil {
+		return nil
+	}
+	sqlDB, err := h.dbConnection.DB()
+	if err != nil {
+		return nil
+	}
+	return sqlDB
+}
+
+// Stats returns the statistics of the connection pool of the holder; they are zero without a connection.
+func (h *DatabaseHolder) Stats() sql.DBStats {
+	sqlDB := h.SQLDB()
+	if sqlDB == nil {
+		return sql.DBStats{}
+	}
+	return sqlDB.Stats()
+}
+
+// ResetDBHolderForTesting closes the singleton created by NewDBHolderInstance and forgets it,
+// so the next call connects again. It is meant for integration tests and must not be called
+// while transactions are running.
+func ResetDBHolderForTesting() error {
+	holderMu.Lock()
+	defer holderMu.Unlock()
+
+	var err error
+	if dbHolder != nil {
+		err = dbHolder.Close()
+	}
+	dbHolder = nil
+	onceDBHolder = sync.Once{}
+	return err
+}
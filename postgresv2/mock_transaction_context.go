@@ -0,0 +1,245 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: transaction_context.go
+
+// Package postgresv2 is a generated GoMock package.
+package postgresv2
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	uuid "github.com/google/uuid"
+	gorm "gorm.io/gorm"
+)
+
+// MockITransactionContext is a mock of ITransactionContext interface.
+type MockITransactionContext struct {
+	ctrl     *gomock.Controller
+	recorder *MockITransactionContextMockRecorder
+}
+
+// MockITransactionContextMockRecorder is the mock recorder for MockITransactionContext.
+type MockITransactionContextMockRecorder struct {
+	mock *MockITransactionContext
+}
+
+// NewMockITransactionContext creates a new mock instance.
+func NewMockITransactionContext(ctrl *gomock.Controller) *MockITransactionContext {
+	mock := &MockITransactionContext{ctrl: ctrl}
+	mock.recorder = &MockITransactionContextMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockITransactionContext) EXPECT() *MockITransactionContextMockRecorder {
+	return m.recorder
+}
+
+// Begin mocks base method.
+func (m *MockITransactionContext) Begin() (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Begin")
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Begin indicates an expected call of Begin.
+func (mr *MockITransactionContextMockRecorder) Begin() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Begin", reflect.TypeOf((*MockITransactionContext)(nil).Begin))
+}
+
+// BeginContext mocks base method.
+func (m *MockITransactionContext) BeginContext(arg0 context.Context) (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginContext", arg0)
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginContext indicates an expected call of BeginContext.
+func (mr *MockITransactionContextMockRecorder) BeginContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginContext", reflect.TypeOf((*MockITransactionContext)(nil).BeginContext), arg0)
+}
+
+// BeginReadOnly mocks base method.
+func (m *MockITransactionContext) BeginReadOnly() (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginReadOnly")
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginReadOnly indicates an expected call of BeginReadOnly.
+func (mr *MockITransactionContextMockRecorder) BeginReadOnly() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginReadOnly", reflect.TypeOf((*MockITransactionContext)(nil).BeginReadOnly))
+}
+
+// BeginWithOptions mocks base method.
+func (m *MockITransactionContext) BeginWithOptions(arg0 TxOptions) (uuid.UUID, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginWithOptions", arg0)
+	ret0, _ := ret[0].(uuid.UUID)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginWithOptions indicates an expected call of BeginWithOptions.
+func (mr *MockITransactionContextMockRecorder) BeginWithOptions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginWithOptions", reflect.TypeOf((*MockITransactionContext)(nil).BeginWithOptions), arg0)
+}
+
+// Commit mocks base method.
+func (m *MockITransactionContext) Commit(arg0 uuid.UUID) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Commit", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Commit indicates an expected call of Commit.
+func (mr *MockITransactionContextMockRecorder) Commit(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Commit", reflect.TypeOf((*MockITransactionContext)(nil).Commit), arg0)
+}
+
+// Complete mocks base method.
+func (m *MockITransactionContext) Complete() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Complete")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Complete indicates an expected call of Complete.
+func (mr *MockITransactionContextMockRecorder) Complete() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Complete", reflect.TypeOf((*MockITransactionContext)(nil).Complete))
+}
+
+// Provider mocks base method.
+func (m *MockITransactionContext) Provider() *gorm.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Provider")
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// Provider indicates an expected call of Provider.
+func (mr *MockITransactionContextMockRecorder) Provider() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Provider", reflect.TypeOf((*MockITransactionContext)(nil).Provider))
+}
+
+// ProviderWithContext mocks base method.
+func (m *MockITransactionContext) ProviderWithContext(arg0 context.Context) *gorm.DB {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProviderWithContext", arg0)
+	ret0, _ := ret[0].(*gorm.DB)
+	return ret0
+}
+
+// ProviderWithContext indicates an expected call of ProviderWithContext.
+func (mr *MockITransactionContextMockRecorder) ProviderWithContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProviderWithContext", reflect.TypeOf((*MockITransactionContext)(nil).ProviderWithContext), arg0)
+}
+
+// RegisterAfterCommit mocks base method.
+func (m *MockITransactionContext) RegisterAfterCommit(arg0 func()) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RegisterAfterCommit", arg0)
+}
+
+// RegisterAfterCommit indicates an expected call of RegisterAfterCommit.
+func (mr *MockITransactionContextMockRecorder) RegisterAfterCommit(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterAfterCommit", reflect.TypeOf((*MockITransactionContext)(nil).RegisterAfterCommit), arg0)
+}
+
+// RegisterAfterRollback mocks base method.
+func (m *MockITransactionContext) RegisterAfterRollback(arg0 func()) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RegisterAfterRollback", arg0)
+}
+
+// RegisterAfterRollback indicates an expected call of RegisterAfterRollback.
+func (mr *MockITransactionContextMockRecorder) RegisterAfterRollback(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterAfterRollback", reflect.TypeOf((*MockITransactionContext)(nil).RegisterAfterRollback), arg0)
+}
+
+// RegisterBeforeCommit mocks base method.
+func (m *MockITransactionContext) RegisterBeforeCommit(arg0 func() error) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RegisterBeforeCommit", arg0)
+}
+
+// RegisterBeforeCommit indicates an expected call of RegisterBeforeCommit.
+func (mr *MockITransactionContextMockRecorder) RegisterBeforeCommit(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterBeforeCommit", reflect.TypeOf((*MockITransactionContext)(nil).RegisterBeforeCommit), arg0)
+}
+
+// Reset mocks base method.
+func (m *MockITransactionContext) Reset() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockITransactionContextMockRecorder) Reset() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockITransactionContext)(nil).Reset))
+}
+
+// Rollback mocks base method.
+func (m *MockITransactionContext) Rollback() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rollback indicates an expected call of Rollback.
+func (mr *MockITransactionContextMockRecorder) Rollback() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockITransactionContext)(nil).Rollback))
+}
+
+// RollbackTo mocks base method.
+func (m *MockITransactionContext) RollbackTo(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollbackTo", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RollbackTo indicates an expected call of RollbackTo.
+func (mr *MockITransactionContextMockRecorder) RollbackTo(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollbackTo", reflect.TypeOf((*MockITransactionContext)(nil).RollbackTo), arg0)
+}
+
+// SavePoint mocks base method.
+func (m *MockITransactionContext) SavePoint(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SavePoint", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SavePoint indicates an expected call of SavePoint.
+func (mr *MockITransactionContextMockRecorder) SavePoint(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SavePoint", reflect.TypeOf((*MockITransactionContext)(nil).SavePoint), arg0)
+}
@@ -0,0 +1,247 @@
+package postgresv2
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/public-forge/go-gorm-unit-of-work/uow"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// getTestTransactionContext builds a GORM v2 transaction context backed by go-sqlmock.
+func getTestTransactionContext(t *testing.T) (*transactionContext, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(pgdriver.New(pgdriver.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	return newTransactionContext(log.FromDefaultContext(), NewDBHolder(gormDB)), mock
+}
+
+// Test nested Begin/Commit where only the owner commits
+func TestTransactionContext_NestedCommit(t *testing.T) {
+	tx, mock := getTestTransactionContext(t)
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	outer, err := tx.Begin()
+	assert.NoError(t, err)
+	inner, err := tx.Begin()
+	assert.NoError(t, err)
+
+	committed := false
+	tx.RegisterAfterCommit(func() { committed = true })
+
+	assert.NoError(t, tx.Commit(inner))
+	assert.False(t, committed)
+	assert.NoError(t, tx.Commit(outer))
+	assert.True(t, committed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a rolled back context rejects further transactions
+func TestTransactionContext_Rollback(t *testing.T) {
+	tx, mock := getTestTransactionContext(t)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+	_, err = tx.Begin()
+	assert.ErrorIs(t, err, ErrTxWasRollbacked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a failing before-commit hook aborts the commit and runs the after-rollback hooks
+func TestTransactionContext_BeforeCommitAndAfterRollbackHooks(t *testing.T) {
+	tx, mock := getTestTransactionContext(t)
+	failure := errors.New("invalid order")
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var calls []string
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	tx.RegisterBeforeCommit(func() error { calls = append(calls, "before-commit"); return failure })
+	tx.RegisterAfterCommit(func() { calls = append(calls, "after-commit") })
+	tx.RegisterAfterRollback(func() { calls = append(calls, "after-rollback") })
+
+	assert.ErrorIs(t, tx.Commit(id), failure)
+	assert.True(t, tx.WasRollbacked())
+	assert.Equal(t, []string{"before-commit", "after-rollback"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a non-owner commit is reported in strict-commit mode
+func TestTransactionContext_StrictCommit(t *testing.T) {
+	base, mock := getTestTransactionContext(t)
+	tx := newTransactionContext(base.Logger, base.dbHolder, WithStrictCommit())
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	innerID, err := tx.Begin()
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, tx.Commit(innerID), ErrNotTransactionOwner)
+	assert.True(t, tx.InTransaction())
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Reset makes a rolled back context usable again
+func TestTransactionContext_Reset(t *testing.T) {
+	tx, mock := getTestTransactionContext(t)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	_, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Rollback())
+	_, err = tx.Begin()
+	assert.ErrorIs(t, err, ErrTxWasRollbacked)
+
+	assert.NoError(t, tx.Reset())
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test SavePoint and RollbackTo to verify the savepoint statements run in the transaction
+func TestTransactionContext_SavePoint(t *testing.T) {
+	tx, mock := getTestTransactionContext(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT item").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	assert.ErrorIs(t, tx.SavePoint("item"), ErrNotInTransaction)
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, tx.SavePoint("item; COMMIT"), ErrInvalidSavePoint)
+	assert.NoError(t, tx.SavePoint("item"))
+	assert.NoError(t, tx.RollbackTo("item"))
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test BeginContext to verify it gives up once its context is done before the transaction has begun
+func TestTransactionContext_BeginContext(t *testing.T) {
+	tx, mock := getTestTransactionContext(t)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := tx.BeginContext(cancelled)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, tx.InTransaction())
+
+	mock.ExpectBegin().WillDelayFor(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = tx.BeginContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), time.Second)
+	assert.False(t, tx.InTransaction())
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	id, err := tx.BeginContext(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, tx.InTransaction())
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test uow.WithTxOptions to verify new transactions start with the options of the context, also when the
+// context is set after the transaction context was created
+func TestTransactionContext_ContextTxOptions(t *testing.T) {
+	tx, mock := getTestTransactionContext(t)
+	tx.Ctx = uow.WithTxOptions(context.Background(), TxOptions{ReadOnly: true})
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	id, err := tx.Begin()
+	assert.NoError(t, err)
+	// A write joining the read-only transaction is rejected
+	_, err = tx.BeginWithOptions(TxOptions{})
+	assert.ErrorIs(t, err, ErrIncompatibleTxOptions)
+	_, err = tx.BeginReadOnly()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit(id))
+
+	tx.Ctx = context.Background()
+	id, err = tx.BeginContext(uow.WithTxOptions(context.Background(), TxOptions{ReadOnly: true}))
+	assert.NoError(t, err)
+	_, err = tx.Begin()
+	assert.ErrorIs(t, err, ErrIncompatibleTxOptions)
+	assert.NoError(t, tx.Commit(id))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// autoBegunOrder is written by the auto-begin tests.
+type autoBegunOrder struct {
+	ID   uint
+	Code string
+}
+
+// Test that auto-begin mode runs reads on the pool and begins the transaction on the first write
+func TestTransactionContext_AutoBegin(t *testing.T) {
+	base, mock := getTestTransactionContext(t)
+	tx := newTransactionContext(base.Logger, base.dbHolder, WithAutoBegin())
+
+	mock.ExpectQuery(`SELECT \* FROM "auto_begun_orders"`).WillReturnRows(sqlmock.NewRows([]string{"id", "code"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "auto_begun_orders"`).WithArgs("a").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(`SELECT \* FROM "auto_begun_orders"`).WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "a"))
+	mock.ExpectCommit()
+
+	provider := tx.Provider()
+	var orders []autoBegunOrder
+	assert.NoError(t, provider.Find(&orders).Error)
+	assert.False(t, tx.InTransaction())
+
+	assert.NoError(t, tx.Provider().Create(&autoBegunOrder{Code: "a"}).Error)
+	assert.True(t, tx.InTransaction())
+	// The *gorm.DB returned before the write reads inside the transaction
+	assert.NoError(t, provider.Find(&orders).Error)
+	assert.Len(t, orders, 1)
+
+	assert.NoError(t, tx.Complete())
+	assert.False(t, tx.InTransaction())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that Complete is a no-op in auto-begin mode if the unit of work only read
+func TestTransactionContext_AutoBeginRead(t *testing.T) {
+	base, mock := getTestTransactionContext(t)
+	tx := newTransactionContext(base.Logger, base.dbHolder, WithAutoBegin())
+
+	mock.ExpectQuery(`SELECT \* FROM "auto_begun_orders"`).WillReturnRows(sqlmock.NewRows([]string{"id", "code"}).AddRow(1, "a"))
+
+	var orders []autoBegunOrder
+	assert.NoError(t, tx.Provider().Find(&orders).Error)
+	assert.False(t, tx.InTransaction())
+	assert.NoError(t, tx.Complete())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
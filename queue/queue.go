@@ -0,0 +1,299 @@
+// Package queue provides a job queue stored in Postgres. Jobs are enqueued in the caller's unit of work,
+// so they exist only if its transaction commits, and are claimed by workers with FOR UPDATE SKIP LOCKED,
+// each job in its own unit of work, so concurrent workers never process the same job twice.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	log "github.com/public-forge/go-logger"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTable is the jobs table used when Config.Table is empty.
+	DefaultTable = "jobs"
+
+	// defaultMaxAttempts defines how often a job is tried when Config.MaxAttempts is not set.
+	defaultMaxAttempts = 5
+	// defaultRetryBackoff defines the delay before the first retry when Config.RetryBackoff is not set.
+	defaultRetryBackoff = time.Second
+	// maxRetryBackoff caps the exponential retry delay.
+	maxRetryBackoff = time.Hour
+	// defaultWorkers defines the number of jobs processed concurrently when Config.Workers is not set.
+	defaultWorkers = 1
+	// defaultPollInterval defines how long idle workers wait when Config.PollInterval is not set.
+	defaultPollInterval = time.Second
+
+	// Job statuses.
+	StatusPending = "pending" // StatusPending marks jobs waiting to be processed.
+	StatusDone    = "done"    // StatusDone marks processed jobs.
+	StatusDead    = "dead"    // StatusDead marks jobs that failed MaxAttempts times.
+)
+
+type (
+	// Job is a row of the jobs table. The table can be created with:
+	//
+	//	CREATE TABLE jobs (
+	//	  id         bigserial   PRIMARY KEY,
+	//	  queue      text        NOT NULL,
+	//	  payload    jsonb       NOT NULL,
+	//	  status     text        NOT NULL,
+	//	  attempts   integer     NOT NULL DEFAULT 0,
+	//	  run_at     timestamptz NOT NULL,
+	//	  last_error text,
+	//	  created_at timestamptz NOT NULL,
+	//	  updated_at timestamptz NOT NULL
+	//	);
+	//	CREATE INDEX jobs_pending ON jobs (queue, run_at) WHERE status = 'pending';
+	Job struct {
+		ID        uint64    `gorm:"primary_key"` // ID identifies the job.
+		Queue     string    // Queue is the name of the queue the job belongs to.
+		Payload   string    // Payload is the JSON encoded payload passed to Enqueue.
+		Status    string    // Status is StatusPending, StatusDone or StatusDead.
+		Attempts  int       // Attempts is the number of failed executions.
+		RunAt     time.Time // RunAt is the earliest time the job is processed.
+		LastError *string   // LastError is the error of the last failed execution.
+		CreatedAt time.Time // CreatedAt is the time the job was enqueued.
+		UpdatedAt time.Time // UpdatedAt is the time the job was last changed.
+	}
+
+	// Handler processes a job. ctx carries the worker's unit of work: writes through its transaction context
+	// commit together with the completion of the job, and are rolled back if the handler fails.
+	Handler func(ctx context.Context, job *Job) error
+
+	// Config holds the settings of a Queue. Zero values fall back to defaults.
+	Config struct {
+		Name         string                              // Name is the queue name, stored with every job.
+		Table        string                              // Table is the jobs table; DefaultTable if empty.
+		Factory      *postgres.TransactionContextFactory // Factory creates the transaction contexts of the workers; nil uses postgres.GetTransactionContext.
+		MaxAttempts  int                                 // MaxAttempts is the number of executions before a job is dead-lettered.
+		RetryBackoff time.Duration                       // RetryBackoff is the delay before the first retry; it doubles on each attempt.
+		Workers      int                                 // Workers is the number of jobs Run processes concurrently.
+		PollInterval time.Duration                       // PollInterval is how long an idle worker waits before looking for jobs again.
+		DeadLetter   func(job *Job, err error)           // DeadLetter, when set, is called after a job has been marked StatusDead.
+		Logger       log.Logger                          // Logger used by the workers; defaults to the default logger.
+		Clock        postgres.Clock                      // Clock waits for the PollInterval; defaults to postgres.SystemClock.
+	}
+
+	// Queue enqueues and processes the jobs of one queue.
+	Queue struct {
+		config Config
+	}
+)
+
+// Decode decodes the JSON payload of the job into v.
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
+
+// New creates a Queue.
+func New(config Config) *Queue {
+	if config.Table == "" {
+		config.Table = DefaultTable
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if config.Workers <= 0 {
+		config.Workers = defaultWorkers
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.Logger == nil {
+		config.Logger = log.FromDefaultContext()
+	}
+	if config.Clock == nil {
+		config.Clock = postgres.SystemClock{}
+	}
+	return &Queue{config: config}
+}
+
+// Enqueue adds a job with payload, encoded as JSON, to the queue in the unit of work of ctx: if ctx runs
+// a transaction, the job becomes visible to workers only once it commits.
+// Example:
+//
+//	err := postgres.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+//	  if err := db.Create(&order).Error; err != nil { return err }
+//	  return emails.Enqueue(ctx, OrderConfirmation{OrderID: order.ID})
+//	})
+func (q *Queue) Enqueue(ctx context.Context, payload interface{}) error {
+	return q.EnqueueAt(ctx, payload, time.Time{})
+}
+
+// EnqueueAt is Enqueue for a job that is not processed before runAt; a zero runAt means now.
+func (q *Queue) EnqueueAt(ctx context.Context, payload interface{}, runAt time.Time) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if runAt.IsZero() {
+		runAt = gorm.NowFunc()
+	}
+	txContext, ctx := q.transactionContext(ctx)
+	db := txContext.ProviderWithContext(ctx)
+	if db == nil {
+		return postgres.ErrTxWasRollbacked
+	}
+	job := Job{Queue: q.config.Name, Payload: string(encoded), Status: StatusPending, RunAt: runAt}
+	return db.Table(q.config.Table).Create(&job).Error
+}
+
+// Process claims the next due job, skipping jobs locked by other workers, and runs handler on it, in a
+// unit of work of its own. If handler succeeds the job is marked StatusDone in the same transaction;
+// if it fails or panics, its writes are rolled back to a savepoint and the job is rescheduled with an
+// exponential backoff, or marked StatusDead once it has failed MaxAttempts times.
+// The first result reports whether a job was found.
+func (q *Queue) Process(ctx context.Context, handler Handler) (bool, error) {
+	var (
+		job        *Job  // The claimed job.
+		handlerErr error // The error of the handler, if it failed.
+		abandoned  bool  // Set if the handler rolled back the whole unit of work.
+	)
+	txContext, txCtx := q.freshTransactionContext(ctx)
+	err := postgres.RunInTransaction(txCtx, func(ctx context.Context, db *gorm.DB) error {
+		var jobs []Job
+		err := db.Table(q.config.Table).
+			Where("queue = ? AND status = ? AND run_at <= ?", q.config.Name, StatusPending, gorm.NowFunc()).
+			Order("run_at, id").Limit(1).
+			Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		job = &jobs[0]
+
+		if err := db.Exec("SAVEPOINT queue_job").Error; err != nil {
+			return err
+		}
+		if handlerErr = q.handle(ctx, handler, job); handlerErr == nil {
+			job.Status = StatusDone
+			return q.update(db, job, map[string]interface{}{"status": StatusDone})
+		}
+		if txContext.Provider() == nil {
+			abandoned = true // e.g. a nested RunInTransaction of the handler failed
+			return handlerErr
+		}
+		if err := db.Exec("ROLLBACK TO SAVEPOINT queue_job").Error; err != nil {
+			return err
+		}
+		return q.recordFailure(db, job, handlerErr)
+	})
+	if abandoned {
+		// The lock has been released with the rollback; the failure is recorded unless another worker
+		// has already claimed the job again.
+		_, txCtx = q.freshTransactionContext(ctx)
+		err = postgres.RunInTransaction(txCtx, func(ctx context.Context, db *gorm.DB) error {
+			return q.recordFailure(db.Where("status = ? AND attempts = ?", StatusPending, job.Attempts), job, handlerErr)
+		})
+	}
+	if err == nil && handlerErr != nil && job.Status == StatusDead && q.config.DeadLetter != nil {
+		q.config.DeadLetter(job, handlerErr)
+	}
+	return job != nil, err
+}
+
+// Run processes jobs with Config.Workers concurrent workers until ctx is done. Idle workers wait
+// Config.PollInterval before looking for due jobs again. Errors are logged and do not stop the workers.
+// Example:
+//
+//	go emails.Run(ctx, func(ctx context.Context, job *queue.Job) error {
+//	  var confirmation OrderConfirmation
+//	  if err := job.Decode(&confirmation); err != nil { return err }
+//	  return mailer.Send(ctx, confirmation)
+//	})
+func (q *Queue) Run(ctx context.Context, handler Handler) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, handler)
+		}()
+	}
+	wg.Wait()
+}
+
+// work processes jobs until ctx is done.
+func (q *Queue) work(ctx context.Context, handler Handler) {
+	for ctx.Err() == nil {
+		processed, err := q.Process(ctx, handler)
+		if err != nil {
+			q.config.Logger.Errorf("queue %s: cannot process job: %s", q.config.Name, err)
+		}
+		if processed && err == nil {
+			continue
+		}
+		_ = q.config.Clock.Sleep(ctx, q.config.PollInterval)
+	}
+}
+
+// handle runs handler, turning a panic into an error.
+func (q *Queue) handle(ctx context.Context, handler Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job %d panicked: %v", job.ID, r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// recordFailure reschedules job after a failed execution, or marks it dead after MaxAttempts failures.
+func (q *Queue) recordFailure(db *gorm.DB, job *Job, err error) error {
+	message := err.Error()
+	job.LastError = &message
+	job.Attempts++
+	if job.Attempts >= q.config.MaxAttempts {
+		job.Status = StatusDead
+	} else {
+		job.RunAt = gorm.NowFunc().Add(q.backoff(job.Attempts))
+	}
+	return q.update(db, job, map[string]interface{}{
+		"status": job.Status, "attempts": job.Attempts, "run_at": job.RunAt, "last_error": job.LastError,
+	})
+}
+
+// backoff returns the retry delay after the given number of failed attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	delay := q.config.RetryBackoff
+	for i := 1; i < attempts && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay
+}
+
+// update writes columns of job.
+func (q *Queue) update(db *gorm.DB, job *Job, columns map[string]interface{}) error {
+	columns["updated_at"] = gorm.NowFunc()
+	result := db.Table(q.config.Table).Where("id = ?", job.ID).Updates(columns)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return errors.New("queue: job " + fmt.Sprint(job.ID) + " has disappeared")
+	}
+	return result.Error
+}
+
+// freshTransactionContext returns a new transaction context of the configured factory, so the work
+// never joins a transaction of the caller.
+func (q *Queue) freshTransactionContext(ctx context.Context) (postgres.ITransactionContext, context.Context) {
+	return q.transactionContext(context.WithValue(ctx, postgres.TransactionContextKey, nil))
+}
+
+// transactionContext returns the transaction context of ctx, created by the configured factory.
+func (q *Queue) transactionContext(ctx context.Context) (postgres.ITransactionContext, context.Context) {
+	if q.config.Factory != nil {
+		return q.config.Factory.GetTransactionContext(ctx)
+	}
+	return postgres.GetTransactionContext(ctx)
+}
@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// newTestQueue builds a queue whose transaction contexts are backed by go-sqlmock.
+func newTestQueue(t *testing.T, config Config) (*Queue, *gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	config.Factory = postgres.NewTransactionContextFactory(postgres.NewDBHolder(db))
+	return New(config), db, mock
+}
+
+var jobColumns = []string{"id", "queue", "payload", "status", "attempts"}
+
+// Test that a successful job is marked done in the unit of work that claimed it
+func TestQueue_Process(t *testing.T) {
+	q, db, mock := newTestQueue(t, Config{Name: "emails"})
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "jobs"`).WithArgs("emails", `{"to":"ann@example.com"}`, StatusPending, 0, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "jobs" .* LIMIT 1 FOR UPDATE SKIP LOCKED`).WithArgs("emails", StatusPending, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(jobColumns).AddRow(1, "emails", `{"to":"ann@example.com"}`, StatusPending, 0))
+	mock.ExpectExec(`SAVEPOINT queue_job`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "jobs" SET "status" = \$1, "updated_at" = \$2 WHERE \(id = \$3\)`).WithArgs(StatusDone, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, q.Enqueue(context.Background(), map[string]string{"to": "ann@example.com"}))
+	var to string
+	processed, err := q.Process(context.Background(), func(ctx context.Context, job *Job) error {
+		var payload map[string]string
+		err := job.Decode(&payload)
+		to = payload["to"]
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, processed)
+	assert.Equal(t, "ann@example.com", to)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test that a job failing its last attempt is rolled back to the savepoint and dead-lettered
+func TestQueue_ProcessDeadLetter(t *testing.T) {
+	var dead *Job
+	q, db, mock := newTestQueue(t, Config{Name: "emails", MaxAttempts: 2, DeadLetter: func(job *Job, err error) { dead = job }})
+	defer db.Close()
+	failure := errors.New("smtp unavailable")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE SKIP LOCKED`).
+		WillReturnRows(sqlmock.NewRows(jobColumns).AddRow(1, "emails", `{}`, StatusPending, 1))
+	mock.ExpectExec(`SAVEPOINT queue_job`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT queue_job`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "jobs"`).WithArgs(2, failure.Error(), sqlmock.AnyArg(), StatusDead, sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`FOR UPDATE SKIP LOCKED`).WillReturnRows(sqlmock.NewRows(jobColumns))
+	mock.ExpectCommit()
+
+	processed, err := q.Process(context.Background(), func(ctx context.Context, job *Job) error { return failure })
+	assert.NoError(t, err)
+	assert.True(t, processed)
+	assert.Equal(t, StatusDead, dead.Status)
+
+	processed, err = q.Process(context.Background(), func(ctx context.Context, job *Job) error { return nil })
+	assert.NoError(t, err)
+	assert.False(t, processed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
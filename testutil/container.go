@@ -0,0 +1,196 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// DefaultPostgresImage is the image started when ContainerConfig.Image is empty.
+	DefaultPostgresImage = "postgres:16-alpine"
+
+	// defaultContainerStartTimeout defines how long the server may take to start when ContainerConfig.StartTimeout is not set.
+	defaultContainerStartTimeout = time.Minute
+	// containerReadyMessage is logged by the server once it accepts connections. The image logs it twice:
+	// for the temporary server running the init scripts, then for the real one.
+	containerReadyMessage = "database system is ready to accept connections"
+)
+
+type (
+	// ContainerConfig holds the settings of StartPostgres. Zero values fall back to defaults.
+	ContainerConfig struct {
+		Image        string                          // Image is the Postgres image; DefaultPostgresImage if empty.
+		DBName       string                          // DBName is the database created in the container; "test" if empty.
+		User         string                          // User is the superuser created in the container; "postgres" if empty.
+		Password     string                          // Password is the password of User; "postgres" if empty.
+		StartTimeout time.Duration                   // StartTimeout limits how long the server may take to accept connections.
+		Configure    func(config *postgres.PgConfig) // Configure, when set, adjusts the PgConfig before connecting, e.g. to enable logging.
+		Migrate      func(db *gorm.DB) error         // Migrate, when set, prepares the schema once connected, e.g. with AutoMigrate.
+	}
+
+	// PostgresContainer is a disposable Postgres server running in a Docker container, with a holder and a
+	// factory connected to it. Everything is removed when the test and its subtests have completed.
+	PostgresContainer struct {
+		ID      string                              // ID is the Docker container ID.
+		Config  *postgres.PgConfig                  // Config describes the connection to the server.
+		Holder  *postgres.DatabaseHolder            // Holder is connected to the database of the container.
+		Factory *postgres.TransactionContextFactory // Factory creates transaction contexts of Holder.
+	}
+)
+
+// StartPostgres starts a Postgres container with the Docker CLI, connects to it and runs config.Migrate.
+// The test is skipped if Docker is not available, and fails if the server does not start.
+// Example:
+//
+//	func TestOrders(t *testing.T) {
+//	  pg := testutil.StartPostgres(t, testutil.ContainerConfig{
+//	    Migrate: func(db *gorm.DB) error { return db.AutoMigrate(&Order{}).Error },
+//	  })
+//	  testutil.WithRollbackTxFactory(t, pg.Factory, func(ctx context.Context) { ... })
+//	}
+func StartPostgres(t testing.TB, config ContainerConfig) *PostgresContainer {
+	t.Helper()
+	config = config.withDefaults()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("docker is not available: %s", err)
+	}
+	if err := docker("info"); err != nil {
+		t.Skipf("docker is not available: %s", err)
+	}
+
+	id, err := dockerOutput("run", "--detach", "--publish", "127.0.0.1::5432",
+		"--env", "POSTGRES_DB="+config.DBName,
+		"--env", "POSTGRES_USER="+config.User,
+		"--env", "POSTGRES_PASSWORD="+config.Password,
+		config.Image)
+	if err != nil {
+		t.Fatalf("cannot start postgres container: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := docker("rm", "--force", "--volumes", id); err != nil {
+			t.Logf("cannot remove postgres container %s: %s", id, err)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.StartTimeout)
+	defer cancel()
+	ports, err := dockerOutput("port", id, "5432/tcp")
+	if err != nil {
+		t.Fatalf("cannot get the port of postgres container %s: %s", id, err)
+	}
+	host, port, err := parsePublishedPort(ports)
+	if err != nil {
+		t.Fatalf("cannot get the port of postgres container %s: %s", id, err)
+	}
+	if err := waitForContainer(ctx, id); err != nil {
+		t.Fatalf("postgres container %s: %s", id, err)
+	}
+
+	pgConfig := &postgres.PgConfig{
+		Host:     host,
+		Port:     port,
+		DBName:   config.DBName,
+		User:     config.User,
+		Password: config.Password,
+		SSLMode:  "disable",
+	}
+	if config.Configure != nil {
+		config.Configure(pgConfig)
+	}
+	db, err := postgres.OpenContext(ctx, pgConfig)
+	if err != nil {
+		t.Fatalf("cannot connect to postgres container %s: %s", id, err)
+	}
+	holder := postgres.NewDBHolder(db)
+	t.Cleanup(func() { _ = holder.Close() })
+	if config.Migrate != nil {
+		if err := config.Migrate(db); err != nil {
+			t.Fatalf("cannot migrate postgres container %s: %s", id, err)
+		}
+	}
+
+	return &PostgresContainer{
+		ID:      id,
+		Config:  pgConfig,
+		Holder:  holder,
+		Factory: postgres.NewTransactionContextFactory(holder),
+	}
+}
+
+// withDefaults returns the configuration with zero values replaced by defaults.
+func (c ContainerConfig) withDefaults() ContainerConfig {
+	if c.Image == "" {
+		c.Image = DefaultPostgresImage
+	}
+	if c.DBName == "" {
+		c.DBName = "test"
+	}
+	if c.User == "" {
+		c.User = "postgres"
+	}
+	if c.Password == "" {
+		c.Password = "postgres"
+	}
+	if c.StartTimeout <= 0 {
+		c.StartTimeout = defaultContainerStartTimeout
+	}
+	return c
+}
+
+// waitForContainer waits until the server of the container has finished initializing.
+func waitForContainer(ctx context.Context, id string) error {
+	for {
+		logs, err := exec.Command("docker", "logs", id).CombinedOutput() // the server logs to stderr
+		if err != nil {
+			return fmt.Errorf("docker logs: %w: %s", err, bytes.TrimSpace(logs))
+		}
+		if bytes.Count(logs, []byte(containerReadyMessage)) >= 2 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("server not ready: %w", ctx.Err())
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// parsePublishedPort returns the first address of the output of docker port, e.g. "127.0.0.1:49153".
+func parsePublishedPort(output string) (string, int, error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	host, port, err := net.SplitHostPort(strings.TrimSpace(line))
+	if err != nil {
+		return "", 0, err
+	}
+	number, err := strconv.Atoi(port)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", port)
+	}
+	return host, number, nil
+}
+
+// docker runs the Docker CLI with args.
+func docker(args ...string) error {
+	_, err := dockerOutput(args...)
+	return err
+}
+
+// dockerOutput runs the Docker CLI with args and returns its standard output, trimmed.
+func dockerOutput(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,19 @@
+package testutil
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test parsePublishedPort to verify the first address printed by docker port is used
+func TestParsePublishedPort(t *testing.T) {
+	host, port, err := parsePublishedPort("127.0.0.1:49153\n[::1]:49153\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+	assert.Equal(t, 49153, port)
+
+	_, _, err = parsePublishedPort("")
+	assert.Error(t, err)
+	_, _, err = parsePublishedPort("127.0.0.1:http")
+	assert.Error(t, err)
+}
@@ -0,0 +1,221 @@
+// Package testutil provides helpers for integration tests that run against a real PostgreSQL database.
+package testutil
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"strings"
+	"sync"
+)
+
+// snapshotSchema is the schema holding table copies taken by Resetter.Snapshot.
+const snapshotSchema = "uow_test_snapshots"
+
+type (
+	// Resetter resets the state of a shared test database between tests.
+	//
+	// Truncate empties every table of the schema in a single TRUNCATE ... RESTART IDENTITY CASCADE,
+	// which is much faster than dropping and recreating the schema. With excluded tables CASCADE is
+	// left out, so the truncation fails rather than empties an excluded table referencing the others.
+	// Snapshot and Restore save and bring back a known data set (e.g. seeded reference data),
+	// inserting rows in foreign-key order.
+	Resetter struct {
+		db        *gorm.DB                   // Connection used for all statements.
+		schema    string                     // Schema whose tables are reset.
+		exclude   map[string]bool            // Tables that are never touched (e.g. migration bookkeeping).
+		mu        sync.Mutex                 // Guards snapshots.
+		snapshots map[string]*snapshotRecord // Registered snapshots by name.
+	}
+
+	// snapshotRecord remembers what a snapshot contains.
+	snapshotRecord struct {
+		tables    []string                 // Tables in insert order (parents first).
+		sequences map[string]sql.NullInt64 // Sequence values at snapshot time, NULL if never used.
+	}
+)
+
+// NewResetter creates a Resetter for the tables in schema, skipping the excluded table names.
+// Example:
+//
+//	resetter := testutil.NewResetter(db, "public", "schema_migrations")
+//	t.Cleanup(func() { _ = resetter.Truncate() })
+func NewResetter(db *gorm.DB, schema string, exclude ...string) *Resetter {
+	r := &Resetter{db: db, schema: schema, exclude: map[string]bool{}, snapshots: map[string]*snapshotRecord{}}
+	for _, table := range exclude {
+		r.exclude[table] = true
+	}
+	return r
+}
+
+// Truncate removes all rows from every table of the schema and restarts owned sequences.
+func (r *Resetter) Truncate() error {
+	tables, err := r.tables()
+	if err != nil || len(tables) == 0 {
+		return err
+	}
+	return r.db.Exec(r.truncate(tables)).Error
+}
+
+// Snapshot copies the current content of every table into a snapshot registered under name.
+// Taking a snapshot with an existing name replaces it.
+func (r *Resetter) Snapshot(name string) error {
+	tables, err := r.orderedTables()
+	if err != nil {
+		return err
+	}
+	sequences, err := r.sequences()
+	if err != nil {
+		return err
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteName(snapshotSchema)).Error; err != nil {
+			return err
+		}
+		for _, table := range tables {
+			copyName := r.snapshotTable(name, table)
+			if err := tx.Exec("DROP TABLE IF EXISTS " + copyName).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(fmt.Sprintf("CREATE TABLE %s AS TABLE %s", copyName, r.qualified(table))).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.snapshots[name] = &snapshotRecord{tables: tables, sequences: sequences}
+	r.mu.Unlock()
+	return nil
+}
+
+// Restore truncates the schema and reloads the snapshot registered under name in a single transaction.
+// Tables referencing each other in a cycle are only restorable if their foreign keys are DEFERRABLE.
+func (r *Resetter) Restore(name string) error {
+	r.mu.Lock()
+	snapshot, ok := r.snapshots[name]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("testutil: snapshot %q has not been registered", name)
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SET CONSTRAINTS ALL DEFERRED").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(r.truncate(snapshot.tables)).Error; err != nil {
+			return err
+		}
+		for _, table := range snapshot.tables {
+			// OVERRIDING SYSTEM VALUE restores the values of GENERATED ALWAYS AS IDENTITY columns too
+			insert := fmt.Sprintf("INSERT INTO %s OVERRIDING SYSTEM VALUE SELECT * FROM %s", r.qualified(table), r.snapshotTable(name, table))
+			if err := tx.Exec(insert).Error; err != nil {
+				return err
+			}
+		}
+		for sequence, value := range snapshot.sequences {
+			if !value.Valid {
+				continue // never used, RESTART IDENTITY already reset it
+			}
+			qualified := r.qualified(sequence)
+			if err := tx.Exec("SELECT setval(?::regclass, ?, true)", qualified, value.Int64).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// tables returns the names of all base tables in the schema, excluding the configured ones.
+func (r *Resetter) tables() ([]string, error) {
+	rows, err := r.db.Raw("SELECT tablename FROM pg_tables WHERE schemaname = ? ORDER BY tablename", r.schema).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		if !r.exclude[table] {
+			tables = append(tables, table)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// orderedTables returns the tables sorted so that referenced tables come before the tables referencing them.
+func (r *Resetter) orderedTables() ([]string, error) {
+	tables, err := r.tables()
+	if err != nil {
+		return nil, err
+	}
+	return postgres.SortTablesByForeignKeys(r.db, r.schema, tables)
+}
+
+// sequences returns the current value of every sequence in the schema.
+func (r *Resetter) sequences() (map[string]sql.NullInt64, error) {
+	rows, err := r.db.Raw("SELECT sequencename, last_value FROM pg_sequences WHERE schemaname = ?", r.schema).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sequences := map[string]sql.NullInt64{}
+	for rows.Next() {
+		var name string
+		var value sql.NullInt64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		sequences[name] = value
+	}
+	return sequences, rows.Err()
+}
+
+// truncate returns the statement emptying tables, cascading to the tables referencing them unless tables
+// are excluded.
+func (r *Resetter) truncate(tables []string) string {
+	statement := fmt.Sprintf("TRUNCATE %s RESTART IDENTITY", r.qualifiedList(tables))
+	if len(r.exclude) == 0 {
+		statement += " CASCADE"
+	}
+	return statement
+}
+
+// qualified returns the quoted, schema-qualified name of table.
+func (r *Resetter) qualified(table string) string {
+	return quoteName(r.schema) + "." + quoteName(table)
+}
+
+// qualifiedList returns the quoted, comma-separated list of tables.
+func (r *Resetter) qualifiedList(tables []string) string {
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = r.qualified(table)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// snapshotTable returns the quoted name of the copy of table kept for snapshot. The name is derived from a
+// hash of the snapshot, schema and table names, as joining them could exceed the 63 bytes of an identifier
+// and make different tables collide.
+func (r *Resetter) snapshotTable(snapshot, table string) string {
+	sum := sha256.Sum256([]byte(snapshot + "\x00" + r.schema + "\x00" + table))
+	return quoteName(snapshotSchema) + "." + quoteName(fmt.Sprintf("snapshot_%x", sum[:16]))
+}
+
+// quoteName quotes name as a single identifier, unlike postgres.QuoteIdentifier even if it contains dots.
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,103 @@
+package testutil
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// newResetterMock opens a gorm.DB on sqlmock matching the statements exactly.
+func newResetterMock(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return db, mock
+}
+
+// expectTables expects the query listing the tables of schema public.
+func expectTables(mock sqlmock.Sqlmock, tables ...string) {
+	rows := sqlmock.NewRows([]string{"tablename"})
+	for _, table := range tables {
+		rows.AddRow(table)
+	}
+	mock.ExpectQuery("SELECT tablename FROM pg_tables WHERE schemaname = $1 ORDER BY tablename").
+		WithArgs("public").WillReturnRows(rows)
+}
+
+// Test Truncate to verify it cascades only without excluded tables
+func TestResetter_Truncate(t *testing.T) {
+	db, mock := newResetterMock(t)
+	expectTables(mock, "orders", "users")
+	mock.ExpectExec(`TRUNCATE "public"."orders", "public"."users" RESTART IDENTITY CASCADE`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectTables(mock, "orders", "schema_migrations", "users")
+	mock.ExpectExec(`TRUNCATE "public"."orders", "public"."users" RESTART IDENTITY`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, NewResetter(db, "public").Truncate())
+	assert.NoError(t, NewResetter(db, "public", "schema_migrations").Truncate())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test Snapshot and Restore to verify the copies are reloaded in foreign-key order with their identity values
+func TestResetter_SnapshotRestore(t *testing.T) {
+	db, mock := newResetterMock(t)
+	resetter := NewResetter(db, "public", "schema_migrations")
+	users, orders := resetter.snapshotTable("seed", "users"), resetter.snapshotTable("seed", "orders")
+
+	expectTables(mock, "orders", "schema_migrations", "users")
+	mock.ExpectQuery(strings.TrimSpace(`
+		SELECT child.relname, parent.relname
+		FROM pg_constraint c
+		JOIN pg_class child ON child.oid = c.conrelid
+		JOIN pg_class parent ON parent.oid = c.confrelid
+		JOIN pg_namespace n ON n.oid = child.relnamespace
+		WHERE c.contype = 'f' AND n.nspname = $1`)).
+		WithArgs("public").WillReturnRows(sqlmock.NewRows([]string{"child", "parent"}).AddRow("orders", "users"))
+	mock.ExpectQuery("SELECT sequencename, last_value FROM pg_sequences WHERE schemaname = $1").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"sequencename", "last_value"}).AddRow("orders_id_seq", 12).AddRow("users_id_seq", nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE SCHEMA IF NOT EXISTS "uow_test_snapshots"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, table := range []string{"users", "orders"} {
+		copyName := resetter.snapshotTable("seed", table)
+		mock.ExpectExec("DROP TABLE IF EXISTS " + copyName).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("CREATE TABLE " + copyName + ` AS TABLE "public"."` + table + `"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	mock.ExpectCommit()
+	assert.NoError(t, resetter.Snapshot("seed"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET CONSTRAINTS ALL DEFERRED").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`TRUNCATE "public"."users", "public"."orders" RESTART IDENTITY`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "public"."users" OVERRIDING SYSTEM VALUE SELECT * FROM ` + users).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO "public"."orders" OVERRIDING SYSTEM VALUE SELECT * FROM ` + orders).WillReturnResult(sqlmock.NewResult(0, 12))
+	mock.ExpectExec("SELECT setval($1::regclass, $2, true)").WithArgs(`"public"."orders_id_seq"`, 12).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	assert.NoError(t, resetter.Restore("seed"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Test snapshotTable to verify the names fit an identifier and do not collide
+func TestResetter_SnapshotTable(t *testing.T) {
+	db, _ := newResetterMock(t)
+	long := strings.Repeat("t", 63)
+	names := map[string]bool{}
+	for _, name := range []string{
+		NewResetter(db, "a__b").snapshotTable("s", "c"),
+		NewResetter(db, "a").snapshotTable("s", "b__c"),
+		NewResetter(db, "public").snapshotTable("seed", "audit.log"),
+		NewResetter(db, "public").snapshotTable("seed", long),
+		NewResetter(db, "public").snapshotTable("seed", long[:62]+"u"),
+	} {
+		assert.Regexp(t, regexp.MustCompile(`^"uow_test_snapshots"\."snapshot_[0-9a-f]{32}"$`), name)
+		names[name] = true
+	}
+	assert.Len(t, names, 5)
+	assert.Equal(t, `"public"."audit.log"`, NewResetter(db, "public").qualified("audit.log"))
+}
@@ -0,0 +1,286 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"sync"
+	"testing"
+)
+
+// rollbackSavepoint is the savepoint standing in for the transaction of the code under test.
+const rollbackSavepoint = "uow_test_tx"
+
+// rollbackTxContext is the transaction context passed to the tests of WithRollbackTx. Everything runs in
+// the transaction of the test; the outermost Begin of the code under test sets a savepoint instead of
+// beginning a transaction, its Commit releases the savepoint and its Rollback rolls back to it, so the
+// code sees the usual semantics of a transaction context while nothing is ever committed.
+type rollbackTxContext struct {
+	tx postgres.ITransactionContext // Transaction context running the transaction of the test.
+
+	mu            sync.Mutex     // Guards the fields below.
+	owner         *uuid.UUID     // UUID of the outermost Begin of the code under test, nil outside it.
+	rollbacked    bool           // Set by Rollback until Reset.
+	afterCommit   []func()       // Hooks run once the owner commits.
+	beforeCommit  []func() error // Hooks run before the owner commits.
+	afterRollback []func()       // Hooks run once the code under test rolls back.
+}
+
+var _ postgres.ITransactionContext = (*rollbackTxContext)(nil)
+
+// WithRollbackTx runs fn in a transaction of the default holder that is rolled back at the end, so
+// integration tests need no per-test truncation. The transaction context in the ctx passed to fn joins
+// that transaction: Begin, Commit and Rollback of the code under test use a savepoint, and Provider
+// always returns the transaction of the test.
+// Example:
+//
+//	testutil.WithRollbackTx(t, func(ctx context.Context) {
+//	  assert.NoError(t, service.PlaceOrder(ctx, order))
+//	  txContext, _ := postgres.GetTransactionContext(ctx)
+//	  var count int
+//	  txContext.Provider().Model(&Order{}).Count(&count)
+//	  assert.Equal(t, 1, count)
+//	})
+func WithRollbackTx(t testing.TB, fn func(ctx context.Context)) {
+	WithRollbackTxFactory(t, nil, fn)
+}
+
+// WithRollbackTxFactory is WithRollbackTx for a transaction context of factory; nil uses the default holder.
+func WithRollbackTxFactory(t testing.TB, factory *postgres.TransactionContextFactory, fn func(ctx context.Context)) {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), postgres.TransactionContextKey, nil)
+	var tx postgres.ITransactionContext
+	if factory != nil {
+		tx, _ = factory.GetTransactionContext(ctx)
+	} else {
+		tx, _ = postgres.GetTransactionContext(ctx)
+	}
+	if _, err := tx.Begin(); err != nil {
+		t.Fatalf("cannot begin test transaction: %s", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			t.Errorf("cannot roll back test transaction: %s", err)
+		}
+	}()
+
+	fn(context.WithValue(ctx, postgres.TransactionContextKey, &rollbackTxContext{tx: tx}))
+}
+
+// Begin implements postgres.ITransactionContext.
+func (c *rollbackTxContext) Begin() (uuid.UUID, error) {
+	return c.BeginWithOptions(postgres.TxOptions{})
+}
+
+// BeginContext implements postgres.ITransactionContext; the savepoint is set without waiting for a connection.
+func (c *rollbackTxContext) BeginContext(ctx context.Context) (uuid.UUID, error) {
+	if err := ctx.Err(); err != nil {
+		return uuid.UUID{}, err
+	}
+	return c.BeginWithOptions(postgres.TxOptions{})
+}
+
+// BeginReadOnly implements postgres.ITransactionContext; the test transaction is not read-only.
+func (c *rollbackTxContext) BeginReadOnly() (uuid.UUID, error) {
+	return c.BeginWithOptions(postgres.TxOptions{ReadOnly: true})
+}
+
+// BeginWithOptions implements postgres.ITransactionContext. The options of the test transaction apply.
+func (c *rollbackTxContext) BeginWithOptions(postgres.TxOptions) (uuid.UUID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rollbacked {
+		return uuid.UUID{}, postgres.ErrTxWasRollbacked
+	}
+	id, err := uuid.NewRandom()
+	if err != nil || c.owner != nil {
+		return id, err
+	}
+	if err := c.exec("SAVEPOINT " + rollbackSavepoint); err != nil {
+		return uuid.UUID{}, err
+	}
+	c.owner = &id
+	return id, nil
+}
+
+// Commit implements postgres.ITransactionContext; the owner releases the savepoint.
+func (c *rollbackTxContext) Commit(id uuid.UUID) error {
+	c.mu.Lock()
+	if c.rollbacked {
+		c.mu.Unlock()
+		return postgres.ErrTxWasRollbacked
+	}
+	if c.owner == nil {
+		c.mu.Unlock()
+		return postgres.ErrNotInTransaction
+	}
+	if *c.owner != id {
+		c.mu.Unlock()
+		return nil
+	}
+	beforeCommit := c.beforeCommit
+	c.mu.Unlock()
+
+	for _, hook := range beforeCommit {
+		if err := hook(); err != nil {
+			_ = c.Rollback()
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	err := c.exec("RELEASE SAVEPOINT " + rollbackSavepoint)
+	afterCommit := c.afterCommit
+	c.clear()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, hook := range afterCommit {
+		hook()
+	}
+	return nil
+}
+
+// Rollback implements postgres.ITransactionContext; it rolls back to the savepoint, if set.
+func (c *rollbackTxContext) Rollback() error {
+	c.mu.Lock()
+	if c.rollbacked {
+		c.mu.Unlock()
+		return postgres.ErrTxWasRollbacked
+	}
+	if c.owner == nil {
+		c.mu.Unlock()
+		return nil
+	}
+	err := c.exec("ROLLBACK TO SAVEPOINT " + rollbackSavepoint)
+	if err == nil {
+		err = c.exec("RELEASE SAVEPOINT " + rollbackSavepoint)
+	}
+	afterRollback := c.afterRollback
+	c.clear()
+	c.rollbacked = true
+	c.mu.Unlock()
+
+	for _, hook := range afterRollback {
+		hook()
+	}
+	return err
+}
+
+// Reset implements postgres.ITransactionContext.
+func (c *rollbackTxContext) Reset() error {
+	if err := c.Rollback(); err != nil && err != postgres.ErrTxWasRollbacked {
+		return err
+	}
+	c.mu.Lock()
+	c.rollbacked = false
+	c.mu.Unlock()
+	return nil
+}
+
+// Provider implements postgres.ITransactionContext; it returns the transaction of the test.
+func (c *rollbackTxContext) Provider() *gorm.DB {
+	c.mu.Lock()
+	rollbacked := c.rollbacked
+	c.mu.Unlock()
+	if rollbacked {
+		return nil
+	}
+	return c.tx.Provider()
+}
+
+// ProviderWithContext implements postgres.ITransactionContext.
+func (c *rollbackTxContext) ProviderWithContext(ctx context.Context) *gorm.DB {
+	if c.Provider() == nil {
+		return nil
+	}
+	return c.tx.ProviderWithContext(ctx)
+}
+
+// RegisterAfterCommit implements postgres.ITransactionContext.
+func (c *rollbackTxContext) RegisterAfterCommit(fn func()) {
+	c.mu.Lock()
+	if c.owner != nil {
+		c.afterCommit = append(c.afterCommit, fn)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	fn()
+}
+
+// RegisterBeforeCommit implements postgres.ITransactionContext.
+func (c *rollbackTxContext) RegisterBeforeCommit(fn func() error) {
+	c.mu.Lock()
+	if c.owner != nil {
+		c.beforeCommit = append(c.beforeCommit, fn)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	_ = fn()
+}
+
+// RegisterAfterRollback implements postgres.ITransactionContext.
+func (c *rollbackTxContext) RegisterAfterRollback(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.owner != nil {
+		c.afterRollback = append(c.afterRollback, fn)
+	}
+}
+
+// Complete implements postgres.ITransactionContext; auto-begin is not supported.
+func (c *rollbackTxContext) Complete() error {
+	return nil
+}
+
+// SavePoint implements postgres.ITransactionContext; the savepoint is set in the transaction of the test.
+func (c *rollbackTxContext) SavePoint(name string) error {
+	if err := c.checkRunning(); err != nil {
+		return err
+	}
+	return c.tx.SavePoint(name)
+}
+
+// RollbackTo implements postgres.ITransactionContext.
+func (c *rollbackTxContext) RollbackTo(name string) error {
+	if err := c.checkRunning(); err != nil {
+		return err
+	}
+	return c.tx.RollbackTo(name)
+}
+
+// checkRunning verifies that the code under test runs a transaction.
+func (c *rollbackTxContext) checkRunning() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rollbacked {
+		return postgres.ErrTxWasRollbacked
+	}
+	if c.owner == nil {
+		return postgres.ErrNotInTransaction
+	}
+	return nil
+}
+
+// exec runs a savepoint statement in the transaction of the test.
+func (c *rollbackTxContext) exec(statement string) error {
+	db := c.tx.Provider()
+	if db == nil {
+		return postgres.ErrTxWasRollbacked
+	}
+	if err := db.Exec(statement).Error; err != nil {
+		return fmt.Errorf("%s: %w", statement, err)
+	}
+	return nil
+}
+
+// clear forgets the transaction of the code under test.
+func (c *rollbackTxContext) clear() {
+	c.owner = nil
+	c.afterCommit, c.beforeCommit, c.afterRollback = nil, nil, nil
+}
@@ -0,0 +1,53 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test WithRollbackTx to verify units of work run on savepoints of a transaction that is rolled back
+func TestWithRollbackTx(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	factory := postgres.NewTransactionContextFactory(postgres.NewDBHolder(db))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT uow_test_tx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO orders DEFAULT VALUES").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT uow_test_tx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT uow_test_tx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT uow_test_tx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT uow_test_tx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	WithRollbackTxFactory(t, factory, func(ctx context.Context) {
+		committed := false
+		err := postgres.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+			txContext, _ := postgres.GetTransactionContext(ctx)
+			txContext.RegisterAfterCommit(func() { committed = true })
+			return db.Exec("INSERT INTO orders DEFAULT VALUES").Error
+		})
+		assert.NoError(t, err)
+		assert.True(t, committed)
+
+		failure := errors.New("failed")
+		err = postgres.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+
+		txContext, _ := postgres.GetTransactionContext(ctx)
+		assert.Nil(t, txContext.Provider())
+		assert.NoError(t, txContext.Reset())
+		assert.NotNil(t, txContext.Provider())
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
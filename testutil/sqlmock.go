@@ -0,0 +1,61 @@
+package testutil
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"testing"
+)
+
+// SQLMock wires go-sqlmock into a DatabaseHolder, so repository tests can assert the exact SQL their units
+// of work execute, including BEGIN and COMMIT, without a database or the singleton holder.
+type SQLMock struct {
+	sqlmock.Sqlmock                                     // Sqlmock sets the expected statements.
+	DB              *gorm.DB                            // DB is the GORM connection over the mock.
+	Holder          *postgres.DatabaseHolder            // Holder wraps DB.
+	Factory         *postgres.TransactionContextFactory // Factory creates transaction contexts of Holder.
+}
+
+// NewSQLMock creates an SQLMock matching statements exactly. Once the test has completed it fails the test
+// if expectations are unmet, and closes the connection.
+// Example:
+//
+//	mock := testutil.NewSQLMock(t)
+//	mock.ExpectBegin()
+//	mock.ExpectExec(`UPDATE orders SET status = $1 WHERE id = $2`).WithArgs("paid", 1).
+//	  WillReturnResult(sqlmock.NewResult(0, 1))
+//	mock.ExpectCommit()
+//	_, ctx := mock.TransactionContext(context.Background())
+//	assert.NoError(t, repository.MarkPaid(ctx, 1))
+func NewSQLMock(t testing.TB) *SQLMock {
+	t.Helper()
+	return NewSQLMockWithMatcher(t, sqlmock.QueryMatcherEqual)
+}
+
+// NewSQLMockWithMatcher is NewSQLMock matching statements with matcher, e.g. sqlmock.QueryMatcherRegexp.
+func NewSQLMockWithMatcher(t testing.TB, matcher sqlmock.QueryMatcher) *SQLMock {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatalf("cannot create sqlmock: %s", err)
+	}
+	db, err := gorm.Open("postgres", sqlDB)
+	if err != nil {
+		t.Fatalf("cannot open sqlmock: %s", err)
+	}
+	holder := postgres.NewDBHolderFromDB(db)
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("sqlmock: %s", err)
+		}
+		_ = db.Close()
+	})
+	return &SQLMock{Sqlmock: mock, DB: db, Holder: holder, Factory: postgres.NewTransactionContextFactory(holder)}
+}
+
+// TransactionContext returns a transaction context of the mock and ctx carrying it, ignoring the
+// transaction context ctx may already carry.
+func (m *SQLMock) TransactionContext(ctx context.Context, opts ...postgres.TransactionContextOption) (postgres.ITransactionContext, context.Context) {
+	return m.Factory.GetTransactionContext(context.WithValue(ctx, postgres.TransactionContextKey, nil), opts...)
+}
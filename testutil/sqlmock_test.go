@@ -0,0 +1,25 @@
+package testutil
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test NewSQLMock to verify units of work of its transaction contexts run on the mock
+func TestNewSQLMock(t *testing.T) {
+	mock := NewSQLMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE orders SET status = $1 WHERE id = $2`).WithArgs("paid", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, ctx := mock.TransactionContext(context.Background())
+	err := postgres.RunInTransaction(ctx, func(ctx context.Context, db *gorm.DB) error {
+		return db.Exec("UPDATE orders SET status = ? WHERE id = ?", "paid", 1).Error
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,80 @@
+package uow
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+type (
+	// ActorFunc extracts the acting user, e.g. a user ID, from a request context.
+	// The second result reports whether the context carries one.
+	ActorFunc func(ctx context.Context) (interface{}, bool)
+
+	// actorKey is the context key of the actor stored by ContextWithActor.
+	actorKey struct{}
+)
+
+// ContextWithActor returns a copy of ctx carrying actor, the user writes are attributed to.
+// Example:
+//
+//	ctx = uow.ContextWithActor(r.Context(), session.UserID)
+func ContextWithActor(ctx context.Context, actor interface{}) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by ContextWithActor. It is the default ActorFunc.
+func ActorFromContext(ctx context.Context) (interface{}, bool) {
+	actor := ctx.Value(actorKey{})
+	return actor, actor != nil
+}
+
+// RegisterAuditFields registers GORM callbacks on db filling the audit fields of models from the context
+// bound by WithContext, so every write through ITransactionContext.ProviderWithContext is attributed:
+// creates set created_by and updated_by, updates set updated_by. The actor is taken from ctx by actor,
+// or by ActorFromContext if actor is nil. Models without these columns, and operations without an actor,
+// are left unchanged; updated_at is maintained by GORM itself.
+// Example:
+//
+//	type Invoice struct {
+//	  ID        int
+//	  CreatedBy string
+//	  UpdatedBy string
+//	  UpdatedAt time.Time
+//	}
+//
+//	uow.RegisterAuditFields(db, func(ctx context.Context) (interface{}, bool) {
+//	  claims, ok := auth.ClaimsFromContext(ctx)
+//	  return claims.Subject, ok
+//	})
+func RegisterAuditFields(db *gorm.DB, actor ActorFunc) {
+	if actor == nil {
+		actor = ActorFromContext
+	}
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:create").Register("uow:audit_create", func(scope *gorm.Scope) {
+		setAuditFields(scope, actor, "created_by", "updated_by")
+	})
+	callbacks.Update().Before("gorm:update").Register("uow:audit_update", func(scope *gorm.Scope) {
+		setAuditFields(scope, actor, "updated_by")
+	})
+}
+
+// setAuditFields sets the given columns of the model of scope to the actor of the bound context.
+func setAuditFields(scope *gorm.Scope, actor ActorFunc, columns ...string) {
+	if scope.HasError() {
+		return
+	}
+	ctx, ok := ContextFromScope(scope)
+	if !ok {
+		return
+	}
+	user, ok := actor(ctx)
+	if !ok {
+		return
+	}
+	for _, column := range columns {
+		if _, found := scope.FieldByName(column); found {
+			_ = scope.SetColumn(column, user)
+		}
+	}
+}
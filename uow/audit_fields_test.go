@@ -0,0 +1,45 @@
+package uow
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type auditedInvoice struct {
+	ID        int
+	Total     int
+	CreatedBy string
+	UpdatedBy string
+}
+
+// Test that writes through a bound context are attributed to its actor
+func TestRegisterAuditFields(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	RegisterAuditFields(db, nil)
+	ctx := ContextWithActor(context.Background(), "ann")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "audited_invoices" ("total","created_by","updated_by") VALUES ($1,$2,$3) RETURNING "audited_invoices"."id"`).
+		WithArgs(10, "ann", "ann").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "audited_invoices" SET "total" = $1, "updated_by" = $2  WHERE "audited_invoices"."id" = $3`).
+		WithArgs(20, "bob", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	invoice := auditedInvoice{Total: 10}
+	assert.NoError(t, WithContext(db, ctx).Create(&invoice).Error)
+	assert.Equal(t, "ann", invoice.CreatedBy)
+
+	ctx = ContextWithActor(ctx, "bob")
+	assert.NoError(t, WithContext(db, ctx).Model(&invoice).Updates(map[string]interface{}{"total": 20}).Error)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,23 @@
+package uow
+
+import "context"
+
+// UntilBegun calls cancel, aborting the start of a transaction, as soon as ctx is done. The returned func
+// must be called once the transaction has begun, or failed to: it stops watching ctx and returns the error
+// of ctx if ctx was done in the meantime, in which case the transaction must be rolled back. It implements
+// ITransactionContext.BeginContext for the backends, whose transactions outlive the context of BeginContext.
+// Example:
+//
+//	txCtx, cancel := context.WithCancel(context.Background())
+//	begun := uow.UntilBegun(ctx, cancel)
+//	tx := db.BeginTx(txCtx, nil)
+//	if err := begun(); err != nil { tx.Rollback(); return err }
+func UntilBegun(ctx context.Context, cancel context.CancelFunc) func() error {
+	stop := context.AfterFunc(ctx, cancel)
+	return func() error {
+		if !stop() {
+			return ctx.Err()
+		}
+		return nil
+	}
+}
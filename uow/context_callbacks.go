@@ -0,0 +1,46 @@
+package uow
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+)
+
+// contextScopeKey is the GORM scope setting carrying the context bound by WithContext.
+const contextScopeKey = "uow:context"
+
+// RegisterContextCallbacks registers GORM callbacks on db that abort Create, Query, Update, Delete and
+// row queries of a *gorm.DB bound by WithContext once its context is done.
+// Transaction contexts register them when their DatabaseHolder is created.
+func RegisterContextCallbacks(db *gorm.DB) {
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:begin_transaction").Register("uow:abort_create_if_done", abortIfContextDone)
+	callbacks.Update().Before("gorm:begin_transaction").Register("uow:abort_update_if_done", abortIfContextDone)
+	callbacks.Delete().Before("gorm:begin_transaction").Register("uow:abort_delete_if_done", abortIfContextDone)
+	callbacks.Query().Before("gorm:query").Register("uow:abort_query_if_done", abortIfContextDone)
+	callbacks.RowQuery().Before("gorm:row_query").Register("uow:abort_row_query_if_done", abortIfContextDone)
+}
+
+// WithContext binds ctx to db: operations started after ctx is done fail with ctx.Err().
+// GORM v1 does not pass contexts to the driver, so a statement already running is not interrupted
+// and raw Exec calls are not checked.
+func WithContext(db *gorm.DB, ctx context.Context) *gorm.DB {
+	return db.Set(contextScopeKey, ctx)
+}
+
+// ContextFromScope returns the context bound to the *gorm.DB of scope by WithContext, for use in callbacks.
+func ContextFromScope(scope *gorm.Scope) (context.Context, bool) {
+	value, ok := scope.Get(contextScopeKey)
+	if !ok {
+		return nil, false
+	}
+	ctx, ok := value.(context.Context)
+	return ctx, ok
+}
+
+// abortIfContextDone fails the operation and skips the remaining callbacks if the bound context is done.
+func abortIfContextDone(scope *gorm.Scope) {
+	if ctx, ok := ContextFromScope(scope); ok && ctx.Err() != nil {
+		_ = scope.Err(ctx.Err())
+		scope.SkipLeft()
+	}
+}
@@ -0,0 +1,129 @@
+package uow
+
+import (
+	"context"
+	log "github.com/public-forge/go-logger"
+	"sync"
+)
+
+type (
+	// TransactionHooks is the part of a transaction context the EventCollector relies on.
+	// It is implemented by the transaction contexts of every backend.
+	TransactionHooks interface {
+		RegisterAfterCommit(func())   // Registers a function to run after the transaction commits.
+		RegisterAfterRollback(func()) // Registers a function to run after the transaction rolls back.
+	}
+
+	// EventDispatcher receives the events of committed transactions.
+	EventDispatcher interface {
+		Dispatch(event interface{})
+	}
+
+	// EventDispatcherFunc adapts a function to an EventDispatcher.
+	EventDispatcherFunc func(event interface{})
+
+	// EventCollector records domain events during a transaction and hands them to its dispatchers only
+	// after the transaction has been committed. Events of rolled back transactions are discarded.
+	EventCollector struct {
+		hooks       TransactionHooks  // Transaction context the events are tied to.
+		dispatchers []EventDispatcher // Dispatchers receiving the flushed events, in order.
+		logger      log.Logger        // Logs panicking dispatchers.
+
+		mu      sync.Mutex    // Guards pending.
+		pending []interface{} // Events recorded in the running transaction, in order.
+	}
+
+	// eventCollectorKey is the context key of the EventCollector stored by ContextWithEventCollector.
+	eventCollectorKey struct{}
+)
+
+// Dispatch implements EventDispatcher.
+func (f EventDispatcherFunc) Dispatch(event interface{}) {
+	f(event)
+}
+
+// NewEventCollector creates an EventCollector for the transactions of hooks.
+// Example:
+//
+//	txContext, ctx := postgres.GetTransactionContext(ctx)
+//	events := uow.NewEventCollector(txContext, uow.EventDispatcherFunc(bus.Publish))
+//	ctx = uow.ContextWithEventCollector(ctx, events)
+func NewEventCollector(hooks TransactionHooks, dispatchers ...EventDispatcher) *EventCollector {
+	return &EventCollector{hooks: hooks, dispatchers: dispatchers, logger: log.FromDefaultContext()}
+}
+
+// Record adds events to the running transaction. They are dispatched in recording order once the
+// transaction commits, or immediately if no transaction is running.
+// Example:
+//
+//	events.Record(OrderPlaced{ID: order.ID})
+func (c *EventCollector) Record(events ...interface{}) {
+	c.mu.Lock()
+	first := len(c.pending) == 0
+	c.pending = append(c.pending, events...)
+	c.mu.Unlock()
+
+	if first && len(events) > 0 {
+		c.hooks.RegisterAfterRollback(c.discard)
+		c.hooks.RegisterAfterCommit(c.flush)
+	}
+}
+
+// Pending returns a copy of the events recorded in the running transaction.
+func (c *EventCollector) Pending() []interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]interface{}(nil), c.pending...)
+}
+
+// flush dispatches the pending events to every dispatcher, isolating panics per dispatcher and event.
+func (c *EventCollector) flush() {
+	c.mu.Lock()
+	events := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	for _, event := range events {
+		for _, dispatcher := range c.dispatchers {
+			c.dispatch(dispatcher, event)
+		}
+	}
+}
+
+// dispatch hands event to dispatcher, recovering and logging a panic.
+func (c *EventCollector) dispatch(dispatcher EventDispatcher, event interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Errorf("event dispatcher panicked on %T: %v", event, r)
+		}
+	}()
+	dispatcher.Dispatch(event)
+}
+
+// discard drops the events of a rolled back transaction.
+func (c *EventCollector) discard() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = nil
+}
+
+// ContextWithEventCollector returns a copy of ctx carrying collector, for use with RecordEvent.
+func ContextWithEventCollector(ctx context.Context, collector *EventCollector) context.Context {
+	return context.WithValue(ctx, eventCollectorKey{}, collector)
+}
+
+// EventCollectorFromContext returns the collector stored by ContextWithEventCollector.
+func EventCollectorFromContext(ctx context.Context) (*EventCollector, bool) {
+	collector, ok := ctx.Value(eventCollectorKey{}).(*EventCollector)
+	return collector, ok
+}
+
+// RecordEvent records events on the collector stored in ctx.
+// It returns false if ctx carries no collector, in which case the events are dropped.
+func RecordEvent(ctx context.Context, events ...interface{}) bool {
+	collector, ok := EventCollectorFromContext(ctx)
+	if ok {
+		collector.Record(events...)
+	}
+	return ok
+}
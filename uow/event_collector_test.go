@@ -0,0 +1,50 @@
+package uow
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// fakeHooks collects the registered hooks so tests can simulate commit and rollback.
+type fakeHooks struct {
+	afterCommit   []func()
+	afterRollback []func()
+}
+
+func (h *fakeHooks) RegisterAfterCommit(fn func())   { h.afterCommit = append(h.afterCommit, fn) }
+func (h *fakeHooks) RegisterAfterRollback(fn func()) { h.afterRollback = append(h.afterRollback, fn) }
+
+func run(hooks []func()) {
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// Test that events are dispatched in order after commit and a panicking dispatcher does not affect the others
+func TestEventCollector_FlushAfterCommit(t *testing.T) {
+	hooks := &fakeHooks{}
+	var received []interface{}
+	collector := NewEventCollector(hooks,
+		EventDispatcherFunc(func(event interface{}) { panic("broken dispatcher") }),
+		EventDispatcherFunc(func(event interface{}) { received = append(received, event) }),
+	)
+
+	collector.Record("created")
+	collector.Record("paid", "shipped")
+	assert.Empty(t, received)
+	assert.Len(t, hooks.afterCommit, 1)
+
+	run(hooks.afterCommit)
+	assert.Equal(t, []interface{}{"created", "paid", "shipped"}, received)
+	assert.Empty(t, collector.Pending())
+}
+
+// Test that events of a rolled back transaction are discarded
+func TestEventCollector_DiscardAfterRollback(t *testing.T) {
+	hooks := &fakeHooks{}
+	collector := NewEventCollector(hooks)
+
+	collector.Record("created")
+	run(hooks.afterRollback)
+	assert.Empty(t, collector.Pending())
+}
@@ -0,0 +1,27 @@
+package uow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors of the savepoints set with ITransactionContext.SavePoint.
+var (
+	ErrInvalidSavePoint = errors.New("invalid savepoint name")                       // ErrInvalidSavePoint occurs when a savepoint name is not a plain identifier.
+	ErrUnknownSavePoint = errors.New("no savepoint of this name in the transaction") // ErrUnknownSavePoint occurs when RollbackTo names a savepoint that was not set.
+)
+
+// CheckSavePointName returns an error wrapping ErrInvalidSavePoint unless name is a plain identifier
+// (letters, digits and underscores, not starting with a digit), which every backend accepts unquoted.
+func CheckSavePointName(name string) error {
+	for i, r := range name {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return fmt.Errorf("%w: %q", ErrInvalidSavePoint, name)
+	}
+	if name == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalidSavePoint)
+	}
+	return nil
+}
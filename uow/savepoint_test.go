@@ -0,0 +1,15 @@
+package uow
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test CheckSavePointName to verify only plain identifiers are accepted
+func TestCheckSavePointName(t *testing.T) {
+	assert.NoError(t, CheckSavePointName("batch_42"))
+	assert.NoError(t, CheckSavePointName("_item"))
+	for _, name := range []string{"", "1st", "item-1", "a; ROLLBACK", `"quoted"`} {
+		assert.ErrorIs(t, CheckSavePointName(name), ErrInvalidSavePoint, name)
+	}
+}
@@ -0,0 +1,90 @@
+// Package uow defines the database-agnostic unit-of-work contract implemented by the postgres, mysql and mssql
+// packages, so repositories can depend on ITransactionContext without knowing which database backs it.
+package uow
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// Important errors related to transaction handling.
+var (
+	ErrTxWasRollbacked  = errors.New("the transaction has been rollbacked")               // ErrTxWasRollbacked occurs when a rollback has already been performed.
+	ErrNotInTransaction = errors.New("not in a transaction, Begin() has not been called") // ErrNotInTransaction occurs when a transaction is expected but not started.
+
+	ErrNotTransactionOwner = errors.New("commit by a caller that does not own the transaction") // ErrNotTransactionOwner occurs in strict-commit mode when Commit is called with a non-owner UUID.
+)
+
+//go:generate mockgen -source=transaction_context.go -destination=./mock_transaction_context.go -package=uow
+type (
+	// ITransactionContext provides methods for handling transactions, including nested transactions.
+	//
+	// Begin() starts a new transaction and returns a UUID to identify it.
+	// Example:
+	//   txContext, _ := postgres.GetTransactionContext(ctx)
+	//   id, err := txContext.Begin()
+	//   if err != nil { return err }
+	//
+	// BeginContext() is Begin() giving up with the error of ctx once ctx is done before the transaction has begun,
+	// e.g. while waiting for a connection of an exhausted pool.
+	//   id, err := txContext.BeginContext(ctx)
+	//
+	// BeginWithOptions() starts a transaction with a specific isolation level or in read-only mode.
+	//   id, err := txContext.BeginWithOptions(uow.TxOptions{Isolation: sql.LevelSerializable})
+	//
+	// BeginReadOnly() starts a transaction that rejects writes, for query-only handlers.
+	//   id, err := txContext.BeginReadOnly()
+	//
+	// Commit() expects the transaction ID to confirm the transaction’s ownership.
+	//   err := txContext.Commit(id)
+	//   if err != nil { return err }
+	//
+	// Rollback() affects the transaction at any level and is recommended to handle any errors.
+	//   defer txContext.Rollback() // ensure rollback on any error
+	//
+	// Reset() makes a rolled back context usable again, e.g. to write an error-audit record.
+	//   _ = txContext.Reset()
+	//
+	// Provider() returns the *gorm.DB instance, used for database operations within the transaction.
+	//   db := txContext.Provider()
+	//   db.Create(&modelInstance)
+	//
+	// ProviderWithContext() binds the caller's context to the returned *gorm.DB.
+	//   db := txContext.ProviderWithContext(ctx)
+	//
+	// RegisterAfterCommit() defers work until the transaction has been committed.
+	//   txContext.RegisterAfterCommit(func() { publisher.Publish(event) })
+	//
+	// RegisterBeforeCommit() runs last-moment validations inside the transaction; an error aborts the commit.
+	//   txContext.RegisterBeforeCommit(func() error { return order.Validate() })
+	//
+	// RegisterAfterRollback() cleans up once the transaction has been rolled back.
+	//   txContext.RegisterAfterRollback(func() { _ = os.Remove(tmpFile) })
+	//
+	// Complete() commits the transaction started implicitly in auto-begin mode.
+	//   err := txContext.Complete()
+	//
+	// SavePoint() and RollbackTo() checkpoint a long transaction, e.g. to skip a failing item of a batch.
+	//   if err := txContext.SavePoint("item"); err != nil { return err }
+	//   if err := process(item); err != nil { _ = txContext.RollbackTo("item") }
+	//
+	ITransactionContext interface {
+		Begin() (uuid.UUID, error)                       // Begins a transaction and returns its UUID.
+		BeginContext(context.Context) (uuid.UUID, error) // Begins a transaction, giving up once the context is done, and returns its UUID.
+		BeginWithOptions(TxOptions) (uuid.UUID, error)   // Begins a transaction with the given options and returns its UUID.
+		BeginReadOnly() (uuid.UUID, error)               // Begins a read-only transaction and returns its UUID.
+		Commit(uuid.UUID) error                          // Commits the transaction if the caller holds the transaction UUID.
+		Rollback() error                                 // Rolls back the transaction.
+		Reset() error                                    // Clears the rolled back state so new transactions can begin.
+		Provider() *gorm.DB                              // Returns the *gorm.DB instance for performing database operations.
+		ProviderWithContext(context.Context) *gorm.DB    // Returns Provider() bound to the given context.
+		RegisterAfterCommit(func())                      // Registers a function to run after the transaction commits.
+		RegisterBeforeCommit(func() error)               // Registers a function to run before the transaction commits.
+		RegisterAfterRollback(func())                    // Registers a function to run after the transaction rolls back.
+		Complete() error                                 // Commits the transaction begun implicitly in auto-begin mode.
+		SavePoint(string) error                          // Sets a named savepoint in the running transaction.
+		RollbackTo(string) error                         // Rolls the running transaction back to a named savepoint, keeping it.
+	}
+)
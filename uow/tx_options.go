@@ -0,0 +1,71 @@
+package uow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrIncompatibleTxOptions occurs when a nested Begin requests options the running transaction cannot honour.
+var ErrIncompatibleTxOptions = errors.New("requested transaction options are incompatible with the running transaction")
+
+// txOptionsKey is the context key of the options of WithTxOptions.
+type txOptionsKey struct{}
+
+// TxOptions holds the options a transaction is started with.
+// The zero value starts a read-write transaction with the server's default isolation level.
+type TxOptions struct {
+	Isolation sql.IsolationLevel // Isolation is the isolation level (e.g., sql.LevelSerializable).
+	ReadOnly  bool               // ReadOnly starts a transaction that rejects writes.
+	Name      string             // Name tags the transaction in diagnostics, e.g. "checkout.placeOrder"; a nested Begin keeps the outer name.
+}
+
+// SQLOptions converts the options to the database/sql representation.
+func (o TxOptions) SQLOptions() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly}
+}
+
+// CompatibleWith reports whether a nested Begin requesting o can join a transaction started with running.
+// A nested scope may ask for the default or a weaker isolation level, and may only ask for
+// read-only work inside a read-write transaction, never the other way around.
+func (o TxOptions) CompatibleWith(running TxOptions) bool {
+	if o.Isolation != sql.LevelDefault && o.Isolation > running.Isolation {
+		return false
+	}
+	return o.ReadOnly || !running.ReadOnly
+}
+
+// WithDefaults returns o with the isolation level and name of defaults where o leaves them unset; the
+// transaction is read-only if either asks for it.
+func (o TxOptions) WithDefaults(defaults TxOptions) TxOptions {
+	if o.Isolation == sql.LevelDefault {
+		o.Isolation = defaults.Isolation
+	}
+	if o.Name == "" {
+		o.Name = defaults.Name
+	}
+	o.ReadOnly = o.ReadOnly || defaults.ReadOnly
+	return o
+}
+
+// WithTxOptions returns a copy of ctx carrying opts, so frameworks and middleware can set the options of
+// the transactions of a request before the repositories call GetTransactionContext. The transaction
+// contexts created for ctx start new transactions with opts, filling in what Begin and BeginWithOptions
+// leave unset, see TxOptions.WithDefaults; transactions already running are joined as they are.
+// Example:
+//
+//	func reportsMiddleware(next http.Handler) http.Handler {
+//	  return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//	    ctx := uow.WithTxOptions(r.Context(), uow.TxOptions{ReadOnly: true, Name: "reports"})
+//	    next.ServeHTTP(w, r.WithContext(ctx))
+//	  })
+//	}
+func WithTxOptions(ctx context.Context, opts TxOptions) context.Context {
+	return context.WithValue(ctx, txOptionsKey{}, opts)
+}
+
+// TxOptionsFromContext returns the options set by WithTxOptions on ctx; the zero TxOptions if none are set.
+func TxOptionsFromContext(ctx context.Context) TxOptions {
+	opts, _ := ctx.Value(txOptionsKey{}).(TxOptions)
+	return opts
+}
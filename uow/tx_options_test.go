@@ -0,0 +1,39 @@
+package uow
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Test which nested options may join a running transaction
+func TestTxOptions_CompatibleWith(t *testing.T) {
+	serializable := TxOptions{Isolation: sql.LevelSerializable}
+	readCommitted := TxOptions{Isolation: sql.LevelReadCommitted}
+	readOnly := TxOptions{ReadOnly: true}
+
+	assert.True(t, TxOptions{}.CompatibleWith(serializable))
+	assert.True(t, readCommitted.CompatibleWith(serializable))
+	assert.False(t, serializable.CompatibleWith(readCommitted))
+	assert.True(t, readOnly.CompatibleWith(TxOptions{}))
+	assert.False(t, TxOptions{}.CompatibleWith(readOnly))
+}
+
+// Test WithDefaults to verify unset options are filled in and read-only work stays read-only
+func TestTxOptions_WithDefaults(t *testing.T) {
+	defaults := TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true, Name: "reports"}
+
+	assert.Equal(t, defaults, TxOptions{}.WithDefaults(defaults))
+	assert.Equal(t, TxOptions{Isolation: sql.LevelReadCommitted, ReadOnly: true, Name: "export"},
+		TxOptions{Isolation: sql.LevelReadCommitted, Name: "export"}.WithDefaults(defaults))
+	assert.Equal(t, TxOptions{ReadOnly: true}, TxOptions{ReadOnly: true}.WithDefaults(TxOptions{}))
+}
+
+// Test WithTxOptions to verify the options are carried by the context
+func TestWithTxOptions(t *testing.T) {
+	opts := TxOptions{Isolation: sql.LevelRepeatableRead, Name: "checkout"}
+
+	assert.Equal(t, opts, TxOptionsFromContext(WithTxOptions(context.Background(), opts)))
+	assert.Equal(t, TxOptions{}, TxOptionsFromContext(context.Background()))
+}
@@ -0,0 +1,320 @@
+package uow
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	log "github.com/public-forge/go-logger"
+)
+
+type (
+	// TxEnder sends the statements ending the running transaction of a TxState. The backends implement it
+	// around the transaction of their GORM version.
+	TxEnder interface {
+		CommitTx() error   // CommitTx sends COMMIT.
+		RollbackTx() error // RollbackTx sends ROLLBACK.
+		ReleaseTx()        // ReleaseTx forgets the ended transaction.
+	}
+
+	// TxState is the backend-independent state of a transaction context: ownership of the running
+	// transaction, its hooks, the implicit transaction of auto-begin mode and the rolled back state. The
+	// mysql, mssql and postgresv2 transaction contexts embed it and only begin transactions and set
+	// savepoints in the SQL of their database; Commit, Rollback, Reset, Complete and the Register methods
+	// of ITransactionContext are the ones of TxState.
+	//
+	// The postgres transaction context does not embed it: its Commit closes reference-counted levels and
+	// sends notifications, dry-run rollbacks and commit deadline checks between the before-commit hooks
+	// and COMMIT, its after-commit hooks live on the *gorm.DB of the transaction for the GORM callbacks,
+	// and it observes the cancellation of the transaction when asked whether it was rolled back. It
+	// shares the hook runners, RunHooks and RunBeforeCommitHook.
+	TxState struct {
+		Logger       log.Logger      // Logger for transaction activity.
+		Ctx          context.Context // Context the transaction context was created for; its WithTxOptions apply to every new transaction.
+		StrictCommit bool            // Makes Commit with a non-owner UUID return ErrNotTransactionOwner.
+		AutoBegin    bool            // Begins a transaction implicitly, see BeganImplicitly.
+
+		ender           TxEnder            // Ends the running transaction.
+		transactionUUID *uuid.UUID         // Unique identifier for the transaction.
+		rollbacked      bool               // Indicates if the transaction has been rolled back.
+		txOptions       TxOptions          // Options the running transaction was started with.
+		autoTxUUID      *uuid.UUID         // Owner UUID of the implicitly begun transaction.
+		cancelTx        context.CancelFunc // Releases the context the running transaction was started with.
+
+		afterCommit   []func()       // Hooks executed after a successful commit.
+		beforeCommit  []func() error // Hooks executed inside the transaction before COMMIT is sent.
+		afterRollback []func()       // Hooks executed after the transaction has been rolled back.
+		txCommitted   bool           // Set once the running transaction has been committed.
+	}
+)
+
+// NewTxState creates the state of a transaction context whose transactions are ended by ender.
+func NewTxState(logger log.Logger, ender TxEnder) TxState {
+	return TxState{Logger: logger, Ctx: context.Background(), ender: ender}
+}
+
+// Join prepares Begin: it returns the identifier of the caller and reports whether the caller joins the
+// running transaction, in which case it must not begin one. It fails with ErrTxWasRollbacked once the
+// context has been rolled back and with ErrIncompatibleTxOptions if opts are stricter than the options
+// of the running transaction.
+func (s *TxState) Join(opts TxOptions) (id uuid.UUID, joined bool, err error) {
+	if s.WasRollbacked() {
+		return id, false, ErrTxWasRollbacked
+	}
+	if id, err = uuid.NewRandom(); err != nil {
+		return id, false, err
+	}
+	if !s.InTransaction() {
+		return id, false, nil
+	}
+	if !opts.CompatibleWith(s.txOptions) {
+		return id, false, ErrIncompatibleTxOptions
+	}
+	s.Logger.Debugf("use existing transaction: %v", s.transactionUUID)
+	return id, true, nil
+}
+
+// TxOptionsFor returns opts completed with the options of ctx, the context of Begin, and then with the
+// options of Ctx, read on every call like the postgres transaction contexts do.
+func (s *TxState) TxOptionsFor(ctx context.Context, opts TxOptions) TxOptions {
+	return opts.WithDefaults(TxOptionsFromContext(ctx)).WithDefaults(TxOptionsFromContext(s.Ctx))
+}
+
+// Begun records the transaction id the backend has begun with opts; cancel releases the context it was
+// started with once the transaction has ended.
+func (s *TxState) Begun(id uuid.UUID, opts TxOptions, cancel context.CancelFunc) {
+	s.transactionUUID, s.txOptions, s.cancelTx = &id, opts, cancel
+	s.Logger.Debugf("new transaction: %v", s.transactionUUID)
+}
+
+// BeganImplicitly makes id, the owner of a transaction begun in auto-begin mode, the transaction
+// committed by Complete.
+func (s *TxState) BeganImplicitly(id uuid.UUID) {
+	s.autoTxUUID = &id
+}
+
+// Commit finalizes the transaction, saving changes if the caller holds the transaction UUID.
+func (s *TxState) Commit(id uuid.UUID) error {
+	if s.WasRollbacked() {
+		return ErrTxWasRollbacked
+	}
+
+	if !s.InTransaction() {
+		return ErrNotInTransaction
+	}
+
+	// Only the transaction owner can commit.
+	if *s.transactionUUID != id {
+		if s.StrictCommit {
+			return ErrNotTransactionOwner
+		}
+		return nil
+	}
+
+	if err := s.runBeforeCommit(); err != nil {
+		err = fmt.Errorf("commit transaction %v: before-commit hook: %w", id, err)
+		_ = s.Rollback()
+		return err
+	}
+
+	afterCommit := s.afterCommit
+	if err := s.commit(); err != nil {
+		return err
+	}
+	s.runHooks("after-commit", afterCommit)
+
+	return nil
+}
+
+// Rollback cancels the transaction and discards changes made within it.
+func (s *TxState) Rollback() error {
+	if s.WasRollbacked() {
+		return ErrTxWasRollbacked
+	}
+	if !s.InTransaction() {
+		s.Logger.Debug("no active transaction to roll back")
+		return nil
+	}
+
+	defer s.disposeAfterRollback()
+
+	if err := s.ender.RollbackTx(); err != nil {
+		s.Logger.Errorf("cannot rollback (%v): %s", s.transactionUUID, err)
+		return fmt.Errorf("rollback transaction %v: %w", s.transactionUUID, err)
+	}
+
+	return nil
+}
+
+// Reset rolls back the running transaction, if any, and clears the rolled back state, so the context can
+// start new transactions, e.g. to write an audit record after a failed business transaction.
+// Example:
+//
+//	if err := placeOrder(ctx); err != nil {
+//	  _ = txContext.Reset()
+//	  _ = audit.RecordFailure(ctx, err)
+//	}
+func (s *TxState) Reset() error {
+	var err error
+	if !s.WasRollbacked() && s.InTransaction() {
+		err = s.Rollback()
+	}
+	s.rollbacked = false
+	return err
+}
+
+// Complete commits the transaction begun implicitly in auto-begin mode.
+// It is a no-op if no implicit transaction is active.
+func (s *TxState) Complete() error {
+	if s.WasRollbacked() {
+		return ErrTxWasRollbacked
+	}
+	if s.autoTxUUID == nil {
+		return nil
+	}
+	return s.Commit(*s.autoTxUUID)
+}
+
+// RegisterAfterCommit registers fn to run once the current transaction has been committed.
+// Without an active transaction fn runs immediately.
+func (s *TxState) RegisterAfterCommit(fn func()) {
+	if !s.InTransaction() {
+		s.runHooks("after-commit", []func(){fn})
+		return
+	}
+	s.afterCommit = append(s.afterCommit, fn)
+}
+
+// RegisterBeforeCommit registers fn to run inside the current transaction right before COMMIT is sent
+// by the owner. Hooks run in registration order; the first error (or panic) rolls the transaction back
+// and is returned by Commit. Without an active transaction fn runs immediately and its error is logged.
+// Example:
+//
+//	txContext.RegisterBeforeCommit(func() error { return order.Validate(txContext.Provider()) })
+func (s *TxState) RegisterBeforeCommit(fn func() error) {
+	if !s.InTransaction() {
+		if err := RunBeforeCommitHook(fn); err != nil {
+			s.Logger.Errorf("before-commit hook failed: %s", err)
+		}
+		return
+	}
+	s.beforeCommit = append(s.beforeCommit, fn)
+}
+
+// RegisterAfterRollback registers fn to run once the current transaction has been rolled back, including
+// rollbacks caused by a failed commit. Hooks run in registration order after the transaction is disposed.
+// They are discarded on commit. Without an active transaction fn is discarded.
+// Example:
+//
+//	txContext.RegisterAfterRollback(func() { _ = os.Remove(tmpFile) })
+func (s *TxState) RegisterAfterRollback(fn func()) {
+	if !s.InTransaction() {
+		s.Logger.Debug("no active transaction, discarding after-rollback hook")
+		return
+	}
+	s.afterRollback = append(s.afterRollback, fn)
+}
+
+// CheckSavePoint verifies name and that a transaction is running, before the backend sets or rolls back
+// to the savepoint name.
+func (s *TxState) CheckSavePoint(name string) error {
+	if err := CheckSavePointName(name); err != nil {
+		return err
+	}
+	if s.WasRollbacked() {
+		return ErrTxWasRollbacked
+	}
+	if !s.InTransaction() {
+		return ErrNotInTransaction
+	}
+	return nil
+}
+
+// InTransaction checks if a transaction is currently active.
+func (s *TxState) InTransaction() bool {
+	return s.transactionUUID != nil
+}
+
+// WasRollbacked returns true if the transaction has already been rolled back.
+func (s *TxState) WasRollbacked() bool {
+	return s.rollbacked
+}
+
+// commit sends COMMIT and disposes of the transaction regardless of the outcome.
+func (s *TxState) commit() error {
+	defer s.dispose()
+
+	if err := s.ender.CommitTx(); err != nil {
+		s.Logger.Errorf("cannot commit transaction: %v; err: %s", s.transactionUUID, err)
+		return fmt.Errorf("commit transaction %v: %w", s.transactionUUID, err)
+	}
+	s.txCommitted = true
+
+	return nil
+}
+
+// runHooks executes hooks in order, see RunHooks.
+func (s *TxState) runHooks(kind string, hooks []func()) {
+	RunHooks(s.Logger, kind, hooks)
+}
+
+// runBeforeCommit executes the before-commit hooks in order, stopping at the first failure.
+// Hooks registered by a running hook are executed in the same pass.
+func (s *TxState) runBeforeCommit() error {
+	for i := 0; i < len(s.beforeCommit); i++ {
+		if err := RunBeforeCommitHook(s.beforeCommit[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunHooks executes the kind hooks in order, recovering and logging panics with logger so one hook cannot
+// break the others. The transaction contexts whose state is not a TxState run their hooks with it.
+func RunHooks(logger log.Logger, kind string, hooks []func()) {
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Errorf("%s hook panicked: %v", kind, r)
+				}
+			}()
+			hook()
+		}()
+	}
+}
+
+// RunBeforeCommitHook executes hook, a before-commit hook, turning a panic into an error.
+func RunBeforeCommitHook(hook func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("before-commit hook panicked: %v", r)
+		}
+	}()
+	return hook()
+}
+
+// dispose clears transaction data after a successful commit or rollback.
+func (s *TxState) dispose() {
+	s.Logger.Debugf("disposing transaction (%v)", s.transactionUUID)
+	afterRollback, committed := s.afterRollback, s.txCommitted
+	if s.cancelTx != nil {
+		s.cancelTx()
+		s.cancelTx = nil
+	}
+	s.ender.ReleaseTx()
+	s.transactionUUID = nil
+	s.afterCommit = nil
+	s.autoTxUUID = nil
+	s.beforeCommit = nil
+	s.afterRollback = nil
+	s.txCommitted = false
+	if !committed {
+		s.runHooks("after-rollback", afterRollback)
+	}
+}
+
+// disposeAfterRollback marks the transaction as rolled back and disposes of it.
+func (s *TxState) disposeAfterRollback() {
+	s.rollbacked = true
+	s.dispose()
+}
@@ -0,0 +1,129 @@
+package uow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	log "github.com/public-forge/go-logger"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// recordingEnder is a TxEnder recording the statements it was asked to send.
+type recordingEnder struct {
+	sent      []string
+	commitErr error
+}
+
+// CommitTx implements TxEnder.
+func (e *recordingEnder) CommitTx() error {
+	e.sent = append(e.sent, "COMMIT")
+	return e.commitErr
+}
+
+// RollbackTx implements TxEnder.
+func (e *recordingEnder) RollbackTx() error {
+	e.sent = append(e.sent, "ROLLBACK")
+	return nil
+}
+
+// ReleaseTx implements TxEnder.
+func (e *recordingEnder) ReleaseTx() {
+	e.sent = append(e.sent, "release")
+}
+
+// begin simulates the backend beginning a transaction of s.
+func begin(t *testing.T, s *TxState, opts TxOptions) (id uuid.UUID, joined bool) {
+	id, joined, err := s.Join(opts)
+	assert.NoError(t, err)
+	if !joined {
+		s.Begun(id, s.TxOptionsFor(context.Background(), opts), func() {})
+	}
+	return id, joined
+}
+
+// Test that only the owner commits, the hooks run once committed and nested calls join
+func TestTxState_Commit(t *testing.T) {
+	ender := &recordingEnder{}
+	s := NewTxState(log.FromDefaultContext(), ender)
+
+	outer, _ := begin(t, &s, TxOptions{})
+	inner, joined := begin(t, &s, TxOptions{})
+	assert.True(t, joined)
+	committed := false
+	s.RegisterAfterCommit(func() { committed = true })
+
+	assert.NoError(t, s.Commit(inner))
+	assert.False(t, committed)
+	assert.NoError(t, s.Commit(outer))
+	assert.True(t, committed)
+	assert.False(t, s.InTransaction())
+	assert.Equal(t, []string{"COMMIT", "release"}, ender.sent)
+	assert.ErrorIs(t, s.Commit(outer), ErrNotInTransaction)
+}
+
+// Test that a failing before-commit hook rolls back, runs the after-rollback hooks and poisons the state
+func TestTxState_BeforeCommitFailure(t *testing.T) {
+	ender := &recordingEnder{}
+	s := NewTxState(log.FromDefaultContext(), ender)
+	failure := errors.New("invalid order")
+
+	id, _ := begin(t, &s, TxOptions{})
+	rolledBack := false
+	s.RegisterBeforeCommit(func() error { return failure })
+	s.RegisterAfterRollback(func() { rolledBack = true })
+
+	assert.ErrorIs(t, s.Commit(id), failure)
+	assert.True(t, rolledBack)
+	assert.True(t, s.WasRollbacked())
+	assert.Equal(t, []string{"ROLLBACK", "release"}, ender.sent)
+	_, _, err := s.Join(TxOptions{})
+	assert.ErrorIs(t, err, ErrTxWasRollbacked)
+	assert.NoError(t, s.Reset())
+	assert.False(t, s.WasRollbacked())
+}
+
+// Test that strict commit rejects non-owners and Complete commits the implicit transaction
+func TestTxState_StrictCommitAndComplete(t *testing.T) {
+	ender := &recordingEnder{}
+	s := NewTxState(log.FromDefaultContext(), ender)
+	s.StrictCommit = true
+
+	id, _ := begin(t, &s, TxOptions{})
+	s.BeganImplicitly(id)
+	inner, _ := begin(t, &s, TxOptions{})
+	assert.ErrorIs(t, s.Commit(inner), ErrNotTransactionOwner)
+	assert.NoError(t, s.Complete())
+	assert.NoError(t, s.Complete())
+	assert.Equal(t, []string{"COMMIT", "release"}, ender.sent)
+}
+
+// Test TxOptionsFor to verify the options of Ctx are read on every call and nested stricter options fail
+func TestTxState_TxOptionsFor(t *testing.T) {
+	s := NewTxState(log.FromDefaultContext(), &recordingEnder{})
+	s.Ctx = WithTxOptions(context.Background(), TxOptions{ReadOnly: true})
+	assert.Equal(t, TxOptions{ReadOnly: true}, s.TxOptionsFor(context.Background(), TxOptions{}))
+
+	s.Ctx = WithTxOptions(context.Background(), TxOptions{Name: "reports"})
+	ctx := WithTxOptions(context.Background(), TxOptions{Isolation: sql.LevelSerializable})
+	assert.Equal(t, TxOptions{Isolation: sql.LevelSerializable, Name: "reports"}, s.TxOptionsFor(ctx, TxOptions{}))
+
+	_, _ = begin(t, &s, TxOptions{})
+	_, _, err := s.Join(TxOptions{Isolation: sql.LevelSerializable})
+	assert.ErrorIs(t, err, ErrIncompatibleTxOptions)
+}
+
+// Test RunHooks and RunBeforeCommitHook to verify a panicking hook does not stop the others
+func TestRunHooks(t *testing.T) {
+	var ran []int
+	RunHooks(log.FromDefaultContext(), "after-commit", []func(){
+		func() { ran = append(ran, 1) },
+		func() { panic("boom") },
+		func() { ran = append(ran, 3) },
+	})
+	assert.Equal(t, []int{1, 3}, ran)
+
+	assert.EqualError(t, RunBeforeCommitHook(func() error { panic("boom") }), "before-commit hook panicked: boom")
+	assert.NoError(t, RunBeforeCommitHook(func() error { return nil }))
+}
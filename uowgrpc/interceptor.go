@@ -0,0 +1,74 @@
+// Package uowgrpc provides gRPC server interceptors running every call in a request-scoped unit of work.
+package uowgrpc
+
+import (
+	"context"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor installs a transaction context into the context of every unary call. The transaction
+// begins lazily on the first write through Provider(), is committed when the handler succeeds and rolled back
+// when it returns an error or panics; the panic is propagated. A failed commit is reported as codes.Aborted.
+// A nil factory uses postgres.GetTransactionContext.
+// Example:
+//
+//	server := grpc.NewServer(grpc.ChainUnaryInterceptor(uowgrpc.UnaryServerInterceptor(factory)))
+func UnaryServerInterceptor(factory *postgres.TransactionContextFactory) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		err = runUnitOfWork(ctx, factory, func(ctx context.Context) error {
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming variant of UnaryServerInterceptor. The unit of work spans the
+// whole stream, so it should only be used for streams that are short-lived.
+func StreamServerInterceptor(factory *postgres.TransactionContextFactory) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return runUnitOfWork(ss.Context(), factory, func(ctx context.Context) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context // Context carrying the transaction context.
+}
+
+// Context returns the context carrying the transaction context.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// runUnitOfWork executes call with an auto-begin transaction context and completes or rolls it back.
+func runUnitOfWork(ctx context.Context, factory *postgres.TransactionContextFactory, call func(ctx context.Context) error) error {
+	var txContext postgres.ITransactionContext
+	if factory != nil {
+		txContext, ctx = factory.GetTransactionContext(ctx, postgres.WithAutoBegin())
+	} else {
+		txContext, ctx = postgres.GetTransactionContext(ctx, postgres.WithAutoBegin())
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = txContext.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := call(ctx); err != nil {
+		_ = txContext.Rollback()
+		return err
+	}
+	if err := txContext.Complete(); err != nil {
+		return status.Errorf(codes.Aborted, "commit failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package uowgrpc
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"testing"
+)
+
+// newTestFactory builds a factory backed by go-sqlmock.
+func newTestFactory(t *testing.T) (*postgres.TransactionContextFactory, sqlmock.Sqlmock, func()) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	return postgres.NewTransactionContextFactory(postgres.NewDBHolder(db)), mock, func() { _ = db.Close() }
+}
+
+// order is written by the handlers of the interceptor tests.
+type order struct {
+	ID     uint
+	Status string
+}
+
+// Test that a successful call commits the lazily begun transaction and a failing one rolls it back
+func TestUnaryServerInterceptor(t *testing.T) {
+	factory, mock, closeDB := newTestFactory(t)
+	defer closeDB()
+	interceptor := UnaryServerInterceptor(factory)
+	useDB := func(ctx context.Context, req interface{}) (interface{}, error) {
+		txContext, _ := factory.GetTransactionContext(ctx)
+		assert.NoError(t, txContext.Provider().Create(&order{Status: req.(string)}).Error)
+		if req == "fail" {
+			return nil, errors.New("failed")
+		}
+		return "ok", nil
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "orders"`).WithArgs("succeed").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	resp, err := interceptor(context.Background(), "succeed", &grpc.UnaryServerInfo{}, useDB)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "orders"`).WithArgs("fail").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectRollback()
+	_, err = interceptor(context.Background(), "fail", &grpc.UnaryServerInfo{}, useDB)
+	assert.EqualError(t, err, "failed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
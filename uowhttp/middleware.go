@@ -0,0 +1,133 @@
+// Package uowhttp provides net/http middleware running every request in a request-scoped unit of work.
+package uowhttp
+
+import (
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	log "github.com/public-forge/go-logger"
+	"net/http"
+)
+
+type (
+	// Option configures the middleware.
+	Option func(*middleware)
+
+	// middleware holds the configuration of Middleware.
+	middleware struct {
+		factory *postgres.TransactionContextFactory // Creates the transaction contexts; nil uses postgres.GetTransactionContext.
+		skip    func(*http.Request) bool            // Requests the middleware passes through untouched.
+	}
+
+	// responseWriter settles the unit of work when the handler writes the status code.
+	responseWriter struct {
+		http.ResponseWriter
+		txContext   postgres.ITransactionContext // Transaction context of the request.
+		logger      log.Logger                   // Logs failed commits.
+		status      int                          // Status code written by the handler.
+		wroteHeader bool                         // Set once the status code has been written.
+	}
+)
+
+// WithSkip excludes requests from the middleware, e.g. streaming or long-poll endpoints that must not hold a
+// transaction for their whole lifetime.
+// Example:
+//
+//	uowhttp.Middleware(factory, uowhttp.WithSkip(func(r *http.Request) bool {
+//	  return strings.HasPrefix(r.URL.Path, "/events")
+//	}))
+func WithSkip(skip func(*http.Request) bool) Option {
+	return func(m *middleware) {
+		m.skip = skip
+	}
+}
+
+// Middleware puts a transaction context into r.Context() that begins lazily on the first write through
+// Provider(); requests that only read run on the pool.
+// The transaction is committed when the handler writes a 2xx or 3xx status, before the status reaches the
+// client, and rolled back on any other status or a panic; the panic is propagated. If the commit fails,
+// the client receives 500 instead. A nil factory uses postgres.GetTransactionContext.
+// Example:
+//
+//	http.ListenAndServe(":8080", uowhttp.Middleware(factory)(mux))
+func Middleware(factory *postgres.TransactionContextFactory, opts ...Option) func(http.Handler) http.Handler {
+	m := &middleware{factory: factory}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m.wrap
+}
+
+// wrap returns next running in a unit of work.
+func (m *middleware) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.skip != nil && m.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var txContext postgres.ITransactionContext
+		ctx := r.Context()
+		if m.factory != nil {
+			txContext, ctx = m.factory.GetTransactionContext(ctx, postgres.WithAutoBegin())
+		} else {
+			txContext, ctx = postgres.GetTransactionContext(ctx, postgres.WithAutoBegin())
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				_ = txContext.Rollback()
+				panic(p)
+			}
+		}()
+
+		rw := &responseWriter{ResponseWriter: w, txContext: txContext, logger: log.FromContext(ctx), status: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+		rw.settle(rw.status) // also settles work done after the status was written
+	})
+}
+
+// WriteHeader settles the unit of work according to code and writes the resulting status.
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = w.settle(code)
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Write writes the implicit 200 status before the first body bytes.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the wrapped writer does.
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// settle commits the unit of work for 2xx and 3xx codes and rolls it back otherwise.
+// It returns the status to send, which is 500 if the commit failed.
+func (w *responseWriter) settle(code int) int {
+	if code >= http.StatusBadRequest {
+		_ = w.txContext.Rollback()
+		return code
+	}
+	if err := w.txContext.Complete(); err != nil {
+		w.logger.Errorf("cannot commit request transaction: %s", err)
+		return http.StatusInternalServerError
+	}
+	return code
+}
@@ -0,0 +1,68 @@
+package uowhttp
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/public-forge/go-gorm-unit-of-work/postgres"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// order is written by the handlers of the middleware tests.
+type order struct {
+	ID   uint
+	Code int
+}
+
+// Test that 2xx responses commit the transaction begun by a write, 5xx responses roll back and skipped requests get no transaction context
+func TestMiddleware(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	db, err := gorm.Open("postgres", sqlDB)
+	assert.NoError(t, err)
+	defer db.Close()
+	factory := postgres.NewTransactionContextFactory(postgres.NewDBHolder(db))
+
+	handler := Middleware(factory, WithSkip(func(r *http.Request) bool { return r.URL.Path == "/stream" }))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/stream" {
+				_, found := r.Context().Value(postgres.TransactionContextKey).(postgres.ITransactionContext)
+				assert.False(t, found)
+				return
+			}
+			txContext, _ := factory.GetTransactionContext(r.Context())
+			code, _ := strconv.Atoi(r.URL.Query().Get("status"))
+			if r.Method == http.MethodGet {
+				assert.NoError(t, txContext.Provider().Find(&[]order{}).Error)
+			} else {
+				assert.NoError(t, txContext.Provider().Create(&order{Code: code}).Error)
+			}
+			w.WriteHeader(code)
+		}))
+
+	serve := func(method, target string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+		return recorder.Code
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "orders"`).WithArgs(201).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/orders?status=201"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "orders"`).WithArgs(503).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectRollback()
+	assert.Equal(t, http.StatusServiceUnavailable, serve(http.MethodPost, "/orders?status=503"))
+
+	// Reads run on the pool: the request begins no transaction
+	mock.ExpectQuery(`SELECT \* FROM "orders"`).WillReturnRows(sqlmock.NewRows([]string{"id", "code"}))
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/orders?status=200"))
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/stream"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}